@@ -0,0 +1,121 @@
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+type Context struct {
+	Sun        SunPosition `json:"sun"`
+	Daylight   bool        `json:"daylight"`
+	GoldenHour bool        `json:"golden_hour"`
+	Moon       MoonPhase   `json:"moon"`
+}
+
+type SunPosition struct {
+	// Degrees above the horizon, ignoring refraction
+	Elevation float64 `json:"elevation"`
+	// Degrees clockwise from true north
+	Azimuth float64 `json:"azimuth"`
+}
+
+type MoonPhase struct {
+	// Fraction of the synodic month elapsed since new moon, in [0, 1)
+	Phase        float64 `json:"phase"`
+	Illumination float64 `json:"illumination"`
+	Name         string  `json:"name"`
+}
+
+const (
+	j2000          = 2451545.0
+	synodicMonth   = 29.530588853
+	knownNewMoonJD = 2451550.1
+)
+
+// Photos dated before this are taken to have a wrong date, such as the
+// camera clock's default, rather than be described
+var earliestPlausible = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Describe returns the sun and moon at t, or false if t isn't a plausible
+// time for a photo to have been taken: zero, before 1900 or in the future.
+// The formulae also lose accuracy far from the present.
+func Describe(t time.Time, lng float64, lat float64) (Context, bool) {
+	// A day of leeway for clocks set to timezones ahead of UTC
+	if t.IsZero() || t.Before(earliestPlausible) || t.After(time.Now().Add(24*time.Hour)) {
+		return Context{}, false
+	}
+	sun := Sun(t, lng, lat)
+	return Context{
+		Sun:        sun,
+		Daylight:   sun.Elevation > -0.833,
+		GoldenHour: sun.Elevation > -4 && sun.Elevation < 6,
+		Moon:       Moon(t),
+	}, true
+}
+
+// LocalMeanTimeToUTC interprets the wall clock reading of t as local mean
+// solar time at lng. This is a rough stand-in for a timezone lookup that is
+// good to within an hour or so outside of daylight saving.
+func LocalMeanTimeToUTC(t time.Time, lng float64) time.Time {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return wall.Add(-time.Duration(lng / 15 * float64(time.Hour)))
+}
+
+// Sun computes the sun's position using the low precision formulae from the
+// Astronomical Almanac, accurate to about a hundredth of a degree.
+func Sun(t time.Time, lng float64, lat float64) SunPosition {
+	d := julianDay(t) - j2000
+
+	g := rad(357.529 + 0.98560028*d)
+	q := 280.459 + 0.98564736*d
+	l := rad(q + 1.915*math.Sin(g) + 0.020*math.Sin(2*g))
+	e := rad(23.439 - 0.00000036*d)
+
+	ra := math.Atan2(math.Cos(e)*math.Sin(l), math.Cos(l))
+	dec := math.Asin(math.Sin(e) * math.Sin(l))
+
+	gmst := 18.697374558 + 24.06570982441908*d
+	h := rad(gmst*15+lng) - ra
+	phi := rad(lat)
+
+	elevation := math.Asin(math.Sin(phi)*math.Sin(dec) + math.Cos(phi)*math.Cos(dec)*math.Cos(h))
+	azimuth := math.Atan2(-math.Cos(dec)*math.Sin(h), math.Sin(dec)*math.Cos(phi)-math.Cos(dec)*math.Cos(h)*math.Sin(phi))
+
+	return SunPosition{
+		Elevation: deg(elevation),
+		Azimuth:   math.Mod(deg(azimuth)+360, 360),
+	}
+}
+
+func Moon(t time.Time) MoonPhase {
+	age := math.Mod(julianDay(t)-knownNewMoonJD, synodicMonth)
+	if age < 0 {
+		age += synodicMonth
+	}
+	phase := age / synodicMonth
+	return MoonPhase{
+		Phase:        phase,
+		Illumination: (1 - math.Cos(2*math.Pi*phase)) / 2,
+		Name:         phaseName(phase),
+	}
+}
+
+func phaseName(phase float64) string {
+	names := []string{
+		"New moon", "Waxing crescent", "First quarter", "Waxing gibbous",
+		"Full moon", "Waning gibbous", "Last quarter", "Waning crescent",
+	}
+	return names[int(math.Floor(phase*8+0.5))%8]
+}
+
+func julianDay(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+}
+
+func rad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func deg(r float64) float64 {
+	return r * 180 / math.Pi
+}
@@ -0,0 +1,67 @@
+package astro
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSun(t *testing.T) {
+	tests := []struct {
+		name      string
+		t         time.Time
+		lng, lat  float64
+		elevation float64
+		azimuth   float64
+	}{
+		// Reference values worked by hand from the declination and hour angle
+		{"london solstice noon", time.Date(2024, 6, 20, 12, 2, 0, 0, time.UTC), -0.1276, 51.5072, 61.9, 180},
+		{"ben nevis winter morning", time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC), -5.0037, 56.7969, 2.0, 137.0},
+	}
+	for _, test := range tests {
+		got := Sun(test.t, test.lng, test.lat)
+		if math.Abs(got.Elevation-test.elevation) > 0.5 {
+			t.Errorf("%s: expected elevation %.1f, got %.2f", test.name, test.elevation, got.Elevation)
+		}
+		if math.Abs(got.Azimuth-test.azimuth) > 1 {
+			t.Errorf("%s: expected azimuth %.1f, got %.2f", test.name, test.azimuth, got.Azimuth)
+		}
+	}
+}
+
+func TestMoon(t *testing.T) {
+	newMoon := Moon(time.Date(2024, 1, 11, 11, 57, 0, 0, time.UTC))
+	if newMoon.Illumination > 0.02 || newMoon.Name != "New moon" {
+		t.Errorf("expected new moon, got %+v", newMoon)
+	}
+
+	fullMoon := Moon(time.Date(2024, 1, 25, 17, 54, 0, 0, time.UTC))
+	if fullMoon.Illumination < 0.98 || fullMoon.Name != "Full moon" {
+		t.Errorf("expected full moon, got %+v", fullMoon)
+	}
+}
+
+func TestDescribeRejectsImplausibleTimes(t *testing.T) {
+	if _, ok := Describe(time.Date(2024, 6, 20, 12, 2, 0, 0, time.UTC), -0.1276, 51.5072); !ok {
+		t.Error("expected a recent time to be described")
+	}
+	tests := map[string]time.Time{
+		"zero":       {},
+		"1899":       time.Date(1899, 12, 31, 12, 0, 0, 0, time.UTC),
+		"next week":  time.Now().AddDate(0, 0, 7),
+		"far future": time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for name, tt := range tests {
+		if got, ok := Describe(tt, -0.1276, 51.5072); ok {
+			t.Errorf("%s: expected no description, got %+v", name, got)
+		}
+	}
+}
+
+func TestLocalMeanTimeToUTC(t *testing.T) {
+	wall := time.Date(2024, 6, 20, 12, 0, 0, 0, time.UTC)
+	got := LocalMeanTimeToUTC(wall, 15)
+	if want := time.Date(2024, 6, 20, 11, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
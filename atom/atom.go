@@ -0,0 +1,146 @@
+package atom
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type Feed struct {
+	// Must be a permanent, globally unique IRI
+	ID       string
+	Title    string
+	Subtitle string
+	// The page the feed is about
+	Link string
+	// Where the feed itself is fetched from
+	SelfLink string
+	Updated  time.Time
+	Author   string
+	Entries  []Entry
+}
+
+type Entry struct {
+	// Must be permanent and globally unique so that readers don't show entries
+	// twice
+	ID        string
+	Title     string
+	Link      string
+	Published time.Time
+	Updated   time.Time
+	Author    string
+	// Author's page, optional
+	AuthorURI string
+	// HTML, which readers sanitize before showing
+	ContentHTML string
+	// Shown by readers that support Media RSS, optional
+	Thumbnail *Thumbnail
+}
+
+type Thumbnail struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+type feed struct {
+	XMLName  xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string   `xml:"id"`
+	Title    string   `xml:"title"`
+	Subtitle string   `xml:"subtitle,omitempty"`
+	Links    []link   `xml:"link"`
+	Updated  string   `xml:"updated"`
+	Author   *person  `xml:"author"`
+	Entries  []entry  `xml:"entry"`
+}
+
+type entry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Links     []link     `xml:"link"`
+	Published string     `xml:"published,omitempty"`
+	Updated   string     `xml:"updated"`
+	Author    *person    `xml:"author"`
+	Content   *content   `xml:"content"`
+	Thumbnail *thumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type link struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type person struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type content struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type thumbnail struct {
+	URL    string `xml:"url,attr"`
+	Width  int    `xml:"width,attr,omitempty"`
+	Height int    `xml:"height,attr,omitempty"`
+}
+
+// Write encodes f as an RFC 4287 Atom feed. Entries without an author are
+// attributed to the feed's author.
+func Write(w io.Writer, f Feed) error {
+	doc := feed{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Subtitle,
+		Updated:  formatTime(f.Updated),
+	}
+	if f.Link != "" {
+		doc.Links = append(doc.Links, link{Rel: "alternate", Type: "text/html", Href: f.Link})
+	}
+	if f.SelfLink != "" {
+		doc.Links = append(doc.Links, link{Rel: "self", Type: "application/atom+xml", Href: f.SelfLink})
+	}
+	if f.Author != "" {
+		doc.Author = &person{Name: f.Author}
+	}
+	for _, e := range f.Entries {
+		out := entry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Updated: formatTime(e.Updated),
+		}
+		if !e.Published.IsZero() {
+			out.Published = formatTime(e.Published)
+		}
+		if e.Link != "" {
+			out.Links = append(out.Links, link{Rel: "alternate", Type: "text/html", Href: e.Link})
+		}
+		if e.Author != "" {
+			out.Author = &person{Name: e.Author, URI: e.AuthorURI}
+		}
+		if e.ContentHTML != "" {
+			out.Content = &content{Type: "html", Body: e.ContentHTML}
+		}
+		if e.Thumbnail != nil {
+			out.Thumbnail = &thumbnail{URL: e.Thumbnail.URL, Width: e.Thumbnail.Width, Height: e.Thumbnail.Height}
+		}
+		doc.Entries = append(doc.Entries, out)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,69 @@
+package atom
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	added := time.Date(2024, 6, 1, 9, 0, 0, 0, time.FixedZone("BST", 3600))
+	var buf bytes.Buffer
+	err := Write(&buf, Feed{
+		ID:       "tag:contourguessr.org,2024:region/3",
+		Title:    "Snowdonia",
+		Link:     "https://contourguessr.org/",
+		SelfLink: "https://api.contourguessr.org/api/v1/region/3/feed.atom",
+		Updated:  added,
+		Author:   "ContourGuessr",
+		Entries: []Entry{
+			{
+				ID:          "tag:contourguessr.org,2024:challenge/abc",
+				Title:       "Tryfan & Glyder Fach",
+				Link:        "https://contourguessr.org/challenge/abc",
+				Updated:     added,
+				Author:      "someone",
+				AuthorURI:   "https://example.com/someone",
+				ContentHTML: `<img src="https://example.com/a.jpg">`,
+				Thumbnail:   &Thumbnail{URL: "https://example.com/a.jpg", Width: 500, Height: 375},
+			},
+			{ID: "tag:contourguessr.org,2024:challenge/def", Title: "Untitled", Updated: added},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>tag:contourguessr.org,2024:region/3</id>
+  <title>Snowdonia</title>
+  <link rel="alternate" type="text/html" href="https://contourguessr.org/"></link>
+  <link rel="self" type="application/atom+xml" href="https://api.contourguessr.org/api/v1/region/3/feed.atom"></link>
+  <updated>2024-06-01T08:00:00Z</updated>
+  <author>
+    <name>ContourGuessr</name>
+  </author>
+  <entry>
+    <id>tag:contourguessr.org,2024:challenge/abc</id>
+    <title>Tryfan &amp; Glyder Fach</title>
+    <link rel="alternate" type="text/html" href="https://contourguessr.org/challenge/abc"></link>
+    <updated>2024-06-01T08:00:00Z</updated>
+    <author>
+      <name>someone</name>
+      <uri>https://example.com/someone</uri>
+    </author>
+    <content type="html">&lt;img src=&#34;https://example.com/a.jpg&#34;&gt;</content>
+    <thumbnail xmlns="http://search.yahoo.com/mrss/" url="https://example.com/a.jpg" width="500" height="375"></thumbnail>
+  </entry>
+  <entry>
+    <id>tag:contourguessr.org,2024:challenge/def</id>
+    <title>Untitled</title>
+    <updated>2024-06-01T08:00:00Z</updated>
+  </entry>
+</feed>
+`
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,145 @@
+// Package blurhash encodes images as BlurHash strings, a few dozen characters
+// that clients decode into a blurred placeholder while the image loads. See
+// https://github.com/woltapp/blurhash for the format.
+package blurhash
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// The components used for photos, enough for the rough layout of a landscape
+const (
+	DefaultXComponents = 4
+	DefaultYComponents = 3
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Encode hashes img with xComponents by yComponents cosine components, each
+// between 1 and 9. Large images are slow to encode, so pass a small rendition.
+func Encode(img image.Image, xComponents int, yComponents int) (string, error) {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", errors.New("blurhash: components must be between 1 and 9")
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return "", errors.New("blurhash: empty image")
+	}
+
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			linear[y*width+x] = [3]float64{sRGBToLinear(r >> 8), sRGBToLinear(g >> 8), sRGBToLinear(b >> 8)}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	cosX := make([]float64, width)
+	cosY := make([]float64, height)
+	for j := 0; j < yComponents; j++ {
+		for y := range cosY {
+			cosY[y] = math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+		}
+		for i := 0; i < xComponents; i++ {
+			for x := range cosX {
+				cosX[x] = math.Cos(math.Pi * float64(i) * float64(x) / float64(width))
+			}
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var f [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := cosX[x] * cosY[y]
+					p := linear[y*width+x]
+					f[0] += basis * p[0]
+					f[1] += basis * p[1]
+					f[2] += basis * p[2]
+				}
+			}
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	appendBase83(&sb, (xComponents-1)+(yComponents-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+	maximum := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantisedMax := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maximum = float64(quantisedMax+1) / 166
+		appendBase83(&sb, quantisedMax, 1)
+	} else {
+		appendBase83(&sb, 0, 1)
+	}
+
+	appendBase83(&sb, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		appendBase83(&sb, quantiseAC(f[0], maximum)*19*19+quantiseAC(f[1], maximum)*19+quantiseAC(f[2], maximum), 2)
+	}
+	return sb.String(), nil
+}
+
+// AverageColor decodes the image's average colour from hash, which it stores
+// exactly as the DC component
+func AverageColor(hash string) (color.RGBA, error) {
+	if len(hash) < 6 {
+		return color.RGBA{}, errors.New("blurhash: hash too short")
+	}
+	v := 0
+	for i := 2; i < 6; i++ {
+		digit := strings.IndexByte(base83Chars, hash[i])
+		if digit < 0 {
+			return color.RGBA{}, errors.New("blurhash: invalid character")
+		}
+		v = v*83 + digit
+	}
+	if v > 0xffffff {
+		return color.RGBA{}, errors.New("blurhash: invalid average colour")
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+func appendBase83(sb *strings.Builder, v int, length int) {
+	for i := 1; i <= length; i++ {
+		digit := v / int(math.Pow(83, float64(length-i))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+}
+
+func quantiseAC(v float64, maximum float64) int {
+	return int(max(0, min(18, math.Floor(signPow(v/maximum, 0.5)*9+9.5))))
+}
+
+func signPow(v float64, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+func sRGBToLinear(v uint32) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = max(0, min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
@@ -0,0 +1,78 @@
+package blurhash
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solid(c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeSolid(t *testing.T) {
+	got, err := Encode(solid(color.Black), DefaultXComponents, DefaultYComponents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "L00000fQfQfQfQfQfQfQfQfQfQfQ"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// The average colour survives the round trip through linear RGB
+	got, err = Encode(solid(color.White), DefaultXComponents, DefaultYComponents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc := got[2:6]; dc != "TSUA" {
+		t.Errorf("expected white to encode as TSUA, got %s in %s", dc, got)
+	}
+}
+
+func TestEncodeGradient(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 64, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x * 4)})
+		}
+	}
+	got, err := Encode(img, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 6+2*(4*3-1) {
+		t.Fatalf("expected a 28 character hash, got %q", got)
+	}
+	if got[1] == '0' {
+		t.Errorf("expected the gradient to have AC components, got %q", got)
+	}
+}
+
+func TestEncodeRejectsComponents(t *testing.T) {
+	if _, err := Encode(solid(color.Black), 0, 3); err == nil {
+		t.Error("expected zero components to be rejected")
+	}
+	if _, err := Encode(solid(color.Black), 4, 10); err == nil {
+		t.Error("expected ten components to be rejected")
+	}
+}
+
+func TestAverageColor(t *testing.T) {
+	want := color.RGBA{R: 40, G: 120, B: 200, A: 255}
+	hash, err := Encode(solid(want), DefaultXComponents, DefaultYComponents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := AverageColor(hash); err != nil || got != want {
+		t.Errorf("expected %v, got %v %v", want, got, err)
+	}
+	if _, err := AverageColor("L0"); err == nil {
+		t.Error("expected a truncated hash to be rejected")
+	}
+}
@@ -0,0 +1,192 @@
+// Command loadtest replays a mix of API requests against a running server
+// and reports throughput and latency percentiles, so that performance
+// changes can be compared with numbers.
+//
+//	go run ./cmd/loadtest -url http://localhost:8000 -c 50 -d 30s
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type scenario struct {
+	name   string
+	weight int
+	// path returns the path to request, using ids of challenges seen so far
+	path func(ids []string) string
+}
+
+var scenarios = []scenario{
+	{"regions", 1, func([]string) string { return "/api/v1/region" }},
+	{"random", 6, func([]string) string { return "/api/v1/challenge/random" }},
+	{"challenge", 3, func(ids []string) string {
+		if len(ids) == 0 {
+			return "/api/v1/challenge/random"
+		}
+		return "/api/v1/challenge/" + ids[rand.Intn(len(ids))]
+	}},
+}
+
+type result struct {
+	scenario string
+	status   int
+	latency  time.Duration
+	err      error
+}
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8000", "server to test")
+	concurrency := flag.Int("c", 10, "concurrent workers")
+	duration := flag.Duration("d", 10*time.Second, "how long to run for")
+	only := flag.String("scenario", "", "only run the named scenario (regions, random, challenge)")
+	flag.Parse()
+
+	mix := scenarios
+	if *only != "" {
+		mix = nil
+		for _, s := range scenarios {
+			if s.name == *only {
+				mix = append(mix, s)
+			}
+		}
+		if len(mix) == 0 {
+			log.Fatalf("unknown scenario %q", *only)
+		}
+	}
+	totalWeight := 0
+	for _, s := range mix {
+		totalWeight += s.weight
+	}
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: *concurrency,
+		},
+	}
+
+	var idsMu sync.Mutex
+	var ids []string
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	results := make(chan result, 1024)
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				pick := rand.Intn(totalWeight)
+				var s scenario
+				for _, s = range mix {
+					if pick < s.weight {
+						break
+					}
+					pick -= s.weight
+				}
+
+				idsMu.Lock()
+				path := s.path(ids)
+				idsMu.Unlock()
+
+				start := time.Now()
+				status, id, err := fetch(ctx, client, *baseURL+path)
+				if ctx.Err() != nil {
+					return
+				}
+				results <- result{scenario: s.name, status: status, latency: time.Since(start), err: err}
+
+				if id != "" {
+					idsMu.Lock()
+					if len(ids) < 10_000 {
+						ids = append(ids, id)
+					}
+					idsMu.Unlock()
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	byScenario := make(map[string][]result)
+	for res := range results {
+		byScenario[res.scenario] = append(byScenario[res.scenario], res)
+	}
+	report(os.Stdout, byScenario, *duration)
+}
+
+// fetch requests url and returns the challenge id in the response, if any
+func fetch(ctx context.Context, client *http.Client, url string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if strings.Contains(url, "/challenge/") && resp.StatusCode == http.StatusOK {
+		var body struct {
+			ID string `json:"id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.ID, nil
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, "", err
+}
+
+func report(w io.Writer, byScenario map[string][]result, duration time.Duration) {
+	names := make([]string, 0, len(byScenario))
+	for name := range byScenario {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "%-10s %8s %8s %8s %10s %10s %10s %10s\n",
+		"scenario", "requests", "rps", "errors", "p50", "p90", "p99", "max")
+	for _, name := range names {
+		results := byScenario[name]
+		latencies := make([]time.Duration, 0, len(results))
+		errors := 0
+		for _, res := range results {
+			if res.err != nil || res.status >= 500 {
+				errors++
+			}
+			latencies = append(latencies, res.latency)
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		fmt.Fprintf(w, "%-10s %8d %8.1f %8d %10s %10s %10s %10s\n",
+			name, len(results), float64(len(results))/duration.Seconds(), errors,
+			percentile(latencies, 0.5), percentile(latencies, 0.9), percentile(latencies, 0.99),
+			percentile(latencies, 1))
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i].Round(10 * time.Microsecond)
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is read from an optional TOML file, with environment variables
+// taking precedence so that secrets and per-deployment tweaks don't need a
+// separate file. Each setting's file key and variable are in its tags.
+type Config struct {
+	Host        string `toml:"host" env:"HOST"`
+	Port        string `toml:"port" env:"PORT"`
+	PublicURL   string `toml:"public_url" env:"PUBLIC_URL"`
+	SiteURL     string `toml:"site_url" env:"SITE_URL"`
+	TokenSecret string `toml:"token_secret" env:"TOKEN_SECRET"`
+	AdminToken  string `toml:"admin_token" env:"ADMIN_TOKEN"`
+	// With a snapshot to fall back on we can start while the database is down
+	SnapshotPath string `toml:"snapshot_path" env:"SNAPSHOT_PATH"`
+	// Serves the regions and challenges in a JSON file from memory instead of
+	// using the database, for local development. Nothing is persisted.
+	FixturesPath string `toml:"fixtures_path" env:"FIXTURES_PATH"`
+	// Fetches every map layer's capabilities from a bundled stub server instead
+	// of its provider, for development and tests
+	CapabilitiesStub     bool     `toml:"capabilities_stub" env:"CAPABILITIES_STUB"`
+	RedisURL             string   `toml:"redis_url" env:"REDIS_URL"`
+	WeatherAPIURL        string   `toml:"weather_api_url" env:"WEATHER_API_URL"`
+	GeocodeAPIURL        string   `toml:"geocode_api_url" env:"GEOCODE_API_URL"`
+	ElevationAPIURL      string   `toml:"elevation_api_url" env:"ELEVATION_API_URL"`
+	OutboundAllowedHosts []string `toml:"outbound_allowed_hosts" env:"OUTBOUND_ALLOWED_HOSTS"`
+	// Serves the gRPC API over plaintext HTTP/2 if set, for use inside the
+	// cluster, along with its grpc-gateway JSON mapping under /v1/
+	GRPCPort string `toml:"grpc_port" env:"GRPC_PORT"`
+
+	RequestTimeout         time.Duration `toml:"request_timeout" env:"REQUEST_TIMEOUT"`
+	ShutdownTimeout        time.Duration `toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	ImageHostCheckInterval time.Duration `toml:"image_host_check_interval" env:"IMAGE_HOST_CHECK_INTERVAL"`
+	// How often a batch of challenges has its terrain computed from the DEM,
+	// or zero to not compute it
+	TerrainBackfillInterval time.Duration `toml:"terrain_backfill_interval" env:"TERRAIN_BACKFILL_INTERVAL"`
+	// How often a batch of challenge photos is fetched to compute their
+	// placeholders, or zero to not compute them
+	BlurHashBackfillInterval time.Duration `toml:"blurhash_backfill_interval" env:"BLURHASH_BACKFILL_INTERVAL"`
+	// How often a batch of dated challenges has its timezone looked up from
+	// the weather API, or zero to not look them up
+	TimezoneBackfillInterval time.Duration `toml:"timezone_backfill_interval" env:"TIMEZONE_BACKFILL_INTERVAL"`
+	// Challenges removed by moderation stay in the primary tables for a while
+	// in case the decision is revisited
+	ArchiveRemovedAfter time.Duration `toml:"archive_removed_after" env:"ARCHIVE_REMOVED_AFTER"`
+
+	// TLS is terminated by a proxy unless CertFile is set
+	TLS struct {
+		CertFile string `toml:"cert_file" env:"TLS_CERT_FILE"`
+		KeyFile  string `toml:"key_file" env:"TLS_KEY_FILE"`
+		// Port for a plain HTTP listener redirecting to HTTPS, if any
+		HTTPPort string `toml:"http_port" env:"TLS_HTTP_PORT"`
+		// Directory ACME http-01 challenges are served from on HTTPPort, for
+		// use with certbot --webroot
+		ACMEWebroot string `toml:"acme_webroot" env:"TLS_ACME_WEBROOT"`
+	} `toml:"tls"`
+
+	// Requests from trusted proxies, like the load balancer, are attributed to
+	// the client they name rather than the proxy
+	Proxy struct {
+		// CIDRs or single addresses
+		Trusted []string `toml:"trusted" env:"TRUSTED_PROXIES"`
+		// X-Forwarded-For or X-Real-IP
+		Header string `toml:"header" env:"CLIENT_IP_HEADER"`
+		// Expect a PROXY protocol header on connections from trusted proxies
+		Protocol bool `toml:"protocol" env:"PROXY_PROTOCOL"`
+	} `toml:"proxy"`
+
+	// Errors are reported to Sentry, or anything else accepting its envelope
+	// API, when DSN is set
+	Sentry struct {
+		DSN         string `toml:"dsn" env:"SENTRY_DSN"`
+		Environment string `toml:"environment" env:"SENTRY_ENVIRONMENT"`
+	} `toml:"sentry"`
+
+	Log struct {
+		Level  string `toml:"level" env:"LOG_LEVEL"`
+		Format string `toml:"format" env:"LOG_FORMAT"`
+		Access bool   `toml:"access" env:"ACCESS_LOG"`
+	} `toml:"log"`
+
+	Database struct {
+		URL               string        `toml:"url" env:"DATABASE_URL"`
+		MaxConns          int           `toml:"max_conns" env:"DB_MAX_CONNS"`
+		MinConns          int           `toml:"min_conns" env:"DB_MIN_CONNS"`
+		HealthCheckPeriod time.Duration `toml:"health_check_period" env:"DB_HEALTH_CHECK_PERIOD"`
+		MaxConnLifetime   time.Duration `toml:"max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME"`
+		StatementTimeout  time.Duration `toml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"`
+	} `toml:"database"`
+
+	Refresh struct {
+		Regions    time.Duration `toml:"regions" env:"REFRESH_REGIONS_INTERVAL"`
+		Challenges time.Duration `toml:"challenges" env:"REFRESH_CHALLENGES_INTERVAL"`
+	} `toml:"refresh"`
+
+	Ready struct {
+		MaxRegionsAge    time.Duration `toml:"max_regions_age" env:"READY_MAX_REGIONS_AGE"`
+		MaxChallengesAge time.Duration `toml:"max_challenges_age" env:"READY_MAX_CHALLENGES_AGE"`
+	} `toml:"ready"`
+
+	CORS struct {
+		AllowedOrigins []string      `toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+		AllowedHeaders []string      `toml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+		MaxAge         time.Duration `toml:"max_age" env:"CORS_MAX_AGE"`
+	} `toml:"cors"`
+
+	RateLimit struct {
+		RPS      float64 `toml:"rps" env:"RATE_LIMIT_RPS"`
+		Burst    float64 `toml:"burst" env:"RATE_LIMIT_BURST"`
+		KeyRPS   float64 `toml:"key_rps" env:"RATE_LIMIT_KEY_RPS"`
+		KeyBurst float64 `toml:"key_burst" env:"RATE_LIMIT_KEY_BURST"`
+		// Guesses each IP and player can make, as they reveal the answer
+		RevealsPerMinute float64 `toml:"reveals_per_minute" env:"RATE_LIMIT_REVEALS_PER_MINUTE"`
+		RevealBurst      float64 `toml:"reveal_burst" env:"RATE_LIMIT_REVEAL_BURST"`
+	} `toml:"rate_limit"`
+
+	QA struct {
+		// Bearer tokens of staff, who along with the admin can switch the
+		// features listed here per request with the X-CG-Features header
+		StaffTokens []string `toml:"staff_tokens" env:"STAFF_TOKENS"`
+		Features    []string `toml:"features" env:"QA_FEATURES"`
+	} `toml:"qa"`
+
+	// v1 endpoints with a v2 replacement are marked deprecated from V1Since,
+	// with removal planned for V1Sunset. Dates are like "2025-01-31".
+	Deprecation struct {
+		V1Since  string `toml:"v1_since" env:"API_V1_DEPRECATED_SINCE"`
+		V1Sunset string `toml:"v1_sunset" env:"API_V1_SUNSET"`
+		// Migration guide linked from deprecated responses
+		Link string `toml:"link" env:"API_DEPRECATION_LINK"`
+	} `toml:"deprecation"`
+
+	// Challenge IDs are sequential unless Key is set, when they become a keyed
+	// permutation that can't be enumerated. Old IDs keep working until
+	// LegacyUntil, a date like "2025-01-31", so that shared links survive.
+	ChallengeIDs struct {
+		Key         string `toml:"key" env:"CHALLENGE_ID_KEY"`
+		LegacyUntil string `toml:"legacy_until" env:"CHALLENGE_ID_LEGACY_UNTIL"`
+	} `toml:"challenge_ids"`
+
+	// FEATURES overrides individual flags as a list like "a,-b", which turns
+	// a on and b off
+	Features map[string]bool `toml:"features" env:"FEATURES"`
+}
+
+func Default() Config {
+	var c Config
+	c.Host = "0.0.0.0"
+	c.Port = "8080"
+	c.PublicURL = "https://api.contourguessr.org"
+	c.SiteURL = "https://contourguessr.org"
+	c.OutboundAllowedHosts = []string{"flickr.com"}
+	c.RequestTimeout = 15 * time.Second
+	c.ShutdownTimeout = 25 * time.Second
+	c.ImageHostCheckInterval = 1 * time.Minute
+	c.TerrainBackfillInterval = 1 * time.Minute
+	c.BlurHashBackfillInterval = 1 * time.Minute
+	c.TimezoneBackfillInterval = 1 * time.Minute
+	c.ArchiveRemovedAfter = 90 * 24 * time.Hour
+	c.Proxy.Header = "X-Forwarded-For"
+	c.Sentry.Environment = "production"
+	c.Log.Level = "info"
+	c.Log.Format = "json"
+	c.Log.Access = true
+	c.Refresh.Regions = 24 * time.Hour
+	c.Refresh.Challenges = 15 * time.Minute
+	// Allows for a couple of failed refreshes
+	c.Ready.MaxRegionsAge = 48 * time.Hour
+	c.Ready.MaxChallengesAge = 1 * time.Hour
+	c.CORS.AllowedOrigins = []string{"*"}
+	c.CORS.AllowedHeaders = []string{"Content-Type", "Authorization", "If-None-Match", "X-CG-Features"}
+	c.CORS.MaxAge = 24 * time.Hour
+	c.RateLimit.RPS = 10
+	c.RateLimit.Burst = 40
+	c.RateLimit.KeyRPS = 50
+	c.RateLimit.KeyBurst = 200
+	c.RateLimit.RevealsPerMinute = 12
+	c.RateLimit.RevealBurst = 30
+	c.Features = map[string]bool{
+		"world_tour": true,
+	}
+	return c
+}
+
+// Load reads the file at path, if path isn't empty, over the defaults and then
+// applies overrides from getenv
+func Load(path string, getenv func(string) string) (Config, error) {
+	c := Default()
+
+	if path != "" {
+		md, err := toml.DecodeFile(path, &c)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, key := range md.Undecoded() {
+			return Config{}, fmt.Errorf("%s: unknown key %s", path, key)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(&c).Elem(), getenv); err != nil {
+		return Config{}, err
+	}
+
+	if c.Database.URL == "" && c.FixturesPath == "" {
+		return Config{}, fmt.Errorf("DATABASE_URL not set")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return Config{}, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	for name, date := range map[string]string{
+		"API_V1_DEPRECATED_SINCE":   c.Deprecation.V1Since,
+		"API_V1_SUNSET":             c.Deprecation.V1Sunset,
+		"CHALLENGE_ID_LEGACY_UNTIL": c.ChallengeIDs.LegacyUntil,
+	} {
+		if _, err := time.Parse(time.DateOnly, date); date != "" && err != nil {
+			return Config{}, fmt.Errorf("invalid %s: expected a date like 2025-01-31", name)
+		}
+	}
+	return c, nil
+}
+
+// Feature reports whether the flag is on. Unknown flags are off.
+func (c Config) Feature(name string) bool {
+	return c.Features[name]
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func applyEnv(v reflect.Value, getenv func(string) string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			if err := applyEnv(fv, getenv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		s := getenv(name)
+		if name == "" || s == "" {
+			continue
+		}
+		if err := setEnv(fv, s); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setEnv(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice:
+		v.Set(reflect.ValueOf(splitList(s)))
+	case v.Kind() == reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for _, name := range splitList(s) {
+			name, off := strings.CutPrefix(name, "-")
+			v.SetMapIndex(reflect.ValueOf(name), reflect.ValueOf(!off))
+		}
+	default:
+		panic("unsupported config field type " + v.Type().String())
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoad(t *testing.T) {
+	path := writeFile(t, `
+port = "9000" # trailing comment
+request_timeout = "5s"
+
+[database]
+url = "postgres://file"
+max_conns = 8
+
+[cors]
+allowed_origins = [
+  "https://contourguessr.org",
+  "https://beta.contourguessr.org",
+]
+
+[rate_limit]
+rps = 2.5
+burst = 10
+
+[features]
+world_tour = false
+new_scoring = true
+`)
+
+	c, err := Load(path, env(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"ACCESS_LOG":   "false",
+		"FEATURES":     "world_tour,-new_scoring",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Port != "9000" || c.RequestTimeout != 5*time.Second || c.Database.MaxConns != 8 {
+		t.Errorf("file values not applied: %+v", c)
+	}
+	if c.Database.URL != "postgres://env" {
+		t.Errorf("expected env to override file, got %s", c.Database.URL)
+	}
+	if c.Log.Access {
+		t.Error("expected ACCESS_LOG=false to disable access logs")
+	}
+	if want := []string{"https://contourguessr.org", "https://beta.contourguessr.org"}; !reflect.DeepEqual(c.CORS.AllowedOrigins, want) {
+		t.Errorf("unexpected origins %v", c.CORS.AllowedOrigins)
+	}
+	if c.RateLimit.RPS != 2.5 || c.RateLimit.Burst != 10 {
+		t.Errorf("unexpected rate limit %+v", c.RateLimit)
+	}
+	if !c.Feature("world_tour") || c.Feature("new_scoring") || c.Feature("unknown") {
+		t.Errorf("unexpected features %v", c.Features)
+	}
+	if c.Host != "0.0.0.0" || c.Refresh.Challenges != 15*time.Minute {
+		t.Error("expected defaults for unset values")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":        "prot = \"9000\"",
+		"wrong type":         "[database]\nmax_conns = \"eight\"",
+		"bad duration":       "request_timeout = \"soon\"",
+		"bad syntax":         "port",
+		"bad date":           "[deprecation]\nv1_sunset = \"next summer\"",
+		"bad legacy ID date": "[challenge_ids]\nlegacy_until = \"soon\"",
+	}
+	for name, contents := range tests {
+		_, err := Load(writeFile(t, contents), env(map[string]string{"DATABASE_URL": "postgres://"}))
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := Load("", env(nil)); err == nil || !strings.Contains(err.Error(), "DATABASE_URL") {
+		t.Errorf("expected missing database URL to fail, got %v", err)
+	}
+	if _, err := Load("", env(map[string]string{"FIXTURES_PATH": "fixtures.json"})); err != nil {
+		t.Errorf("expected fixtures to stand in for the database, got %v", err)
+	}
+	if _, err := Load("", env(map[string]string{"DATABASE_URL": "postgres://", "DB_MAX_CONNS": "many"})); err == nil {
+		t.Error("expected invalid env value to fail")
+	}
+}
+
+func TestExampleParses(t *testing.T) {
+	c, err := Load("../config.example.toml", env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The example documents the defaults
+	c.Database.URL = ""
+	if !reflect.DeepEqual(c, Default()) {
+		t.Errorf("expected example to match defaults, got %+v", c)
+	}
+}
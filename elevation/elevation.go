@@ -0,0 +1,77 @@
+package elevation
+
+import (
+	"context"
+	"contourguessr-api/geo"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL looks up the Copernicus GLO-90 DEM
+const DefaultBaseURL = "https://api.open-meteo.com/v1/elevation"
+
+// MaxPoints is how many points can be looked up in one request
+const MaxPoints = 100
+
+type Client struct {
+	baseURL string
+	c       *http.Client
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: baseURL,
+		c:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Lookup returns the elevation in meters of each point, in order. Points over
+// the sea are at 0.
+func (c *Client) Lookup(ctx context.Context, points []geo.Point) ([]float64, error) {
+	if len(points) == 0 {
+		return nil, nil
+	} else if len(points) > MaxPoints {
+		return nil, fmt.Errorf("too many points: %d > %d", len(points), MaxPoints)
+	}
+
+	lats := make([]string, len(points))
+	lngs := make([]string, len(points))
+	for i, p := range points {
+		lats[i] = strconv.FormatFloat(p.Lat, 'f', 5, 64)
+		lngs[i] = strconv.FormatFloat(p.Lng, 'f', 5, 64)
+	}
+	q := url.Values{}
+	q.Set("latitude", strings.Join(lats, ","))
+	q.Set("longitude", strings.Join(lngs, ","))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "github.com/dzfranklin/contourguessr")
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Elevation []float64 `json:"elevation"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if len(body.Elevation) != len(points) {
+		return nil, fmt.Errorf("expected %d elevations, got %d", len(points), len(body.Elevation))
+	}
+	return body.Elevation, nil
+}
@@ -0,0 +1,47 @@
+package elevation
+
+import (
+	"context"
+	"contourguessr-api/geo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("latitude"); got != "53.06850,54.50000" {
+			t.Errorf("expected both latitudes, got %s", got)
+		}
+		if got := r.URL.Query().Get("longitude"); got != "-4.07620,-3.50000" {
+			t.Errorf("expected both longitudes, got %s", got)
+		}
+		_, _ = w.Write([]byte(`{"elevation": [1085.0, 412.5]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	got, err := c.Lookup(context.Background(), []geo.Point{{Lng: -4.0762, Lat: 53.0685}, {Lng: -3.5, Lat: 54.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != 1085 || got[1] != 412.5 {
+		t.Errorf("unexpected elevations %v", got)
+	}
+
+	if _, err := c.Lookup(context.Background(), make([]geo.Point, MaxPoints+1)); err == nil {
+		t.Error("expected too many points to be refused")
+	}
+}
+
+func TestLookupMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"elevation": [1085.0]}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL).Lookup(context.Background(), []geo.Point{{}, {}})
+	if err == nil {
+		t.Error("expected an error when the counts don't match")
+	}
+}
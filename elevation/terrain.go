@@ -0,0 +1,109 @@
+package elevation
+
+import (
+	"context"
+	"contourguessr-api/geo"
+	"fmt"
+	"math"
+)
+
+// TerrainSpacing is the distance in meters between the samples terrain is
+// computed from, about the DEM's resolution
+const TerrainSpacing = 90.0
+
+// MaxTerrainPoints is how many points Terrain can describe in one request
+const MaxTerrainPoints = MaxPoints / 9
+
+const (
+	LandformFlat   = "flat"
+	LandformSlope  = "slope"
+	LandformRidge  = "ridge"
+	LandformValley = "valley"
+)
+
+// How far the point must stand above or below its surroundings to be on a
+// ridge or in a valley, and how gentle flat ground is
+const (
+	landformTPIThreshold = 10.0
+	flatSlopeDeg         = 5.0
+)
+
+type Terrain struct {
+	SlopeDeg float64 `json:"slope_deg"`
+	// The direction the slope faces in degrees clockwise from north, or nil on
+	// level ground
+	AspectDeg *float64 `json:"aspect_deg"`
+	// The mean difference in height from the point to its surroundings
+	RuggednessM float64 `json:"ruggedness_m"`
+	Landform    string  `json:"landform"`
+}
+
+// Terrain describes the ground around each point from a 3x3 grid of heights
+// centered on it
+func (c *Client) Terrain(ctx context.Context, points []geo.Point) ([]Terrain, error) {
+	if len(points) > MaxTerrainPoints {
+		return nil, fmt.Errorf("too many points: %d > %d", len(points), MaxTerrainPoints)
+	}
+
+	samples := make([]geo.Point, 0, 9*len(points))
+	for _, p := range points {
+		dLat := TerrainSpacing / geo.EarthRadius * 180 / math.Pi
+		dLng := dLat / math.Cos(p.Lat*math.Pi/180)
+		for row := 1; row >= -1; row-- {
+			for col := -1; col <= 1; col++ {
+				samples = append(samples, geo.Point{Lng: p.Lng + float64(col)*dLng, Lat: p.Lat + float64(row)*dLat})
+			}
+		}
+	}
+	heights, err := c.Lookup(ctx, samples)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Terrain, len(points))
+	for i := range points {
+		out[i] = terrainFromGrid([9]float64(heights[9*i:9*i+9]), TerrainSpacing)
+	}
+	return out, nil
+}
+
+// terrainFromGrid describes the center of z, which runs west to east along
+// rows from north to south with spacing meters between heights. Slope and
+// aspect are by Horn's method.
+func terrainFromGrid(z [9]float64, spacing float64) Terrain {
+	// Rising to the east and to the north
+	dzdx := ((z[2] + 2*z[5] + z[8]) - (z[0] + 2*z[3] + z[6])) / (8 * spacing)
+	dzdy := ((z[0] + 2*z[1] + z[2]) - (z[6] + 2*z[7] + z[8])) / (8 * spacing)
+
+	var t Terrain
+	t.SlopeDeg = math.Atan(math.Hypot(dzdx, dzdy)) * 180 / math.Pi
+	if dzdx != 0 || dzdy != 0 {
+		aspect := math.Mod(math.Atan2(-dzdx, -dzdy)*180/math.Pi+360, 360)
+		t.AspectDeg = &aspect
+	}
+
+	var sumAbs, sum float64
+	for i, h := range z {
+		if i == 4 {
+			continue
+		}
+		sumAbs += math.Abs(h - z[4])
+		sum += h
+	}
+	t.RuggednessM = sumAbs / 8
+	// The topographic position index, how far the point stands above its
+	// surroundings
+	tpi := z[4] - sum/8
+
+	switch {
+	case tpi > landformTPIThreshold:
+		t.Landform = LandformRidge
+	case tpi < -landformTPIThreshold:
+		t.Landform = LandformValley
+	case t.SlopeDeg < flatSlopeDeg:
+		t.Landform = LandformFlat
+	default:
+		t.Landform = LandformSlope
+	}
+	return t
+}
@@ -0,0 +1,66 @@
+package elevation
+
+import (
+	"context"
+	"contourguessr-api/geo"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTerrainFromGrid(t *testing.T) {
+	tests := []struct {
+		name     string
+		z        [9]float64
+		slope    float64
+		aspect   float64
+		landform string
+	}{
+		// Rising 90m to the east over each 90m, so 45 degrees facing west
+		{"west face", [9]float64{0, 90, 180, 0, 90, 180, 0, 90, 180}, 45, 270, LandformSlope},
+		{"south face", [9]float64{20, 20, 20, 10, 10, 10, 0, 0, 0}, 6.3, 180, LandformSlope},
+		{"ridge", [9]float64{0, 50, 0, 0, 50, 0, 0, 50, 0}, 0, -1, LandformRidge},
+		{"valley", [9]float64{50, 0, 50, 50, 0, 50, 50, 0, 50}, 0, -1, LandformValley},
+		{"flat", [9]float64{100, 101, 100, 100, 100, 100, 99, 100, 100}, 0.25, 0, LandformFlat},
+	}
+	for _, tt := range tests {
+		got := terrainFromGrid(tt.z, 90)
+		if math.Abs(got.SlopeDeg-tt.slope) > 0.1 {
+			t.Errorf("%s: expected slope %v, got %v", tt.name, tt.slope, got.SlopeDeg)
+		}
+		if tt.aspect < 0 && got.AspectDeg != nil {
+			t.Errorf("%s: expected no aspect, got %v", tt.name, *got.AspectDeg)
+		} else if tt.aspect > 0 && (got.AspectDeg == nil || math.Abs(*got.AspectDeg-tt.aspect) > 0.1) {
+			t.Errorf("%s: expected aspect %v, got %v", tt.name, tt.aspect, got.AspectDeg)
+		}
+		if got.Landform != tt.landform {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.landform, got.Landform)
+		}
+	}
+
+	if got := terrainFromGrid([9]float64{0, 0, 0, 0, 80, 0, 0, 0, 0}, 90); got.RuggednessM != 80 {
+		t.Errorf("expected ruggedness 80, got %v", got.RuggednessM)
+	}
+}
+
+func TestTerrain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lats := strings.Split(r.URL.Query().Get("latitude"), ",")
+		if len(lats) != 18 {
+			t.Errorf("expected a grid for each point, got %d samples", len(lats))
+		}
+		// Level ground then a summit
+		_, _ = w.Write([]byte(`{"elevation": [5,5,5,5,5,5,5,5,5, 900,900,900,900,1085,900,900,900,900]}`))
+	}))
+	defer srv.Close()
+
+	got, err := NewClient(srv.URL).Terrain(context.Background(), []geo.Point{{Lng: 0, Lat: 51.5}, {Lng: -4.0762, Lat: 53.0685}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Landform != LandformFlat || got[1].Landform != LandformRidge {
+		t.Errorf("unexpected terrain %+v", got)
+	}
+}
@@ -0,0 +1,56 @@
+// Package geo measures between points on a sphere with the earth's mean
+// radius. Scores are computed with it and clients are given the same numbers
+// to display, so the two never disagree.
+package geo
+
+import "math"
+
+// EarthRadius is the mean radius in meters
+const EarthRadius = 6371008.8
+
+type Point struct {
+	Lng float64 `json:"lng"`
+	Lat float64 `json:"lat"`
+}
+
+// Distance is the great-circle distance in meters, by the haversine formula
+func Distance(a Point, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLng := radians(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadius * math.Atan2(math.Sqrt(h), math.Sqrt(1-h))
+}
+
+// InitialBearing is the direction to set off from a to follow the great
+// circle to b, in degrees clockwise from true north in [0, 360). It is zero if
+// the points coincide.
+func InitialBearing(a Point, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLng := radians(b.Lng - a.Lng)
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+	if x == 0 && y == 0 {
+		return 0
+	}
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// Destination is where following the great circle from p for distance meters,
+// setting off at bearing degrees clockwise from true north, arrives
+func Destination(p Point, bearing float64, distance float64) Point {
+	lat1, lng1 := radians(p.Lat), radians(p.Lng)
+	theta := radians(bearing)
+	delta := distance / EarthRadius
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(delta) + math.Cos(lat1)*math.Sin(delta)*math.Cos(theta))
+	lng2 := lng1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(lat1), math.Cos(delta)-math.Sin(lat1)*math.Sin(lat2))
+	return Point{
+		Lng: math.Mod(lng2*180/math.Pi+540, 360) - 180,
+		Lat: lat2 * 180 / math.Pi,
+	}
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
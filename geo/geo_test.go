@@ -0,0 +1,58 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	scafellPike = Point{Lng: -3.2117, Lat: 54.4541}
+	benNevis    = Point{Lng: -5.0036, Lat: 56.7969}
+)
+
+func TestDistance(t *testing.T) {
+	got := Distance(scafellPike, benNevis)
+	if math.Abs(got-282_000) > 2_000 {
+		t.Errorf("unexpected distance %f", got)
+	}
+	if got := Distance(benNevis, scafellPike); math.Abs(got-Distance(scafellPike, benNevis)) > 1e-6 {
+		t.Errorf("expected distance to be symmetric, got %f", got)
+	}
+	if got := Distance(benNevis, benNevis); got != 0 {
+		t.Errorf("expected 0 between the same point, got %f", got)
+	}
+	// Half the circumference
+	if got := Distance(Point{Lng: 0, Lat: 0}, Point{Lng: 180, Lat: 0}); math.Abs(got-math.Pi*EarthRadius) > 1e-6 {
+		t.Errorf("unexpected antipodal distance %f", got)
+	}
+}
+
+func TestInitialBearing(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Point
+		want float64
+	}{
+		{"north", Point{0, 0}, Point{0, 10}, 0},
+		{"east", Point{0, 0}, Point{10, 0}, 90},
+		{"south", Point{0, 10}, Point{0, 0}, 180},
+		{"west", Point{10, 0}, Point{0, 0}, 270},
+		{"same point", benNevis, benNevis, 0},
+		{"Scafell Pike to Ben Nevis", scafellPike, benNevis, 337.4},
+	}
+	for _, tt := range tests {
+		if got := InitialBearing(tt.a, tt.b); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("%s: expected %.1f, got %f", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	got := Destination(scafellPike, InitialBearing(scafellPike, benNevis), Distance(scafellPike, benNevis))
+	if Distance(got, benNevis) > 1 {
+		t.Errorf("expected to arrive at Ben Nevis, got %+v", got)
+	}
+	if got := Destination(Point{Lng: 179.5, Lat: 0}, 90, 111_195); math.Abs(got.Lng+179.5) > 0.01 {
+		t.Errorf("expected to wrap around the antimeridian, got %+v", got)
+	}
+}
@@ -0,0 +1,160 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DefaultBaseURL = "https://nominatim.openstreetmap.org"
+
+// The public Nominatim usage policy allows at most one request per second
+const MinInterval = 1 * time.Second
+
+type Client struct {
+	baseURL string
+	c       *http.Client
+
+	limiter Limiter
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// Limiter spaces out requests across replicas. Without one requests are only
+// spaced out within this process.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+type Place struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Locality    string `json:"locality"`
+	County      string `json:"county"`
+	State       string `json:"state"`
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code"`
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: baseURL,
+		c:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Reverse returns the place at the coordinates, or nil if there isn't one
+// (for example in the sea)
+func (c *Client) Reverse(ctx context.Context, lng float64, lat float64) (*Place, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+	q.Set("format", "jsonv2")
+	q.Set("lat", strconv.FormatFloat(lat, 'f', 5, 64))
+	q.Set("lon", strconv.FormatFloat(lng, 'f', 5, 64))
+	q.Set("zoom", "14")
+	q.Set("accept-language", "en")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/reverse?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "github.com/dzfranklin/contourguessr")
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Error       string `json:"error"`
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+		Address     struct {
+			Village     string `json:"village"`
+			Town        string `json:"town"`
+			City        string `json:"city"`
+			Hamlet      string `json:"hamlet"`
+			County      string `json:"county"`
+			State       string `json:"state"`
+			Country     string `json:"country"`
+			CountryCode string `json:"country_code"`
+		} `json:"address"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Error != "" {
+		return nil, nil
+	}
+
+	a := body.Address
+	return &Place{
+		Name:        body.Name,
+		DisplayName: body.DisplayName,
+		Locality:    firstNonEmpty(a.Village, a.Town, a.City, a.Hamlet),
+		County:      a.County,
+		State:       a.State,
+		Country:     a.Country,
+		CountryCode: a.CountryCode,
+	}, nil
+}
+
+// SetLimiter shares the rate limit with other replicas. The limiter should
+// allow one request per second.
+func (c *Client) SetLimiter(l Limiter) {
+	c.limiter = l
+}
+
+// wait spaces out requests to respect the usage policy. A caller that gives
+// up before its turn hands the slot back if nobody has queued behind it.
+func (c *Client) wait(ctx context.Context) error {
+	if c.limiter != nil {
+		return c.limiter.Wait(ctx)
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	start := c.next
+	if start.Before(now) {
+		start = now
+	}
+	reserved := start.Add(MinInterval)
+	c.next = reserved
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		if c.next.Equal(reserved) {
+			c.next = start
+		}
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package geocode
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReverse(t *testing.T) {
+	var requests []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		if r.URL.Query().Get("lat") == "0.00000" {
+			_, _ = w.Write([]byte(`{"error": "Unable to geocode"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{
+			"name": "Ben Nevis",
+			"display_name": "Ben Nevis, Highland, Scotland, United Kingdom",
+			"address": {"town": "Fort William", "county": "Highland", "state": "Scotland", "country": "United Kingdom", "country_code": "gb"}
+		}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+
+	got, err := c.Reverse(context.Background(), -5.0037, 56.7969)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Name != "Ben Nevis" || got.Locality != "Fort William" || got.CountryCode != "gb" {
+		t.Errorf("unexpected place %+v", got)
+	}
+
+	got, err = c.Reverse(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("expected nil place, got %+v", got)
+	}
+
+	if len(requests) != 2 || requests[1].Sub(requests[0]) < 900*time.Millisecond {
+		t.Error("expected requests to be spaced out")
+	}
+}
+
+func TestWaitReleasesCancelledReservation(t *testing.T) {
+	c := NewClient("")
+	if err := c.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.wait(ctx); err == nil {
+		t.Fatal("expected the cancelled wait to fail")
+	}
+
+	start := time.Now()
+	if err := c.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited > MinInterval+100*time.Millisecond {
+		t.Errorf("expected the cancelled slot to be handed back, waited %v", waited)
+	}
+}
@@ -0,0 +1,97 @@
+package gpx
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type GPX struct {
+	// Names the program that wrote the file, like ContourGuessr
+	Creator   string
+	Name      string
+	Time      time.Time
+	Waypoints []Waypoint
+}
+
+type Waypoint struct {
+	Lng  float64
+	Lat  float64
+	Name string
+	// Plain text, which most GPS apps show under the name
+	Description string
+	// Zero if unknown
+	Time time.Time
+	Link string
+	// A free-form classification, like "photo" or "guess"
+	Type string
+}
+
+type document struct {
+	XMLName   xml.Name   `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Metadata  metadata   `xml:"metadata"`
+	Waypoints []waypoint `xml:"wpt"`
+}
+
+type metadata struct {
+	Name string `xml:"name,omitempty"`
+	Time string `xml:"time,omitempty"`
+}
+
+// Elements must be in the order of the schema's sequence
+type waypoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time,omitempty"`
+	Name string  `xml:"name,omitempty"`
+	Desc string  `xml:"desc,omitempty"`
+	Link *link   `xml:"link"`
+	Type string  `xml:"type,omitempty"`
+}
+
+type link struct {
+	Href string `xml:"href,attr"`
+}
+
+// Write encodes g as GPX 1.1
+func Write(w io.Writer, g GPX) error {
+	doc := document{
+		Version:  "1.1",
+		Creator:  g.Creator,
+		Metadata: metadata{Name: g.Name, Time: formatTime(g.Time)},
+	}
+	for _, wp := range g.Waypoints {
+		out := waypoint{
+			Lat:  wp.Lat,
+			Lon:  wp.Lng,
+			Time: formatTime(wp.Time),
+			Name: wp.Name,
+			Desc: wp.Description,
+			Type: wp.Type,
+		}
+		if wp.Link != "" {
+			out.Link = &link{Href: wp.Link}
+		}
+		doc.Waypoints = append(doc.Waypoints, out)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,53 @@
+package gpx
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, GPX{
+		Creator: "ContourGuessr",
+		Name:    "Game",
+		Time:    time.Date(2024, 6, 1, 9, 0, 0, 0, time.FixedZone("BST", 3600)),
+		Waypoints: []Waypoint{
+			{
+				Lng:         -3.5,
+				Lat:         53.25,
+				Name:        "Round 1: Tryfan & Glyder Fach",
+				Description: "Photo by someone",
+				Link:        "https://example.com/photo?a=1&b=2",
+				Type:        "photo",
+			},
+			{Lng: -3.4, Lat: 53.2, Name: "Round 1 guess", Time: time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC), Type: "guess"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" version="1.1" creator="ContourGuessr">
+  <metadata>
+    <name>Game</name>
+    <time>2024-06-01T08:00:00Z</time>
+  </metadata>
+  <wpt lat="53.25" lon="-3.5">
+    <name>Round 1: Tryfan &amp; Glyder Fach</name>
+    <desc>Photo by someone</desc>
+    <link href="https://example.com/photo?a=1&amp;b=2"></link>
+    <type>photo</type>
+  </wpt>
+  <wpt lat="53.2" lon="-3.4">
+    <time>2024-06-01T08:00:00Z</time>
+    <name>Round 1 guess</name>
+    <type>guess</type>
+  </wpt>
+</gpx>
+`
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,95 @@
+package ical
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+type Calendar struct {
+	// Identifies the product that wrote the calendar, like
+	// -//ContourGuessr//Events//EN
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+type Event struct {
+	// Must be globally unique and stable across regenerations so that
+	// subscribers update events in place
+	UID         string
+	Summary     string
+	Description string
+	URL         string
+	Start       time.Time
+	End         time.Time
+	// An RRULE value such as FREQ=DAILY, empty for one-off events
+	RRule    string
+	Modified time.Time
+}
+
+const timeFormat = "20060102T150405Z"
+
+// Write encodes cal as RFC 5545 iCalendar
+func Write(w io.Writer, cal Calendar) error {
+	var b strings.Builder
+	line := func(name string, value string) {
+		writeFolded(&b, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", cal.ProdID)
+	line("CALSCALE", "GREGORIAN")
+	if cal.Name != "" {
+		line("X-WR-CALNAME", escape(cal.Name))
+	}
+	for _, e := range cal.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("DTSTAMP", e.Modified.UTC().Format(timeFormat))
+		line("LAST-MODIFIED", e.Modified.UTC().Format(timeFormat))
+		line("DTSTART", e.Start.UTC().Format(timeFormat))
+		line("DTEND", e.End.UTC().Format(timeFormat))
+		if e.RRule != "" {
+			line("RRULE", e.RRule)
+		}
+		line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		if e.URL != "" {
+			line("URL", e.URL)
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeFolded ends lines with CRLF and folds them at 75 octets, without
+// splitting UTF-8 sequences
+func writeFolded(b *strings.Builder, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
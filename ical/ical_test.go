@@ -0,0 +1,52 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2024, 6, 1, 9, 0, 0, 0, time.FixedZone("BST", 3600))
+	var buf bytes.Buffer
+	err := Write(&buf, Calendar{
+		ProdID: "-//Test//EN",
+		Name:   "Events",
+		Events: []Event{{
+			UID:         "event-1@example.com",
+			Summary:     "Tournament; round 1, Lake District",
+			Description: "Line one\nLine two " + strings.Repeat("é", 60),
+			Start:       start,
+			End:         start.Add(time.Hour),
+			RRule:       "FREQ=DAILY",
+			Modified:    start,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART:20240601T080000Z\r\n",
+		"RRULE:FREQ=DAILY\r\n",
+		`SUMMARY:Tournament\; round 1\, Lake District` + "\r\n",
+		`DESCRIPTION:Line one\nLine two`,
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+		if strings.ToValidUTF8(line, "?") != line {
+			t.Errorf("fold split a UTF-8 sequence: %q", line)
+		}
+	}
+}
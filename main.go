@@ -2,173 +2,132 @@ package main
 
 import (
 	"context"
+	"contourguessr-api/config"
 	"contourguessr-api/repos"
-	"encoding/json"
-	"errors"
-	"github.com/gorilla/mux"
+	"contourguessr-api/server"
+	"contourguessr-api/wmts/wmtstest"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
-	"sort"
+	"os/signal"
 	"strconv"
-	"strings"
+	"syscall"
 	"time"
 )
 
-var repo *repos.Repo
-
-var challengesPerRegionGauge = promauto.NewGaugeVec(
-	prometheus.GaugeOpts{
-		Namespace: "contourguessr",
-		Name:      "challenges_per_region",
-		Help:      "Number of challenges partitioned by region",
-	},
-	[]string{"region"},
-)
-
 func main() {
-	err := godotenv.Load(".env", ".env.local")
-	if err != nil {
-		log.Println(err)
+	// Subcommands don't run a server, so don't need its config
+	if len(os.Args) > 1 && os.Args[1] == "smoketest" {
+		os.Exit(runSmoketest(os.Args[2:]))
 	}
-
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		log.Fatal("DATABASE_URL not set")
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		os.Exit(runSeed(os.Args[2:]))
 	}
 
-	host := os.Getenv("HOST")
-	if host == "" {
-		host = "0.0.0.0"
-	}
+	err := godotenv.Load(".env", ".env.local")
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	cfg, cfgErr := config.Load(os.Getenv("CONFIG_FILE"), os.Getenv)
 
-	db, err := pgxpool.Connect(context.Background(), databaseURL)
+	if err := server.ConfigureLogging(cfg); err != nil {
+		fatal("invalid config", "err", err)
+	}
 	if err != nil {
-		log.Fatal(err)
+		slog.Warn("error loading .env", "err", err)
 	}
-
-	repo = repos.New(db)
-	repo.WaitUntilReady()
-
-	go updateChallengesPerRegionCounter()
-
-	router := mux.NewRouter()
-
-	router.Use(apiAllowCORSMiddleware)
-
-	router.HandleFunc("/healthz", handleHealthz)
-	router.Handle("/metrics", promhttp.Handler())
-
-	router.HandleFunc("/debug/challenge", handleDebugChallenge).Methods("GET")
-
-	router.HandleFunc("/api/v1/region", handleGetRegions).Methods("GET")
-	router.HandleFunc("/api/v1/challenge/random", handleGetRandomChallenge).Methods("GET")
-	router.HandleFunc("/api/v1/challenge/{id}", handleGetChallenge).Methods("GET")
-
-	addr := host + ":" + port
-	log.Println("listening on", addr)
-	log.Fatal(http.ListenAndServe(addr, router))
-}
-
-func handleGetRegions(w http.ResponseWriter, _ *http.Request) {
-	regions := repo.Regions()
-	list := make([]repos.Region, 0, len(regions))
-	for _, region := range regions {
-		list = append(list, region)
+	if cfgErr != nil {
+		fatal("invalid config", "err", cfgErr)
 	}
-	sort.Slice(list, func(i, j int) bool {
-		return list[i].ID < list[j].ID
-	})
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(list)
-}
+	if cfg.ChallengeIDs.Key != "" {
+		legacyUntil, _ := time.Parse(time.DateOnly, cfg.ChallengeIDs.LegacyUntil)
+		repos.ConfigureChallengeIDs([]byte(cfg.ChallengeIDs.Key), legacyUntil)
+	}
 
-func handleGetRandomChallenge(w http.ResponseWriter, r *http.Request) {
-	var regionID *int
-	regionS := r.URL.Query().Get("region")
-	if regionS == "" {
-		regionID = nil
-	} else {
-		val, err := strconv.Atoi(regionS)
+	var repo repos.Store
+	if cfg.FixturesPath != "" {
+		fixtures, err := repos.LoadFixtures(cfg.FixturesPath)
 		if err != nil {
-			http.Error(w, "invalid region_id", http.StatusBadRequest)
-			return
+			fatal("startup failed", "err", err)
 		}
-		regionID = &val
+		slog.Warn("serving fixtures instead of the database, nothing will be saved", "path", cfg.FixturesPath)
+		repo = repos.NewMemory(fixtures)
+	} else {
+		repo = connectRepo(cfg, server.RepoOptions(cfg))
 	}
+	repo.WaitUntilReady()
 
-	challenge, err := repo.RandomChallenge(regionID)
-	if errors.Is(err, repos.NoChallengesAvailableError) {
-		http.Error(w, "no challenges available", http.StatusNotFound)
-		return
-	} else if err != nil {
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+	srv, err := server.New(cfg, repo)
+	if err != nil {
+		fatal("startup failed", "err", err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(challenge)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := srv.Run(ctx); err != nil {
+		fatal("server failed", "err", err)
+	}
+	repo.Close()
+	server.FlushErrorReports()
+	slog.Info("shut down")
 }
 
-func handleGetChallenge(w http.ResponseWriter, r *http.Request) {
-	id := mux.Vars(r)["id"]
-	challenge, err := repo.Challenge(id)
-	if errors.Is(err, repos.ChallengeNotFoundError) {
-		http.Error(w, "challenge not found", http.StatusNotFound)
-		return
-	} else if err != nil {
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+// connectRepo connects to the database and migrates it. With a snapshot to
+// fall back on, a database that's down doesn't stop us starting.
+func connectRepo(cfg config.Config, opts []repos.Option) *repos.Repo {
+	dbConfig, err := pgxpool.ParseConfig(cfg.Database.URL)
+	if err != nil {
+		fatal("startup failed", "err", err)
+	}
+	if v := cfg.Database.MaxConns; v > 0 {
+		dbConfig.MaxConns = int32(v)
+	}
+	if v := cfg.Database.MinConns; v > 0 {
+		dbConfig.MinConns = int32(v)
+	}
+	if v := cfg.Database.HealthCheckPeriod; v > 0 {
+		dbConfig.HealthCheckPeriod = v
+	}
+	if v := cfg.Database.MaxConnLifetime; v > 0 {
+		dbConfig.MaxConnLifetime = v
+	}
+	if v := cfg.Database.StatementTimeout; v > 0 {
+		dbConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(v.Milliseconds(), 10)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(challenge)
-}
+	if cfg.CapabilitiesStub {
+		stub := wmtstest.NewServer()
+		slog.Warn("fetching map layer capabilities from a stub", "url", stub.URL)
+		opts = append(opts, repos.WithCapabilitiesURLs(func(layer string, matrixSet string) string {
+			return wmtstest.URL(stub.URL, layer, matrixSet)
+		}))
+	}
 
-func handleHealthz(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("OK"))
-}
+	if cfg.SnapshotPath != "" {
+		dbConfig.LazyConnect = true
+		opts = append(opts, repos.WithSnapshotPath(cfg.SnapshotPath))
+	}
 
-func handleDebugChallenge(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	info, err := repo.ChallengeDebugInfoJSON(r.Context(), id)
+	db, err := pgxpool.ConnectConfig(context.Background(), dbConfig)
 	if err != nil {
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		fatal("startup failed", "err", err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write([]byte(info))
-}
-
-func apiAllowCORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/api/") {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "*")
+	migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), 1*time.Minute)
+	err = repos.Migrate(migrateCtx, db)
+	cancelMigrate()
+	if err != nil {
+		if cfg.SnapshotPath == "" {
+			fatal("startup failed", "err", err)
 		}
-		next.ServeHTTP(w, r)
-	})
+		slog.Error("failed to migrate, continuing in case we can serve from snapshot", "err", err)
+	}
+
+	return repos.New(db, opts...)
 }
 
-func updateChallengesPerRegionCounter() {
-	ticker := time.NewTicker(1 * time.Second)
-	for range ticker.C {
-		counts := repo.ChallengesPerRegion()
-		for region, count := range counts {
-			challengesPerRegionGauge.WithLabelValues(strconv.Itoa(region)).Set(float64(count))
-		}
-	}
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	server.FlushErrorReports()
+	os.Exit(1)
 }
@@ -0,0 +1,83 @@
+package pacing
+
+import (
+	"errors"
+	"hash/fnv"
+)
+
+// Pacing tells the frontend how a game should feel, so that it can be tuned
+// without a frontend release
+type Pacing struct {
+	// Zero means rounds aren't timed
+	SecondsPerRound  int    `json:"seconds_per_round"`
+	HintsEnabled     bool   `json:"hints_enabled"`
+	RevealAnimation  string `json:"reveal_animation"`
+	RevealDurationMS int    `json:"reveal_duration_ms"`
+	// The experiment variant the player is in, empty for the default
+	Variant string `json:"variant,omitempty"`
+}
+
+type Variant struct {
+	Name string `json:"name"`
+	// Share of players out of 100
+	Percent int    `json:"percent"`
+	Pacing  Pacing `json:"pacing"`
+}
+
+type Config struct {
+	Default Pacing `json:"default"`
+	// Experiment is mixed into the player's bucket so that each experiment
+	// splits players independently of the last one
+	Experiment string    `json:"experiment"`
+	Variants   []Variant `json:"variants"`
+}
+
+var DefaultConfig = Config{
+	Default: Pacing{
+		SecondsPerRound:  0,
+		HintsEnabled:     false,
+		RevealAnimation:  "fly-to",
+		RevealDurationMS: 1500,
+	},
+}
+
+var InvalidConfigError = errors.New("variant percentages must be positive and add up to at most 100")
+
+func (c Config) Validate() error {
+	total := 0
+	for _, v := range c.Variants {
+		if v.Percent <= 0 || v.Name == "" {
+			return InvalidConfigError
+		}
+		total += v.Percent
+	}
+	if total > 100 {
+		return InvalidConfigError
+	}
+	return nil
+}
+
+// For returns the pacing for player. Players are bucketed by a hash of their
+// ID so that they stay in the same variant for the whole experiment, and
+// anonymous players always get the default.
+func (c Config) For(player string) Pacing {
+	if player == "" || len(c.Variants) == 0 {
+		return c.Default
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(c.Experiment))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(player))
+	bucket := int(h.Sum64() % 100)
+
+	for _, v := range c.Variants {
+		if bucket < v.Percent {
+			p := v.Pacing
+			p.Variant = v.Name
+			return p
+		}
+		bucket -= v.Percent
+	}
+	return c.Default
+}
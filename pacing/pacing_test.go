@@ -0,0 +1,50 @@
+package pacing
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	c := Config{
+		Default:    Pacing{RevealDurationMS: 1500},
+		Experiment: "timed-rounds",
+		Variants: []Variant{
+			{Name: "timed-60", Percent: 25, Pacing: Pacing{SecondsPerRound: 60}},
+			{Name: "timed-30", Percent: 25, Pacing: Pacing{SecondsPerRound: 30}},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.For(""); got != c.Default {
+		t.Errorf("expected anonymous players to get the default, got %+v", got)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		player := fmt.Sprintf("player-%d", i)
+		got := c.For(player)
+		if got != c.For(player) {
+			t.Fatal("expected a stable variant")
+		}
+		counts[got.Variant]++
+	}
+	for variant, want := range map[string]int{"timed-60": 2500, "timed-30": 2500, "": 5000} {
+		if got := counts[variant]; got < want*9/10 || got > want*11/10 {
+			t.Errorf("expected about %d players in %q, got %d", want, variant, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := Config{Variants: []Variant{{Name: "a", Percent: 60}, {Name: "b", Percent: 50}}}
+	if c.Validate() == nil {
+		t.Error("expected percentages over 100 to be rejected")
+	}
+	c = Config{Variants: []Variant{{Name: "a", Percent: 0}}}
+	if c.Validate() == nil {
+		t.Error("expected empty variants to be rejected")
+	}
+}
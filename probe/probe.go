@@ -0,0 +1,136 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Hosts report at most this many example failures
+const maxExampleErrors = 5
+
+type HostStats struct {
+	Host     string         `json:"host"`
+	Requests int            `json:"requests"`
+	Errors   int            `json:"errors"`
+	Statuses map[string]int `json:"statuses"`
+	P50MS    float64        `json:"p50_ms"`
+	P90MS    float64        `json:"p90_ms"`
+	MaxMS    float64        `json:"max_ms"`
+	Examples []string       `json:"example_errors"`
+}
+
+type result struct {
+	url     string
+	host    string
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// Probe sends a HEAD request to each URL, at most concurrency at a time, and
+// summarises the results by host. Anything other than a 2xx counts as an
+// error.
+func Probe(ctx context.Context, c *http.Client, urls []string, concurrency int) []HostStats {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([]result, len(urls))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, u string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = probeOne(ctx, c, u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	return summarise(results)
+}
+
+func probeOne(ctx context.Context, c *http.Client, rawURL string) result {
+	res := result{url: rawURL}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.host = u.Host
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	req.Header.Set("User-Agent", "github.com/dzfranklin/contourguessr")
+
+	start := time.Now()
+	resp, err := c.Do(req)
+	res.latency = time.Since(start)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	_ = resp.Body.Close()
+	res.status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		res.err = fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return res
+}
+
+func summarise(results []result) []HostStats {
+	byHost := make(map[string][]result)
+	for _, res := range results {
+		byHost[res.host] = append(byHost[res.host], res)
+	}
+
+	out := make([]HostStats, 0, len(byHost))
+	for host, results := range byHost {
+		stats := HostStats{
+			Host:     host,
+			Requests: len(results),
+			Statuses: make(map[string]int),
+			Examples: make([]string, 0),
+		}
+		latencies := make([]time.Duration, 0, len(results))
+		for _, res := range results {
+			if res.status != 0 {
+				stats.Statuses[fmt.Sprint(res.status)]++
+				latencies = append(latencies, res.latency)
+			} else {
+				stats.Statuses["error"]++
+			}
+			if res.err != nil {
+				stats.Errors++
+				if len(stats.Examples) < maxExampleErrors {
+					stats.Examples = append(stats.Examples, res.url+": "+res.err.Error())
+				}
+			}
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.P50MS = percentileMS(latencies, 0.5)
+		stats.P90MS = percentileMS(latencies, 0.9)
+		stats.MaxMS = percentileMS(latencies, 1)
+		out = append(out, stats)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+func percentileMS(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	d := sorted[int(float64(len(sorted)-1)*p)]
+	return float64(d.Microseconds()) / 1000
+}
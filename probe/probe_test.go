@@ -0,0 +1,47 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("expected HEAD, got %s", r.Method)
+		}
+		if r.URL.Path == "/missing.jpg" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ok.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	stats := Probe(context.Background(), http.DefaultClient, []string{
+		ok.URL + "/a.jpg",
+		ok.URL + "/b.jpg",
+		ok.URL + "/missing.jpg",
+		down.URL + "/c.jpg",
+	}, 2)
+
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 hosts, got %+v", stats)
+	}
+	for _, s := range stats {
+		switch "http://" + s.Host {
+		case ok.URL:
+			if s.Requests != 3 || s.Errors != 1 || s.Statuses["200"] != 2 || s.Statuses["404"] != 1 {
+				t.Errorf("unexpected stats for up host %+v", s)
+			}
+		case down.URL:
+			if s.Requests != 1 || s.Errors != 1 || s.Statuses["error"] != 1 || len(s.Examples) != 1 {
+				t.Errorf("unexpected stats for down host %+v", s)
+			}
+		default:
+			t.Errorf("unexpected host %s", s.Host)
+		}
+	}
+}
@@ -0,0 +1,1031 @@
+// The gRPC interface to ContourGuessr, served alongside the REST API on
+// GRPC_PORT. It mirrors the v2 play endpoints, so challenges leave out their
+// location.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: contourguessr/v1/contourguessr.proto
+
+package contourguessrv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListRegionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRegionsRequest) Reset() {
+	*x = ListRegionsRequest{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRegionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRegionsRequest) ProtoMessage() {}
+
+func (x *ListRegionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRegionsRequest.ProtoReflect.Descriptor instead.
+func (*ListRegionsRequest) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{0}
+}
+
+type ListRegionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Regions       []*Region              `protobuf:"bytes,1,rep,name=regions,proto3" json:"regions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRegionsResponse) Reset() {
+	*x = ListRegionsResponse{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRegionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRegionsResponse) ProtoMessage() {}
+
+func (x *ListRegionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRegionsResponse.ProtoReflect.Descriptor instead.
+func (*ListRegionsResponse) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListRegionsResponse) GetRegions() []*Region {
+	if x != nil {
+		return x.Regions
+	}
+	return nil
+}
+
+type GetChallengeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChallengeRequest) Reset() {
+	*x = GetChallengeRequest{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChallengeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChallengeRequest) ProtoMessage() {}
+
+func (x *GetChallengeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChallengeRequest.ProtoReflect.Descriptor instead.
+func (*GetChallengeRequest) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetChallengeRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetRandomChallengeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RegionId      *int32                 `protobuf:"varint,1,opt,name=region_id,json=regionId,proto3,oneof" json:"region_id,omitempty"`
+	Player        string                 `protobuf:"bytes,2,opt,name=player,proto3" json:"player,omitempty"`
+	Seq           uint64                 `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRandomChallengeRequest) Reset() {
+	*x = GetRandomChallengeRequest{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRandomChallengeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRandomChallengeRequest) ProtoMessage() {}
+
+func (x *GetRandomChallengeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRandomChallengeRequest.ProtoReflect.Descriptor instead.
+func (*GetRandomChallengeRequest) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetRandomChallengeRequest) GetRegionId() int32 {
+	if x != nil && x.RegionId != nil {
+		return *x.RegionId
+	}
+	return 0
+}
+
+func (x *GetRandomChallengeRequest) GetPlayer() string {
+	if x != nil {
+		return x.Player
+	}
+	return ""
+}
+
+func (x *GetRandomChallengeRequest) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+type Region struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id          string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name        string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	CountryIso2 string                 `protobuf:"bytes,3,opt,name=country_iso2,json=countryIso2,proto3" json:"country_iso2,omitempty"`
+	LogoUrl     string                 `protobuf:"bytes,4,opt,name=logo_url,json=logoUrl,proto3" json:"logo_url,omitempty"`
+	// GeoJSON geometry of the region's boundary
+	GeoJson string `protobuf:"bytes,5,opt,name=geo_json,json=geoJson,proto3" json:"geo_json,omitempty"`
+	Bbox    *BBox  `protobuf:"bytes,6,opt,name=bbox,proto3" json:"bbox,omitempty"`
+	// The default layer followed by its seasonal variants
+	MapLayers     []*MapLayer `protobuf:"bytes,7,rep,name=map_layers,json=mapLayers,proto3" json:"map_layers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Region) Reset() {
+	*x = Region{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Region) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Region) ProtoMessage() {}
+
+func (x *Region) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Region.ProtoReflect.Descriptor instead.
+func (*Region) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Region) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Region) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Region) GetCountryIso2() string {
+	if x != nil {
+		return x.CountryIso2
+	}
+	return ""
+}
+
+func (x *Region) GetLogoUrl() string {
+	if x != nil {
+		return x.LogoUrl
+	}
+	return ""
+}
+
+func (x *Region) GetGeoJson() string {
+	if x != nil {
+		return x.GeoJson
+	}
+	return ""
+}
+
+func (x *Region) GetBbox() *BBox {
+	if x != nil {
+		return x.Bbox
+	}
+	return nil
+}
+
+func (x *Region) GetMapLayers() []*MapLayer {
+	if x != nil {
+		return x.MapLayers
+	}
+	return nil
+}
+
+type BBox struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinLng        float64                `protobuf:"fixed64,1,opt,name=min_lng,json=minLng,proto3" json:"min_lng,omitempty"`
+	MinLat        float64                `protobuf:"fixed64,2,opt,name=min_lat,json=minLat,proto3" json:"min_lat,omitempty"`
+	MaxLng        float64                `protobuf:"fixed64,3,opt,name=max_lng,json=maxLng,proto3" json:"max_lng,omitempty"`
+	MaxLat        float64                `protobuf:"fixed64,4,opt,name=max_lat,json=maxLat,proto3" json:"max_lat,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BBox) Reset() {
+	*x = BBox{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BBox) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BBox) ProtoMessage() {}
+
+func (x *BBox) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BBox.ProtoReflect.Descriptor instead.
+func (*BBox) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BBox) GetMinLng() float64 {
+	if x != nil {
+		return x.MinLng
+	}
+	return 0
+}
+
+func (x *BBox) GetMinLat() float64 {
+	if x != nil {
+		return x.MinLat
+	}
+	return 0
+}
+
+func (x *BBox) GetMaxLng() float64 {
+	if x != nil {
+		return x.MaxLng
+	}
+	return 0
+}
+
+func (x *BBox) GetMaxLat() float64 {
+	if x != nil {
+		return x.MaxLat
+	}
+	return 0
+}
+
+// A map layer with its capabilities reduced to what's needed to request tiles
+type MapLayer struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Seasonal variants are used between the inclusive MM-DD bounds, which may
+	// wrap around the new year. The default layer has none.
+	Season            string        `protobuf:"bytes,3,opt,name=season,proto3" json:"season,omitempty"`
+	From              string        `protobuf:"bytes,4,opt,name=from,proto3" json:"from,omitempty"`
+	To                string        `protobuf:"bytes,5,opt,name=to,proto3" json:"to,omitempty"`
+	Layer             string        `protobuf:"bytes,6,opt,name=layer,proto3" json:"layer,omitempty"`
+	Format            string        `protobuf:"bytes,7,opt,name=format,proto3" json:"format,omitempty"`
+	TileUrlTemplate   string        `protobuf:"bytes,8,opt,name=tile_url_template,json=tileUrlTemplate,proto3" json:"tile_url_template,omitempty"`
+	Crs               string        `protobuf:"bytes,9,opt,name=crs,proto3" json:"crs,omitempty"`
+	TileMatrices      []*TileMatrix `protobuf:"bytes,10,rep,name=tile_matrices,json=tileMatrices,proto3" json:"tile_matrices,omitempty"`
+	Resolutions       []float64     `protobuf:"fixed64,11,rep,packed,name=resolutions,proto3" json:"resolutions,omitempty"`
+	DefaultResolution float64       `protobuf:"fixed64,12,opt,name=default_resolution,json=defaultResolution,proto3" json:"default_resolution,omitempty"`
+	OsBranding        bool          `protobuf:"varint,13,opt,name=os_branding,json=osBranding,proto3" json:"os_branding,omitempty"`
+	ExtraAttributions []string      `protobuf:"bytes,14,rep,name=extra_attributions,json=extraAttributions,proto3" json:"extra_attributions,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *MapLayer) Reset() {
+	*x = MapLayer{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MapLayer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MapLayer) ProtoMessage() {}
+
+func (x *MapLayer) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MapLayer.ProtoReflect.Descriptor instead.
+func (*MapLayer) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *MapLayer) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *MapLayer) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *MapLayer) GetSeason() string {
+	if x != nil {
+		return x.Season
+	}
+	return ""
+}
+
+func (x *MapLayer) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *MapLayer) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *MapLayer) GetLayer() string {
+	if x != nil {
+		return x.Layer
+	}
+	return ""
+}
+
+func (x *MapLayer) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *MapLayer) GetTileUrlTemplate() string {
+	if x != nil {
+		return x.TileUrlTemplate
+	}
+	return ""
+}
+
+func (x *MapLayer) GetCrs() string {
+	if x != nil {
+		return x.Crs
+	}
+	return ""
+}
+
+func (x *MapLayer) GetTileMatrices() []*TileMatrix {
+	if x != nil {
+		return x.TileMatrices
+	}
+	return nil
+}
+
+func (x *MapLayer) GetResolutions() []float64 {
+	if x != nil {
+		return x.Resolutions
+	}
+	return nil
+}
+
+func (x *MapLayer) GetDefaultResolution() float64 {
+	if x != nil {
+		return x.DefaultResolution
+	}
+	return 0
+}
+
+func (x *MapLayer) GetOsBranding() bool {
+	if x != nil {
+		return x.OsBranding
+	}
+	return false
+}
+
+func (x *MapLayer) GetExtraAttributions() []string {
+	if x != nil {
+		return x.ExtraAttributions
+	}
+	return nil
+}
+
+type TileMatrix struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Id               string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ScaleDenominator float64                `protobuf:"fixed64,2,opt,name=scale_denominator,json=scaleDenominator,proto3" json:"scale_denominator,omitempty"`
+	TopLeftX         float64                `protobuf:"fixed64,3,opt,name=top_left_x,json=topLeftX,proto3" json:"top_left_x,omitempty"`
+	TopLeftY         float64                `protobuf:"fixed64,4,opt,name=top_left_y,json=topLeftY,proto3" json:"top_left_y,omitempty"`
+	TileWidth        int32                  `protobuf:"varint,5,opt,name=tile_width,json=tileWidth,proto3" json:"tile_width,omitempty"`
+	TileHeight       int32                  `protobuf:"varint,6,opt,name=tile_height,json=tileHeight,proto3" json:"tile_height,omitempty"`
+	MatrixWidth      int32                  `protobuf:"varint,7,opt,name=matrix_width,json=matrixWidth,proto3" json:"matrix_width,omitempty"`
+	MatrixHeight     int32                  `protobuf:"varint,8,opt,name=matrix_height,json=matrixHeight,proto3" json:"matrix_height,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *TileMatrix) Reset() {
+	*x = TileMatrix{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TileMatrix) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TileMatrix) ProtoMessage() {}
+
+func (x *TileMatrix) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TileMatrix.ProtoReflect.Descriptor instead.
+func (*TileMatrix) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *TileMatrix) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TileMatrix) GetScaleDenominator() float64 {
+	if x != nil {
+		return x.ScaleDenominator
+	}
+	return 0
+}
+
+func (x *TileMatrix) GetTopLeftX() float64 {
+	if x != nil {
+		return x.TopLeftX
+	}
+	return 0
+}
+
+func (x *TileMatrix) GetTopLeftY() float64 {
+	if x != nil {
+		return x.TopLeftY
+	}
+	return 0
+}
+
+func (x *TileMatrix) GetTileWidth() int32 {
+	if x != nil {
+		return x.TileWidth
+	}
+	return 0
+}
+
+func (x *TileMatrix) GetTileHeight() int32 {
+	if x != nil {
+		return x.TileHeight
+	}
+	return 0
+}
+
+func (x *TileMatrix) GetMatrixWidth() int32 {
+	if x != nil {
+		return x.MatrixWidth
+	}
+	return 0
+}
+
+func (x *TileMatrix) GetMatrixHeight() int32 {
+	if x != nil {
+		return x.MatrixHeight
+	}
+	return 0
+}
+
+type Challenge struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	RegionId        string                 `protobuf:"bytes,2,opt,name=region_id,json=regionId,proto3" json:"region_id,omitempty"`
+	Title           string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	DescriptionHtml string                 `protobuf:"bytes,4,opt,name=description_html,json=descriptionHtml,proto3" json:"description_html,omitempty"`
+	// Unset if unknown
+	DateTaken     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=date_taken,json=dateTaken,proto3" json:"date_taken,omitempty"`
+	Link          string                 `protobuf:"bytes,6,opt,name=link,proto3" json:"link,omitempty"`
+	Regular       *Picture               `protobuf:"bytes,7,opt,name=regular,proto3" json:"regular,omitempty"`
+	Large         *Picture               `protobuf:"bytes,8,opt,name=large,proto3" json:"large,omitempty"`
+	Photographer  *Photographer          `protobuf:"bytes,9,opt,name=photographer,proto3" json:"photographer,omitempty"`
+	RX            float64                `protobuf:"fixed64,10,opt,name=r_x,json=rX,proto3" json:"r_x,omitempty"`
+	RY            float64                `protobuf:"fixed64,11,opt,name=r_y,json=rY,proto3" json:"r_y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Challenge) Reset() {
+	*x = Challenge{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Challenge) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Challenge) ProtoMessage() {}
+
+func (x *Challenge) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Challenge.ProtoReflect.Descriptor instead.
+func (*Challenge) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Challenge) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Challenge) GetRegionId() string {
+	if x != nil {
+		return x.RegionId
+	}
+	return ""
+}
+
+func (x *Challenge) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Challenge) GetDescriptionHtml() string {
+	if x != nil {
+		return x.DescriptionHtml
+	}
+	return ""
+}
+
+func (x *Challenge) GetDateTaken() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DateTaken
+	}
+	return nil
+}
+
+func (x *Challenge) GetLink() string {
+	if x != nil {
+		return x.Link
+	}
+	return ""
+}
+
+func (x *Challenge) GetRegular() *Picture {
+	if x != nil {
+		return x.Regular
+	}
+	return nil
+}
+
+func (x *Challenge) GetLarge() *Picture {
+	if x != nil {
+		return x.Large
+	}
+	return nil
+}
+
+func (x *Challenge) GetPhotographer() *Photographer {
+	if x != nil {
+		return x.Photographer
+	}
+	return nil
+}
+
+func (x *Challenge) GetRX() float64 {
+	if x != nil {
+		return x.RX
+	}
+	return 0
+}
+
+func (x *Challenge) GetRY() float64 {
+	if x != nil {
+		return x.RY
+	}
+	return 0
+}
+
+type Picture struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Src           string                 `protobuf:"bytes,1,opt,name=src,proto3" json:"src,omitempty"`
+	Width         int32                  `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Picture) Reset() {
+	*x = Picture{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Picture) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Picture) ProtoMessage() {}
+
+func (x *Picture) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Picture.ProtoReflect.Descriptor instead.
+func (*Picture) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Picture) GetSrc() string {
+	if x != nil {
+		return x.Src
+	}
+	return ""
+}
+
+func (x *Picture) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Picture) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type Photographer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Icon          string                 `protobuf:"bytes,1,opt,name=icon,proto3" json:"icon,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	Link          string                 `protobuf:"bytes,3,opt,name=link,proto3" json:"link,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Photographer) Reset() {
+	*x = Photographer{}
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Photographer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Photographer) ProtoMessage() {}
+
+func (x *Photographer) ProtoReflect() protoreflect.Message {
+	mi := &file_contourguessr_v1_contourguessr_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Photographer.ProtoReflect.Descriptor instead.
+func (*Photographer) Descriptor() ([]byte, []int) {
+	return file_contourguessr_v1_contourguessr_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Photographer) GetIcon() string {
+	if x != nil {
+		return x.Icon
+	}
+	return ""
+}
+
+func (x *Photographer) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Photographer) GetLink() string {
+	if x != nil {
+		return x.Link
+	}
+	return ""
+}
+
+var File_contourguessr_v1_contourguessr_proto protoreflect.FileDescriptor
+
+const file_contourguessr_v1_contourguessr_proto_rawDesc = "" +
+	"\n" +
+	"$contourguessr/v1/contourguessr.proto\x12\x10contourguessr.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x14\n" +
+	"\x12ListRegionsRequest\"I\n" +
+	"\x13ListRegionsResponse\x122\n" +
+	"\aregions\x18\x01 \x03(\v2\x18.contourguessr.v1.RegionR\aregions\"%\n" +
+	"\x13GetChallengeRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"u\n" +
+	"\x19GetRandomChallengeRequest\x12 \n" +
+	"\tregion_id\x18\x01 \x01(\x05H\x00R\bregionId\x88\x01\x01\x12\x16\n" +
+	"\x06player\x18\x02 \x01(\tR\x06player\x12\x10\n" +
+	"\x03seq\x18\x03 \x01(\x04R\x03seqB\f\n" +
+	"\n" +
+	"_region_id\"\xec\x01\n" +
+	"\x06Region\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12!\n" +
+	"\fcountry_iso2\x18\x03 \x01(\tR\vcountryIso2\x12\x19\n" +
+	"\blogo_url\x18\x04 \x01(\tR\alogoUrl\x12\x19\n" +
+	"\bgeo_json\x18\x05 \x01(\tR\ageoJson\x12*\n" +
+	"\x04bbox\x18\x06 \x01(\v2\x16.contourguessr.v1.BBoxR\x04bbox\x129\n" +
+	"\n" +
+	"map_layers\x18\a \x03(\v2\x1a.contourguessr.v1.MapLayerR\tmapLayers\"j\n" +
+	"\x04BBox\x12\x17\n" +
+	"\amin_lng\x18\x01 \x01(\x01R\x06minLng\x12\x17\n" +
+	"\amin_lat\x18\x02 \x01(\x01R\x06minLat\x12\x17\n" +
+	"\amax_lng\x18\x03 \x01(\x01R\x06maxLng\x12\x17\n" +
+	"\amax_lat\x18\x04 \x01(\x01R\x06maxLat\"\xba\x03\n" +
+	"\bMapLayer\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06season\x18\x03 \x01(\tR\x06season\x12\x12\n" +
+	"\x04from\x18\x04 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x05 \x01(\tR\x02to\x12\x14\n" +
+	"\x05layer\x18\x06 \x01(\tR\x05layer\x12\x16\n" +
+	"\x06format\x18\a \x01(\tR\x06format\x12*\n" +
+	"\x11tile_url_template\x18\b \x01(\tR\x0ftileUrlTemplate\x12\x10\n" +
+	"\x03crs\x18\t \x01(\tR\x03crs\x12A\n" +
+	"\rtile_matrices\x18\n" +
+	" \x03(\v2\x1c.contourguessr.v1.TileMatrixR\ftileMatrices\x12 \n" +
+	"\vresolutions\x18\v \x03(\x01R\vresolutions\x12-\n" +
+	"\x12default_resolution\x18\f \x01(\x01R\x11defaultResolution\x12\x1f\n" +
+	"\vos_branding\x18\r \x01(\bR\n" +
+	"osBranding\x12-\n" +
+	"\x12extra_attributions\x18\x0e \x03(\tR\x11extraAttributions\"\x8d\x02\n" +
+	"\n" +
+	"TileMatrix\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12+\n" +
+	"\x11scale_denominator\x18\x02 \x01(\x01R\x10scaleDenominator\x12\x1c\n" +
+	"\n" +
+	"top_left_x\x18\x03 \x01(\x01R\btopLeftX\x12\x1c\n" +
+	"\n" +
+	"top_left_y\x18\x04 \x01(\x01R\btopLeftY\x12\x1d\n" +
+	"\n" +
+	"tile_width\x18\x05 \x01(\x05R\ttileWidth\x12\x1f\n" +
+	"\vtile_height\x18\x06 \x01(\x05R\n" +
+	"tileHeight\x12!\n" +
+	"\fmatrix_width\x18\a \x01(\x05R\vmatrixWidth\x12#\n" +
+	"\rmatrix_height\x18\b \x01(\x05R\fmatrixHeight\"\x94\x03\n" +
+	"\tChallenge\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tregion_id\x18\x02 \x01(\tR\bregionId\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12)\n" +
+	"\x10description_html\x18\x04 \x01(\tR\x0fdescriptionHtml\x129\n" +
+	"\n" +
+	"date_taken\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tdateTaken\x12\x12\n" +
+	"\x04link\x18\x06 \x01(\tR\x04link\x123\n" +
+	"\aregular\x18\a \x01(\v2\x19.contourguessr.v1.PictureR\aregular\x12/\n" +
+	"\x05large\x18\b \x01(\v2\x19.contourguessr.v1.PictureR\x05large\x12B\n" +
+	"\fphotographer\x18\t \x01(\v2\x1e.contourguessr.v1.PhotographerR\fphotographer\x12\x0f\n" +
+	"\x03r_x\x18\n" +
+	" \x01(\x01R\x02rX\x12\x0f\n" +
+	"\x03r_y\x18\v \x01(\x01R\x02rY\"I\n" +
+	"\aPicture\x12\x10\n" +
+	"\x03src\x18\x01 \x01(\tR\x03src\x12\x14\n" +
+	"\x05width\x18\x02 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x03 \x01(\x05R\x06height\"J\n" +
+	"\fPhotographer\x12\x12\n" +
+	"\x04icon\x18\x01 \x01(\tR\x04icon\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12\x12\n" +
+	"\x04link\x18\x03 \x01(\tR\x04link2\x9f\x02\n" +
+	"\rContourGuessr\x12Z\n" +
+	"\vListRegions\x12$.contourguessr.v1.ListRegionsRequest\x1a%.contourguessr.v1.ListRegionsResponse\x12R\n" +
+	"\fGetChallenge\x12%.contourguessr.v1.GetChallengeRequest\x1a\x1b.contourguessr.v1.Challenge\x12^\n" +
+	"\x12GetRandomChallenge\x12+.contourguessr.v1.GetRandomChallengeRequest\x1a\x1b.contourguessr.v1.ChallengeB:Z8contourguessr-api/proto/contourguessr/v1;contourguessrv1b\x06proto3"
+
+var (
+	file_contourguessr_v1_contourguessr_proto_rawDescOnce sync.Once
+	file_contourguessr_v1_contourguessr_proto_rawDescData []byte
+)
+
+func file_contourguessr_v1_contourguessr_proto_rawDescGZIP() []byte {
+	file_contourguessr_v1_contourguessr_proto_rawDescOnce.Do(func() {
+		file_contourguessr_v1_contourguessr_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_contourguessr_v1_contourguessr_proto_rawDesc), len(file_contourguessr_v1_contourguessr_proto_rawDesc)))
+	})
+	return file_contourguessr_v1_contourguessr_proto_rawDescData
+}
+
+var file_contourguessr_v1_contourguessr_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_contourguessr_v1_contourguessr_proto_goTypes = []any{
+	(*ListRegionsRequest)(nil),        // 0: contourguessr.v1.ListRegionsRequest
+	(*ListRegionsResponse)(nil),       // 1: contourguessr.v1.ListRegionsResponse
+	(*GetChallengeRequest)(nil),       // 2: contourguessr.v1.GetChallengeRequest
+	(*GetRandomChallengeRequest)(nil), // 3: contourguessr.v1.GetRandomChallengeRequest
+	(*Region)(nil),                    // 4: contourguessr.v1.Region
+	(*BBox)(nil),                      // 5: contourguessr.v1.BBox
+	(*MapLayer)(nil),                  // 6: contourguessr.v1.MapLayer
+	(*TileMatrix)(nil),                // 7: contourguessr.v1.TileMatrix
+	(*Challenge)(nil),                 // 8: contourguessr.v1.Challenge
+	(*Picture)(nil),                   // 9: contourguessr.v1.Picture
+	(*Photographer)(nil),              // 10: contourguessr.v1.Photographer
+	(*timestamppb.Timestamp)(nil),     // 11: google.protobuf.Timestamp
+}
+var file_contourguessr_v1_contourguessr_proto_depIdxs = []int32{
+	4,  // 0: contourguessr.v1.ListRegionsResponse.regions:type_name -> contourguessr.v1.Region
+	5,  // 1: contourguessr.v1.Region.bbox:type_name -> contourguessr.v1.BBox
+	6,  // 2: contourguessr.v1.Region.map_layers:type_name -> contourguessr.v1.MapLayer
+	7,  // 3: contourguessr.v1.MapLayer.tile_matrices:type_name -> contourguessr.v1.TileMatrix
+	11, // 4: contourguessr.v1.Challenge.date_taken:type_name -> google.protobuf.Timestamp
+	9,  // 5: contourguessr.v1.Challenge.regular:type_name -> contourguessr.v1.Picture
+	9,  // 6: contourguessr.v1.Challenge.large:type_name -> contourguessr.v1.Picture
+	10, // 7: contourguessr.v1.Challenge.photographer:type_name -> contourguessr.v1.Photographer
+	0,  // 8: contourguessr.v1.ContourGuessr.ListRegions:input_type -> contourguessr.v1.ListRegionsRequest
+	2,  // 9: contourguessr.v1.ContourGuessr.GetChallenge:input_type -> contourguessr.v1.GetChallengeRequest
+	3,  // 10: contourguessr.v1.ContourGuessr.GetRandomChallenge:input_type -> contourguessr.v1.GetRandomChallengeRequest
+	1,  // 11: contourguessr.v1.ContourGuessr.ListRegions:output_type -> contourguessr.v1.ListRegionsResponse
+	8,  // 12: contourguessr.v1.ContourGuessr.GetChallenge:output_type -> contourguessr.v1.Challenge
+	8,  // 13: contourguessr.v1.ContourGuessr.GetRandomChallenge:output_type -> contourguessr.v1.Challenge
+	11, // [11:14] is the sub-list for method output_type
+	8,  // [8:11] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_contourguessr_v1_contourguessr_proto_init() }
+func file_contourguessr_v1_contourguessr_proto_init() {
+	if File_contourguessr_v1_contourguessr_proto != nil {
+		return
+	}
+	file_contourguessr_v1_contourguessr_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_contourguessr_v1_contourguessr_proto_rawDesc), len(file_contourguessr_v1_contourguessr_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_contourguessr_v1_contourguessr_proto_goTypes,
+		DependencyIndexes: file_contourguessr_v1_contourguessr_proto_depIdxs,
+		MessageInfos:      file_contourguessr_v1_contourguessr_proto_msgTypes,
+	}.Build()
+	File_contourguessr_v1_contourguessr_proto = out.File
+	file_contourguessr_v1_contourguessr_proto_goTypes = nil
+	file_contourguessr_v1_contourguessr_proto_depIdxs = nil
+}
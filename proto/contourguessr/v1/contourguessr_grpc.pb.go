@@ -0,0 +1,207 @@
+// The gRPC interface to ContourGuessr, served alongside the REST API on
+// GRPC_PORT. It mirrors the v2 play endpoints, so challenges leave out their
+// location.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: contourguessr/v1/contourguessr.proto
+
+package contourguessrv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ContourGuessr_ListRegions_FullMethodName        = "/contourguessr.v1.ContourGuessr/ListRegions"
+	ContourGuessr_GetChallenge_FullMethodName       = "/contourguessr.v1.ContourGuessr/GetChallenge"
+	ContourGuessr_GetRandomChallenge_FullMethodName = "/contourguessr.v1.ContourGuessr/GetRandomChallenge"
+)
+
+// ContourGuessrClient is the client API for ContourGuessr service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ContourGuessrClient interface {
+	// Regions with their map layers parsed, sorted by ID
+	ListRegions(ctx context.Context, in *ListRegionsRequest, opts ...grpc.CallOption) (*ListRegionsResponse, error)
+	GetChallenge(ctx context.Context, in *GetChallengeRequest, opts ...grpc.CallOption) (*Challenge, error)
+	// Players that identify themselves get every challenge once before
+	// repeats, stepping through their personal ordering with seq
+	GetRandomChallenge(ctx context.Context, in *GetRandomChallengeRequest, opts ...grpc.CallOption) (*Challenge, error)
+}
+
+type contourGuessrClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewContourGuessrClient(cc grpc.ClientConnInterface) ContourGuessrClient {
+	return &contourGuessrClient{cc}
+}
+
+func (c *contourGuessrClient) ListRegions(ctx context.Context, in *ListRegionsRequest, opts ...grpc.CallOption) (*ListRegionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRegionsResponse)
+	err := c.cc.Invoke(ctx, ContourGuessr_ListRegions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contourGuessrClient) GetChallenge(ctx context.Context, in *GetChallengeRequest, opts ...grpc.CallOption) (*Challenge, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Challenge)
+	err := c.cc.Invoke(ctx, ContourGuessr_GetChallenge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contourGuessrClient) GetRandomChallenge(ctx context.Context, in *GetRandomChallengeRequest, opts ...grpc.CallOption) (*Challenge, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Challenge)
+	err := c.cc.Invoke(ctx, ContourGuessr_GetRandomChallenge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ContourGuessrServer is the server API for ContourGuessr service.
+// All implementations must embed UnimplementedContourGuessrServer
+// for forward compatibility.
+type ContourGuessrServer interface {
+	// Regions with their map layers parsed, sorted by ID
+	ListRegions(context.Context, *ListRegionsRequest) (*ListRegionsResponse, error)
+	GetChallenge(context.Context, *GetChallengeRequest) (*Challenge, error)
+	// Players that identify themselves get every challenge once before
+	// repeats, stepping through their personal ordering with seq
+	GetRandomChallenge(context.Context, *GetRandomChallengeRequest) (*Challenge, error)
+	mustEmbedUnimplementedContourGuessrServer()
+}
+
+// UnimplementedContourGuessrServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedContourGuessrServer struct{}
+
+func (UnimplementedContourGuessrServer) ListRegions(context.Context, *ListRegionsRequest) (*ListRegionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRegions not implemented")
+}
+func (UnimplementedContourGuessrServer) GetChallenge(context.Context, *GetChallengeRequest) (*Challenge, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChallenge not implemented")
+}
+func (UnimplementedContourGuessrServer) GetRandomChallenge(context.Context, *GetRandomChallengeRequest) (*Challenge, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRandomChallenge not implemented")
+}
+func (UnimplementedContourGuessrServer) mustEmbedUnimplementedContourGuessrServer() {}
+func (UnimplementedContourGuessrServer) testEmbeddedByValue()                       {}
+
+// UnsafeContourGuessrServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ContourGuessrServer will
+// result in compilation errors.
+type UnsafeContourGuessrServer interface {
+	mustEmbedUnimplementedContourGuessrServer()
+}
+
+func RegisterContourGuessrServer(s grpc.ServiceRegistrar, srv ContourGuessrServer) {
+	// If the following call pancis, it indicates UnimplementedContourGuessrServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ContourGuessr_ServiceDesc, srv)
+}
+
+func _ContourGuessr_ListRegions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRegionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContourGuessrServer).ListRegions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContourGuessr_ListRegions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContourGuessrServer).ListRegions(ctx, req.(*ListRegionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContourGuessr_GetChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContourGuessrServer).GetChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContourGuessr_GetChallenge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContourGuessrServer).GetChallenge(ctx, req.(*GetChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContourGuessr_GetRandomChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRandomChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContourGuessrServer).GetRandomChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContourGuessr_GetRandomChallenge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContourGuessrServer).GetRandomChallenge(ctx, req.(*GetRandomChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ContourGuessr_ServiceDesc is the grpc.ServiceDesc for ContourGuessr service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ContourGuessr_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "contourguessr.v1.ContourGuessr",
+	HandlerType: (*ContourGuessrServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRegions",
+			Handler:    _ContourGuessr_ListRegions_Handler,
+		},
+		{
+			MethodName: "GetChallenge",
+			Handler:    _ContourGuessr_GetChallenge_Handler,
+		},
+		{
+			MethodName: "GetRandomChallenge",
+			Handler:    _ContourGuessr_GetRandomChallenge_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "contourguessr/v1/contourguessr.proto",
+}
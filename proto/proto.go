@@ -0,0 +1,12 @@
+// Package proto holds the protobuf schemas, for serving to clients. The Go
+// code generated from them is in the subpackages.
+package proto
+
+import (
+	_ "embed"
+)
+
+//go:generate buf generate
+
+//go:embed contourguessr/v1/contourguessr.proto
+var ContourguessrV1 []byte
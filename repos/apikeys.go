@@ -0,0 +1,98 @@
+package repos
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	DailyQuota int        `json:"daily_quota"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+var APIKeyNotFoundError = errors.New("api key not found")
+
+// CreateAPIKey returns the new key's secret, which isn't stored
+func (r *Repo) CreateAPIKey(ctx context.Context, name string, dailyQuota int) (APIKey, string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return APIKey{}, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	k := APIKey{Name: name, DailyQuota: dailyQuota}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO api_keys (name, token_hash, daily_quota) VALUES ($1, $2, $3) RETURNING id, created_at
+	`, name, hashPartnerToken(token), dailyQuota).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	return k, token, nil
+}
+
+// APIKeyByToken only finds keys that haven't been revoked
+func (r *Repo) APIKeyByToken(ctx context.Context, token string) (APIKey, error) {
+	var k APIKey
+	err := r.db.QueryRow(ctx, `
+		SELECT id, name, daily_quota, created_at, revoked_at
+		FROM api_keys
+		WHERE token_hash = $1 AND revoked_at IS NULL
+	`, hashPartnerToken(token)).Scan(&k.ID, &k.Name, &k.DailyQuota, &k.CreatedAt, &k.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, APIKeyNotFoundError
+	}
+	return k, err
+}
+
+// APIKeys lists every key, including revoked ones, oldest first
+func (r *Repo) APIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, name, daily_quota, created_at, revoked_at FROM api_keys ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []APIKey
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.Name, &k.DailyQuota, &k.CreatedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) SetAPIKeyQuota(ctx context.Context, id int, dailyQuota int) (APIKey, error) {
+	var k APIKey
+	err := r.db.QueryRow(ctx, `
+		UPDATE api_keys SET daily_quota = $2 WHERE id = $1
+		RETURNING id, name, daily_quota, created_at, revoked_at
+	`, id, dailyQuota).Scan(&k.ID, &k.Name, &k.DailyQuota, &k.CreatedAt, &k.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, APIKeyNotFoundError
+	}
+	return k, err
+}
+
+// RevokeAPIKey keeps the key's row so that it still shows up in the list
+func (r *Repo) RevokeAPIKey(ctx context.Context, id int) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = coalesce(revoked_at, now()) WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return APIKeyNotFoundError
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package repos
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// WithArchiveRemovedAfter archives challenges that moderation removed more
+// than d ago. Zero disables automatic archival.
+func WithArchiveRemovedAfter(d time.Duration) Option {
+	return func(r *Repo) {
+		r.archiveRemovedAfter = d
+	}
+}
+
+// ArchiveChallenge moves a challenge and everything referencing it into
+// archived_challenges.
+//
+// The ingestion pipeline owns flickr_challenge_sources and flickr_photos.
+// The challenge's source row is archived and deleted along with it, but the
+// photo itself is only copied since ingestion uses it to avoid picking the
+// same photo again. Images stay wherever ingestion mirrored them; archival
+// only covers the database.
+func (r *Repo) ArchiveChallenge(ctx context.Context, id string, reason string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return ChallengeNotFoundError
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO archived_challenges (id, region_id, date_taken, challenge, related, reason)
+		SELECT c.id, c.region_id, c.date_taken, to_jsonb(c), jsonb_build_object(
+			'guesses', (SELECT coalesce(jsonb_agg(to_jsonb(g)), '[]') FROM guesses AS g WHERE g.challenge_id = c.id),
+			'weather', (SELECT to_jsonb(w) FROM challenge_weather AS w WHERE w.challenge_id = c.id),
+			'moderation', (SELECT to_jsonb(m) FROM challenge_moderation AS m WHERE m.challenge_id = c.id),
+			'flags', (SELECT coalesce(jsonb_agg(to_jsonb(f)), '[]') FROM challenge_flags AS f WHERE f.challenge_id = c.id),
+			'slugs', (SELECT coalesce(jsonb_agg(to_jsonb(s)), '[]') FROM challenge_slugs AS s WHERE s.challenge_id = c.id),
+			'hints', (SELECT coalesce(jsonb_agg(to_jsonb(h)), '[]') FROM challenge_hints AS h WHERE h.challenge_id = c.id),
+			'flickr_sources', (SELECT coalesce(jsonb_agg(to_jsonb(fs)), '[]') FROM flickr_challenge_sources AS fs WHERE fs.challenge_id = c.id),
+			'flickr_photos', (
+				SELECT coalesce(jsonb_agg(to_jsonb(fp)), '[]') FROM flickr_photos AS fp
+				WHERE fp.flickr_id IN (SELECT flickr_id FROM flickr_challenge_sources WHERE challenge_id = c.id)
+			)
+		), $2
+		FROM challenges AS c
+		WHERE c.id = $1
+	`, internalID, reason)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ChallengeNotFoundError
+	}
+
+	// Our tables cascade. Ingestion's are deleted explicitly rather than
+	// relying on however it declared its foreign keys.
+	for _, q := range []string{
+		`DELETE FROM guesses WHERE challenge_id = $1`,
+		`DELETE FROM flickr_challenge_sources WHERE challenge_id = $1`,
+		`DELETE FROM challenges WHERE id = $1`,
+	} {
+		if _, err := tx.Exec(ctx, q, internalID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// RestoreChallenge moves an archived challenge back into the primary tables
+// in the state it was archived in
+func (r *Repo) RestoreChallenge(ctx context.Context, id string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return ChallengeNotFoundError
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO challenges
+		SELECT (jsonb_populate_record(NULL::challenges, challenge)).*
+		FROM archived_challenges
+		WHERE id = $1
+	`, internalID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ChallengeNotFoundError
+	}
+
+	for _, q := range []string{
+		`INSERT INTO guesses
+		SELECT (jsonb_populate_recordset(NULL::guesses, related -> 'guesses')).*
+		FROM archived_challenges WHERE id = $1`,
+		`INSERT INTO challenge_weather
+		SELECT (jsonb_populate_record(NULL::challenge_weather, related -> 'weather')).*
+		FROM archived_challenges WHERE id = $1 AND jsonb_typeof(related -> 'weather') = 'object'`,
+		`INSERT INTO challenge_moderation
+		SELECT (jsonb_populate_record(NULL::challenge_moderation, related -> 'moderation')).*
+		FROM archived_challenges WHERE id = $1 AND jsonb_typeof(related -> 'moderation') = 'object'`,
+		`INSERT INTO challenge_flags
+		SELECT (jsonb_populate_recordset(NULL::challenge_flags, related -> 'flags')).*
+		FROM archived_challenges WHERE id = $1`,
+		// The keys below were added after the first challenges were archived.
+		// A slug may have been given to another challenge in the meantime.
+		`INSERT INTO challenge_slugs
+		SELECT (jsonb_populate_recordset(NULL::challenge_slugs, related -> 'slugs')).*
+		FROM archived_challenges WHERE id = $1 AND jsonb_typeof(related -> 'slugs') = 'array'
+		ON CONFLICT DO NOTHING`,
+		`INSERT INTO challenge_hints
+		SELECT (jsonb_populate_recordset(NULL::challenge_hints, related -> 'hints')).*
+		FROM archived_challenges WHERE id = $1 AND jsonb_typeof(related -> 'hints') = 'array'`,
+		`INSERT INTO flickr_photos
+		SELECT (jsonb_populate_recordset(NULL::flickr_photos, related -> 'flickr_photos')).*
+		FROM archived_challenges WHERE id = $1 AND jsonb_typeof(related -> 'flickr_photos') = 'array'
+		ON CONFLICT (flickr_id) DO NOTHING`,
+		`INSERT INTO flickr_challenge_sources
+		SELECT (jsonb_populate_recordset(NULL::flickr_challenge_sources, related -> 'flickr_sources')).*
+		FROM archived_challenges WHERE id = $1 AND jsonb_typeof(related -> 'flickr_sources') = 'array'`,
+		`DELETE FROM archived_challenges WHERE id = $1`,
+	} {
+		if _, err := tx.Exec(ctx, q, internalID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repo) archiveUpdater(ctx context.Context) {
+	defer r.closeWg.Done()
+
+	t := time.NewTicker(24 * time.Hour)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			slog.Info("cancelling archive updater")
+			return
+		}
+
+		n, err := r.archiveRemoved(ctx)
+		if err != nil {
+			slog.Error("error archiving removed challenges", "err", err)
+		} else if n > 0 {
+			slog.Info("archived removed challenges", "count", n)
+		}
+	}
+}
+
+func (r *Repo) archiveRemoved(ctx context.Context) (int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT challenge_id FROM challenge_moderation
+		WHERE state = 'removed' AND updated_at < now() - make_interval(secs => $1)
+	`, r.archiveRemovedAfter.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		challengeID, err := encodeChallengeID(id)
+		if err != nil {
+			return i, err
+		}
+		if err := r.ArchiveChallenge(ctx, challengeID, "removed by moderation"); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,130 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchRepo builds a repo from synthetic challenges so benchmarks don't need
+// a database. Descriptions are preloaded so ChallengeJSON never misses.
+func benchRepo(b *testing.B, regions int, perRegion int) (*Repo, []string) {
+	b.Helper()
+
+	r := &Repo{descriptions: newLRU(regions * perRegion), outboundPrefix: "https://api.contourguessr.org/out"}
+	r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
+
+	taken := time.Date(2023, 8, 12, 9, 30, 0, 0, time.UTC)
+	var list []*Challenge
+	var ids []string
+	for region := 1; region <= regions; region++ {
+		for i := 0; i < perRegion; i++ {
+			c := &Challenge{
+				ID:        testChallengeID((region-1)*perRegion + i + 1),
+				RegionID:  fmt.Sprint(region),
+				Title:     fmt.Sprintf("Challenge %d in region %d", i, region),
+				DateTaken: &taken,
+				Link:      "https://www.flickr.com/photos/example/123456789/",
+			}
+			c.Geo.Lng, c.Geo.Lat = -5.0037, 56.7969
+			c.Src.Regular = PictureSrc{Src: "https://live.staticflickr.com/1/2_b.jpg", Width: 1024, Height: 768}
+			c.Src.Large = PictureSrc{Src: "https://live.staticflickr.com/1/2_h.jpg", Width: 1600, Height: 1200}
+			c.Photographer.Text = "Example Photographer"
+			c.Photographer.Link = "https://www.flickr.com/people/example/"
+			list = append(list, c)
+			ids = append(ids, c.ID)
+			r.descriptions.add(c.ID, "<p>A view over the ridge towards the summit in the morning light.</p>")
+		}
+	}
+	if err := r.storeChallenges(list); err != nil {
+		b.Fatal(err)
+	}
+	return r, ids
+}
+
+func BenchmarkRandomChallenge(b *testing.B) {
+	r, _ := benchRepo(b, 20, 5000)
+	region := 7
+
+	b.Run("any", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := r.RandomChallenge(nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("region", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := r.RandomChallenge(&region); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("player", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := r.PlayerChallenge(&region, "player", uint64(i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := r.RandomChallenge(&region); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkChallenge(b *testing.B) {
+	r, ids := benchRepo(b, 20, 5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Challenge(ids[i%len(ids)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkChallengeJSON(b *testing.B) {
+	r, ids := benchRepo(b, 1, 1000)
+	ctx := context.Background()
+
+	b.Run("precomputed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c, err := r.Challenge(ids[i%len(ids)])
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := r.ChallengeJSON(ctx, c); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// What every request paid before the encoding was precomputed
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c, err := r.Challenge(ids[i%len(ids)])
+			if err != nil {
+				b.Fatal(err)
+			}
+			c.DescriptionHTML, _ = r.descriptions.get(c.ID)
+			if _, err := json.Marshal(c); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkStoreChallenges(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchRepo(b, 20, 5000)
+	}
+}
@@ -0,0 +1,58 @@
+package repos
+
+import (
+	"context"
+)
+
+// ChallengePhoto is a challenge's regular size photo
+type ChallengePhoto struct {
+	ID  string
+	Src string
+}
+
+// ChallengesWithoutBlurHash lists challenges whose placeholder hasn't been
+// computed yet, oldest first
+func (r *Repo) ChallengesWithoutBlurHash(ctx context.Context, limit int) ([]ChallengePhoto, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id, c.regular_src
+		FROM challenges AS c
+		LEFT JOIN challenge_blurhashes AS bh ON bh.challenge_id = c.id
+		WHERE bh.challenge_id IS NULL
+		ORDER BY c.id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChallengePhoto
+	for rows.Next() {
+		var internalID int
+		var p ChallengePhoto
+		if err := rows.Scan(&internalID, &p.Src); err != nil {
+			return nil, err
+		}
+		p.ID, err = encodeChallengeID(internalID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// SetChallengeBlurHash records the placeholder for both sizes of the photo.
+// An empty hash marks the photo as undecodable.
+func (r *Repo) SetChallengeBlurHash(ctx context.Context, id string, hash string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO challenge_blurhashes (challenge_id, blurhash)
+		VALUES ($1, $2)
+		ON CONFLICT (challenge_id) DO UPDATE SET blurhash = EXCLUDED.blurhash, computed_at = now()
+	`, internalID, hash)
+	return err
+}
@@ -0,0 +1,93 @@
+package repos
+
+import (
+	"time"
+	"unsafe"
+)
+
+// CacheStats describes one of the in-memory caches
+type CacheStats struct {
+	Entries int
+	// A rough estimate counting structs, strings and encoded bodies but not
+	// map overhead. Interned and shared strings are counted every time they're
+	// referenced, so it errs high.
+	Bytes int64
+}
+
+// CacheStats returns stats for each in-memory cache by name
+func (r *Repo) CacheStats() map[string]CacheStats {
+	s := r.snapshot.Load()
+	out := make(map[string]CacheStats, 4)
+
+	var regions CacheStats
+	for _, region := range s.regions {
+		regions.Entries++
+		regions.Bytes += int64(unsafe.Sizeof(region)) + int64(len(region.GeoJSON)) +
+			int64(len(region.Name)+len(region.LogoURL)) + mapLayerSize(region.MapLayer)
+		for _, variant := range region.seasonalMapLayers {
+			regions.Bytes += mapLayerSize(variant.layer)
+		}
+	}
+	out["regions"] = regions
+
+	var challenges CacheStats
+	for _, c := range s.challenges {
+		challenges.Entries++
+		challenges.Bytes += int64(unsafe.Sizeof(*c)) + int64(len(c.encodedHead)+len(c.encodedTail)) +
+			int64(len(c.ID)+len(c.Title)+len(c.Link)+len(c.Color)+len(c.Src.Regular.Src)+len(c.Src.Large.Src)+len(c.Src.Regular.BlurHash)) +
+			int64(len(c.Src.Sizes))*int64(unsafe.Sizeof(PictureSrc{})) +
+			int64(len(c.sizes.sizes))*int64(unsafe.Sizeof(compactSize{})) +
+			int64(len(c.Photographer.Icon)+len(c.Photographer.Text)+len(c.Photographer.Link))
+		challenges.Bytes += int64(len(c.sizes.prefix))
+		for _, size := range c.sizes.sizes {
+			challenges.Bytes += int64(len(size.suffix))
+		}
+	}
+	out["challenges"] = challenges
+
+	var regionsJSON CacheStats
+	if s.regionsJSON != nil {
+		s.regionsJSON.Range(func(_, v any) bool {
+			regionsJSON.Entries++
+			if encoded, ok := v.(EncodedRegions); ok {
+				regionsJSON.Bytes += int64(len(encoded.Body) + len(encoded.ETag))
+			}
+			return true
+		})
+	}
+	out["regions_json"] = regionsJSON
+
+	if r.descriptions != nil {
+		out["descriptions"] = r.descriptions.stats()
+	}
+	return out
+}
+
+func mapLayerSize(ml MapLayer) int64 {
+	return int64(unsafe.Sizeof(ml)) + int64(len(ml.CapabilitiesXML)+len(ml.Name)+len(ml.Layer)+len(ml.MatrixSet)) +
+		int64(len(ml.Resolutions)*8)
+}
+
+func (l *lru) stats() CacheStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := CacheStats{Entries: len(l.items)}
+	for key, el := range l.items {
+		out.Bytes += int64(len(key) + len(el.Value.(*lruEntry).value))
+	}
+	return out
+}
+
+// CacheAge returns how long ago regions and challenges were last loaded from
+// the database, or since startup if they never have been. Data restored from
+// a snapshot is older still.
+func (r *Repo) CacheAge() (regions time.Duration, challenges time.Duration) {
+	regionsAt, challengesAt := r.LastRefreshed()
+	if regionsAt.IsZero() {
+		regionsAt = r.startedAt
+	}
+	if challengesAt.IsZero() {
+		challengesAt = r.startedAt
+	}
+	return time.Since(regionsAt), time.Since(challengesAt)
+}
@@ -0,0 +1,36 @@
+package repos
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheStats(t *testing.T) {
+	r := &Repo{descriptions: newLRU(10), startedAt: time.Now().Add(-time.Hour)}
+	r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
+	r.storeRegions(map[int]Region{
+		1: {ID: "1", Name: "Lake District", MapLayer: MapLayer{ID: "7", CapabilitiesXML: testCapabilities}},
+	}, time.Now())
+	r.descriptions.add("abc", "<p>A fell</p>")
+
+	stats := r.CacheStats()
+	if stats["regions"].Entries != 1 || stats["regions"].Bytes < int64(len(testCapabilities)) {
+		t.Errorf("unexpected regions stats %+v", stats["regions"])
+	}
+	if stats["regions_json"].Entries != 1 || stats["regions_json"].Bytes == 0 {
+		t.Errorf("unexpected regions JSON stats %+v", stats["regions_json"])
+	}
+	if stats["descriptions"] != (CacheStats{Entries: 1, Bytes: int64(len("abc") + len("<p>A fell</p>"))}) {
+		t.Errorf("unexpected descriptions stats %+v", stats["descriptions"])
+	}
+
+	r.regionsRefreshedAt.Store(time.Now().Add(-time.Minute).UnixNano())
+	regions, challenges := r.CacheAge()
+	if regions < time.Minute || regions > 2*time.Minute {
+		t.Errorf("expected regions to be a minute old, got %s", regions)
+	}
+	if challenges < time.Hour {
+		t.Errorf("expected never refreshed challenges to be as old as the repo, got %s", challenges)
+	}
+}
@@ -0,0 +1,136 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+type Campaign struct {
+	RegionID    string     `json:"region_id"`
+	Total       int        `json:"total"`
+	Completed   int        `json:"completed"`
+	Next        *Challenge `json:"-"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+var CampaignNotFoundError = errors.New("campaign not found")
+var NotNextInCampaignError = errors.New("challenge is not next in campaign")
+
+// StartCampaign returns the player's campaign through the region, creating it
+// with a freshly shuffled order if they haven't started one
+func (r *Repo) StartCampaign(ctx context.Context, player string, regionID int) (Campaign, error) {
+	r.initWg.Wait()
+	list := r.snapshot.Load().challengesByRegion[regionID]
+	if len(list) == 0 {
+		return Campaign{}, NoChallengesAvailableError
+	}
+
+	order := make([]int64, 0, len(list))
+	for _, c := range list {
+		id, err := decodeChallengeID(c.ID)
+		if err != nil {
+			return Campaign{}, err
+		}
+		order = append(order, int64(id))
+	}
+	rand.Shuffle(len(order), func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO campaigns (player_id, region_id, challenge_order)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (player_id, region_id) DO NOTHING
+	`, player, regionID, order)
+	if err != nil {
+		return Campaign{}, err
+	}
+
+	return r.Campaign(ctx, player, regionID)
+}
+
+func (r *Repo) Campaign(ctx context.Context, player string, regionID int) (Campaign, error) {
+	c, _, err := r.loadCampaign(ctx, player, regionID)
+	return c, err
+}
+
+// AdvanceCampaign marks challengeID, which must be the next challenge in the
+// campaign, as completed
+func (r *Repo) AdvanceCampaign(ctx context.Context, player string, regionID int, challengeID string) (Campaign, error) {
+	c, nextIndex, err := r.loadCampaign(ctx, player, regionID)
+	if err != nil {
+		return Campaign{}, err
+	}
+	if c.Next == nil || c.Next.ID != challengeID {
+		return Campaign{}, NotNextInCampaignError
+	}
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE campaigns
+		SET cursor = $3 + 1,
+			completed_at = CASE WHEN $3 + 1 >= cardinality(challenge_order) THEN now() END
+		WHERE player_id = $1 AND region_id = $2 AND cursor <= $3
+	`, player, regionID, nextIndex)
+	if err != nil {
+		return Campaign{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		// Raced with another advance
+		return Campaign{}, NotNextInCampaignError
+	}
+
+	return r.Campaign(ctx, player, regionID)
+}
+
+// loadCampaign also returns the position of the next challenge. Challenges
+// retired since the campaign started count as done, and a campaign with only
+// retired challenges left is marked completed.
+func (r *Repo) loadCampaign(ctx context.Context, player string, regionID int) (Campaign, int, error) {
+	r.initWg.Wait()
+
+	var order []int64
+	var cursor int
+	c := Campaign{RegionID: strconv.Itoa(regionID)}
+	err := r.db.QueryRow(ctx, `
+		SELECT challenge_order, cursor, started_at, completed_at
+		FROM campaigns
+		WHERE player_id = $1 AND region_id = $2
+	`, player, regionID).Scan(&order, &cursor, &c.StartedAt, &c.CompletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Campaign{}, 0, CampaignNotFoundError
+	} else if err != nil {
+		return Campaign{}, 0, err
+	}
+
+	c.Total = len(order)
+	c.Completed = cursor
+
+	challenges := r.snapshot.Load().challenges
+	for i := cursor; i < len(order); i++ {
+		if next, ok := challenges[int(order[i])]; ok {
+			v := *next
+			c.Next = &v
+			c.Completed = i
+			return c, i, nil
+		}
+	}
+
+	c.Completed = len(order)
+	if c.CompletedAt == nil {
+		err := r.db.QueryRow(ctx, `
+			UPDATE campaigns
+			SET cursor = cardinality(challenge_order), completed_at = coalesce(completed_at, now())
+			WHERE player_id = $1 AND region_id = $2
+			RETURNING completed_at
+		`, player, regionID).Scan(&c.CompletedAt)
+		if err != nil {
+			return Campaign{}, 0, err
+		}
+	}
+	return c, len(order), nil
+}
@@ -0,0 +1,382 @@
+package repos
+
+import (
+	"context"
+	"contourguessr-api/wmts"
+	"errors"
+	"fmt"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/jackc/pgx/v4"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Refreshes of layers we already have a copy of are spread out so that we
+// don't hit every provider at the same instant
+const capabilitiesJitter = 30 * time.Second
+
+type cachedCapabilities struct {
+	url string
+	xml string
+}
+
+// capabilitiesUpdater fetches capabilities in the background after each
+// regions refresh. Until a fresh copy arrives regions are served with the
+// previous one. Regions whose layer has never been fetched are left out of
+// the regions list. Each fetch is diffed against the last copy, which is kept
+// in the database so that changes made while the server was down are caught
+// too.
+func (r *Repo) capabilitiesUpdater(ctx context.Context) {
+	defer r.closeWg.Done()
+
+	c := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	for {
+		select {
+		case <-r.capabilitiesStale:
+		case <-ctx.Done():
+			slog.Info("cancelling capabilities updater")
+			return
+		}
+
+		var wg sync.WaitGroup
+		for id, url := range r.capabilitiesURLs() {
+			r.capabilitiesMu.Lock()
+			_, haveCopy := r.capabilities[id]
+			r.capabilitiesMu.Unlock()
+
+			wg.Add(1)
+			go func(id int, url string, haveCopy bool) {
+				defer wg.Done()
+
+				if haveCopy {
+					select {
+					case <-time.After(time.Duration(rand.Int63n(int64(capabilitiesJitter)))):
+					case <-ctx.Done():
+						return
+					}
+				}
+
+				var attempts []time.Duration
+				xml, err := fetchCapabilities(ctx, c, url, &attempts)
+				if ctx.Err() != nil {
+					return
+				}
+				r.observeCapabilitiesFetches(id, attempts, err, haveCopy)
+				if err != nil {
+					slog.Error("error fetching capabilities", "map_layer", id, "url", url, "err", err, "dropped", !haveCopy)
+					return
+				}
+
+				r.capabilitiesMu.Lock()
+				previous, hadPrevious := r.capabilities[id]
+				r.capabilities[id] = cachedCapabilities{url: url, xml: xml}
+				r.capabilitiesMu.Unlock()
+				stored, hadStored, err := r.storedCapabilities(ctx, id)
+				if err != nil {
+					slog.Error("error loading stored capabilities", "map_layer", id, "err", err)
+				}
+				if !hadPrevious && hadStored {
+					// First fetch since starting without a snapshot
+					previous, hadPrevious = stored, true
+				}
+				if hadPrevious && previous.xml != xml {
+					r.diffCapabilities(id, url, previous.xml, xml)
+				}
+				if !hadStored || stored.xml != xml || stored.url != url {
+					if err := r.storeCapabilities(ctx, id, url, xml); err != nil {
+						slog.Error("error storing capabilities", "map_layer", id, "err", err)
+					}
+				}
+
+				r.writeMu.Lock()
+				next := *r.snapshot.Load()
+				next.regions = r.applyCapabilities(next.regions)
+				next.regionsJSON = newRegionsJSONCache(next.regions)
+				r.snapshot.Store(&next)
+				r.writeMu.Unlock()
+				r.persistedDirty.Store(true)
+			}(id, url, haveCopy)
+		}
+		wg.Wait()
+	}
+}
+
+// Results of a capabilities fetch attempt
+const (
+	CapabilitiesFetchSuccess = "success"
+	// Failed but will be retried
+	CapabilitiesFetchRetrying = "retrying"
+	// Gave up, the previously fetched copy is still served
+	CapabilitiesFetchStale = "stale"
+	// Gave up with no previous copy, so regions using the layer are left out
+	CapabilitiesFetchDropped = "dropped"
+)
+
+// CapabilitiesFetch is one attempt at fetching a map layer's capabilities
+type CapabilitiesFetch struct {
+	MapLayerID int
+	Duration   time.Duration
+	Result     string
+}
+
+// WithCapabilitiesObserver calls observe after every attempt at fetching a
+// map layer's capabilities
+func WithCapabilitiesObserver(observe func(CapabilitiesFetch)) Option {
+	return func(r *Repo) {
+		r.capabilitiesObserver = observe
+	}
+}
+
+// WithCapabilitiesURLs fetches each map layer's capabilities from the URL
+// returned by url instead of the one in the database, such as a wmtstest
+// server's
+func WithCapabilitiesURLs(url func(layer string, matrixSet string) string) Option {
+	return func(r *Repo) {
+		r.capabilitiesURLFunc = url
+	}
+}
+
+func (r *Repo) observeCapabilitiesFetches(id int, attempts []time.Duration, err error, haveCopy bool) {
+	if r.capabilitiesObserver == nil {
+		return
+	}
+	for i, d := range attempts {
+		result := CapabilitiesFetchRetrying
+		if i == len(attempts)-1 {
+			switch {
+			case err == nil:
+				result = CapabilitiesFetchSuccess
+			case haveCopy:
+				result = CapabilitiesFetchStale
+			default:
+				result = CapabilitiesFetchDropped
+			}
+		}
+		r.capabilitiesObserver(CapabilitiesFetch{MapLayerID: id, Duration: d, Result: result})
+	}
+}
+
+// CapabilitiesChange records structural changes a provider made to a layer's
+// capabilities between refreshes
+type CapabilitiesChange struct {
+	MapLayerID int           `json:"map_layer_id"`
+	URL        string        `json:"url"`
+	DetectedAt time.Time     `json:"detected_at"`
+	Changes    []wmts.Change `json:"changes"`
+	// Set if the changes touch the layer or tile matrix set we display, or
+	// the new document couldn't be parsed
+	Breaking bool   `json:"breaking"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Older changes are dropped, they'll be in the logs
+const maxCapabilitiesChanges = 100
+
+// CapabilitiesChanges returns recently detected changes, newest first
+func (r *Repo) CapabilitiesChanges() []CapabilitiesChange {
+	r.capabilitiesMu.Lock()
+	defer r.capabilitiesMu.Unlock()
+	out := make([]CapabilitiesChange, len(r.capabilitiesChanges))
+	for i, c := range r.capabilitiesChanges {
+		out[len(out)-1-i] = c
+	}
+	return out
+}
+
+// BreakingCapabilitiesChanges counts breaking changes detected since startup
+func (r *Repo) BreakingCapabilitiesChanges() uint64 {
+	return r.breakingCapabilitiesChanges.Load()
+}
+
+func (r *Repo) diffCapabilities(id int, url string, oldXML string, newXML string) {
+	change := CapabilitiesChange{MapLayerID: id, URL: url, DetectedAt: time.Now()}
+
+	newCaps, err := wmts.Parse(newXML)
+	if err != nil {
+		change.Breaking = true
+		change.Error = err.Error()
+	} else if oldCaps, err := wmts.Parse(oldXML); err == nil {
+		change.Changes = wmts.Diff(oldCaps, newCaps)
+		for _, ml := range r.mapLayers(id) {
+			for _, c := range change.Changes {
+				if c.Layer == ml.Layer || c.TileMatrixSet == ml.MatrixSet {
+					change.Breaking = true
+				}
+			}
+		}
+	}
+	if len(change.Changes) == 0 && change.Error == "" {
+		// Only cosmetic edits
+		return
+	}
+
+	summary := make([]string, 0, len(change.Changes))
+	for _, c := range change.Changes {
+		summary = append(summary, c.String())
+	}
+	if change.Breaking {
+		r.breakingCapabilitiesChanges.Add(1)
+		slog.Error("breaking change to capabilities", "map_layer", id, "url", url, "changes", summary, "err", change.Error)
+	} else {
+		slog.Warn("change to capabilities", "map_layer", id, "url", url, "changes", summary)
+	}
+
+	r.capabilitiesMu.Lock()
+	r.capabilitiesChanges = append(r.capabilitiesChanges, change)
+	if len(r.capabilitiesChanges) > maxCapabilitiesChanges {
+		r.capabilitiesChanges = r.capabilitiesChanges[len(r.capabilitiesChanges)-maxCapabilitiesChanges:]
+	}
+	r.capabilitiesMu.Unlock()
+}
+
+// storedCapabilities is the copy last saved by storeCapabilities, which may
+// be from before a restart
+func (r *Repo) storedCapabilities(ctx context.Context, id int) (cachedCapabilities, bool, error) {
+	var c cachedCapabilities
+	err := r.db.QueryRow(ctx, `SELECT url, xml FROM map_layer_capabilities WHERE map_layer_id = $1`, id).Scan(&c.url, &c.xml)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return cachedCapabilities{}, false, nil
+	} else if err != nil {
+		return cachedCapabilities{}, false, err
+	}
+	return c, true, nil
+}
+
+func (r *Repo) storeCapabilities(ctx context.Context, id int, url string, xml string) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO map_layer_capabilities (map_layer_id, url, xml)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (map_layer_id) DO UPDATE SET url = excluded.url, xml = excluded.xml, fetched_at = now()
+	`, id, url, xml)
+	return err
+}
+
+// mapLayers returns every use of the map layer, which seasonal variants can
+// share
+func (r *Repo) mapLayers(id int) []MapLayer {
+	var out []MapLayer
+	check := func(ml MapLayer) {
+		if ml.ID == strconv.Itoa(id) {
+			out = append(out, ml)
+		}
+	}
+	for _, region := range r.snapshot.Load().regions {
+		check(region.MapLayer)
+		for _, variant := range region.seasonalMapLayers {
+			check(variant.layer)
+		}
+	}
+	return out
+}
+
+func (r *Repo) capabilitiesURLs() map[int]string {
+	out := make(map[int]string)
+	add := func(ml MapLayer) {
+		if ml.capabilitiesURL == "" {
+			return
+		}
+		if id, err := strconv.Atoi(ml.ID); err == nil {
+			out[id] = ml.capabilitiesURL
+		}
+	}
+	for _, region := range r.snapshot.Load().regions {
+		add(region.MapLayer)
+		for _, variant := range region.seasonalMapLayers {
+			add(variant.layer)
+		}
+	}
+	return out
+}
+
+// applyCapabilities returns a copy of regions with the latest capabilities
+// filled in. Layers without a cached copy keep whatever they had.
+func (r *Repo) applyCapabilities(regions map[int]Region) map[int]Region {
+	r.capabilitiesMu.Lock()
+	defer r.capabilitiesMu.Unlock()
+	if r.capabilities == nil {
+		r.capabilities = make(map[int]cachedCapabilities)
+	}
+
+	apply := func(ml MapLayer) MapLayer {
+		id, err := strconv.Atoi(ml.ID)
+		if err != nil {
+			return ml
+		}
+		if cached, ok := r.capabilities[id]; ok {
+			ml.CapabilitiesXML = cached.xml
+		} else if ml.CapabilitiesXML != "" {
+			// Restored from a snapshot
+			r.capabilities[id] = cachedCapabilities{xml: ml.CapabilitiesXML}
+		}
+		return ml
+	}
+
+	out := make(map[int]Region, len(regions))
+	for id, region := range regions {
+		region.MapLayer = apply(region.MapLayer)
+		if region.seasonalMapLayers != nil {
+			variants := make([]seasonalMapLayer, len(region.seasonalMapLayers))
+			for i, variant := range region.seasonalMapLayers {
+				variant.layer = apply(variant.layer)
+				variants[i] = variant
+			}
+			region.seasonalMapLayers = variants
+		}
+		out[id] = region
+	}
+	return out
+}
+
+// fetchCapabilities appends the duration of each attempt to attempts
+func fetchCapabilities(ctx context.Context, c *http.Client, url string, attempts *[]time.Duration) (string, error) {
+	var out string
+	err := backoff.Retry(func() error {
+		start := time.Now()
+		defer func() { *attempts = append(*attempts, time.Since(start)) }()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", "github.com/dzfranklin/contourguessr")
+
+		slog.Info("fetching capabilities", "url", url)
+
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			var body string
+			if v, err := io.ReadAll(resp.Body); err == nil {
+				body = string(v)
+			} else {
+				body = fmt.Sprintf("<error reading body: %v>", err)
+			}
+			err = fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+			return err
+		}
+
+		xml, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+
+		if !utf8.Valid(xml) {
+			return errors.New("invalid utf-8")
+		}
+
+		out = string(xml)
+		return nil
+	}, backoff.NewExponentialBackOff(backoff.WithMaxElapsedTime(1*time.Minute)))
+	return out, err
+}
@@ -0,0 +1,70 @@
+package repos
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testCapabilities = `<Capabilities xmlns="http://www.opengis.net/wmts/1.0" xmlns:ows="http://www.opengis.net/ows/1.1">
+  <Contents>
+    <Layer>
+      <ows:Identifier>Outdoor</ows:Identifier>
+      <TileMatrixSetLink><TileMatrixSet>EPSG:27700</TileMatrixSet></TileMatrixSetLink>
+      <ResourceURL format="image/png" resourceType="tile" template="https://tiles.example/v1/{TileMatrix}.png"/>
+    </Layer>
+    <Layer>
+      <ows:Identifier>Road</ows:Identifier>
+      <TileMatrixSetLink><TileMatrixSet>EPSG:3857</TileMatrixSet></TileMatrixSetLink>
+    </Layer>
+  </Contents>
+</Capabilities>`
+
+func TestDiffCapabilities(t *testing.T) {
+	r := &Repo{descriptions: newLRU(10)}
+	r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
+	r.storeRegions(map[int]Region{
+		1: {ID: "1", MapLayer: MapLayer{ID: "7", Layer: "Outdoor", MatrixSet: "EPSG:27700", CapabilitiesXML: testCapabilities}},
+	}, time.Now())
+
+	r.diffCapabilities(7, "https://example/caps", testCapabilities, strings.Replace(testCapabilities, "<ows:Identifier>Road</ows:Identifier>", "<ows:Identifier>Roads</ows:Identifier>", 1))
+	r.diffCapabilities(7, "https://example/caps", testCapabilities, strings.Replace(testCapabilities, "/v1/", "/v2/", 1))
+	r.diffCapabilities(7, "https://example/caps", testCapabilities, "<Capabilities>")
+
+	changes := r.CapabilitiesChanges()
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 recorded changes, got %+v", changes)
+	}
+	if !changes[0].Breaking || changes[0].Error == "" {
+		t.Errorf("expected unparseable document to be breaking, got %+v", changes[0])
+	}
+	if !changes[1].Breaking {
+		t.Errorf("expected template change on the layer in use to be breaking, got %+v", changes[1])
+	}
+	if changes[2].Breaking || len(changes[2].Changes) != 2 {
+		t.Errorf("expected other layer changes to be non-breaking, got %+v", changes[2])
+	}
+	if r.BreakingCapabilitiesChanges() != 2 {
+		t.Errorf("expected 2 breaking changes counted, got %d", r.BreakingCapabilitiesChanges())
+	}
+}
+
+func TestObserveCapabilitiesFetches(t *testing.T) {
+	var got []string
+	r := &Repo{}
+	WithCapabilitiesObserver(func(f CapabilitiesFetch) {
+		got = append(got, f.Result)
+	})(r)
+
+	attempts := []time.Duration{time.Second, time.Second, time.Second}
+	r.observeCapabilitiesFetches(7, attempts, nil, false)
+	r.observeCapabilitiesFetches(7, attempts[:1], errors.New("timeout"), true)
+	r.observeCapabilitiesFetches(7, attempts[:2], errors.New("timeout"), false)
+
+	want := []string{"retrying", "retrying", "success", "stale", "retrying", "dropped"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -1,35 +1,128 @@
 package repos
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base32"
 	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
 )
 
 var encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
 var endianness = binary.BigEndian
 
-func encodeChallengeID(id int) string {
-	if id <= 0 || id >= 0xFFFFFFFF {
-		panic("id out of bounds")
+// InvalidChallengeIDError is returned for IDs that can't refer to a challenge.
+// They're treated like challenges that don't exist.
+var InvalidChallengeIDError = fmt.Errorf("%w: invalid ID", ChallengeNotFoundError)
+
+// LegacyChallengeIDError is returned once legacy IDs are no longer accepted.
+// They're treated like challenges that don't exist.
+var LegacyChallengeIDError = fmt.Errorf("%w: legacy IDs are no longer accepted", ChallengeNotFoundError)
+
+// Keyed IDs encode all four bytes of internal IDs that fit in 32 bits and all
+// eight otherwise, after a prefix that's outside the legacy IDs' alphabet. The
+// lengths alone would be ambiguous, as legacy IDs reach seven characters at
+// 1<<24 and thirteen at 1<<56.
+const (
+	keyedChallengeIDPrefix = "0"
+	keyedChallengeIDLen    = 7
+	keyedChallengeID64Len  = 13
+)
+
+var challengeIDs struct {
+	key         []byte
+	legacyUntil time.Time
+}
+
+// ConfigureChallengeIDs switches to IDs that are a keyed permutation of the
+// internal ID, so that the catalog can't be enumerated by counting. Legacy
+// IDs are still accepted until legacyUntil, so that shared links survive the
+// switch, but are no longer handed out. It must be called before any repo is
+// created, and a nil key keeps the legacy IDs.
+func ConfigureChallengeIDs(key []byte, legacyUntil time.Time) {
+	challengeIDs.key = key
+	challengeIDs.legacyUntil = legacyUntil
+}
+
+// encodeChallengeID accepts any positive internal ID. Legacy IDs are the
+// big-endian bytes without leading zeros, so they grow with the ID.
+func encodeChallengeID(id int) (string, error) {
+	if id <= 0 {
+		return "", fmt.Errorf("challenge ID %d out of range", id)
 	}
 
-	bytes := make([]byte, 4)
-	endianness.PutUint32(bytes[:], uint32(id))
+	if challengeIDs.key != nil {
+		if id <= math.MaxUint32 {
+			return keyedChallengeIDPrefix + encoding.EncodeToString(endianness.AppendUint32(nil, uint32(permuteChallengeID(uint64(id), 32, false)))), nil
+		}
+		return keyedChallengeIDPrefix + encoding.EncodeToString(endianness.AppendUint64(nil, permuteChallengeID(uint64(id), 64, false))), nil
+	}
 
+	bytes := endianness.AppendUint64(nil, uint64(id))
 	for bytes[0] == 0 {
 		bytes = bytes[1:]
 	}
 
-	return encoding.EncodeToString(bytes[:])
+	return encoding.EncodeToString(bytes), nil
 }
 
 func decodeChallengeID(id string) (int, error) {
+	id, keyed := strings.CutPrefix(id, keyedChallengeIDPrefix)
+	if keyed && (challengeIDs.key == nil || (len(id) != keyedChallengeIDLen && len(id) != keyedChallengeID64Len)) {
+		return 0, InvalidChallengeIDError
+	}
+	if challengeIDs.key != nil && !keyed && !time.Now().Before(challengeIDs.legacyUntil) {
+		return 0, LegacyChallengeIDError
+	}
 	bytes, err := encoding.DecodeString(id)
-	if err != nil {
-		return 0, err
+	if err != nil || len(bytes) == 0 || len(bytes) > 8 {
+		return 0, InvalidChallengeIDError
 	}
-	for len(bytes) < 4 {
+	for len(bytes) < 8 {
 		bytes = append([]byte{0}, bytes...)
 	}
-	return int(endianness.Uint32(bytes)), nil
+	decoded := endianness.Uint64(bytes)
+	if keyed && len(id) == keyedChallengeIDLen {
+		decoded = permuteChallengeID(decoded, 32, true)
+	} else if keyed {
+		decoded = permuteChallengeID(decoded, 64, true)
+	}
+	if decoded == 0 || decoded > math.MaxInt {
+		return 0, InvalidChallengeIDError
+	}
+	return int(decoded), nil
+}
+
+// permuteChallengeID is a four round Feistel network over the halves of the
+// low bits of id, with HMAC-SHA256 as the round function
+func permuteChallengeID(id uint64, bits int, inverse bool) uint64 {
+	const rounds = 4
+	half := bits / 2
+	mask := uint64(1)<<half - 1
+	l, r := id>>half&mask, id&mask
+	for i := 0; i < rounds; i++ {
+		round := i
+		if inverse {
+			round = rounds - 1 - i
+			l, r = r^challengeIDRound(round, l, half), l
+		} else {
+			l, r = r, l^challengeIDRound(round, r, half)
+		}
+	}
+	return l<<half | r
+}
+
+func challengeIDRound(round int, v uint64, bits int) uint64 {
+	mac := hmac.New(sha256.New, challengeIDs.key)
+	in := []byte{byte(round)}
+	if bits == 16 {
+		in = endianness.AppendUint16(in, uint16(v))
+	} else {
+		in = endianness.AppendUint32(in, uint32(v))
+	}
+	mac.Write(in)
+	return endianness.Uint64(mac.Sum(nil)) >> (64 - bits)
 }
@@ -1,11 +1,19 @@
 package repos
 
-import "testing"
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
 
 func TestChallengeID(t *testing.T) {
 	t.Run("round trip", func(t *testing.T) {
-		for i := 0; i < 1000; i++ {
-			encoded := encodeChallengeID(i)
+		for i := 1; i < 1000; i++ {
+			encoded, err := encodeChallengeID(i)
+			if err != nil {
+				t.Fatal(err)
+			}
 			decoded, err := decodeChallengeID(encoded)
 			if err != nil {
 				t.Fatal(err)
@@ -24,10 +32,12 @@ func TestChallengeID(t *testing.T) {
 			{1, "ae"},
 			{42, "fi"},
 			{2048, "baaa"},
+			{1 << 40, "aeaaaaaaaa"},
+			{math.MaxInt64, "p777777777776"},
 		}
 		for _, test := range tests {
 			t.Logf("testing %+v", test)
-			encoded := encodeChallengeID(test.decoded)
+			encoded := testChallengeID(test.decoded)
 			if encoded != test.encoded {
 				t.Errorf("encode: expected %s, got %s", test.encoded, encoded)
 			}
@@ -39,4 +49,97 @@ func TestChallengeID(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("out of range", func(t *testing.T) {
+		for _, id := range []int{0, -1} {
+			if _, err := encodeChallengeID(id); err == nil {
+				t.Errorf("expected %d to be rejected", id)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		// Empty, not base32, more than eight bytes and negative
+		for _, id := range []string{"", "a1", "aaaaaaaaaaaaaaaa", "777777777777y"} {
+			if _, err := decodeChallengeID(id); !errors.Is(err, ChallengeNotFoundError) {
+				t.Errorf("%q: expected not found, got %v", id, err)
+			}
+		}
+	})
+}
+
+func testChallengeID(id int) string {
+	encoded, err := encodeChallengeID(id)
+	if err != nil {
+		panic(err)
+	}
+	return encoded
+}
+
+func TestKeyedChallengeID(t *testing.T) {
+	t.Cleanup(func() { ConfigureChallengeIDs(nil, time.Time{}) })
+	ConfigureChallengeIDs([]byte("test key"), time.Now().Add(time.Hour))
+
+	seen := make(map[string]bool)
+	ids := []int{math.MaxUint32, 1 << 32, math.MaxInt64}
+	for i := 1; i < 1000; i++ {
+		ids = append(ids, i)
+	}
+	for _, i := range ids {
+		encoded := testChallengeID(i)
+		want := len(keyedChallengeIDPrefix) + keyedChallengeIDLen
+		if i > math.MaxUint32 {
+			want = len(keyedChallengeIDPrefix) + keyedChallengeID64Len
+		}
+		if len(encoded) != want {
+			t.Fatalf("expected %d characters for %d, got %s", want, i, encoded)
+		}
+		if seen[encoded] {
+			t.Fatalf("%s encoded twice", encoded)
+		}
+		seen[encoded] = true
+		decoded, err := decodeChallengeID(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != decoded {
+			t.Fatalf("expected %d, got %d", i, decoded)
+		}
+	}
+	if testChallengeID(1) == "0aaaaaae" || testChallengeID(2)[:7] == testChallengeID(1)[:7] {
+		t.Error("expected keyed IDs not to follow the internal ID")
+	}
+
+	t.Run("legacy IDs in the window", func(t *testing.T) {
+		// The same lengths as keyed IDs, without the prefix
+		for encoded, want := range map[string]int{"fi": 42, "aeaaaaa": 1 << 24, "aeaaaaaaaaaaa": 1 << 56} {
+			if decoded, err := decodeChallengeID(encoded); err != nil || decoded != want {
+				t.Errorf("%s: expected %d, got %d, %v", encoded, want, decoded, err)
+			}
+		}
+	})
+
+	t.Run("malformed keyed IDs", func(t *testing.T) {
+		for _, id := range []string{"0", "0fi", "0aeaaaaaaaa"} {
+			if _, err := decodeChallengeID(id); !errors.Is(err, ChallengeNotFoundError) {
+				t.Errorf("%q: expected not found, got %v", id, err)
+			}
+		}
+	})
+
+	t.Run("legacy IDs after the window", func(t *testing.T) {
+		ConfigureChallengeIDs([]byte("test key"), time.Now().Add(-time.Hour))
+		if _, err := decodeChallengeID("fi"); !errors.Is(err, ChallengeNotFoundError) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("another key", func(t *testing.T) {
+		ConfigureChallengeIDs([]byte("test key"), time.Time{})
+		encoded := testChallengeID(42)
+		ConfigureChallengeIDs([]byte("another key"), time.Time{})
+		if decoded, _ := decodeChallengeID(encoded); decoded == 42 {
+			t.Error("expected a different key to permute differently")
+		}
+	})
 }
@@ -0,0 +1,188 @@
+package repos
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"log/slog"
+	"sync"
+)
+
+// Descriptions are the bulk of a challenge's memory and are only needed for
+// the challenges actually being served, so the cache leaves them out and loads
+// them on demand through a bounded LRU.
+const descriptionCacheSize = 4096
+
+// interner dedupes strings repeated across challenges, such as photographer
+// details and region IDs, while building a snapshot
+type interner map[string]string
+
+func (in interner) intern(s string) string {
+	if v, ok := in[s]; ok {
+		return v
+	}
+	in[s] = s
+	return s
+}
+
+var descriptionMarker = []byte(`"description_html":""`)
+
+// servedChallenge is a challenge as it's served, with its description, every
+// size and links through the outbound redirect
+func servedChallenge(c Challenge, description string, outboundPrefix string) Challenge {
+	c.DescriptionHTML = description
+	c.Src.Sizes = c.PictureSizes()
+	c.Link = outboundLink(outboundPrefix, c.Link, c.ID)
+	c.Photographer.Link = outboundLink(outboundPrefix, c.Photographer.Link, c.ID)
+	return c
+}
+
+// encodeChallenge splits the encoding of a served challenge without its
+// description so that the description can be spliced in when it is served.
+// The encoding holds a second copy of the challenge's strings, which is paid
+// for by compactSizes.
+func encodeChallenge(c *Challenge, outboundPrefix string) error {
+	b, err := json.Marshal(servedChallenge(*c, "", outboundPrefix))
+	if err != nil {
+		return err
+	}
+	// Earlier fields are JSON strings, which escape quotes, so the first match
+	// is the field itself
+	i := bytes.Index(b, descriptionMarker)
+	if i < 0 {
+		return errors.New("description_html missing from encoded challenge")
+	}
+	split := i + len(descriptionMarker) - len(`""`)
+	c.encodedHead = b[:split]
+	c.encodedTail = b[split+len(`""`):]
+	return nil
+}
+
+// spliceChallenge encodes a challenge with its description, falling back to
+// encoding it in full when it wasn't encoded as it was cached
+func spliceChallenge(c Challenge, description string, outboundPrefix string) ([]byte, error) {
+	if c.encodedHead == nil {
+		return json.Marshal(servedChallenge(c, description, outboundPrefix))
+	}
+	encodedDescription, err := json.Marshal(description)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(c.encodedHead)+len(encodedDescription)+len(c.encodedTail))
+	out = append(out, c.encodedHead...)
+	out = append(out, encodedDescription...)
+	out = append(out, c.encodedTail...)
+	return out, nil
+}
+
+// ServedChallenge returns the challenge as served, including its description
+func (r *Repo) ServedChallenge(ctx context.Context, c Challenge) Challenge {
+	description, err := r.ChallengeDescription(ctx, c.ID)
+	if err != nil {
+		// Serve the rest of the challenge when the database is unreachable
+		slog.ErrorContext(ctx, "error getting description", "challenge", c.ID, "err", err)
+		description = ""
+	}
+	return servedChallenge(c, description, r.outboundPrefix)
+}
+
+// ChallengeJSON returns the encoded challenge including its description
+func (r *Repo) ChallengeJSON(ctx context.Context, c Challenge) ([]byte, error) {
+	description, err := r.ChallengeDescription(ctx, c.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error getting description", "challenge", c.ID, "err", err)
+		description = ""
+	}
+	return spliceChallenge(c, description, r.outboundPrefix)
+}
+
+func (r *Repo) ChallengeDescription(ctx context.Context, id string) (string, error) {
+	if v, ok := r.descriptions.get(id); ok {
+		return v, nil
+	}
+
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return "", err
+	}
+
+	var description string
+	err = r.db.QueryRow(ctx, `SELECT description_html FROM challenges WHERE id = $1`, internalID).Scan(&description)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ChallengeNotFoundError
+	} else if err != nil {
+		return "", err
+	}
+
+	r.descriptions.add(id, description)
+	return description, nil
+}
+
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value string
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (l *lru) get(key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return "", false
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true
+}
+
+func (l *lru) add(key string, value string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		el.Value.(*lruEntry).value = value
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.order.Remove(el)
+		delete(l.items, key)
+	}
+}
+
+// forgetEditedDescriptions drops the cached descriptions of challenges that
+// were removed or whose description changed between snapshots
+func (r *Repo) forgetEditedDescriptions(prev map[int]*Challenge, next map[int]*Challenge) {
+	for id, old := range prev {
+		if c, ok := next[id]; !ok || c.descriptionHash != old.descriptionHash {
+			r.descriptions.remove(old.ID)
+		}
+	}
+}
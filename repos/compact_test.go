@@ -0,0 +1,98 @@
+package repos
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestServedChallenge(t *testing.T) {
+	c := Challenge{ID: "ae", Title: `tricky "description_html":"" title`}
+	b, err := json.Marshal(servedChallenge(c, "<p>hello</p>", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Challenge
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid json %s: %v", b, err)
+	}
+	if got.Title != c.Title || got.DescriptionHTML != "<p>hello</p>" {
+		t.Errorf("unexpected round trip %+v", got)
+	}
+}
+
+func TestEncodeChallenge(t *testing.T) {
+	c := &Challenge{ID: "ae", Title: `tricky "description_html":"" title`, Link: "https://www.flickr.com/photos/x/1"}
+	if err := encodeChallenge(c, "https://api.example.com/out"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := spliceChallenge(*c, "<p>hello</p>", "https://api.example.com/out")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := json.Marshal(servedChallenge(*c, "<p>hello</p>", "https://api.example.com/out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(want) {
+		t.Errorf("expected the spliced encoding to match the served challenge\n got %s\nwant %s", b, want)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	l := newLRU(2)
+	l.add("a", "1")
+	l.add("b", "2")
+	l.get("a")
+	l.add("c", "3")
+
+	if _, ok := l.get("b"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if v, ok := l.get("a"); !ok || v != "1" {
+		t.Error("expected a to be kept")
+	}
+	if v, ok := l.get("c"); !ok || v != "3" {
+		t.Error("expected c to be kept")
+	}
+}
+
+func TestServedChallengeOutboundLinks(t *testing.T) {
+	c := Challenge{ID: "ae", Link: "https://www.flickr.com/photos/x/1"}
+	got := servedChallenge(c, "", "https://api.example.com/out")
+	want := "https://api.example.com/out?challenge=ae&target=https%3A%2F%2Fwww.flickr.com%2Fphotos%2Fx%2F1"
+	if got.Link != want {
+		t.Errorf("expected link %s, got %s", want, got.Link)
+	}
+	if c.Link != "https://www.flickr.com/photos/x/1" {
+		t.Error("expected cached challenge to keep the raw link")
+	}
+}
+
+func TestForgetEditedDescriptions(t *testing.T) {
+	r := &Repo{descriptions: newLRU(10)}
+	prev := map[int]*Challenge{
+		1: {ID: "a", descriptionHash: 1},
+		2: {ID: "b", descriptionHash: 2},
+		3: {ID: "c", descriptionHash: 3},
+	}
+	next := map[int]*Challenge{
+		1: {ID: "a", descriptionHash: 1},
+		2: {ID: "b", descriptionHash: 20},
+	}
+	for _, c := range prev {
+		r.descriptions.add(c.ID, "<p>"+c.ID+"</p>")
+	}
+	r.forgetEditedDescriptions(prev, next)
+
+	if _, ok := r.descriptions.get("a"); !ok {
+		t.Error("expected the unchanged description to stay cached")
+	}
+	if _, ok := r.descriptions.get("b"); ok {
+		t.Error("expected the edited description to be dropped")
+	}
+	if _, ok := r.descriptions.get("c"); ok {
+		t.Error("expected the removed challenge's description to be dropped")
+	}
+}
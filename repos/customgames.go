@@ -0,0 +1,206 @@
+package repos
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"math/big"
+	mathrand "math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const MaxCustomGameChallenges = 50
+
+// ChallengeFilter narrows SearchChallenges. Empty fields match everything.
+type ChallengeFilter struct {
+	RegionIDs []int
+	// Meteorological seasons of the date taken, like "winter"
+	Seasons []string
+	// Matched case-insensitively against the title
+	Query string
+	// Like "ridge", see elevation.Terrain. Challenges without terrain don't
+	// match.
+	Landforms []string
+}
+
+type CustomGame struct {
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// Challenges retired since the game was created are left out
+	Challenges []Challenge `json:"-"`
+}
+
+var CustomGameNotFoundError = errors.New("custom game not found")
+var InvalidCustomGameError = errors.New("invalid custom game")
+
+func challengeSeason(t time.Time) string {
+	switch t.Month() {
+	case time.December, time.January, time.February:
+		return "winter"
+	case time.March, time.April, time.May:
+		return "spring"
+	case time.June, time.July, time.August:
+		return "summer"
+	default:
+		return "autumn"
+	}
+}
+
+// SearchChallenges returns challenges matching f in a stable order
+func (r *Repo) SearchChallenges(f ChallengeFilter, limit int) []Challenge {
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+
+	var candidates []*Challenge
+	if len(f.RegionIDs) > 0 {
+		for _, id := range f.RegionIDs {
+			candidates = append(candidates, s.challengesByRegion[id]...)
+		}
+	} else {
+		candidates = make([]*Challenge, 0, len(s.challenges))
+		for _, c := range s.challenges {
+			candidates = append(candidates, c)
+		}
+	}
+
+	query := strings.ToLower(f.Query)
+	out := make([]Challenge, 0)
+	for _, c := range candidates {
+		if query != "" && !strings.Contains(strings.ToLower(c.Title), query) {
+			continue
+		}
+		if len(f.Seasons) > 0 {
+			if c.DateTaken == nil {
+				continue
+			}
+			season := challengeSeason(*c.DateTaken)
+			match := false
+			for _, want := range f.Seasons {
+				match = match || want == season
+			}
+			if !match {
+				continue
+			}
+		}
+		if len(f.Landforms) > 0 && (c.Terrain == nil || !slices.Contains(f.Landforms, c.Terrain.Landform)) {
+			continue
+		}
+		out = append(out, *c)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		a, _ := decodeChallengeID(out[i].ID)
+		b, _ := decodeChallengeID(out[j].ID)
+		return a < b
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// CreateCustomGame freezes challengeIDs, in order, under a new share code
+func (r *Repo) CreateCustomGame(ctx context.Context, creator string, name string, challengeIDs []string) (CustomGame, error) {
+	if len(challengeIDs) == 0 || len(challengeIDs) > MaxCustomGameChallenges {
+		return CustomGame{}, InvalidCustomGameError
+	}
+
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+	internalIDs := make([]int64, 0, len(challengeIDs))
+	seen := make(map[int]bool)
+	for _, id := range challengeIDs {
+		internalID, err := decodeChallengeID(id)
+		if err != nil {
+			return CustomGame{}, InvalidCustomGameError
+		}
+		if _, ok := s.challenges[internalID]; !ok || seen[internalID] {
+			return CustomGame{}, InvalidCustomGameError
+		}
+		seen[internalID] = true
+		internalIDs = append(internalIDs, int64(internalID))
+	}
+
+	// Codes are short enough to read out, so retry the rare collision
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := newShareCode()
+		if err != nil {
+			return CustomGame{}, err
+		}
+		_, err = r.db.Exec(ctx, `
+			INSERT INTO custom_games (code, name, creator_id, challenge_ids)
+			VALUES ($1, $2, $3, $4)
+		`, code, name, creator, internalIDs)
+		var pgErr interface{ SQLState() string }
+		if errors.As(err, &pgErr) && pgErr.SQLState() == "23505" {
+			continue
+		} else if err != nil {
+			return CustomGame{}, err
+		}
+		return r.CustomGame(ctx, code)
+	}
+	return CustomGame{}, errors.New("failed to allocate share code")
+}
+
+// CreateCustomGameFromFilter freezes a random sample of up to n challenges
+// matching f, spread out where there are enough of them
+func (r *Repo) CreateCustomGameFromFilter(ctx context.Context, creator string, name string, f ChallengeFilter, n int) (CustomGame, error) {
+	matches := r.SearchChallenges(f, 0)
+	mathrand.Shuffle(len(matches), func(i, j int) {
+		matches[i], matches[j] = matches[j], matches[i]
+	})
+	ids := make([]string, 0, n)
+	for _, c := range r.snapshot.Load().spreadOut(matches, n) {
+		ids = append(ids, c.ID)
+	}
+	return r.CreateCustomGame(ctx, creator, name, ids)
+}
+
+func (r *Repo) CustomGame(ctx context.Context, code string) (CustomGame, error) {
+	r.initWg.Wait()
+
+	g := CustomGame{Code: code}
+	var ids []int64
+	err := r.db.QueryRow(ctx, `
+		SELECT name, challenge_ids, created_at
+		FROM custom_games
+		WHERE code = $1
+	`, code).Scan(&g.Name, &ids, &g.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CustomGame{}, CustomGameNotFoundError
+	} else if err != nil {
+		return CustomGame{}, err
+	}
+
+	challenges := r.snapshot.Load().challenges
+	for _, id := range ids {
+		if c, ok := challenges[int(id)]; ok {
+			g.Challenges = append(g.Challenges, *c)
+		}
+	}
+	return g, nil
+}
+
+// Without vowels or lookalike characters, so codes don't spell words and are
+// easy to read out
+const shareCodeAlphabet = "bcdfghjkmnpqrstvwxz23456789"
+
+const shareCodeLength = 8
+
+func newShareCode() (string, error) {
+	var sb strings.Builder
+	n := big.NewInt(int64(len(shareCodeAlphabet)))
+	for range shareCodeLength {
+		i, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(shareCodeAlphabet[i.Int64()])
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,72 @@
+package repos
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSearchChallenges(t *testing.T) {
+	r := &Repo{descriptions: newLRU(10)}
+	r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
+
+	challenge := func(id int, region string, title string, taken time.Time) *Challenge {
+		return &Challenge{ID: testChallengeID(id), RegionID: region, Title: title, DateTaken: &taken}
+	}
+	err := r.storeChallenges([]*Challenge{
+		challenge(1, "1", "Helvellyn in snow", time.Date(2019, 1, 21, 0, 0, 0, 0, time.UTC)),
+		challenge(2, "1", "Striding Edge", time.Date(2015, 7, 21, 0, 0, 0, 0, time.UTC)),
+		challenge(3, "2", "Ben Nevis", time.Date(2021, 12, 2, 0, 0, 0, 0, time.UTC)),
+		{ID: testChallengeID(4), RegionID: "1", Title: "Undated"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(list []Challenge) []string {
+		var out []string
+		for _, c := range list {
+			out = append(out, c.ID)
+		}
+		return out
+	}
+
+	if got := r.SearchChallenges(ChallengeFilter{}, 0); len(got) != 4 || got[0].ID != testChallengeID(1) {
+		t.Errorf("expected every challenge in order, got %v", ids(got))
+	}
+	if got := r.SearchChallenges(ChallengeFilter{Seasons: []string{"winter"}}, 0); len(got) != 2 {
+		t.Errorf("expected 2 winter challenges, got %v", ids(got))
+	}
+	if got := r.SearchChallenges(ChallengeFilter{RegionIDs: []int{1}, Seasons: []string{"winter"}}, 0); len(got) != 1 {
+		t.Errorf("expected 1 winter challenge in region 1, got %v", ids(got))
+	}
+	if got := r.SearchChallenges(ChallengeFilter{Query: "HELVELLYN"}, 0); len(got) != 1 {
+		t.Errorf("expected case-insensitive title match, got %v", ids(got))
+	}
+	if got := r.SearchChallenges(ChallengeFilter{}, 2); len(got) != 2 {
+		t.Errorf("expected limit to apply, got %v", ids(got))
+	}
+}
+
+func TestNewShareCode(t *testing.T) {
+	seen := make(map[rune]bool)
+	for range 1000 {
+		code, err := newShareCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != shareCodeLength {
+			t.Fatalf("expected %d characters, got %q", shareCodeLength, code)
+		}
+		for _, c := range code {
+			if !strings.ContainsRune(shareCodeAlphabet, c) {
+				t.Fatalf("unexpected character in %q", code)
+			}
+			seen[c] = true
+		}
+	}
+	if len(seen) != len(shareCodeAlphabet) {
+		t.Errorf("expected every character to be used, got %d", len(seen))
+	}
+}
@@ -0,0 +1,154 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+	// Timezones are looked up by name, and the image may not have a zoneinfo
+	_ "time/tzdata"
+)
+
+// How much of DateTaken can be trusted
+const (
+	DateTakenExact = "exact"
+	// The time is midnight, as cameras without a clock and scans give
+	DateTakenDay = "day"
+	// The day is the 1st
+	DateTakenMonth = "month"
+)
+
+// Flickr's takengranularity, see https://www.flickr.com/services/api/misc.dates.html
+const (
+	flickrTakenExact = 0
+	flickrTakenMonth = 4
+)
+
+// flickrDates is the dates in the response to flickr.photos.getInfo, which
+// gives numbers as strings
+type flickrDates struct {
+	Posted           json.RawMessage `json:"posted"`
+	TakenGranularity json.RawMessage `json:"takengranularity"`
+	TakenUnknown     json.RawMessage `json:"takenunknown"`
+}
+
+var timezones sync.Map // name to *time.Location
+
+func loadTimezone(name string) *time.Location {
+	if loc, ok := timezones.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	timezones.Store(name, loc)
+	return loc
+}
+
+// setDateTaken works out DateTakenPrecision from the flickr.photos.getInfo
+// dates, if there are any, and moves DateTaken into the named timezone.
+//
+// Flickr gives the wall-clock time the camera recorded, which is stored as if
+// it were UTC. Dates Flickr doesn't know, or only knows the year of, are no
+// use for play and dropped, as are ones after the photo was uploaded.
+func (c *Challenge) setDateTaken(raw json.RawMessage, timezone string) {
+	c.DateTakenPrecision = ""
+	c.Timezone = ""
+	if c.DateTaken == nil {
+		return
+	}
+
+	var dates flickrDates
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &dates)
+	}
+	if flickrDimension(dates.TakenUnknown) == 1 {
+		c.DateTaken = nil
+		return
+	}
+	if posted := flickrDimension(dates.Posted); posted > 0 && c.DateTaken.After(time.Unix(int64(posted), 0).Add(24*time.Hour)) {
+		c.DateTaken = nil
+		return
+	}
+
+	wall := c.DateTaken.UTC()
+	switch flickrDimension(dates.TakenGranularity) {
+	case flickrTakenExact:
+		c.DateTakenPrecision = DateTakenExact
+		if wall.Hour() == 0 && wall.Minute() == 0 && wall.Second() == 0 {
+			c.DateTakenPrecision = DateTakenDay
+		}
+	case flickrTakenMonth:
+		c.DateTakenPrecision = DateTakenMonth
+	default:
+		// Only the year, or circa
+		c.DateTaken = nil
+		return
+	}
+
+	if timezone == "" {
+		return
+	}
+	loc := loadTimezone(timezone)
+	if loc == nil {
+		return
+	}
+	local := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+	c.DateTaken = &local
+	c.Timezone = timezone
+}
+
+// ChallengeLocation is where a challenge's photo was taken
+type ChallengeLocation struct {
+	ID  string
+	Lng float64
+	Lat float64
+}
+
+// ChallengesWithoutTimezone lists dated challenges whose timezone hasn't been
+// looked up yet, oldest first
+func (r *Repo) ChallengesWithoutTimezone(ctx context.Context, limit int) ([]ChallengeLocation, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id, ST_X(c.geo::geometry), ST_Y(c.geo::geometry)
+		FROM challenges AS c
+		LEFT JOIN challenge_timezones AS tz ON tz.challenge_id = c.id
+		WHERE tz.challenge_id IS NULL AND c.date_taken IS NOT NULL
+		ORDER BY c.id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChallengeLocation
+	for rows.Next() {
+		var internalID int
+		var l ChallengeLocation
+		if err := rows.Scan(&internalID, &l.Lng, &l.Lat); err != nil {
+			return nil, err
+		}
+		l.ID, err = encodeChallengeID(internalID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// SetChallengeTimezone records the IANA timezone of the challenge's location.
+// An empty timezone marks it as not found.
+func (r *Repo) SetChallengeTimezone(ctx context.Context, id string, timezone string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO challenge_timezones (challenge_id, timezone)
+		VALUES ($1, $2)
+		ON CONFLICT (challenge_id) DO UPDATE SET timezone = EXCLUDED.timezone, computed_at = now()
+	`, internalID, timezone)
+	return err
+}
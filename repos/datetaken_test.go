@@ -0,0 +1,41 @@
+package repos
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSetDateTaken(t *testing.T) {
+	cases := []struct {
+		name      string
+		taken     time.Time
+		dates     string
+		timezone  string
+		want      string // RFC 3339, empty for none
+		precision string
+	}{
+		{"exact", time.Date(2023, 6, 1, 14, 37, 0, 0, time.UTC), `{"takengranularity": "0"}`, "", "2023-06-01T14:37:00Z", DateTakenExact},
+		{"in timezone", time.Date(2023, 6, 1, 14, 37, 0, 0, time.UTC), `{"takengranularity": 0}`, "Europe/London", "2023-06-01T14:37:00+01:00", DateTakenExact},
+		{"midnight", time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), `{"takengranularity": "0"}`, "", "2023-06-01T00:00:00Z", DateTakenDay},
+		{"month", time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), `{"takengranularity": "4"}`, "", "2023-06-01T00:00:00Z", DateTakenMonth},
+		{"year", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), `{"takengranularity": "6"}`, "", "", ""},
+		{"unknown", time.Date(2023, 6, 1, 14, 37, 0, 0, time.UTC), `{"takenunknown": "1"}`, "", "", ""},
+		{"after upload", time.Date(2023, 6, 1, 14, 37, 0, 0, time.UTC), `{"posted": "1672531200"}`, "", "", ""},
+		{"no info", time.Date(2023, 6, 1, 14, 37, 0, 0, time.UTC), ``, "Not/AZone", "2023-06-01T14:37:00Z", DateTakenExact},
+	}
+	for _, c := range cases {
+		ch := Challenge{DateTaken: &c.taken}
+		ch.setDateTaken(json.RawMessage(c.dates), c.timezone)
+		got := ""
+		if ch.DateTaken != nil {
+			got = ch.DateTaken.Format(time.RFC3339)
+		}
+		if got != c.want || ch.DateTakenPrecision != c.precision {
+			t.Errorf("%s: expected %q %q, got %q %q", c.name, c.want, c.precision, got, ch.DateTakenPrecision)
+		}
+		if c.timezone == "Europe/London" && ch.Timezone != c.timezone {
+			t.Errorf("%s: expected timezone to be set, got %q", c.name, ch.Timezone)
+		}
+	}
+}
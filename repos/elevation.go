@@ -0,0 +1,47 @@
+package repos
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ChallengeElevation returns the cached elevation of the challenge location in
+// meters, if it has been looked up
+func (r *Repo) ChallengeElevation(ctx context.Context, id string) (float64, bool, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var elevation float64
+	err = r.db.QueryRow(ctx, `
+		SELECT elevation_m FROM challenge_elevations WHERE challenge_id = $1
+	`, internalID).Scan(&elevation)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return elevation, true, nil
+}
+
+func (r *Repo) SetChallengeElevation(ctx context.Context, id string, elevation float64) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO challenge_elevations (challenge_id, elevation_m)
+		VALUES ($1, $2)
+		ON CONFLICT (challenge_id) DO UPDATE SET elevation_m = EXCLUDED.elevation_m, fetched_at = now()
+	`, internalID, elevation)
+	return err
+}
+
+func (r *Repo) SetGuessElevation(ctx context.Context, guessID int64, elevation float64) error {
+	_, err := r.db.Exec(ctx, `UPDATE guesses SET elevation_m = $2 WHERE id = $1`, guessID, elevation)
+	return err
+}
@@ -0,0 +1,95 @@
+package repos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type EncodedRegions struct {
+	Body []byte
+	// What Body is the JSON encoding of, for encoding in other formats
+	Value any
+	ETag  string
+	// The most preferred language any region was localized into, or empty
+	// if they're all in English
+	Language string
+}
+
+// RegionsJSON returns the sorted list of regions with seasonal map layers
+// resolved for date and names localized by the LanguageChain langs.
+// Resolution only depends on the day of the year and the languages there are
+// translations in, so each snapshot encodes few lists per day.
+func (r *Repo) RegionsJSON(date time.Time, langs []string) (EncodedRegions, error) {
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+
+	langs = s.availableLanguages(langs)
+	key := date.Format("01-02") + "/" + strings.Join(langs, ",")
+	if v, ok := s.regionsJSON.Load(key); ok {
+		return v.(EncodedRegions), nil
+	}
+
+	encoded, err := encodeRegions(s.regions, date, langs)
+	if err != nil {
+		return EncodedRegions{}, err
+	}
+	s.regionsJSON.Store(key, encoded)
+	return encoded, nil
+}
+
+func encodeRegions(regions map[int]Region, date time.Time, langs []string) (EncodedRegions, error) {
+	list := make([]Region, 0, len(regions))
+	var used []string
+	for _, region := range regions {
+		region = region.ForDate(date)
+		region, lang := region.Localize(langs)
+		if lang != "" {
+			used = append(used, lang)
+		}
+		if region.MapLayer.CapabilitiesXML == "" {
+			// Not fetched yet, clients can't render the map without it
+			continue
+		}
+		list = append(list, region)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+
+	b, err := json.Marshal(list)
+	if err != nil {
+		return EncodedRegions{}, err
+	}
+	sum := sha256.Sum256(b)
+	return EncodedRegions{
+		Body:     b,
+		Value:    list,
+		ETag:     `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Language: preferredLanguage(langs, used),
+	}, nil
+}
+
+// preferredLanguage is the first of langs in used
+func preferredLanguage(langs []string, used []string) string {
+	for _, lang := range langs {
+		if slices.Contains(used, lang) {
+			return lang
+		}
+	}
+	return ""
+}
+
+func newRegionsJSONCache(regions map[int]Region) *sync.Map {
+	m := new(sync.Map)
+	today := time.Now().UTC()
+	if encoded, err := encodeRegions(regions, today, nil); err == nil {
+		m.Store(today.Format("01-02")+"/", encoded)
+	}
+	return m
+}
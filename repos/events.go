@@ -0,0 +1,65 @@
+package repos
+
+import (
+	"context"
+	"time"
+)
+
+type ScheduledEvent struct {
+	ID          int64     `json:"id"`
+	Kind        string    `json:"kind"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	RegionID    *int      `json:"region_id"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Recurrence  string    `json:"recurrence"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+var EventKinds = []string{"daily_reset", "tournament", "featured_region"}
+
+// ScheduledEvents returns events that haven't ended by since, including every
+// recurring event
+func (r *Repo) ScheduledEvents(ctx context.Context, since time.Time) ([]ScheduledEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, kind, title, description, region_id, starts_at, ends_at, recurrence, updated_at
+		FROM scheduled_events
+		WHERE ends_at > $1 OR recurrence <> ''
+		ORDER BY starts_at, id
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ScheduledEvent, 0)
+	for rows.Next() {
+		var e ScheduledEvent
+		err := rows.Scan(&e.ID, &e.Kind, &e.Title, &e.Description, &e.RegionID,
+			&e.StartsAt, &e.EndsAt, &e.Recurrence, &e.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) CreateScheduledEvent(ctx context.Context, e ScheduledEvent) (ScheduledEvent, error) {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO scheduled_events (kind, title, description, region_id, starts_at, ends_at, recurrence)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, updated_at
+	`, e.Kind, e.Title, e.Description, e.RegionID, e.StartsAt, e.EndsAt, e.Recurrence).Scan(&e.ID, &e.UpdatedAt)
+	return e, err
+}
+
+// DeleteScheduledEvent reports whether the event existed
+func (r *Repo) DeleteScheduledEvent(ctx context.Context, id int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM scheduled_events WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
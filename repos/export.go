@@ -0,0 +1,114 @@
+package repos
+
+import (
+	"context"
+	"slices"
+	"strconv"
+)
+
+type flickrLicense struct {
+	name string
+	// An SPDX identifier, or a LicenseRef- for those SPDX doesn't list
+	code        string
+	url         string
+	attribution bool
+}
+
+// Flickr's license IDs, as listed by flickr.photos.licenses.getInfo
+var flickrLicenses = map[string]flickrLicense{
+	"0":  {"All Rights Reserved", "LicenseRef-All-Rights-Reserved", "", true},
+	"1":  {"CC BY-NC-SA 2.0", "CC-BY-NC-SA-2.0", "https://creativecommons.org/licenses/by-nc-sa/2.0/", true},
+	"2":  {"CC BY-NC 2.0", "CC-BY-NC-2.0", "https://creativecommons.org/licenses/by-nc/2.0/", true},
+	"3":  {"CC BY-NC-ND 2.0", "CC-BY-NC-ND-2.0", "https://creativecommons.org/licenses/by-nc-nd/2.0/", true},
+	"4":  {"CC BY 2.0", "CC-BY-2.0", "https://creativecommons.org/licenses/by/2.0/", true},
+	"5":  {"CC BY-SA 2.0", "CC-BY-SA-2.0", "https://creativecommons.org/licenses/by-sa/2.0/", true},
+	"6":  {"CC BY-ND 2.0", "CC-BY-ND-2.0", "https://creativecommons.org/licenses/by-nd/2.0/", true},
+	"7":  {"No known copyright restrictions", "LicenseRef-Flickr-Commons", "https://www.flickr.com/commons/usage/", false},
+	"8":  {"United States Government Work", "LicenseRef-US-Government-Work", "https://www.usa.gov/government-works", false},
+	"9":  {"CC0 1.0", "CC0-1.0", "https://creativecommons.org/publicdomain/zero/1.0/", false},
+	"10": {"Public Domain Mark 1.0", "LicenseRef-PDM-1.0", "https://creativecommons.org/publicdomain/mark/1.0/", false},
+	"11": {"CC BY 4.0", "CC-BY-4.0", "https://creativecommons.org/licenses/by/4.0/", true},
+	"12": {"CC BY-SA 4.0", "CC-BY-SA-4.0", "https://creativecommons.org/licenses/by-sa/4.0/", true},
+	"13": {"CC BY-ND 4.0", "CC-BY-ND-4.0", "https://creativecommons.org/licenses/by-nd/4.0/", true},
+	"14": {"CC BY-NC 4.0", "CC-BY-NC-4.0", "https://creativecommons.org/licenses/by-nc/4.0/", true},
+	"15": {"CC BY-NC-SA 4.0", "CC-BY-NC-SA-4.0", "https://creativecommons.org/licenses/by-nc-sa/4.0/", true},
+	"16": {"CC BY-NC-ND 4.0", "CC-BY-NC-ND-4.0", "https://creativecommons.org/licenses/by-nc-nd/4.0/", true},
+}
+
+// setLicense fills in the challenge's license fields, leaving them empty if
+// the license isn't known
+func (c *Challenge) setLicense(l flickrLicense) {
+	c.License = l.code
+	c.LicenseURL = l.url
+	c.AttributionRequired = l.attribution
+}
+
+// flickrLicenseNamed looks up a license by the name ExportChallenges gives it
+func flickrLicenseNamed(name string) (flickrLicense, bool) {
+	for _, l := range flickrLicenses {
+		if l.name == name {
+			return l, true
+		}
+	}
+	return flickrLicense{}, false
+}
+
+// ExportChallenges calls row with each challenge being served, optionally
+// only those in region, in the order they were added. license is the name of
+// the photo's license, or empty if it isn't known.
+func (r *Repo) ExportChallenges(ctx context.Context, region *int, row func(c Challenge, license string) error) error {
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+
+	var regionID string
+	if region != nil {
+		regionID = strconv.Itoa(*region)
+	}
+	ids := make([]int, 0, len(s.challenges))
+	for id, c := range s.challenges {
+		if region == nil || c.RegionID == regionID {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	licenses, err := r.challengeLicenses(ctx, region)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := row(*s.challenges[id], licenses[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repo) challengeLicenses(ctx context.Context, region *int) (map[int]string, error) {
+	// info is from flickr.photos.getInfo, and may or may not be unwrapped from
+	// its "photo" key
+	rows, err := r.db.Query(ctx, `
+		SELECT src.challenge_id, coalesce(p.info->>'license', p.info->'photo'->>'license', '')
+		FROM flickr_challenge_sources AS src
+		JOIN flickr_photos AS p ON p.flickr_id = src.flickr_id
+		JOIN challenges AS c ON c.id = src.challenge_id
+		WHERE $1::integer IS NULL OR c.region_id = $1
+	`, region)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var license string
+		if err := rows.Scan(&id, &license); err != nil {
+			return nil, err
+		}
+		if l, ok := flickrLicenses[license]; ok {
+			out[id] = l.name
+		}
+	}
+	return out, rows.Err()
+}
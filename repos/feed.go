@@ -0,0 +1,48 @@
+package repos
+
+import (
+	"context"
+	"time"
+)
+
+type AddedChallenge struct {
+	Challenge Challenge
+	AddedAt   time.Time
+}
+
+// RecentlyAdded returns up to limit of the challenges being served in region,
+// newest first. Only challenges from Flickr record when they were added, so
+// any others are left out.
+func (r *Repo) RecentlyAdded(ctx context.Context, region int, limit int) ([]AddedChallenge, error) {
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT src.challenge_id, p.inserted_at
+		FROM flickr_challenge_sources AS src
+		JOIN flickr_photos AS p ON p.flickr_id = src.flickr_id
+		JOIN challenges AS c ON c.id = src.challenge_id
+		WHERE c.region_id = $1
+		ORDER BY p.inserted_at DESC, src.challenge_id DESC
+		LIMIT $2
+	`, region, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]AddedChallenge, 0)
+	for rows.Next() {
+		var id int
+		var addedAt time.Time
+		if err := rows.Scan(&id, &addedAt); err != nil {
+			return nil, err
+		}
+		// Not every challenge in the table is served, for example if it's
+		// been moderated
+		if c, ok := s.challenges[id]; ok {
+			out = append(out, AddedChallenge{Challenge: *c, AddedAt: addedAt})
+		}
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,151 @@
+package repos
+
+import (
+	"contourguessr-api/elevation"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// fixtureCapabilitiesXML describes a single zoom level, which is enough for
+// regions to be served but not for a client to render tiles
+const fixtureCapabilitiesXML = `<Capabilities xmlns="http://www.opengis.net/wmts/1.0" xmlns:ows="http://www.opengis.net/ows/1.1">
+  <Contents>
+    <Layer>
+      <ows:Identifier>Outdoor_3857</ows:Identifier>
+      <Format>image/png</Format>
+      <TileMatrixSetLink><TileMatrixSet>EPSG:3857</TileMatrixSet></TileMatrixSetLink>
+      <ResourceURL format="image/png" resourceType="tile" template="https://tiles.example/{TileMatrixSet}/{TileMatrix}/{TileCol}/{TileRow}.png"/>
+    </Layer>
+    <TileMatrixSet>
+      <ows:Identifier>EPSG:3857</ows:Identifier>
+      <ows:SupportedCRS>urn:ogc:def:crs:EPSG::3857</ows:SupportedCRS>
+      <TileMatrix>
+        <ows:Identifier>EPSG:3857:7</ows:Identifier>
+        <ScaleDenominator>4367830.187724</ScaleDenominator>
+        <TopLeftCorner>-20037508.34 20037508.34</TopLeftCorner>
+        <TileWidth>256</TileWidth>
+        <TileHeight>256</TileHeight>
+        <MatrixWidth>128</MatrixWidth>
+        <MatrixHeight>128</MatrixHeight>
+      </TileMatrix>
+    </TileMatrixSet>
+  </Contents>
+</Capabilities>`
+
+// DefaultFixtures returns two regions with three challenges each. Every
+// challenge was photographed on a different day and has a distinct title.
+func DefaultFixtures() Fixtures {
+	region := func(id string, name string, minLng float64, minLat float64, maxLng float64, maxLat float64) Region {
+		r := Region{
+			ID:          id,
+			Name:        name,
+			CountryISO2: "GB",
+			LogoURL:     "https://static.contourguessr.org/logos/" + id + ".png",
+		}
+		geo, _ := json.Marshal(map[string]any{
+			"type": "Polygon",
+			"coordinates": [][][2]float64{{
+				{minLng, minLat}, {minLng, maxLat}, {maxLng, maxLat}, {maxLng, minLat}, {minLng, minLat},
+			}},
+		})
+		r.GeoJSON = geo
+		r.BBox.MinLng, r.BBox.MinLat, r.BBox.MaxLng, r.BBox.MaxLat = minLng, minLat, maxLng, maxLat
+		r.MapLayer = MapLayer{
+			ID:                id,
+			Name:              "Outdoor",
+			CapabilitiesXML:   fixtureCapabilitiesXML,
+			Layer:             "Outdoor_3857",
+			MatrixSet:         "EPSG:3857",
+			Resolutions:       []float64{4.777, 2.389, 1.194},
+			DefaultResolution: 2.389,
+			OSBranding:        true,
+			ExtraAttributions: []string{},
+		}
+		return r
+	}
+
+	challenge := func(regionID string, lng float64, lat float64, title string, taken time.Time, added time.Time) FixtureChallenge {
+		var c Challenge
+		c.RegionID = regionID
+		c.Geo.Lng = lng
+		c.Geo.Lat = lat
+		c.Title = title
+		c.DescriptionHTML = "<p>" + title + "</p>"
+		c.DateTaken = &taken
+		c.Link = "https://www.flickr.com/photos/example/" + taken.Format("20060102")
+		c.Src.Regular = PictureSrc{Src: "https://live.staticflickr.com/" + taken.Format("20060102") + "_n.jpg", Width: 320, Height: 240}
+		c.Src.Large = PictureSrc{Src: "https://live.staticflickr.com/" + taken.Format("20060102") + "_b.jpg", Width: 1024, Height: 768}
+		c.Photographer.Text = "Example Photographer"
+		c.Photographer.Link = "https://www.flickr.com/people/example/"
+		c.R.X = 0.5
+		c.R.Y = 0.5
+		return FixtureChallenge{Challenge: c, License: "CC BY 2.0", AddedAt: added}
+	}
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 10, 0, 0, 0, time.UTC)
+	}
+	landmark := func(kind string, osmID int64, name string, lng float64, lat float64, distanceM float64) *NearbyPOI {
+		p := &NearbyPOI{Kind: kind, OSMID: osmID, Name: name, DistanceM: distanceM}
+		p.Geo.Lng, p.Geo.Lat = lng, lat
+		return p
+	}
+
+	snowdon := challenge("1", -4.0763, 53.0685, "Snowdon from Llyn Llydaw", day(2019, time.January, 5), day(2024, time.March, 1))
+	snowdon.Landmarks = &Landmarks{
+		Peak:      landmark("peak", 1, "Yr Wyddfa", -4.0762, 53.0685, 7),
+		Lake:      landmark("lake", 2, "Llyn Llydaw", -4.0626, 53.0706, 940),
+		AdminArea: &AdminArea{OSMID: 3, Name: "Gwynedd", Level: 6},
+	}
+	summit := 1085.0
+	snowdon.ElevationM = &summit
+	aspect := 118.0
+	snowdon.Challenge.Terrain = &elevation.Terrain{SlopeDeg: 21.4, AspectDeg: &aspect, RuggednessM: 38.2, Landform: elevation.LandformRidge}
+
+	return Fixtures{
+		Regions: []Region{
+			region("1", "Snowdonia", -4.2, 52.8, -3.6, 53.3),
+			region("2", "Lake District", -3.4, 54.2, -2.7, 54.7),
+		},
+		Challenges: []FixtureChallenge{
+			snowdon,
+			challenge("1", -3.9966, 53.1148, "Tryfan north ridge", day(2020, time.July, 14), day(2024, time.March, 2)),
+			challenge("1", -4.0186, 53.0900, "Glyder Fach cantilever", day(2021, time.October, 3), day(2024, time.March, 3)),
+			challenge("2", -3.2115, 54.4541, "Scafell Pike summit", day(2018, time.April, 22), day(2024, time.April, 1)),
+			challenge("2", -3.0170, 54.5275, "Helvellyn from Striding Edge", day(2022, time.December, 30), day(2024, time.April, 2)),
+			challenge("2", -3.0957, 54.4461, "Langdale Pikes", day(2017, time.August, 9), day(2024, time.April, 3)),
+		},
+	}
+}
+
+// LoadFixtures reads fixtures written as JSON, so that the API can be run
+// locally from a file instead of a database
+func LoadFixtures(path string) (Fixtures, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Fixtures{}, err
+	}
+	var f Fixtures
+	if err := json.Unmarshal(b, &f); err != nil {
+		return Fixtures{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	regions := make(map[string]bool, len(f.Regions))
+	for _, region := range f.Regions {
+		if _, err := strconv.Atoi(region.ID); err != nil {
+			return Fixtures{}, fmt.Errorf("%s: region ID %q is not an integer", path, region.ID)
+		}
+		if regions[region.ID] {
+			return Fixtures{}, fmt.Errorf("%s: duplicate region %s", path, region.ID)
+		}
+		regions[region.ID] = true
+	}
+	for i, fc := range f.Challenges {
+		if !regions[fc.Challenge.RegionID] {
+			return Fixtures{}, fmt.Errorf("%s: challenge %d is in unknown region %q", path, i, fc.Challenge.RegionID)
+		}
+	}
+	return f, nil
+}
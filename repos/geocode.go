@@ -0,0 +1,33 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+func (r *Repo) CachedGeocode(ctx context.Context, kind string, lng float64, lat float64) (json.RawMessage, bool, error) {
+	var result json.RawMessage
+	err := r.db.QueryRow(ctx, `
+		SELECT result
+		FROM geocode_cache
+		WHERE kind = $1 AND lng_key = round($2::numeric, 3) AND lat_key = round($3::numeric, 3) AND expires_at > now()
+	`, kind, lng, lat).Scan(&result)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return result, true, nil
+}
+
+func (r *Repo) SetCachedGeocode(ctx context.Context, kind string, lng float64, lat float64, result json.RawMessage, ttl time.Duration) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO geocode_cache (kind, lng_key, lat_key, result, expires_at)
+		VALUES ($1, round($2::numeric, 3), round($3::numeric, 3), $4, now() + $5::interval)
+		ON CONFLICT (kind, lng_key, lat_key) DO UPDATE SET result = EXCLUDED.result, expires_at = EXCLUDED.expires_at
+	`, kind, lng, lat, result, ttl)
+	return err
+}
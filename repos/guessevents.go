@@ -0,0 +1,143 @@
+package repos
+
+import (
+	"context"
+	"slices"
+	"sort"
+)
+
+// GuessEvent is a guess reported by a client for analytics
+type GuessEvent struct {
+	ChallengeID   string
+	Lng           float64
+	Lat           float64
+	DistanceM     float64
+	HintsUsed     int
+	ClientVersion string
+}
+
+func (r *Repo) RecordGuessEvent(ctx context.Context, e GuessEvent) error {
+	internalID, err := decodeChallengeID(e.ChallengeID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO guess_events (challenge_id, geo, distance_m, hints_used, client_version)
+		VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography, $4, $5, $6)
+	`, internalID, e.Lng, e.Lat, e.DistanceM, e.HintsUsed, e.ClientVersion)
+	return err
+}
+
+// GuessStats summarizes the guess events of a challenge
+type GuessStats struct {
+	Plays   int64
+	MedianM float64
+	// Plays in each band of distance split at the ascending edges asked for,
+	// so there is one more band than edges
+	Bands []int64
+	// Plays further from the challenge than the distance asked about
+	Further int64
+}
+
+// guessStats is the in memory equivalent of the query in ChallengeGuessStats
+func guessStats(distances []float64, edges []float64, distance float64) GuessStats {
+	stats := GuessStats{Plays: int64(len(distances)), Bands: make([]int64, len(edges)+1)}
+	if len(distances) == 0 {
+		return stats
+	}
+	sorted := slices.Sorted(slices.Values(distances))
+	if mid := len(sorted) / 2; len(sorted)%2 == 1 {
+		stats.MedianM = sorted[mid]
+	} else {
+		stats.MedianM = (sorted[mid-1] + sorted[mid]) / 2
+	}
+	for _, d := range sorted {
+		// As width_bucket, the number of edges at or below d
+		stats.Bands[sort.Search(len(edges), func(i int) bool { return edges[i] > d })]++
+		if d > distance {
+			stats.Further++
+		}
+	}
+	return stats
+}
+
+func (r *Repo) ChallengeGuessStats(ctx context.Context, id string, edges []float64, distance float64) (GuessStats, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return GuessStats{}, err
+	}
+
+	stats := GuessStats{Bands: make([]int64, len(edges)+1)}
+	err = r.db.QueryRow(ctx, `
+		SELECT count(*), coalesce(percentile_cont(0.5) WITHIN GROUP (ORDER BY distance_m), 0),
+			count(*) FILTER (WHERE distance_m > $2)
+		FROM guess_events
+		WHERE challenge_id = $1
+	`, internalID, distance).Scan(&stats.Plays, &stats.MedianM, &stats.Further)
+	if err != nil || stats.Plays == 0 {
+		return stats, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT width_bucket(distance_m, $2::double precision[]), count(*)
+		FROM guess_events
+		WHERE challenge_id = $1
+		GROUP BY 1
+	`, internalID, edges)
+	if err != nil {
+		return GuessStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var band int
+		var n int64
+		if err := rows.Scan(&band, &n); err != nil {
+			return GuessStats{}, err
+		}
+		if band >= 0 && band < len(stats.Bands) {
+			stats.Bands[band] = n
+		}
+	}
+	return stats, rows.Err()
+}
+
+// HeatmapCell counts the guesses in a cell of a grid laid over the map, whose
+// south west corner is X cells east and Y cells north of 0,0
+type HeatmapCell struct {
+	X       int
+	Y       int
+	Guesses int64
+}
+
+// GuessHeatmap counts the challenge's guess events in cells of lngStep by
+// latStep degrees, leaving out cells with fewer than minGuesses so that no one
+// player's guess can be picked out
+func (r *Repo) GuessHeatmap(ctx context.Context, id string, lngStep float64, latStep float64, minGuesses int) ([]HeatmapCell, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT floor(ST_X(geo::geometry) / $2)::integer, floor(ST_Y(geo::geometry) / $3)::integer, count(*)
+		FROM guess_events
+		WHERE challenge_id = $1
+		GROUP BY 1, 2
+		HAVING count(*) >= $4
+		ORDER BY 1, 2
+	`, internalID, lngStep, latStep, minGuesses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []HeatmapCell
+	for rows.Next() {
+		var c HeatmapCell
+		if err := rows.Scan(&c.X, &c.Y, &c.Guesses); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
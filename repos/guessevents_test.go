@@ -0,0 +1,20 @@
+package repos
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGuessStats(t *testing.T) {
+	got := guessStats([]float64{900, 100, 5000, 2000}, []float64{1000, 3000}, 1000)
+	if got.Plays != 4 || got.MedianM != 1450 || got.Further != 2 {
+		t.Errorf("unexpected stats %+v", got)
+	}
+	if !slices.Equal(got.Bands, []int64{2, 1, 1}) {
+		t.Errorf("expected bands of 2, 1 and 1, got %v", got.Bands)
+	}
+
+	if got := guessStats(nil, []float64{1000}, 0); got.Plays != 0 || len(got.Bands) != 2 {
+		t.Errorf("expected empty bands with no plays, got %+v", got)
+	}
+}
@@ -0,0 +1,29 @@
+package repos
+
+import (
+	"context"
+	"time"
+)
+
+func (r *Repo) Ping(ctx context.Context) error {
+	return r.db.Ping(ctx)
+}
+
+// Populated reports whether there are regions and challenges to serve. It
+// doesn't wait for the initial load.
+func (r *Repo) Populated() bool {
+	s := r.snapshot.Load()
+	return len(s.regions) > 0 && len(s.challenges) > 0
+}
+
+// LastRefreshed returns when regions and challenges were last loaded from the
+// database, which is zero while they're only restored from a snapshot
+func (r *Repo) LastRefreshed() (regions time.Time, challenges time.Time) {
+	if v := r.regionsRefreshedAt.Load(); v != 0 {
+		regions = time.Unix(0, v)
+	}
+	if v := r.challengesRefreshedAt.Load(); v != 0 {
+		challenges = time.Unix(0, v)
+	}
+	return regions, challenges
+}
@@ -0,0 +1,32 @@
+package repos
+
+import "context"
+
+// RecordHint records a hint asked for with the play token whose nonce is
+// play
+func (r *Repo) RecordHint(ctx context.Context, id string, play string, level int) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO challenge_hints (challenge_id, play_nonce, level) VALUES ($1, $2, $3)
+	`, internalID, play, level)
+	return err
+}
+
+// HintLevel is the highest level of hint asked for with the play token whose
+// nonce is play, or zero if none were
+func (r *Repo) HintLevel(ctx context.Context, id string, play string) (int, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return 0, err
+	}
+
+	var level int
+	err = r.db.QueryRow(ctx, `
+		SELECT coalesce(max(level), 0) FROM challenge_hints WHERE play_nonce = $1 AND challenge_id = $2
+	`, play, internalID).Scan(&level)
+	return level, err
+}
@@ -0,0 +1,88 @@
+package repos
+
+import (
+	"context"
+	"time"
+)
+
+type BestRound struct {
+	ChallengeID string     `json:"challenge_id"`
+	RegionID    string     `json:"region_id"`
+	Title       string     `json:"title"`
+	DateTaken   *time.Time `json:"date_taken"`
+	DistanceM   float64    `json:"distance_m"`
+	GuessedAt   time.Time  `json:"guessed_at"`
+}
+
+// BestRounds returns the player's closest guesses, at most one per challenge.
+// Challenges that are no longer served are left out.
+func (r *Repo) BestRounds(ctx context.Context, player string, limit int) ([]BestRound, error) {
+	r.initWg.Wait()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT challenge_id, distance_m, inserted_at
+		FROM (
+			SELECT DISTINCT ON (challenge_id) challenge_id, distance_m, inserted_at
+			FROM guesses
+			WHERE player_id = $1 AND distance_m IS NOT NULL
+			ORDER BY challenge_id, distance_m, inserted_at
+		) AS best
+		ORDER BY distance_m, inserted_at
+		LIMIT $2
+	`, player, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	challenges := r.snapshot.Load().challenges
+	out := make([]BestRound, 0)
+	for rows.Next() {
+		var challengeID int
+		var round BestRound
+		if err := rows.Scan(&challengeID, &round.DistanceM, &round.GuessedAt); err != nil {
+			return nil, err
+		}
+		c, ok := challenges[challengeID]
+		if !ok {
+			continue
+		}
+		round.ChallengeID = c.ID
+		round.RegionID = c.RegionID
+		round.Title = c.Title
+		round.DateTaken = c.DateTaken
+		out = append(out, round)
+	}
+	return out, rows.Err()
+}
+
+type Guess struct {
+	ID        int64
+	Lng       float64
+	Lat       float64
+	GuessedAt time.Time
+	// Set once the guess has been revealed
+	ElevationM *float64
+}
+
+// Guesses looks up recorded guesses by ID. Unknown IDs are left out.
+func (r *Repo) Guesses(ctx context.Context, ids []int64) (map[int64]Guess, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, ST_X(geo::geometry), ST_Y(geo::geometry), inserted_at, elevation_m
+		FROM guesses
+		WHERE id = ANY($1)
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[int64]Guess, len(ids))
+	for rows.Next() {
+		var g Guess
+		if err := rows.Scan(&g.ID, &g.Lng, &g.Lat, &g.GuessedAt, &g.ElevationM); err != nil {
+			return nil, err
+		}
+		out[g.ID] = g
+	}
+	return out, rows.Err()
+}
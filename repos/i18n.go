@@ -0,0 +1,113 @@
+package repos
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+type RegionTranslation struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// The base names of regions are in English
+const defaultLanguage = "en"
+
+// Readers of the key language can generally read these too, so they're tried
+// before falling back to English
+var relatedLanguages = map[string][]string{
+	"nb": {"no", "nn"},
+	"nn": {"no", "nb"},
+	"no": {"nb", "nn"},
+}
+
+// LanguageChain expands the client's preferred languages, most preferred
+// first, into the order translations are tried in. Each tag is followed by
+// its less specific forms, so fr-ca falls back to fr, and then by related
+// languages.
+func LanguageChain(preferred []string) []string {
+	var out []string
+	add := func(lang string) {
+		if !slices.Contains(out, lang) {
+			out = append(out, lang)
+		}
+	}
+	for _, tag := range preferred {
+		tag = strings.ToLower(tag)
+		for {
+			add(tag)
+			i := strings.LastIndexByte(tag, '-')
+			if i < 0 {
+				break
+			}
+			tag = tag[:i]
+		}
+		for _, related := range relatedLanguages[tag] {
+			add(related)
+		}
+	}
+	add(defaultLanguage)
+	return out
+}
+
+// Localize replaces the region's name and description with the first
+// translation in chain, returning the language used or empty if there wasn't
+// one
+func (r Region) Localize(chain []string) (Region, string) {
+	for _, lang := range chain {
+		if t, ok := r.translations[lang]; ok {
+			r.Name = t.Name
+			r.Description = t.Description
+			r.translations = nil
+			return r, lang
+		}
+	}
+	r.translations = nil
+	return r, ""
+}
+
+// availableLanguages narrows chain to the languages some region has been
+// translated into, which is all that affects the result of Localize
+func (s *snapshot) availableLanguages(chain []string) []string {
+	var out []string
+	for _, lang := range chain {
+		if s.languages[lang] {
+			out = append(out, lang)
+		}
+	}
+	return out
+}
+
+func regionLanguages(regions map[int]Region) map[string]bool {
+	out := make(map[string]bool)
+	for _, region := range regions {
+		for lang := range region.translations {
+			out[lang] = true
+		}
+	}
+	return out
+}
+
+func (r *Repo) SetRegionTranslation(ctx context.Context, regionID int, lang string, t RegionTranslation) error {
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO region_translations (region_id, lang, name, description)
+		SELECT id, $2, $3, $4 FROM regions WHERE id = $1
+		ON CONFLICT (region_id, lang) DO UPDATE SET
+			name = EXCLUDED.name,
+			description = EXCLUDED.description,
+			updated_at = now()
+	`, regionID, lang, t.Name, t.Description)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return RegionNotFoundError
+	}
+	return nil
+}
+
+func (r *Repo) DeleteRegionTranslation(ctx context.Context, regionID int, lang string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM region_translations WHERE region_id = $1 AND lang = $2`, regionID, lang)
+	return err
+}
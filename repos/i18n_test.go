@@ -0,0 +1,92 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLanguageChain(t *testing.T) {
+	tests := []struct {
+		preferred []string
+		want      []string
+	}{
+		{nil, []string{"en"}},
+		{[]string{"fr-CA", "en"}, []string{"fr-ca", "fr", "en"}},
+		{[]string{"nb-NO"}, []string{"nb-no", "nb", "no", "nn", "en"}},
+		{[]string{"ja", "fr"}, []string{"ja", "fr", "en"}},
+	}
+	for _, tt := range tests {
+		if got := LanguageChain(tt.preferred); !slices.Equal(got, tt.want) {
+			t.Errorf("%v: expected %v, got %v", tt.preferred, tt.want, got)
+		}
+	}
+}
+
+func TestMemoryRegionTranslations(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(DefaultFixtures())
+	if err := m.SetRegionTranslation(ctx, 1, "cy", RegionTranslation{Name: "Eryri", Description: "Parc Cenedlaethol"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetRegionTranslation(ctx, 999, "cy", RegionTranslation{Name: "Nowhere"}); err != RegionNotFoundError {
+		t.Errorf("expected an unknown region to be rejected, got %v", err)
+	}
+
+	names := func(encoded EncodedRegions) map[string]string {
+		var body struct {
+			Regions []struct {
+				ID          string `json:"id"`
+				Name        string `json:"name"`
+				Description string `json:"description"`
+			} `json:"regions"`
+		}
+		if err := json.Unmarshal(encoded.Body, &body); err != nil {
+			t.Fatal(err)
+		}
+		out := make(map[string]string)
+		for _, r := range body.Regions {
+			out[r.ID] = r.Name + "|" + r.Description
+		}
+		return out
+	}
+
+	welsh, err := m.RegionsV2JSON(LanguageChain([]string{"cy-GB"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(welsh); got["1"] != "Eryri|Parc Cenedlaethol" || got["2"] != "Lake District|" || welsh.Language != "cy" {
+		t.Errorf("expected only Snowdonia to be translated, got %v in %q", got, welsh.Language)
+	}
+
+	english, err := m.RegionsV2JSON(LanguageChain([]string{"fr"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(english); got["1"] != "Snowdonia|" || english.Language != "" || english.ETag == welsh.ETag {
+		t.Errorf("expected English names without a translation, got %v in %q", got, english.Language)
+	}
+
+	v1, err := m.RegionsJSON(time.Now(), LanguageChain([]string{"cy"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var regions []Region
+	if err := json.Unmarshal(v1.Body, &regions); err != nil {
+		t.Fatal(err)
+	}
+	for _, region := range regions {
+		if region.ID == "1" && region.Name != "Eryri" {
+			t.Errorf("expected v1 to be translated too, got %q", region.Name)
+		}
+	}
+
+	if err := m.DeleteRegionTranslation(ctx, 1, "cy"); err != nil {
+		t.Fatal(err)
+	}
+	if encoded, _ := m.RegionsV2JSON(LanguageChain([]string{"cy"})); names(encoded)["1"] != "Snowdonia|" {
+		t.Errorf("expected the translation to be removed, got %v", names(encoded))
+	}
+}
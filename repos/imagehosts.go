@@ -0,0 +1,121 @@
+package repos
+
+import (
+	"contourguessr-api/selection"
+	"net/url"
+	"sort"
+)
+
+// Picks give up avoiding down hosts after this many tries. If every host is
+// down a broken image is still better than no challenge.
+const downHostAttempts = 16
+
+func imageHost(c *Challenge) string {
+	u, err := url.Parse(c.Src.Regular.Src)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// SetImageHostsDown replaces the set of image hosts that random selection
+// avoids. An empty list restores every challenge.
+func (r *Repo) SetImageHostsDown(hosts []string) {
+	down := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		down[h] = true
+	}
+	r.downHosts.Store(&down)
+}
+
+func (r *Repo) ImageHostsDown() []string {
+	down := r.downHosts.Load()
+	if down == nil {
+		return nil
+	}
+	out := make([]string, 0, len(*down))
+	for h := range *down {
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SkippedForDownHosts counts picks that were redrawn because their image host
+// was down
+func (r *Repo) SkippedForDownHosts() uint64 {
+	return r.skippedForDownHosts.Load()
+}
+
+func (r *Repo) imageHostDown(c *Challenge) bool {
+	down := r.downHosts.Load()
+	if down == nil || len(*down) == 0 {
+		return false
+	}
+	return (*down)[c.imageHost]
+}
+
+// upPools are the snapshot's selection pools without the challenges on down
+// hosts, built the first time they're needed after either changes
+type upPools struct {
+	snap          *snapshot
+	down          *map[string]bool
+	pool          *selection.Pool
+	poolsByRegion map[int]*selection.Pool
+}
+
+// pools returns the pools to pick players' challenges from. A region whose
+// challenges are all on down hosts keeps them, as a broken image is still
+// better than no challenge.
+func (r *Repo) pools(s *snapshot) (*selection.Pool, map[int]*selection.Pool) {
+	down := r.downHosts.Load()
+	if down == nil || len(*down) == 0 {
+		return s.pool, s.poolsByRegion
+	}
+	if p := r.upPools.Load(); p != nil && p.snap == s && p.down == down {
+		return p.pool, p.poolsByRegion
+	}
+
+	var allIDs []int
+	poolsByRegion := make(map[int]*selection.Pool, len(s.poolsByRegion))
+	for regionID, list := range s.challengesByRegion {
+		var ids []int
+		for _, c := range list {
+			if (*down)[c.imageHost] {
+				continue
+			}
+			id, err := decodeChallengeID(c.ID)
+			if err != nil {
+				continue
+			}
+			ids = append(ids, id)
+		}
+		allIDs = append(allIDs, ids...)
+		if len(ids) == 0 {
+			poolsByRegion[regionID] = s.poolsByRegion[regionID]
+		} else {
+			poolsByRegion[regionID] = selection.NewPool(ids, selection.DefaultShardSize)
+		}
+	}
+	pool := s.pool
+	if len(allIDs) > 0 {
+		pool = selection.NewPool(allIDs, selection.DefaultShardSize)
+	}
+
+	r.upPools.Store(&upPools{snap: s, down: down, pool: pool, poolsByRegion: poolsByRegion})
+	return pool, poolsByRegion
+}
+
+// pickAvoidingDownHosts calls pick until it returns a challenge whose image
+// host is up
+func (r *Repo) pickAvoidingDownHosts(pick func(attempt int) *Challenge) *Challenge {
+	var c *Challenge
+	for attempt := 0; attempt < downHostAttempts; attempt++ {
+		c = pick(attempt)
+		if c == nil || !r.imageHostDown(c) {
+			return c
+		}
+		r.skippedForDownHosts.Add(1)
+	}
+	return c
+}
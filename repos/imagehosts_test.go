@@ -0,0 +1,70 @@
+package repos
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAvoidDownHosts(t *testing.T) {
+	r := &Repo{descriptions: newLRU(10)}
+	r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
+
+	var list []*Challenge
+	for i := 1; i <= 20; i++ {
+		c := &Challenge{ID: testChallengeID(i), RegionID: "1"}
+		c.Src.Regular.Src = "https://mirror.example/1/2_b.jpg"
+		if i%4 == 0 {
+			c.Src.Regular.Src = "https://live.staticflickr.com/1/2_b.jpg"
+		}
+		list = append(list, c)
+	}
+	if err := r.storeChallenges(list); err != nil {
+		t.Fatal(err)
+	}
+
+	r.SetImageHostsDown([]string{"live.staticflickr.com"})
+	region := 1
+	for i := 0; i < 100; i++ {
+		got, err := r.RandomChallenge(&region)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.imageHost != "mirror.example" {
+			t.Errorf("expected a challenge on the up host, got %s", got.imageHost)
+		}
+	}
+	if r.SkippedForDownHosts() == 0 {
+		t.Error("expected picks on the down host to be redrawn")
+	}
+
+	// A whole cycle of the player's ordering serves every challenge on the up
+	// host exactly once
+	seen := make(map[string]bool)
+	for seq := uint64(0); seq < 15; seq++ {
+		got, err := r.PlayerChallenge(&region, "player", seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.imageHost != "mirror.example" {
+			t.Errorf("seq %d: expected a challenge on the up host, got %s", seq, got.imageHost)
+		}
+		if seen[got.ID] {
+			t.Errorf("seq %d: %s was already served", seq, got.ID)
+		}
+		seen[got.ID] = true
+	}
+
+	r.SetImageHostsDown([]string{"live.staticflickr.com", "mirror.example"})
+	if _, err := r.PlayerChallenge(&region, "player", 0); err != nil {
+		t.Errorf("expected a challenge with every host down, got %v", err)
+	}
+
+	r.SetImageHostsDown(nil)
+	skipped := r.SkippedForDownHosts()
+	for i := 0; i < 20; i++ {
+		_, _ = r.RandomChallenge(&region)
+	}
+	if r.SkippedForDownHosts() != skipped {
+		t.Error("expected no redraws once the host is restored")
+	}
+}
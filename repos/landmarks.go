@@ -0,0 +1,72 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+)
+
+// Landmarks are the named features nearest a challenge and the smallest
+// administrative area it's in, so that players learn where the photo was.
+// They're looked up in OSM data when challenges change rather than per reveal.
+type Landmarks struct {
+	Peak      *NearbyPOI `json:"peak"`
+	Valley    *NearbyPOI `json:"valley"`
+	Lake      *NearbyPOI `json:"lake"`
+	AdminArea *AdminArea `json:"admin_area"`
+}
+
+type AdminArea struct {
+	OSMID int64  `json:"osm_id"`
+	Name  string `json:"name"`
+	// OSM's admin_level, where higher levels are smaller areas
+	Level int `json:"admin_level"`
+}
+
+func (r *Repo) ChallengeLandmarks(ctx context.Context, id string) (Landmarks, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return Landmarks{}, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT kind, osm_id, name, ST_X(geo::geometry), ST_Y(geo::geometry), distance_m
+		FROM challenge_landmarks
+		WHERE challenge_id = $1
+	`, internalID)
+	if err != nil {
+		return Landmarks{}, err
+	}
+	defer rows.Close()
+	var out Landmarks
+	for rows.Next() {
+		p := new(NearbyPOI)
+		if err := rows.Scan(&p.Kind, &p.OSMID, &p.Name, &p.Geo.Lng, &p.Geo.Lat, &p.DistanceM); err != nil {
+			return Landmarks{}, err
+		}
+		switch p.Kind {
+		case "peak":
+			out.Peak = p
+		case "valley":
+			out.Valley = p
+		case "lake":
+			out.Lake = p
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Landmarks{}, err
+	}
+
+	area := new(AdminArea)
+	err = r.db.QueryRow(ctx, `
+		SELECT osm_id, name, admin_level
+		FROM challenge_admin_areas
+		WHERE challenge_id = $1
+	`, internalID).Scan(&area.OSMID, &area.Name, &area.Level)
+	if err == nil {
+		out.AdminArea = area
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return Landmarks{}, err
+	}
+	return out, nil
+}
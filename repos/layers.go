@@ -0,0 +1,214 @@
+package repos
+
+import (
+	"contourguessr-api/wmts"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParsedMapLayer is a map layer with its capabilities reduced to what a client
+// needs to request tiles, so that clients don't each have to parse the XML
+type ParsedMapLayer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Seasonal variants are used between the inclusive MM-DD bounds, which may
+	// wrap around the new year. The default layer has none.
+	Season            string       `json:"season,omitempty"`
+	From              string       `json:"from,omitempty"`
+	To                string       `json:"to,omitempty"`
+	Layer             string       `json:"layer"`
+	Format            string       `json:"format"`
+	TileURLTemplate   string       `json:"tile_url_template"`
+	CRS               string       `json:"crs"`
+	TileMatrices      []TileMatrix `json:"tile_matrices"`
+	Resolutions       []float64    `json:"resolutions"`
+	DefaultResolution float64      `json:"default_resolution"`
+	OSBranding        bool         `json:"os_branding"`
+	ExtraAttributions []string     `json:"extra_attributions"`
+}
+
+type TileMatrix struct {
+	ID               string     `json:"id"`
+	ScaleDenominator float64    `json:"scale_denominator"`
+	TopLeft          [2]float64 `json:"top_left"`
+	TileWidth        int        `json:"tile_width"`
+	TileHeight       int        `json:"tile_height"`
+	MatrixWidth      int        `json:"matrix_width"`
+	MatrixHeight     int        `json:"matrix_height"`
+}
+
+// MapLayers returns the region's default map layer followed by its seasonal
+// variants, skipping any whose capabilities haven't been fetched yet
+func (r Region) MapLayers() []ParsedMapLayer {
+	var out []ParsedMapLayer
+	add := func(ml MapLayer, season string, from string, to string) {
+		if ml.CapabilitiesXML == "" {
+			return
+		}
+		parsed, err := parseMapLayer(ml)
+		if err != nil {
+			slog.Error("error parsing capabilities", "region", r.ID, "map_layer", ml.ID, "err", err)
+			return
+		}
+		parsed.Season, parsed.From, parsed.To = season, from, to
+		out = append(out, parsed)
+	}
+	add(r.MapLayer, "", "", "")
+	for _, variant := range r.seasonalMapLayers {
+		add(variant.layer, variant.season, variant.from, variant.to)
+	}
+	return out
+}
+
+func parseMapLayer(ml MapLayer) (ParsedMapLayer, error) {
+	caps, err := wmts.Parse(ml.CapabilitiesXML)
+	if err != nil {
+		return ParsedMapLayer{}, err
+	}
+	layer, ok := caps.Layer(ml.Layer)
+	if !ok {
+		return ParsedMapLayer{}, fmt.Errorf("layer %s not in capabilities", ml.Layer)
+	}
+	set, ok := caps.TileMatrixSet(ml.MatrixSet)
+	if !ok {
+		return ParsedMapLayer{}, fmt.Errorf("tile matrix set %s not in capabilities", ml.MatrixSet)
+	}
+
+	out := ParsedMapLayer{
+		ID:                ml.ID,
+		Name:              ml.Name,
+		Layer:             ml.Layer,
+		CRS:               set.SupportedCRS,
+		Resolutions:       ml.Resolutions,
+		DefaultResolution: ml.DefaultResolution,
+		OSBranding:        ml.OSBranding,
+		ExtraAttributions: ml.ExtraAttributions,
+	}
+	for _, res := range layer.ResourceURLs {
+		if res.ResourceType == "tile" {
+			out.Format = res.Format
+			out.TileURLTemplate = strings.ReplaceAll(res.Template, "{TileMatrixSet}", ml.MatrixSet)
+			break
+		}
+	}
+	if out.TileURLTemplate == "" {
+		return ParsedMapLayer{}, fmt.Errorf("layer %s has no tile resource URL", ml.Layer)
+	}
+
+	for _, m := range set.TileMatrices {
+		parsed, err := parseTileMatrix(m)
+		if err != nil {
+			return ParsedMapLayer{}, fmt.Errorf("tile matrix %s: %w", m.Identifier, err)
+		}
+		out.TileMatrices = append(out.TileMatrices, parsed)
+	}
+	return out, nil
+}
+
+func parseTileMatrix(m wmts.TileMatrix) (TileMatrix, error) {
+	out := TileMatrix{ID: m.Identifier}
+	var err error
+	if out.ScaleDenominator, err = strconv.ParseFloat(strings.TrimSpace(m.ScaleDenominator), 64); err != nil {
+		return TileMatrix{}, err
+	}
+	corner := strings.Fields(m.TopLeftCorner)
+	if len(corner) != 2 {
+		return TileMatrix{}, fmt.Errorf("invalid top left corner %q", m.TopLeftCorner)
+	}
+	for i, s := range corner {
+		if out.TopLeft[i], err = strconv.ParseFloat(s, 64); err != nil {
+			return TileMatrix{}, err
+		}
+	}
+	for _, f := range []struct {
+		s   string
+		dst *int
+	}{
+		{m.TileWidth, &out.TileWidth},
+		{m.TileHeight, &out.TileHeight},
+		{m.MatrixWidth, &out.MatrixWidth},
+		{m.MatrixHeight, &out.MatrixHeight},
+	} {
+		if *f.dst, err = strconv.Atoi(strings.TrimSpace(f.s)); err != nil {
+			return TileMatrix{}, err
+		}
+	}
+	return out, nil
+}
+
+type regionV2 struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	CountryISO2 string           `json:"country_iso2"`
+	LogoURL     string           `json:"logo_url"`
+	GeoJSON     json.RawMessage  `json:"geo_json"`
+	BBox        any              `json:"bbox"`
+	MapLayers   []ParsedMapLayer `json:"map_layers"`
+	Advisory    *RegionAdvisory  `json:"advisory"`
+	Units       RegionUnits      `json:"units"`
+}
+
+// RegionsV2JSON returns the sorted list of regions with every map layer
+// parsed, leaving clients to pick the seasonal variant themselves, and names
+// localized by the LanguageChain langs
+func (r *Repo) RegionsV2JSON(langs []string) (EncodedRegions, error) {
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+
+	// Shares the per-day cache, whose keys can't collide with this one
+	langs = s.availableLanguages(langs)
+	key := "v2/" + strings.Join(langs, ",")
+	if v, ok := s.regionsJSON.Load(key); ok {
+		return v.(EncodedRegions), nil
+	}
+
+	list := make([]regionV2, 0, len(s.regions))
+	var used []string
+	for _, region := range s.regions {
+		layers := region.MapLayers()
+		if len(layers) == 0 {
+			continue
+		}
+		region, lang := region.Localize(langs)
+		if lang != "" {
+			used = append(used, lang)
+		}
+		list = append(list, regionV2{
+			ID:          region.ID,
+			Name:        region.Name,
+			Description: region.Description,
+			CountryISO2: region.CountryISO2,
+			LogoURL:     region.LogoURL,
+			GeoJSON:     region.GeoJSON,
+			BBox:        region.BBox,
+			MapLayers:   layers,
+			Advisory:    region.Advisory,
+			Units:       region.Units,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+
+	value := map[string]any{"regions": list}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return EncodedRegions{}, err
+	}
+	sum := sha256.Sum256(b)
+	encoded := EncodedRegions{
+		Body:     b,
+		Value:    value,
+		ETag:     `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Language: preferredLanguage(langs, used),
+	}
+	s.regionsJSON.Store(key, encoded)
+	return encoded, nil
+}
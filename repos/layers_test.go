@@ -0,0 +1,76 @@
+package repos
+
+import (
+	"testing"
+)
+
+const testTiledCapabilities = `<Capabilities xmlns="http://www.opengis.net/wmts/1.0" xmlns:ows="http://www.opengis.net/ows/1.1">
+  <Contents>
+    <Layer>
+      <ows:Identifier>Outdoor</ows:Identifier>
+      <Format>image/png</Format>
+      <TileMatrixSetLink><TileMatrixSet>EPSG:27700</TileMatrixSet></TileMatrixSetLink>
+      <ResourceURL format="image/png" resourceType="tile" template="https://tiles.example/{TileMatrixSet}/{TileMatrix}/{TileCol}/{TileRow}.png"/>
+    </Layer>
+    <TileMatrixSet>
+      <ows:Identifier>EPSG:27700</ows:Identifier>
+      <ows:SupportedCRS>urn:ogc:def:crs:EPSG::27700</ows:SupportedCRS>
+      <TileMatrix>
+        <ows:Identifier>EPSG:27700:0</ows:Identifier>
+        <ScaleDenominator>3199999.999496</ScaleDenominator>
+        <TopLeftCorner>-238375.0 1376256.0</TopLeftCorner>
+        <TileWidth>256</TileWidth>
+        <TileHeight>256</TileHeight>
+        <MatrixWidth>5</MatrixWidth>
+        <MatrixHeight>7</MatrixHeight>
+      </TileMatrix>
+    </TileMatrixSet>
+  </Contents>
+</Capabilities>`
+
+func TestMapLayers(t *testing.T) {
+	layer := MapLayer{ID: "1", Layer: "Outdoor", MatrixSet: "EPSG:27700", CapabilitiesXML: testTiledCapabilities}
+	winter := layer
+	winter.ID = "2"
+	missing := layer
+	missing.ID, missing.Layer = "3", "Leisure"
+	region := Region{
+		ID:       "1",
+		MapLayer: layer,
+		seasonalMapLayers: []seasonalMapLayer{
+			{season: "winter", from: "12-01", to: "02-28", layer: winter},
+			// Not in the capabilities
+			{season: "spring", from: "03-01", to: "05-31", layer: missing},
+			// Capabilities not fetched yet
+			{season: "summer", from: "06-01", to: "08-31", layer: MapLayer{ID: "4"}},
+		},
+	}
+
+	layers := region.MapLayers()
+	if len(layers) != 2 {
+		t.Fatalf("expected the default and winter layers, got %+v", layers)
+	}
+	if layers[0].ID != "1" || layers[0].Season != "" || layers[1].ID != "2" || layers[1].From != "12-01" || layers[1].To != "02-28" {
+		t.Errorf("unexpected layers %+v", layers)
+	}
+
+	got := layers[0]
+	if got.TileURLTemplate != "https://tiles.example/EPSG:27700/{TileMatrix}/{TileCol}/{TileRow}.png" || got.Format != "image/png" {
+		t.Errorf("unexpected tile URL %s (%s)", got.TileURLTemplate, got.Format)
+	}
+	if got.CRS != "urn:ogc:def:crs:EPSG::27700" || len(got.TileMatrices) != 1 {
+		t.Fatalf("unexpected tile matrix set %+v", got)
+	}
+	want := TileMatrix{
+		ID:               "EPSG:27700:0",
+		ScaleDenominator: 3199999.999496,
+		TopLeft:          [2]float64{-238375, 1376256},
+		TileWidth:        256,
+		TileHeight:       256,
+		MatrixWidth:      5,
+		MatrixHeight:     7,
+	}
+	if got.TileMatrices[0] != want {
+		t.Errorf("expected %+v, got %+v", want, got.TileMatrices[0])
+	}
+}
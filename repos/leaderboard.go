@@ -0,0 +1,54 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type LeaderboardEntry struct {
+	Name      string    `json:"name"`
+	Total     int       `json:"total"`
+	Rounds    int       `json:"rounds"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var GameAlreadySubmittedError = errors.New("game already submitted")
+
+// SubmitScore records a verified game. Callers are responsible for checking
+// the total against the signed round results.
+func (r *Repo) SubmitScore(ctx context.Context, board string, game string, player string, name string, total int, rounds int) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO leaderboard_scores (board, game_id, player_id, name, total, rounds)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, board, game, player, name, total, rounds)
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) && pgErr.SQLState() == "23505" {
+		return GameAlreadySubmittedError
+	}
+	return err
+}
+
+func (r *Repo) Leaderboard(ctx context.Context, board string, limit int) ([]LeaderboardEntry, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT name, total, rounds, created_at
+		FROM leaderboard_scores
+		WHERE board = $1
+		ORDER BY total DESC, created_at
+		LIMIT $2
+	`, board, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]LeaderboardEntry, 0)
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.Name, &e.Total, &e.Rounds, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,82 @@
+package repos
+
+import (
+	"context"
+	"github.com/cenkalti/backoff/v4"
+	"log/slog"
+	"time"
+)
+
+const (
+	challengesChangedChannel = "challenges_changed"
+	regionsChangedChannel    = "regions_changed"
+)
+
+// changeListener forwards notifications sent by the triggers in the migrations
+// to the updaters. Each signal channel has a buffer of one so that a burst of
+// writes coalesces into a single refresh.
+func (r *Repo) changeListener(ctx context.Context) {
+	defer r.closeWg.Done()
+
+	b := backoff.NewExponentialBackOff(backoff.WithMaxElapsedTime(0), backoff.WithMaxInterval(1*time.Minute))
+	reconnect := false
+	for {
+		err := r.listen(ctx, b, reconnect)
+		reconnect = true
+		if ctx.Err() != nil {
+			slog.Info("cancelling change listener")
+			return
+		}
+		wait := b.NextBackOff()
+		slog.Error("change listener failed", "retry_in", wait.String(), "err", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			slog.Info("cancelling change listener")
+			return
+		}
+	}
+}
+
+func (r *Repo) listen(ctx context.Context, b backoff.BackOff, reconnect bool) error {
+	pooled, err := r.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection is left in the LISTEN state, so don't return it to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	for _, channel := range []string{challengesChangedChannel, regionsChangedChannel} {
+		if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return err
+		}
+	}
+	b.Reset()
+
+	if reconnect {
+		// Anything that changed while we weren't listening would otherwise be missed
+		signal(r.challengesChanged)
+		signal(r.regionsChanged)
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		switch n.Channel {
+		case challengesChangedChannel:
+			signal(r.challengesChanged)
+		case regionsChangedChannel:
+			signal(r.regionsChanged)
+		}
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
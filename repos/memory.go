@@ -0,0 +1,1585 @@
+package repos
+
+import (
+	"cmp"
+	"context"
+	"contourguessr-api/elevation"
+	"contourguessr-api/geo"
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is a Store that keeps everything in process, starting from fixture
+// data, for testing handlers without Postgres. Reads from the snapshot go
+// through the same code as Repo. Picks that Repo makes at random are made in
+// ID order instead so that tests are deterministic.
+type Memory struct {
+	// Holds the snapshot, but never has a database
+	snap *Repo
+
+	mu           sync.Mutex
+	fixtures     map[int]FixtureChallenge
+	archived     map[int]bool
+	moderation   map[int]*ChallengeModeration
+	privacyZones map[int][]PrivacyZone
+	advisories   map[int]*RegionAdvisory
+	translations map[int]map[string]RegionTranslation
+	regions      map[int]Region
+
+	guesses     []memoryGuess
+	guessEvents []GuessEvent
+	serves      map[int]memoryServes
+	weather     map[int]memoryWeather
+	elevations  map[int]float64
+	// Photos SetChallengeBlurHash was told couldn't be decoded
+	undecodable map[int]bool
+	// Looked up by SetChallengeTimezone, empty if none was found
+	timezones   map[int]string
+	geocodes    map[string]json.RawMessage
+	settings    map[string][]byte
+	clicks      map[string]int
+	scores      []memoryScore
+	campaigns   map[string]*memoryCampaign
+	customGames map[string]memoryCustomGame
+	partners    map[string]Partner
+	apiKeys     []APIKey
+	apiKeyIDs   map[string]int
+	optOuts     []OptOut
+	events      []ScheduledEvent
+	slugs       map[string]int
+	hints       map[memoryHintKey]int
+
+	nextRandom  int
+	nextGuessID int64
+	nextEventID int64
+	nextCode    int
+}
+
+// FixtureChallenge is a challenge along with what Repo would otherwise look
+// up in the database
+type FixtureChallenge struct {
+	// ID is assigned from the position in Fixtures.Challenges, and
+	// DescriptionHTML is served as the description
+	Challenge Challenge   `json:"challenge"`
+	License   string      `json:"license"`
+	AddedAt   time.Time   `json:"added_at"`
+	Nearby    []NearbyPOI `json:"nearby,omitempty"`
+	Landmarks *Landmarks  `json:"landmarks,omitempty"`
+	// Served as though already looked up from the DEM
+	ElevationM *float64 `json:"elevation_m,omitempty"`
+}
+
+type Fixtures struct {
+	Regions    []Region           `json:"regions"`
+	Challenges []FixtureChallenge `json:"challenges"`
+}
+
+type memoryGuess struct {
+	Guess
+	challengeID int
+	player      string
+	distanceM   float64
+}
+
+type memoryServes struct {
+	regionID int
+	served   int64
+	lastAt   time.Time
+}
+
+type memoryScore struct {
+	board string
+	game  string
+	entry LeaderboardEntry
+}
+
+type memoryCampaign struct {
+	order       []int
+	cursor      int
+	startedAt   time.Time
+	completedAt *time.Time
+}
+
+type memoryHintKey struct {
+	challengeID int
+	play        string
+}
+
+type memoryCustomGame struct {
+	name      string
+	ids       []int
+	createdAt time.Time
+}
+
+// NewMemory returns a Memory serving f. Challenges get internal IDs from one
+// upwards in the order given.
+func NewMemory(f Fixtures) *Memory {
+	now := time.Now()
+	r := &Repo{
+		cancelUpdater: func() {},
+		descriptions:  newLRU(descriptionCacheSize),
+		startedAt:     now,
+	}
+	r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
+	r.regionsRefreshedAt.Store(now.UnixNano())
+	r.challengesRefreshedAt.Store(now.UnixNano())
+
+	m := &Memory{
+		snap:         r,
+		fixtures:     make(map[int]FixtureChallenge),
+		archived:     make(map[int]bool),
+		moderation:   make(map[int]*ChallengeModeration),
+		privacyZones: make(map[int][]PrivacyZone),
+		advisories:   make(map[int]*RegionAdvisory),
+		translations: make(map[int]map[string]RegionTranslation),
+		regions:      make(map[int]Region),
+		serves:       make(map[int]memoryServes),
+		weather:      make(map[int]memoryWeather),
+		elevations:   make(map[int]float64),
+		undecodable:  make(map[int]bool),
+		timezones:    make(map[int]string),
+		geocodes:     make(map[string]json.RawMessage),
+		settings:     make(map[string][]byte),
+		clicks:       make(map[string]int),
+		campaigns:    make(map[string]*memoryCampaign),
+		customGames:  make(map[string]memoryCustomGame),
+		partners:     make(map[string]Partner),
+		apiKeyIDs:    make(map[string]int),
+		slugs:        make(map[string]int),
+		hints:        make(map[memoryHintKey]int),
+	}
+	for _, region := range f.Regions {
+		id, err := strconv.Atoi(region.ID)
+		if err != nil {
+			panic("fixture region IDs must be integers")
+		}
+		m.regions[id] = region
+		if region.Advisory != nil {
+			advisory := *region.Advisory
+			m.advisories[id] = &advisory
+		}
+	}
+	for i, fc := range f.Challenges {
+		// Counting from one is always in range
+		fc.Challenge.ID, _ = encodeChallengeID(i + 1)
+		m.fixtures[i+1] = fc
+	}
+	m.restock()
+	return m
+}
+
+// restock rebuilds the snapshot the way a reload from the database would,
+// leaving out archived, moderated and privacy zoned challenges. Must be
+// called with mu held, or before m is shared.
+func (m *Memory) restock() {
+	regions := make(map[int]Region, len(m.regions))
+	for id, region := range m.regions {
+		region.Advisory = m.advisories[id]
+		region.translations = maps.Clone(m.translations[id])
+		regions[id] = region
+	}
+	m.snap.storeRegions(regions, time.Now())
+
+	list := make([]*Challenge, 0, len(m.fixtures))
+	for _, id := range m.sortedFixtureIDs() {
+		if m.withheld(id) {
+			continue
+		}
+		c := m.fixtures[id].Challenge
+		c.DescriptionHTML = ""
+		if l, ok := flickrLicenseNamed(m.fixtures[id].License); ok {
+			c.setLicense(l)
+		}
+		if len(c.Src.Sizes) == 0 {
+			c.setSizes(nil)
+		}
+		c.setLayout()
+		c.setDateTaken(nil, m.timezones[id])
+		list = append(list, &c)
+	}
+	if err := m.snap.storeChallenges(list); err != nil {
+		panic(err)
+	}
+}
+
+func (m *Memory) withheld(id int) bool {
+	if m.archived[id] {
+		return true
+	}
+	if mod, ok := m.moderation[id]; ok && (mod.State == ModerationQuarantined || mod.State == ModerationRemoved) {
+		return true
+	}
+	c := m.fixtures[id].Challenge
+	regionID, _ := strconv.Atoi(c.RegionID)
+	if _, ok := m.regions[regionID]; !ok {
+		return true
+	}
+	for _, zone := range m.privacyZones[regionID] {
+		if geometryCovers(zone.Geometry, c.Geo.Lng, c.Geo.Lat) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) sortedFixtureIDs() []int {
+	ids := make([]int, 0, len(m.fixtures))
+	for id := range m.fixtures {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// served returns the challenge if it's in the snapshot
+func (m *Memory) served(internalID int) (*Challenge, bool) {
+	c, ok := m.snap.snapshot.Load().challenges[internalID]
+	return c, ok
+}
+
+func (m *Memory) WaitUntilReady() {}
+
+func (m *Memory) Close() {}
+
+func (m *Memory) Ping(_ context.Context) error {
+	return nil
+}
+
+func (m *Memory) Populated() bool {
+	return m.snap.Populated()
+}
+
+func (m *Memory) LastRefreshed() (regions time.Time, challenges time.Time) {
+	return m.snap.LastRefreshed()
+}
+
+func (m *Memory) CacheAge() (regions time.Duration, challenges time.Duration) {
+	return m.snap.CacheAge()
+}
+
+func (m *Memory) CacheStats() map[string]CacheStats {
+	return m.snap.CacheStats()
+}
+
+func (m *Memory) Regions() map[int]Region {
+	return m.snap.Regions()
+}
+
+func (m *Memory) RegionsUpdatedAt() time.Time {
+	return m.snap.RegionsUpdatedAt()
+}
+
+func (m *Memory) RegionsJSON(date time.Time, langs []string) (EncodedRegions, error) {
+	return m.snap.RegionsJSON(date, langs)
+}
+
+func (m *Memory) RegionsV2JSON(langs []string) (EncodedRegions, error) {
+	return m.snap.RegionsV2JSON(langs)
+}
+
+func (m *Memory) SetRegionAdvisory(_ context.Context, regionID int, advisory RegionAdvisory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.regions[regionID]; !ok {
+		return RegionNotFoundError
+	}
+	if advisory.SeasonalRestrictions == nil {
+		advisory.SeasonalRestrictions = []SeasonalRestriction{}
+	}
+	m.advisories[regionID] = &advisory
+	m.restock()
+	return nil
+}
+
+func (m *Memory) DeleteRegionAdvisory(_ context.Context, regionID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.advisories, regionID)
+	m.restock()
+	return nil
+}
+
+func (m *Memory) SetRegionTranslation(_ context.Context, regionID int, lang string, t RegionTranslation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.regions[regionID]; !ok {
+		return RegionNotFoundError
+	}
+	if m.translations[regionID] == nil {
+		m.translations[regionID] = make(map[string]RegionTranslation)
+	}
+	m.translations[regionID][lang] = t
+	m.restock()
+	return nil
+}
+
+func (m *Memory) DeleteRegionTranslation(_ context.Context, regionID int, lang string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.translations[regionID], lang)
+	m.restock()
+	return nil
+}
+
+func (m *Memory) PrivacyZones(_ context.Context, regionID int) ([]PrivacyZone, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]PrivacyZone, 0, len(m.privacyZones[regionID]))
+	for _, zone := range m.privacyZones[regionID] {
+		zone.ExcludedChallenges = m.zoneExcludes(regionID, zone)
+		out = append(out, zone)
+	}
+	return out, nil
+}
+
+func (m *Memory) SetPrivacyZone(_ context.Context, regionID int, zone PrivacyZone) ([]string, error) {
+	var geometry struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(zone.Geometry, &geometry); err != nil ||
+		(geometry.Type != "Polygon" && geometry.Type != "MultiPolygon") {
+		return nil, InvalidZoneGeometryError
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.regions[regionID]; !ok {
+		return nil, RegionNotFoundError
+	}
+	zone.ExcludedChallenges = nil
+	zones := slices.DeleteFunc(m.privacyZones[regionID], func(z PrivacyZone) bool {
+		return z.Name == zone.Name
+	})
+	zones = append(zones, zone)
+	slices.SortFunc(zones, func(a, b PrivacyZone) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	m.privacyZones[regionID] = zones
+	m.restock()
+	return m.zoneExcludes(regionID, zone), nil
+}
+
+func (m *Memory) DeletePrivacyZone(_ context.Context, regionID int, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.privacyZones[regionID] = slices.DeleteFunc(m.privacyZones[regionID], func(z PrivacyZone) bool {
+		return z.Name == name
+	})
+	m.restock()
+	return nil
+}
+
+func (m *Memory) zoneExcludes(regionID int, zone PrivacyZone) []string {
+	out := make([]string, 0)
+	for _, id := range m.sortedFixtureIDs() {
+		c := m.fixtures[id].Challenge
+		if c.RegionID == strconv.Itoa(regionID) && !m.archived[id] && geometryCovers(zone.Geometry, c.Geo.Lng, c.Geo.Lat) {
+			out = append(out, c.ID)
+		}
+	}
+	return out
+}
+
+func (m *Memory) CapabilitiesChanges() []CapabilitiesChange {
+	return m.snap.CapabilitiesChanges()
+}
+
+func (m *Memory) BreakingCapabilitiesChanges() uint64 {
+	return m.snap.BreakingCapabilitiesChanges()
+}
+
+func (m *Memory) Challenge(id string) (Challenge, error) {
+	return m.snap.Challenge(id)
+}
+
+func (m *Memory) ServedChallenge(ctx context.Context, c Challenge) Challenge {
+	description, err := m.ChallengeDescription(ctx, c.ID)
+	if err != nil {
+		description = ""
+	}
+	return servedChallenge(c, description, m.snap.outboundPrefix)
+}
+
+func (m *Memory) ChallengeJSON(ctx context.Context, c Challenge) ([]byte, error) {
+	description, err := m.ChallengeDescription(ctx, c.ID)
+	if err != nil {
+		description = ""
+	}
+	return spliceChallenge(c, description, m.snap.outboundPrefix)
+}
+
+func (m *Memory) ChallengeDescription(_ context.Context, id string) (string, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fc, ok := m.fixtures[internalID]
+	if !ok || m.archived[internalID] {
+		return "", ChallengeNotFoundError
+	}
+	return fc.Challenge.DescriptionHTML, nil
+}
+
+func (m *Memory) ChallengeDebugInfoJSON(ctx context.Context, id string) (string, error) {
+	challenge, err := m.Challenge(id)
+	if err != nil {
+		return "", err
+	}
+	challenge.DescriptionHTML, err = m.ChallengeDescription(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	fc := m.fixtures[internalID]
+	m.mu.Unlock()
+
+	b, err := json.MarshalIndent(map[string]any{
+		"challenge":   challenge,
+		"internal_id": internalID,
+		"license":     fc.License,
+		"inserted_at": fc.AddedAt,
+	}, "", "  ")
+	return string(b), err
+}
+
+func (m *Memory) ChallengesPerRegion() map[int]int {
+	return m.snap.ChallengesPerRegion()
+}
+
+// RandomChallenge cycles through the challenges in the region, or every
+// region, in ID order
+func (m *Memory) RandomChallenge(region *int) (Challenge, error) {
+	list := m.SearchChallenges(m.regionFilter(region), 0)
+	if len(list) == 0 {
+		return Challenge{}, NoChallengesAvailableError
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pick := list[m.nextRandom%len(list)]
+	m.nextRandom++
+	return pick, nil
+}
+
+func (m *Memory) regionFilter(region *int) ChallengeFilter {
+	if region == nil {
+		return ChallengeFilter{}
+	}
+	return ChallengeFilter{RegionIDs: []int{*region}}
+}
+
+func (m *Memory) PlayerChallenge(region *int, player string, seq uint64) (Challenge, error) {
+	return m.snap.PlayerChallenge(region, player, seq)
+}
+
+// SampleChallenges picks the first n challenges from each region
+func (m *Memory) SampleChallenges(n int) []Challenge {
+	var out []Challenge
+	perRegion := make(map[string]int)
+	for _, c := range m.SearchChallenges(ChallengeFilter{}, 0) {
+		if perRegion[c.RegionID] < n {
+			perRegion[c.RegionID]++
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (m *Memory) SearchChallenges(f ChallengeFilter, limit int) []Challenge {
+	return m.snap.SearchChallenges(f, limit)
+}
+
+func (m *Memory) OnThisDay(date time.Time, region *int) []Challenge {
+	return m.snap.OnThisDay(date, region)
+}
+
+func (m *Memory) WorldTour(player string, seq uint64, rounds int) ([]Challenge, error) {
+	return m.snap.WorldTour(player, seq, rounds)
+}
+
+func (m *Memory) RecentlyAdded(_ context.Context, region int, limit int) ([]AddedChallenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]AddedChallenge, 0)
+	for _, id := range m.sortedFixtureIDs() {
+		fc := m.fixtures[id]
+		c, ok := m.served(id)
+		if !ok || fc.AddedAt.IsZero() || c.RegionID != strconv.Itoa(region) {
+			continue
+		}
+		out = append(out, AddedChallenge{Challenge: *c, AddedAt: fc.AddedAt})
+	}
+	slices.SortStableFunc(out, func(a, b AddedChallenge) int {
+		return b.AddedAt.Compare(a.AddedAt)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (m *Memory) ExportChallenges(_ context.Context, region *int, row func(c Challenge, license string) error) error {
+	m.mu.Lock()
+	licenses := make(map[string]string, len(m.fixtures))
+	for _, fc := range m.fixtures {
+		licenses[fc.Challenge.ID] = fc.License
+	}
+	m.mu.Unlock()
+
+	for _, c := range m.SearchChallenges(m.regionFilter(region), 0) {
+		if err := row(c, licenses[c.ID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ChallengeNearby(_ context.Context, id string) ([]NearbyPOI, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append(make([]NearbyPOI, 0), m.fixtures[internalID].Nearby...), nil
+}
+
+func (m *Memory) ChallengeLandmarks(_ context.Context, id string) (Landmarks, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return Landmarks{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l := m.fixtures[internalID].Landmarks; l != nil {
+		return *l, nil
+	}
+	return Landmarks{}, nil
+}
+
+func (m *Memory) ChallengeWeather(_ context.Context, id string) (json.RawMessage, bool, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return nil, false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.weather[internalID]
+	if !ok || missingWeather(w.conditions) && time.Since(w.fetchedAt) > missingWeatherTTL {
+		return nil, false, nil
+	}
+	return w.conditions, true, nil
+}
+
+func (m *Memory) SetChallengeWeather(_ context.Context, id string, conditions json.RawMessage) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.weather[internalID] = memoryWeather{conditions: conditions, fetchedAt: time.Now()}
+	return nil
+}
+
+type memoryWeather struct {
+	conditions json.RawMessage
+	fetchedAt  time.Time
+}
+
+func missingWeather(conditions json.RawMessage) bool {
+	return conditions == nil || string(conditions) == "null"
+}
+
+func (m *Memory) ChallengeElevation(_ context.Context, id string) (float64, bool, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return 0, false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elevation, ok := m.elevations[internalID]; ok {
+		return elevation, true, nil
+	}
+	if e := m.fixtures[internalID].ElevationM; e != nil {
+		return *e, true, nil
+	}
+	return 0, false, nil
+}
+
+func (m *Memory) SetChallengeElevation(_ context.Context, id string, elevation float64) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.elevations[internalID] = elevation
+	return nil
+}
+
+func (m *Memory) ChallengesWithoutTerrain(_ context.Context, limit int) ([]ChallengePoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []ChallengePoint
+	for _, id := range m.sortedFixtureIDs() {
+		c := m.fixtures[id].Challenge
+		if c.Terrain != nil {
+			continue
+		}
+		if len(out) == limit {
+			break
+		}
+		out = append(out, ChallengePoint{ID: c.ID, Geo: geo.Point{Lng: c.Geo.Lng, Lat: c.Geo.Lat}})
+	}
+	return out, nil
+}
+
+func (m *Memory) SetChallengeTerrain(_ context.Context, id string, t elevation.Terrain) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fc, ok := m.fixtures[internalID]
+	if !ok {
+		return ChallengeNotFoundError
+	}
+	fc.Challenge.Terrain = &t
+	m.fixtures[internalID] = fc
+	m.restock()
+	return nil
+}
+
+func (m *Memory) ChallengesWithoutBlurHash(_ context.Context, limit int) ([]ChallengePhoto, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []ChallengePhoto
+	for _, id := range m.sortedFixtureIDs() {
+		c := m.fixtures[id].Challenge
+		if c.Src.Regular.BlurHash != "" || m.undecodable[id] {
+			continue
+		}
+		if len(out) == limit {
+			break
+		}
+		out = append(out, ChallengePhoto{ID: c.ID, Src: c.Src.Regular.Src})
+	}
+	return out, nil
+}
+
+func (m *Memory) SetChallengeBlurHash(_ context.Context, id string, hash string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fc, ok := m.fixtures[internalID]
+	if !ok {
+		return ChallengeNotFoundError
+	}
+	m.undecodable[internalID] = hash == ""
+	fc.Challenge.Src.Regular.BlurHash = hash
+	fc.Challenge.Src.Large.BlurHash = hash
+	// Served snapshots share the old slice
+	fc.Challenge.Src.Sizes = slices.Clone(fc.Challenge.Src.Sizes)
+	for i := range fc.Challenge.Src.Sizes {
+		fc.Challenge.Src.Sizes[i].BlurHash = hash
+	}
+	m.fixtures[internalID] = fc
+	m.restock()
+	return nil
+}
+
+func (m *Memory) ChallengesWithoutTimezone(_ context.Context, limit int) ([]ChallengeLocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []ChallengeLocation
+	for _, id := range m.sortedFixtureIDs() {
+		c := m.fixtures[id].Challenge
+		if _, ok := m.timezones[id]; ok || c.DateTaken == nil {
+			continue
+		}
+		if len(out) == limit {
+			break
+		}
+		out = append(out, ChallengeLocation{ID: c.ID, Lng: c.Geo.Lng, Lat: c.Geo.Lat})
+	}
+	return out, nil
+}
+
+func (m *Memory) SetChallengeTimezone(_ context.Context, id string, timezone string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.fixtures[internalID]; !ok {
+		return ChallengeNotFoundError
+	}
+	m.timezones[internalID] = timezone
+	m.restock()
+	return nil
+}
+
+func (m *Memory) ArchiveChallenge(_ context.Context, id string, _ string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return ChallengeNotFoundError
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.fixtures[internalID]; !ok || m.archived[internalID] {
+		return ChallengeNotFoundError
+	}
+	m.archived[internalID] = true
+	m.restock()
+	return nil
+}
+
+func (m *Memory) RestoreChallenge(_ context.Context, id string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return ChallengeNotFoundError
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.archived[internalID] {
+		return ChallengeNotFoundError
+	}
+	delete(m.archived, internalID)
+	m.restock()
+	return nil
+}
+
+func (m *Memory) ChallengeIDBySlug(_ context.Context, slug string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	internalID, ok := m.slugs[slug]
+	if !ok {
+		return "", SlugNotFoundError
+	}
+	return encodeChallengeID(internalID)
+}
+
+func (m *Memory) SetChallengeSlug(_ context.Context, id string, slug string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return ChallengeNotFoundError
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.fixtures[internalID]; !ok || m.archived[internalID] {
+		return ChallengeNotFoundError
+	}
+	if other, ok := m.slugs[slug]; ok && other != internalID {
+		return SlugTakenError
+	}
+	for s, other := range m.slugs {
+		if other == internalID {
+			delete(m.slugs, s)
+		}
+	}
+	m.slugs[slug] = internalID
+	return nil
+}
+
+func (m *Memory) DeleteChallengeSlug(_ context.Context, id string) (bool, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return false, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for s, other := range m.slugs {
+		if other == internalID {
+			delete(m.slugs, s)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) ImageHostsDown() []string {
+	return m.snap.ImageHostsDown()
+}
+
+func (m *Memory) SetImageHostsDown(hosts []string) {
+	m.snap.SetImageHostsDown(hosts)
+}
+
+func (m *Memory) SkippedForDownHosts() uint64 {
+	return m.snap.SkippedForDownHosts()
+}
+
+func (m *Memory) RecordGuess(_ context.Context, id string, player string, lng float64, lat float64) (int64, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return 0, err
+	}
+	c, ok := m.served(internalID)
+	if !ok {
+		return 0, ChallengeNotFoundError
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextGuessID++
+	m.guesses = append(m.guesses, memoryGuess{
+		Guess:       Guess{ID: m.nextGuessID, Lng: lng, Lat: lat, GuessedAt: time.Now()},
+		challengeID: internalID,
+		player:      player,
+		distanceM:   geo.Distance(geo.Point{Lng: lng, Lat: lat}, geo.Point{Lng: c.Geo.Lng, Lat: c.Geo.Lat}),
+	})
+	return m.nextGuessID, nil
+}
+
+func (m *Memory) RecordGuessEvent(_ context.Context, e GuessEvent) error {
+	if _, err := decodeChallengeID(e.ChallengeID); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.guessEvents = append(m.guessEvents, e)
+	return nil
+}
+
+func (m *Memory) ChallengeGuessStats(_ context.Context, id string, edges []float64, distance float64) (GuessStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var distances []float64
+	for _, e := range m.guessEvents {
+		if e.ChallengeID == id {
+			distances = append(distances, e.DistanceM)
+		}
+	}
+	return guessStats(distances, edges, distance), nil
+}
+
+func (m *Memory) GuessHeatmap(_ context.Context, id string, lngStep float64, latStep float64, minGuesses int) ([]HeatmapCell, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[[2]int]int64)
+	for _, e := range m.guessEvents {
+		if e.ChallengeID == id {
+			counts[[2]int{int(math.Floor(e.Lng / lngStep)), int(math.Floor(e.Lat / latStep))}]++
+		}
+	}
+	var out []HeatmapCell
+	for cell, n := range counts {
+		if n >= int64(minGuesses) {
+			out = append(out, HeatmapCell{X: cell[0], Y: cell[1], Guesses: n})
+		}
+	}
+	slices.SortFunc(out, func(a, b HeatmapCell) int {
+		return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Y, b.Y))
+	})
+	return out, nil
+}
+
+func (m *Memory) Guesses(_ context.Context, ids []int64) (map[int64]Guess, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[int64]Guess, len(ids))
+	for _, g := range m.guesses {
+		if slices.Contains(ids, g.ID) {
+			out[g.ID] = g.Guess
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) SetGuessElevation(_ context.Context, guessID int64, elevation float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.guesses {
+		if m.guesses[i].ID == guessID {
+			m.guesses[i].ElevationM = &elevation
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RecordHint(_ context.Context, id string, play string, level int) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memoryHintKey{internalID, play}
+	m.hints[key] = max(m.hints[key], level)
+	return nil
+}
+
+func (m *Memory) HintLevel(_ context.Context, id string, play string) (int, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hints[memoryHintKey{internalID, play}], nil
+}
+
+func (m *Memory) BestRounds(_ context.Context, player string, limit int) ([]BestRound, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	best := make(map[int]memoryGuess)
+	for _, g := range m.guesses {
+		if g.player != player {
+			continue
+		}
+		if prev, ok := best[g.challengeID]; !ok || g.distanceM < prev.distanceM {
+			best[g.challengeID] = g
+		}
+	}
+	out := make([]BestRound, 0)
+	for id, g := range best {
+		c, ok := m.served(id)
+		if !ok {
+			continue
+		}
+		out = append(out, BestRound{
+			ChallengeID: c.ID,
+			RegionID:    c.RegionID,
+			Title:       c.Title,
+			DateTaken:   c.DateTaken,
+			DistanceM:   g.distanceM,
+			GuessedAt:   g.GuessedAt,
+		})
+	}
+	slices.SortFunc(out, func(a, b BestRound) int {
+		if c := cmp.Compare(a.DistanceM, b.DistanceM); c != 0 {
+			return c
+		}
+		return a.GuessedAt.Compare(b.GuessedAt)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (m *Memory) RecordServe(challenge Challenge) {
+	internalID, err := decodeChallengeID(challenge.ID)
+	if err != nil {
+		return
+	}
+	regionID, err := strconv.Atoi(challenge.RegionID)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.serves[internalID]
+	s.regionID = regionID
+	s.served++
+	s.lastAt = time.Now()
+	m.serves[internalID] = s
+}
+
+func (m *Memory) RegionPopularity(_ context.Context) ([]RegionPopularity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snap := m.snap.snapshot.Load()
+	out := make([]RegionPopularity, 0, len(snap.regions))
+	for id, region := range snap.regions {
+		p := RegionPopularity{
+			RegionID:   region.ID,
+			Name:       region.Name,
+			Challenges: len(snap.challengesByRegion[id]),
+		}
+		for _, s := range m.serves {
+			if s.regionID != id {
+				continue
+			}
+			p.Served += s.served
+			p.ChallengesServed++
+			if p.LastServedAt == nil || s.lastAt.After(*p.LastServedAt) {
+				lastAt := s.lastAt
+				p.LastServedAt = &lastAt
+			}
+		}
+		out = append(out, p)
+	}
+	sortPopularity(out)
+	return out, nil
+}
+
+func (m *Memory) ChallengePopularity(_ context.Context, regionID *int, limit int) ([]ChallengePopularity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []ChallengePopularity
+	for id, s := range m.serves {
+		if regionID != nil && s.regionID != *regionID {
+			continue
+		}
+		challengeID, err := encodeChallengeID(id)
+		if err != nil {
+			return nil, err
+		}
+		p := ChallengePopularity{
+			ID:           challengeID,
+			RegionID:     strconv.Itoa(s.regionID),
+			Served:       s.served,
+			LastServedAt: s.lastAt,
+		}
+		if c, ok := m.served(id); ok {
+			p.Title = c.Title
+		}
+		out = append(out, p)
+	}
+	slices.SortFunc(out, func(a, b ChallengePopularity) int {
+		if c := cmp.Compare(b.Served, a.Served); c != 0 {
+			return c
+		}
+		x, _ := decodeChallengeID(a.ID)
+		y, _ := decodeChallengeID(b.ID)
+		return cmp.Compare(x, y)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (m *Memory) OutboundLink(target string, challengeID string) string {
+	return m.snap.OutboundLink(target, challengeID)
+}
+
+func (m *Memory) RecordOutboundClick(_ context.Context, target string, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	target = clickTarget(target)
+	if _, ok := m.clicks[target]; !ok && len(m.clicks) >= maxOutboundTargetsPerDay {
+		target = otherOutboundTarget
+	}
+	m.clicks[target]++
+	return nil
+}
+
+// StartCampaign orders the campaign by challenge ID rather than shuffling it
+func (m *Memory) StartCampaign(ctx context.Context, player string, regionID int) (Campaign, error) {
+	list := m.SearchChallenges(ChallengeFilter{RegionIDs: []int{regionID}}, 0)
+	if len(list) == 0 {
+		return Campaign{}, NoChallengesAvailableError
+	}
+	m.mu.Lock()
+	key := campaignKey(player, regionID)
+	if _, ok := m.campaigns[key]; !ok {
+		order := make([]int, 0, len(list))
+		for _, c := range list {
+			id, _ := decodeChallengeID(c.ID)
+			order = append(order, id)
+		}
+		m.campaigns[key] = &memoryCampaign{order: order, startedAt: time.Now()}
+	}
+	m.mu.Unlock()
+	return m.Campaign(ctx, player, regionID)
+}
+
+func (m *Memory) Campaign(_ context.Context, player string, regionID int) (Campaign, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, _, err := m.loadCampaign(player, regionID)
+	return c, err
+}
+
+func (m *Memory) AdvanceCampaign(_ context.Context, player string, regionID int, challengeID string) (Campaign, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, nextIndex, err := m.loadCampaign(player, regionID)
+	if err != nil {
+		return Campaign{}, err
+	}
+	if c.Next == nil || c.Next.ID != challengeID {
+		return Campaign{}, NotNextInCampaignError
+	}
+	stored := m.campaigns[campaignKey(player, regionID)]
+	stored.cursor = nextIndex + 1
+	if stored.cursor >= len(stored.order) {
+		now := time.Now()
+		stored.completedAt = &now
+	}
+	c, _, err = m.loadCampaign(player, regionID)
+	return c, err
+}
+
+// loadCampaign must be called with mu held
+func (m *Memory) loadCampaign(player string, regionID int) (Campaign, int, error) {
+	stored, ok := m.campaigns[campaignKey(player, regionID)]
+	if !ok {
+		return Campaign{}, 0, CampaignNotFoundError
+	}
+	c := Campaign{
+		RegionID:    strconv.Itoa(regionID),
+		Total:       len(stored.order),
+		Completed:   stored.cursor,
+		StartedAt:   stored.startedAt,
+		CompletedAt: stored.completedAt,
+	}
+	for i := stored.cursor; i < len(stored.order); i++ {
+		if next, ok := m.served(stored.order[i]); ok {
+			v := *next
+			c.Next = &v
+			c.Completed = i
+			return c, i, nil
+		}
+	}
+
+	// Only retired challenges were left
+	c.Completed = len(stored.order)
+	if stored.completedAt == nil {
+		now := time.Now()
+		stored.cursor = len(stored.order)
+		stored.completedAt = &now
+		c.CompletedAt = &now
+	}
+	return c, len(stored.order), nil
+}
+
+func campaignKey(player string, regionID int) string {
+	return player + "\x00" + strconv.Itoa(regionID)
+}
+
+func (m *Memory) SubmitScore(_ context.Context, board string, game string, _ string, name string, total int, rounds int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.scores {
+		if s.board == board && s.game == game {
+			return GameAlreadySubmittedError
+		}
+	}
+	m.scores = append(m.scores, memoryScore{
+		board: board,
+		game:  game,
+		entry: LeaderboardEntry{Name: name, Total: total, Rounds: rounds, CreatedAt: time.Now()},
+	})
+	return nil
+}
+
+func (m *Memory) Leaderboard(_ context.Context, board string, limit int) ([]LeaderboardEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]LeaderboardEntry, 0)
+	for _, s := range m.scores {
+		if s.board == board {
+			out = append(out, s.entry)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b LeaderboardEntry) int {
+		return cmp.Compare(b.Total, a.Total)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// CreateCustomGame hands out share codes in sequence
+func (m *Memory) CreateCustomGame(ctx context.Context, _ string, name string, challengeIDs []string) (CustomGame, error) {
+	if len(challengeIDs) == 0 || len(challengeIDs) > MaxCustomGameChallenges {
+		return CustomGame{}, InvalidCustomGameError
+	}
+	ids := make([]int, 0, len(challengeIDs))
+	for _, id := range challengeIDs {
+		internalID, err := decodeChallengeID(id)
+		if err != nil {
+			return CustomGame{}, InvalidCustomGameError
+		}
+		if _, ok := m.served(internalID); !ok || slices.Contains(ids, internalID) {
+			return CustomGame{}, InvalidCustomGameError
+		}
+		ids = append(ids, internalID)
+	}
+
+	m.mu.Lock()
+	m.nextCode++
+	code := sequentialShareCode(m.nextCode)
+	m.customGames[code] = memoryCustomGame{name: name, ids: ids, createdAt: time.Now()}
+	m.mu.Unlock()
+	return m.CustomGame(ctx, code)
+}
+
+// CreateCustomGameFromFilter takes the first n matches rather than a random
+// sample
+func (m *Memory) CreateCustomGameFromFilter(ctx context.Context, creator string, name string, f ChallengeFilter, n int) (CustomGame, error) {
+	ids := make([]string, 0, n)
+	for _, c := range m.snap.snapshot.Load().spreadOut(m.SearchChallenges(f, 0), n) {
+		ids = append(ids, c.ID)
+	}
+	return m.CreateCustomGame(ctx, creator, name, ids)
+}
+
+func (m *Memory) CustomGame(_ context.Context, code string) (CustomGame, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.customGames[code]
+	if !ok {
+		return CustomGame{}, CustomGameNotFoundError
+	}
+	g := CustomGame{Code: code, Name: stored.name, CreatedAt: stored.createdAt}
+	for _, id := range stored.ids {
+		if c, ok := m.served(id); ok {
+			g.Challenges = append(g.Challenges, *c)
+		}
+	}
+	return g, nil
+}
+
+func sequentialShareCode(n int) string {
+	b := []byte(strings.Repeat(shareCodeAlphabet[:1], 8))
+	for i := len(b) - 1; n > 0 && i >= 0; i-- {
+		b[i] = shareCodeAlphabet[n%len(shareCodeAlphabet)]
+		n /= len(shareCodeAlphabet)
+	}
+	return string(b)
+}
+
+// CreatePartner returns tokens of the form partner-1, partner-2 and so on
+func (m *Memory) CreatePartner(_ context.Context, name string) (Partner, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := Partner{ID: len(m.partners) + 1, Name: name}
+	token := "partner-" + strconv.Itoa(p.ID)
+	m.partners[hashPartnerToken(token)] = p
+	return p, token, nil
+}
+
+func (m *Memory) PartnerByToken(_ context.Context, token string) (Partner, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.partners[hashPartnerToken(token)]
+	if !ok {
+		return Partner{}, PartnerNotFoundError
+	}
+	return p, nil
+}
+
+// CreateAPIKey returns keys of the form apikey-1, apikey-2 and so on
+func (m *Memory) CreateAPIKey(_ context.Context, name string, dailyQuota int) (APIKey, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := APIKey{ID: len(m.apiKeys) + 1, Name: name, DailyQuota: dailyQuota, CreatedAt: time.Now()}
+	token := "apikey-" + strconv.Itoa(k.ID)
+	m.apiKeys = append(m.apiKeys, k)
+	m.apiKeyIDs[hashPartnerToken(token)] = k.ID
+	return k, token, nil
+}
+
+func (m *Memory) APIKeyByToken(_ context.Context, token string) (APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.apiKeyIDs[hashPartnerToken(token)]
+	if !ok || m.apiKeys[id-1].RevokedAt != nil {
+		return APIKey{}, APIKeyNotFoundError
+	}
+	return m.apiKeys[id-1], nil
+}
+
+func (m *Memory) APIKeys(_ context.Context) ([]APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.apiKeys), nil
+}
+
+func (m *Memory) SetAPIKeyQuota(_ context.Context, id int, dailyQuota int) (APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.apiKeys) {
+		return APIKey{}, APIKeyNotFoundError
+	}
+	m.apiKeys[id-1].DailyQuota = dailyQuota
+	return m.apiKeys[id-1], nil
+}
+
+func (m *Memory) RevokeAPIKey(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.apiKeys) {
+		return APIKeyNotFoundError
+	}
+	if m.apiKeys[id-1].RevokedAt == nil {
+		now := time.Now()
+		m.apiKeys[id-1].RevokedAt = &now
+	}
+	return nil
+}
+
+func (m *Memory) RequestOptOut(_ context.Context, link string, contact string, reason string) (OptOut, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o := OptOut{
+		ID:               len(m.optOuts) + 1,
+		Account:          PhotographerAccount(link),
+		PhotographerLink: link,
+		Contact:          contact,
+		Reason:           reason,
+		State:            OptOutPending,
+		CreatedAt:        time.Now(),
+	}
+	m.optOuts = append(m.optOuts, o)
+	return o, nil
+}
+
+func (m *Memory) OptOuts(_ context.Context, state OptOutState) ([]OptOut, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []OptOut
+	for _, o := range m.optOuts {
+		if o.State == state {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) ReviewOptOut(_ context.Context, id int, state OptOutState) (OptOut, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.optOuts) {
+		return OptOut{}, nil, OptOutNotFoundError
+	}
+	o := &m.optOuts[id-1]
+	if o.State != OptOutPending {
+		return OptOut{}, nil, OptOutAlreadyReviewedError
+	}
+	now := time.Now()
+	o.State = state
+	o.ReviewedAt = &now
+	if state != OptOutApproved {
+		return *o, nil, nil
+	}
+
+	var archived []string
+	for _, internalID := range slices.Sorted(maps.Keys(m.fixtures)) {
+		if m.archived[internalID] || PhotographerAccount(m.fixtures[internalID].Challenge.Photographer.Link) != o.Account {
+			continue
+		}
+		m.archived[internalID] = true
+		archived = append(archived, m.fixtures[internalID].Challenge.ID)
+	}
+	m.restock()
+	return *o, archived, nil
+}
+
+func (m *Memory) PhotographerStats(_ context.Context, account string) (PhotographerStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.snap.snapshot.Load()
+	challenges := s.photographerChallenges(account)
+	if len(challenges) == 0 {
+		return PhotographerStats{}, PhotographerNotFoundError
+	}
+	played := make(map[int]int64, len(challenges))
+	for id := range challenges {
+		played[id] = m.serves[id].served
+	}
+	return s.photographerStats(account, challenges, played), nil
+}
+
+func (m *Memory) FlagChallenge(_ context.Context, partner Partner, challengeID string, reason string) (ModerationState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mod, err := m.moderationOf(challengeID)
+	if err != nil {
+		return "", err
+	}
+	mod.Flags = append(mod.Flags, ChallengeFlag{Partner: partner.Name, Reason: reason, CreatedAt: time.Now()})
+	if canTransition(mod.State, ModerationQuarantined) {
+		mod.State = ModerationQuarantined
+		mod.Note = ""
+		mod.UpdatedAt = time.Now()
+		m.restock()
+	}
+	return mod.State, nil
+}
+
+func (m *Memory) ReviewChallenge(_ context.Context, challengeID string, to ModerationState, note string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mod, err := m.moderationOf(challengeID)
+	if err != nil {
+		return err
+	}
+	if !canTransition(mod.State, to) {
+		return InvalidModerationTransitionError
+	}
+	mod.State = to
+	mod.Note = note
+	mod.UpdatedAt = time.Now()
+	m.restock()
+	return nil
+}
+
+// moderationOf must be called with mu held. Challenges that have never been
+// moderated start out published.
+func (m *Memory) moderationOf(challengeID string) (*ChallengeModeration, error) {
+	internalID, err := decodeChallengeID(challengeID)
+	if err != nil {
+		return nil, ChallengeNotFoundError
+	}
+	if _, ok := m.fixtures[internalID]; !ok || m.archived[internalID] {
+		return nil, ChallengeNotFoundError
+	}
+	mod, ok := m.moderation[internalID]
+	if !ok {
+		mod = &ChallengeModeration{ChallengeID: challengeID, State: ModerationPublished, Flags: make([]ChallengeFlag, 0)}
+		m.moderation[internalID] = mod
+	}
+	return mod, nil
+}
+
+func (m *Memory) ModerationQueue(_ context.Context, state ModerationState) ([]ChallengeModeration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ChallengeModeration, 0)
+	for _, mod := range m.moderation {
+		if mod.State == state {
+			v := *mod
+			v.Flags = slices.Clone(mod.Flags)
+			out = append(out, v)
+		}
+	}
+	slices.SortFunc(out, func(a, b ChallengeModeration) int {
+		if c := a.UpdatedAt.Compare(b.UpdatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ChallengeID, b.ChallengeID)
+	})
+	return out, nil
+}
+
+func (m *Memory) ScheduledEvents(_ context.Context, since time.Time) ([]ScheduledEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ScheduledEvent, 0)
+	for _, e := range m.events {
+		if e.EndsAt.After(since) || e.Recurrence != "" {
+			out = append(out, e)
+		}
+	}
+	slices.SortFunc(out, func(a, b ScheduledEvent) int {
+		if c := a.StartsAt.Compare(b.StartsAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
+
+func (m *Memory) CreateScheduledEvent(_ context.Context, e ScheduledEvent) (ScheduledEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextEventID++
+	e.ID = m.nextEventID
+	e.UpdatedAt = time.Now()
+	m.events = append(m.events, e)
+	return e, nil
+}
+
+func (m *Memory) DeleteScheduledEvent(_ context.Context, id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.events)
+	m.events = slices.DeleteFunc(m.events, func(e ScheduledEvent) bool {
+		return e.ID == id
+	})
+	return len(m.events) < n, nil
+}
+
+// Expiry isn't tracked, so cached results last as long as m
+func (m *Memory) CachedGeocode(_ context.Context, kind string, lng float64, lat float64) (json.RawMessage, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result, ok := m.geocodes[geocodeKey(kind, lng, lat)]
+	return result, ok, nil
+}
+
+func (m *Memory) SetCachedGeocode(_ context.Context, kind string, lng float64, lat float64, result json.RawMessage, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.geocodes[geocodeKey(kind, lng, lat)] = result
+	return nil
+}
+
+// geocodeKey rounds to three decimal places like the geocode_cache table
+func geocodeKey(kind string, lng float64, lat float64) string {
+	return kind + " " + strconv.FormatFloat(lng, 'f', 3, 64) + " " + strconv.FormatFloat(lat, 'f', 3, 64)
+}
+
+func (m *Memory) Setting(_ context.Context, key string, v any) (bool, error) {
+	m.mu.Lock()
+	value, ok := m.settings[key]
+	m.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(value, v)
+}
+
+func (m *Memory) SetSetting(_ context.Context, key string, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings[key] = value
+	return nil
+}
+
+// geometryCovers reports whether a GeoJSON Polygon or MultiPolygon contains
+// the point. Holes are handled by counting crossings over every ring.
+func geometryCovers(geometry json.RawMessage, lng float64, lat float64) bool {
+	var g struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(geometry, &g); err != nil {
+		return false
+	}
+	var polygons [][][][2]float64
+	switch g.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return false
+		}
+		polygons = append(polygons, polygon)
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return false
+		}
+	}
+	for _, polygon := range polygons {
+		inside := false
+		for _, ring := range polygon {
+			for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+				a, b := ring[i], ring[j]
+				if (a[1] > lat) != (b[1] > lat) && lng < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+					inside = !inside
+				}
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,318 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func memoryChallengeIDs(m *Memory, region *int) []string {
+	var ids []string
+	for _, c := range m.SearchChallenges(m.regionFilter(region), 0) {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestMemoryServesFixtures(t *testing.T) {
+	m := NewMemory(DefaultFixtures())
+	if !m.Populated() {
+		t.Fatal("expected fixtures to be served")
+	}
+	if got := m.ChallengesPerRegion(); got[1] != 3 || got[2] != 3 {
+		t.Errorf("expected three challenges per region, got %v", got)
+	}
+	regions, err := m.RegionsV2JSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v2 struct {
+		Regions []struct {
+			MapLayers []ParsedMapLayer `json:"map_layers"`
+		} `json:"regions"`
+	}
+	if err := json.Unmarshal(regions.Body, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if len(v2.Regions) != 2 || len(v2.Regions[0].MapLayers) != 1 {
+		t.Errorf("expected both regions with a parsed map layer, got %s", regions.Body)
+	}
+
+	c, err := m.Challenge(testChallengeID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.ChallengeJSON(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Challenge
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Title != "Snowdon from Llyn Llydaw" || decoded.DescriptionHTML != "<p>Snowdon from Llyn Llydaw</p>" {
+		t.Errorf("unexpected encoding %s", b)
+	}
+	if decoded.License != "CC-BY-2.0" || decoded.LicenseURL != "https://creativecommons.org/licenses/by/2.0/" || !decoded.AttributionRequired {
+		t.Errorf("expected the fixture's license to be filled in, got %s", b)
+	}
+}
+
+func TestMemoryRandomChallengeIsDeterministic(t *testing.T) {
+	region := 2
+	want := memoryChallengeIDs(NewMemory(DefaultFixtures()), &region)
+	for range 2 {
+		m := NewMemory(DefaultFixtures())
+		var got []string
+		for range len(want) {
+			c, err := m.RandomChallenge(&region)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, c.ID)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestMemoryWithholdsChallenges(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(DefaultFixtures())
+	region := 1
+	ids := memoryChallengeIDs(m, &region)
+
+	p, token, err := m.CreatePartner(ctx, "Park authority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.PartnerByToken(ctx, token); err != nil || got != p {
+		t.Fatalf("expected to look up the partner, got %+v %v", got, err)
+	}
+	state, err := m.FlagChallenge(ctx, p, ids[0], "nesting birds")
+	if err != nil || state != ModerationQuarantined {
+		t.Fatalf("expected quarantine, got %s %v", state, err)
+	}
+	if _, err := m.Challenge(ids[0]); err != ChallengeNotFoundError {
+		t.Errorf("expected a quarantined challenge to be withheld, got %v", err)
+	}
+	if err := m.ReviewChallenge(ctx, ids[0], ModerationCleared, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Challenge(ids[0]); err != nil {
+		t.Errorf("expected a cleared challenge to be served, got %v", err)
+	}
+
+	// Around Tryfan but not the other two
+	zone := PrivacyZone{
+		Name:     "crag",
+		Geometry: json.RawMessage(`{"type":"Polygon","coordinates":[[[-4.01,53.11],[-3.98,53.11],[-3.98,53.12],[-4.01,53.12],[-4.01,53.11]]]}`),
+	}
+	excluded, err := m.SetPrivacyZone(ctx, region, zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(excluded, []string{ids[1]}) {
+		t.Errorf("expected the zone to exclude %s, got %v", ids[1], excluded)
+	}
+	if got := memoryChallengeIDs(m, &region); !slices.Equal(got, []string{ids[0], ids[2]}) {
+		t.Errorf("expected the zoned challenge to be withheld, got %v", got)
+	}
+
+	if err := m.ArchiveChallenge(ctx, ids[2], "test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.ChallengesPerRegion()[region]; got != 1 {
+		t.Errorf("expected one challenge left, got %d", got)
+	}
+	if err := m.RestoreChallenge(ctx, ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeletePrivacyZone(ctx, region, "crag"); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.ChallengesPerRegion()[region]; got != 3 {
+		t.Errorf("expected all three challenges back, got %d", got)
+	}
+}
+
+func TestMemoryCampaign(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(DefaultFixtures())
+	region := 2
+	ids := memoryChallengeIDs(m, &region)
+
+	c, err := m.StartCampaign(ctx, "p1", region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range ids {
+		if c.Next == nil || c.Next.ID != id {
+			t.Fatalf("round %d: expected %s next, got %+v", i, id, c.Next)
+		}
+		if i < len(ids)-1 {
+			if _, err := m.AdvanceCampaign(ctx, "p1", region, ids[len(ids)-1]); err != NotNextInCampaignError {
+				t.Errorf("expected advancing out of order to fail, got %v", err)
+			}
+		}
+		c, err = m.AdvanceCampaign(ctx, "p1", region, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Completed != len(ids) || c.CompletedAt == nil || c.Next != nil {
+		t.Errorf("expected a completed campaign, got %+v", c)
+	}
+}
+
+func TestMemoryCampaignWithRetiredChallenges(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(DefaultFixtures())
+	region := 2
+	ids := memoryChallengeIDs(m, &region)
+	if len(ids) < 2 {
+		t.Fatalf("expected at least two challenges in region %d", region)
+	}
+
+	if _, err := m.StartCampaign(ctx, "p1", region); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AdvanceCampaign(ctx, "p1", region, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids[1:] {
+		if err := m.ArchiveChallenge(ctx, id, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := m.Campaign(ctx, "p1", region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Next != nil || c.Completed != c.Total || c.CompletedAt == nil {
+		t.Errorf("expected retired challenges to count as done, got %+v", c)
+	}
+}
+
+func TestGeometryCovers(t *testing.T) {
+	// A square with a square hole
+	polygon := json.RawMessage(`{"type":"Polygon","coordinates":[
+		[[0,0],[10,0],[10,10],[0,10],[0,0]],
+		[[4,4],[6,4],[6,6],[4,6],[4,4]]
+	]}`)
+	tests := []struct {
+		lng, lat float64
+		want     bool
+	}{
+		{1, 1, true},
+		{5, 5, false},
+		{11, 5, false},
+	}
+	for _, tt := range tests {
+		if got := geometryCovers(polygon, tt.lng, tt.lat); got != tt.want {
+			t.Errorf("%v,%v: expected %v", tt.lng, tt.lat, tt.want)
+		}
+	}
+
+	multi := json.RawMessage(`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,5]]]]}`)
+	if !geometryCovers(multi, 5.8, 5.2) {
+		t.Error("expected the second polygon to cover the point")
+	}
+}
+
+func TestSequentialShareCode(t *testing.T) {
+	if a, b := sequentialShareCode(1), sequentialShareCode(2); a == b || len(a) != 8 {
+		t.Errorf("expected distinct 8 character codes, got %s %s", a, b)
+	}
+}
+
+func TestLoadFixturesExample(t *testing.T) {
+	f, err := LoadFixtures("../fixtures.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The example is the same as the fixtures the tests use
+	got, _ := json.Marshal(f)
+	want, _ := json.Marshal(DefaultFixtures())
+	if string(got) != string(want) {
+		t.Errorf("expected example to match DefaultFixtures, got %s", got)
+	}
+
+	m := NewMemory(f)
+	if len(m.Regions()) != 2 || len(m.SampleChallenges(10)) != 6 {
+		t.Errorf("expected 2 regions and 6 challenges to be served")
+	}
+}
+
+func TestLoadFixturesInvalid(t *testing.T) {
+	for name, contents := range map[string]string{
+		"region id":      `{"regions":[{"id":"one"}]}`,
+		"unknown region": `{"regions":[{"id":"1"}],"challenges":[{"challenge":{"region_id":"2"}}]}`,
+		"syntax":         `{"regions":`,
+	} {
+		path := filepath.Join(t.TempDir(), "fixtures.json")
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadFixtures(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMemoryRecordOutboundClickAggregatesTargets(t *testing.T) {
+	m := NewMemory(DefaultFixtures())
+	ctx := context.Background()
+	for _, target := range []string{
+		"https://www.flickr.com/photos/x/1?utm_source=a",
+		"https://WWW.flickr.com/photos/x/1?utm_source=b#comments",
+	} {
+		if err := m.RecordOutboundClick(ctx, target, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := m.clicks["https://www.flickr.com/photos/x/1"]; got != 2 {
+		t.Fatalf("expected 2 clicks on the bare target, got %v", m.clicks)
+	}
+
+	for i := len(m.clicks); i < maxOutboundTargetsPerDay; i++ {
+		m.clicks[fmt.Sprintf("https://www.flickr.com/photos/x/%d", i+100)] = 1
+	}
+	if err := m.RecordOutboundClick(ctx, "https://www.flickr.com/photos/y/1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if m.clicks[otherOutboundTarget] != 1 || len(m.clicks) != maxOutboundTargetsPerDay+1 {
+		t.Fatalf("expected the new target to be counted as other, got %d rows", len(m.clicks))
+	}
+}
+
+func TestMemoryMissingWeatherExpires(t *testing.T) {
+	m := NewMemory(DefaultFixtures())
+	ctx := context.Background()
+	if err := m.SetChallengeWeather(ctx, "ae", json.RawMessage(`{"time":"2023-06-01T14:00"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetChallengeWeather(ctx, "ai", json.RawMessage("null")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := m.ChallengeWeather(ctx, "ai"); !ok {
+		t.Error("expected a fresh null to be cached")
+	}
+
+	for id, w := range m.weather {
+		w.fetchedAt = w.fetchedAt.Add(-2 * missingWeatherTTL)
+		m.weather[id] = w
+	}
+	if _, ok, _ := m.ChallengeWeather(ctx, "ai"); ok {
+		t.Error("expected an old null to be fetched again")
+	}
+	if got, ok, _ := m.ChallengeWeather(ctx, "ae"); !ok || string(got) != `{"time":"2023-06-01T14:00"}` {
+		t.Errorf("expected conditions to be kept, got %s", got)
+	}
+}
@@ -0,0 +1,81 @@
+package repos
+
+import (
+	"context"
+	"embed"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"io/fs"
+	"log/slog"
+	"sort"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Arbitrary key so that replicas starting together apply migrations one at a time
+const migrationsLockID = 0x6367617069
+
+// Migrate applies the schema for the tables owned by the API. The challenge and
+// region tables are owned by the ingestion pipeline and are not managed here.
+func Migrate(ctx context.Context, db *pgxpool.Pool) error {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationsLockID); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationsLockID)
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS api_schema_migrations (
+			name text PRIMARY KEY,
+			applied_at timestamp with time zone NOT NULL DEFAULT now()
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var applied bool
+		err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM api_schema_migrations WHERE name = $1)`, name).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		sql, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("applying migration", "migration", name)
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, string(sql)); err != nil {
+			_ = tx.Rollback(ctx)
+			return err
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO api_schema_migrations (name) VALUES ($1)`, name); err != nil {
+			_ = tx.Rollback(ctx)
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+CREATE TABLE guesses
+(
+    id           bigserial PRIMARY KEY,
+    challenge_id integer                  NOT NULL REFERENCES challenges (id),
+    geo          geography(Point, 4326)   NOT NULL,
+    inserted_at  timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX guesses_challenge_id_idx ON guesses (challenge_id);
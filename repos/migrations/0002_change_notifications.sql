@@ -0,0 +1,46 @@
+CREATE FUNCTION notify_challenges_changed() RETURNS trigger AS
+$$
+BEGIN
+    PERFORM pg_notify('challenges_changed', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION notify_regions_changed() RETURNS trigger AS
+$$
+BEGIN
+    PERFORM pg_notify('regions_changed', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER challenges_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON challenges
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_challenges_changed();
+
+-- Activating or deactivating a region changes which challenges are served
+CREATE TRIGGER regions_changed_challenges
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON regions
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_challenges_changed();
+
+CREATE TRIGGER regions_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON regions
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_regions_changed();
+
+CREATE TRIGGER map_layers_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON map_layers
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_regions_changed();
+
+CREATE TRIGGER region_map_layers_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON region_map_layers
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_regions_changed();
@@ -0,0 +1,7 @@
+CREATE TABLE challenge_weather
+(
+    challenge_id integer PRIMARY KEY REFERENCES challenges (id) ON DELETE CASCADE,
+    -- null when the archive has no data, so that we don't ask again
+    conditions   jsonb,
+    fetched_at   timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,30 @@
+-- Populated by the OSM import. kind is one of trailhead, bothy, transport_stop.
+CREATE TABLE osm_pois
+(
+    osm_id bigint PRIMARY KEY,
+    kind   text                   NOT NULL,
+    name   text,
+    geo    geography(Point, 4326) NOT NULL
+);
+
+CREATE INDEX osm_pois_geo_idx ON osm_pois USING gist (geo);
+
+CREATE MATERIALIZED VIEW challenge_nearby_pois AS
+SELECT c.id                      AS challenge_id,
+       k.kind,
+       p.osm_id,
+       p.name,
+       p.geo,
+       ST_Distance(c.geo, p.geo) AS distance_m
+FROM challenges AS c
+         CROSS JOIN (VALUES ('trailhead'), ('bothy'), ('transport_stop')) AS k(kind)
+         CROSS JOIN LATERAL (
+    SELECT osm_id, name, geo
+    FROM osm_pois
+    WHERE osm_pois.kind = k.kind
+      AND ST_DWithin(c.geo, osm_pois.geo, 25000)
+    ORDER BY c.geo <-> osm_pois.geo
+    LIMIT 1
+    ) AS p;
+
+CREATE UNIQUE INDEX challenge_nearby_pois_idx ON challenge_nearby_pois (challenge_id, kind);
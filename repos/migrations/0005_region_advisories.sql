@@ -0,0 +1,15 @@
+CREATE TABLE region_advisories
+(
+    region_id             integer PRIMARY KEY REFERENCES regions (id) ON DELETE CASCADE,
+    avalanche_service_url text,
+    access_notes          text,
+    -- [{"from": "MM-DD", "to": "MM-DD", "note": "..."}]
+    seasonal_restrictions jsonb                    NOT NULL DEFAULT '[]',
+    updated_at            timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE TRIGGER region_advisories_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON region_advisories
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_regions_changed();
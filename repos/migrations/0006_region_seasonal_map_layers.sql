@@ -0,0 +1,16 @@
+CREATE TABLE region_seasonal_map_layers
+(
+    region_id    integer NOT NULL REFERENCES regions (id) ON DELETE CASCADE,
+    map_layer_id integer NOT NULL REFERENCES map_layers (id) ON DELETE CASCADE,
+    season       text    NOT NULL,
+    -- e.g. from 12-01 to 02-28 for a winter variant
+    from_day     text    NOT NULL CHECK (from_day ~ '^\d\d-\d\d$'),
+    to_day       text    NOT NULL CHECK (to_day ~ '^\d\d-\d\d$'),
+    PRIMARY KEY (region_id, season)
+);
+
+CREATE TRIGGER region_seasonal_map_layers_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON region_seasonal_map_layers
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_regions_changed();
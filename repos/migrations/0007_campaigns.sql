@@ -0,0 +1,11 @@
+CREATE TABLE campaigns
+(
+    player_id       text                     NOT NULL,
+    region_id       integer                  NOT NULL REFERENCES regions (id) ON DELETE CASCADE,
+    -- Shuffled when the campaign starts, challenges added later aren't included
+    challenge_order integer[]                NOT NULL,
+    cursor          integer                  NOT NULL DEFAULT 0,
+    started_at      timestamp with time zone NOT NULL DEFAULT now(),
+    completed_at    timestamp with time zone,
+    PRIMARY KEY (player_id, region_id)
+);
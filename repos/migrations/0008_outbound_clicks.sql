@@ -0,0 +1,9 @@
+CREATE TABLE outbound_clicks
+(
+    target       text    NOT NULL,
+    day          date    NOT NULL DEFAULT current_date,
+    -- 0 when the link wasn't followed from a challenge
+    challenge_id integer NOT NULL DEFAULT 0,
+    clicks       bigint  NOT NULL DEFAULT 0,
+    PRIMARY KEY (target, day, challenge_id)
+);
@@ -0,0 +1,12 @@
+-- Keyed by coordinates rounded to about 100m so that nearby lookups share an
+-- entry. result is null when there is nothing at the location. Expired rows are
+-- overwritten by the next lookup.
+CREATE TABLE geocode_cache
+(
+    kind       text                     NOT NULL,
+    lng_key    numeric(7, 3)            NOT NULL,
+    lat_key    numeric(6, 3)            NOT NULL,
+    result     jsonb,
+    expires_at timestamp with time zone NOT NULL,
+    PRIMARY KEY (kind, lng_key, lat_key)
+);
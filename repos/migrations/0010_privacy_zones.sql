@@ -0,0 +1,20 @@
+-- Areas around private dwellings, sensitive nesting sites and the like.
+-- Challenges inside a zone are never served, and the ingest pipeline should
+-- skip candidate photos that fall inside one.
+CREATE TABLE privacy_zones
+(
+    region_id  integer                   NOT NULL REFERENCES regions (id) ON DELETE CASCADE,
+    name       text                      NOT NULL,
+    reason     text,
+    geo        geography(Geometry, 4326) NOT NULL,
+    updated_at timestamp with time zone  NOT NULL DEFAULT now(),
+    PRIMARY KEY (region_id, name)
+);
+
+CREATE INDEX privacy_zones_geo_idx ON privacy_zones USING gist (geo);
+
+CREATE TRIGGER privacy_zones_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON privacy_zones
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_challenges_changed();
@@ -0,0 +1,36 @@
+-- Land managers and other partners who can flag published challenges
+CREATE TABLE partners
+(
+    id         serial PRIMARY KEY,
+    name       text                     NOT NULL,
+    -- hex sha256 of the bearer token, which is only shown once
+    token_hash text                     NOT NULL UNIQUE,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE TABLE challenge_flags
+(
+    id           serial PRIMARY KEY,
+    challenge_id integer                  NOT NULL REFERENCES challenges (id) ON DELETE CASCADE,
+    partner_id   integer                  NOT NULL REFERENCES partners (id) ON DELETE CASCADE,
+    reason       text                     NOT NULL,
+    created_at   timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX challenge_flags_challenge_idx ON challenge_flags (challenge_id);
+
+-- Challenges without a row are published. Only published and cleared
+-- challenges are served.
+CREATE TABLE challenge_moderation
+(
+    challenge_id integer PRIMARY KEY REFERENCES challenges (id) ON DELETE CASCADE,
+    state        text                     NOT NULL CHECK (state IN ('quarantined', 'cleared', 'removed')),
+    note         text,
+    updated_at   timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE TRIGGER challenge_moderation_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON challenge_moderation
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_challenges_changed();
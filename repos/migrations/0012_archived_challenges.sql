@@ -0,0 +1,17 @@
+-- Retired challenges moved out of the primary tables. The archive keeps
+-- everything needed to restore them, including guesses, so that history
+-- survives for audits. It can live in a cheaper tablespace.
+CREATE TABLE archived_challenges
+(
+    id          integer PRIMARY KEY,
+    region_id   integer                  NOT NULL,
+    date_taken  timestamp with time zone,
+    -- The challenges row
+    challenge   jsonb                    NOT NULL,
+    -- {"guesses": [...], "weather": {...}, "moderation": {...}, "flags": [...]}
+    related     jsonb                    NOT NULL,
+    reason      text                     NOT NULL,
+    archived_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX archived_challenges_region_idx ON archived_challenges (region_id);
@@ -0,0 +1,7 @@
+-- Runtime configuration that can be changed without a deploy
+CREATE TABLE settings
+(
+    key        text PRIMARY KEY,
+    value      jsonb                    NOT NULL,
+    updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,14 @@
+CREATE TABLE leaderboard_scores
+(
+    id         bigserial PRIMARY KEY,
+    board      text                     NOT NULL,
+    -- Each game can only be submitted once
+    game_id    text                     NOT NULL UNIQUE,
+    player_id  text                     NOT NULL,
+    name       text                     NOT NULL,
+    total      integer                  NOT NULL,
+    rounds     integer                  NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX leaderboard_scores_board_idx ON leaderboard_scores (board, total DESC);
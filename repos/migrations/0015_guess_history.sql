@@ -0,0 +1,12 @@
+-- Guesses made before this migration have no player and can't be attributed
+ALTER TABLE guesses
+    ADD COLUMN player_id  text,
+    ADD COLUMN distance_m double precision;
+
+UPDATE guesses AS g
+SET distance_m = ST_Distance(g.geo, c.geo)
+FROM challenges AS c
+WHERE c.id = g.challenge_id;
+
+CREATE INDEX guesses_player_id_distance_m_idx ON guesses (player_id, distance_m)
+    WHERE player_id IS NOT NULL;
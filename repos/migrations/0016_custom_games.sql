@@ -0,0 +1,9 @@
+CREATE TABLE custom_games
+(
+    code          text PRIMARY KEY,
+    name          text                     NOT NULL,
+    creator_id    text                     NOT NULL,
+    -- Frozen when the game is created, in play order
+    challenge_ids integer[]                NOT NULL,
+    created_at    timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,16 @@
+-- Content events announced in the public calendar
+CREATE TABLE scheduled_events
+(
+    id          bigserial PRIMARY KEY,
+    kind        text                     NOT NULL CHECK (kind IN ('daily_reset', 'tournament', 'featured_region')),
+    title       text                     NOT NULL,
+    description text                     NOT NULL DEFAULT '',
+    region_id   integer REFERENCES regions (id) ON DELETE SET NULL,
+    starts_at   timestamp with time zone NOT NULL,
+    ends_at     timestamp with time zone NOT NULL CHECK (ends_at > starts_at),
+    -- An iCalendar RRULE value such as FREQ=DAILY, empty for one-off events
+    recurrence  text                     NOT NULL DEFAULT '',
+    updated_at  timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX scheduled_events_ends_at_idx ON scheduled_events (ends_at);
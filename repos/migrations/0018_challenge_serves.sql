@@ -0,0 +1,13 @@
+-- How often each challenge has been served to players. Counts are kept in
+-- memory and added here periodically, so they lag slightly. There's no
+-- foreign key so that counts survive archiving.
+CREATE TABLE challenge_serves
+(
+    challenge_id   integer PRIMARY KEY,
+    region_id      integer                  NOT NULL,
+    served         bigint                   NOT NULL,
+    last_served_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX challenge_serves_region_id_idx ON challenge_serves (region_id);
+CREATE INDEX challenge_serves_served_idx ON challenge_serves (served DESC);
@@ -0,0 +1,11 @@
+-- Challenge IDs may outgrow 32 bits, so the tables owned by the API store
+-- them as bigint ahead of the challenges table itself
+ALTER TABLE guesses ALTER COLUMN challenge_id TYPE bigint;
+ALTER TABLE challenge_weather ALTER COLUMN challenge_id TYPE bigint;
+ALTER TABLE outbound_clicks ALTER COLUMN challenge_id TYPE bigint;
+ALTER TABLE challenge_flags ALTER COLUMN challenge_id TYPE bigint;
+ALTER TABLE challenge_moderation ALTER COLUMN challenge_id TYPE bigint;
+ALTER TABLE archived_challenges ALTER COLUMN id TYPE bigint;
+ALTER TABLE campaigns ALTER COLUMN challenge_order TYPE bigint[];
+ALTER TABLE custom_games ALTER COLUMN challenge_ids TYPE bigint[];
+ALTER TABLE challenge_serves ALTER COLUMN challenge_id TYPE bigint;
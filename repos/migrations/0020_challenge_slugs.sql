@@ -0,0 +1,8 @@
+-- Vanity slugs admins give curated challenges, for links in articles and
+-- playlists. A challenge has at most one.
+CREATE TABLE challenge_slugs
+(
+    slug         text PRIMARY KEY,
+    challenge_id bigint UNIQUE            NOT NULL REFERENCES challenges (id) ON DELETE CASCADE,
+    created_at   timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,44 @@
+-- Populated by the OSM import along with the peak, valley and lake kinds of
+-- osm_pois, which are at their label point
+CREATE TABLE osm_admin_areas
+(
+    osm_id      bigint PRIMARY KEY,
+    name        text                          NOT NULL,
+    admin_level integer                       NOT NULL,
+    geo         geography(MultiPolygon, 4326) NOT NULL
+);
+
+CREATE INDEX osm_admin_areas_geo_idx ON osm_admin_areas USING gist (geo);
+
+CREATE MATERIALIZED VIEW challenge_landmarks AS
+SELECT c.id                      AS challenge_id,
+       k.kind,
+       p.osm_id,
+       p.name,
+       p.geo,
+       ST_Distance(c.geo, p.geo) AS distance_m
+FROM challenges AS c
+         CROSS JOIN (VALUES ('peak'), ('valley'), ('lake')) AS k(kind)
+         CROSS JOIN LATERAL (
+    SELECT osm_id, name, geo
+    FROM osm_pois
+    WHERE osm_pois.kind = k.kind
+      AND osm_pois.name IS NOT NULL
+      AND ST_DWithin(c.geo, osm_pois.geo, 25000)
+    ORDER BY c.geo <-> osm_pois.geo
+    LIMIT 1
+    ) AS p;
+
+CREATE UNIQUE INDEX challenge_landmarks_idx ON challenge_landmarks (challenge_id, kind);
+
+-- The smallest area each challenge is in
+CREATE MATERIALIZED VIEW challenge_admin_areas AS
+SELECT DISTINCT ON (c.id) c.id AS challenge_id,
+                          a.osm_id,
+                          a.name,
+                          a.admin_level
+FROM challenges AS c
+         JOIN osm_admin_areas AS a ON ST_Covers(a.geo, c.geo)
+ORDER BY c.id, a.admin_level DESC;
+
+CREATE UNIQUE INDEX challenge_admin_areas_idx ON challenge_admin_areas (challenge_id);
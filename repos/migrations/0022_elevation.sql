@@ -0,0 +1,12 @@
+-- Looked up from a DEM the first time a challenge is revealed or played in the
+-- elevation mode
+CREATE TABLE challenge_elevations
+(
+    challenge_id bigint PRIMARY KEY REFERENCES challenges (id) ON DELETE CASCADE,
+    elevation_m  real                     NOT NULL,
+    fetched_at   timestamp with time zone NOT NULL DEFAULT now()
+);
+
+-- Null until the guess is revealed
+ALTER TABLE guesses
+    ADD COLUMN elevation_m real;
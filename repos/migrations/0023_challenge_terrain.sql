@@ -0,0 +1,14 @@
+-- Computed from the DEM around each challenge by a backfill in the API, and
+-- served with the challenge from the next reload
+CREATE TABLE challenge_terrain
+(
+    challenge_id bigint PRIMARY KEY REFERENCES challenges (id) ON DELETE CASCADE,
+    slope_deg    real                     NOT NULL,
+    -- null on level ground
+    aspect_deg   real,
+    ruggedness_m real                     NOT NULL,
+    landform     text                     NOT NULL,
+    computed_at  timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX challenge_terrain_landform ON challenge_terrain (landform);
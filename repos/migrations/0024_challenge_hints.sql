@@ -0,0 +1,11 @@
+-- Every hint a player asks for, so that their guess at the challenge can be
+-- penalized
+CREATE TABLE challenge_hints
+(
+    challenge_id bigint                   NOT NULL REFERENCES challenges (id) ON DELETE CASCADE,
+    player_id    text                     NOT NULL,
+    level        smallint                 NOT NULL,
+    requested_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX challenge_hints_player ON challenge_hints (player_id, challenge_id);
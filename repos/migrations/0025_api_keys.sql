@@ -0,0 +1,11 @@
+-- Keys for third-party consumers of the API, each with a daily quota
+CREATE TABLE api_keys
+(
+    id          serial PRIMARY KEY,
+    name        text                     NOT NULL,
+    -- hex sha256 of the key, which is only shown once
+    token_hash  text                     NOT NULL UNIQUE,
+    daily_quota integer                  NOT NULL,
+    created_at  timestamp with time zone NOT NULL DEFAULT now(),
+    revoked_at  timestamp with time zone
+);
@@ -0,0 +1,46 @@
+-- Photographers asking for their photos to be taken out of the game
+CREATE TABLE photographer_opt_outs
+(
+    id                serial PRIMARY KEY,
+    -- Lowercased from photographer_link by photographer_account below
+    account           text                     NOT NULL,
+    photographer_link text                     NOT NULL,
+    contact           text                     NOT NULL,
+    reason            text                     NOT NULL DEFAULT '',
+    state             text                     NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'approved', 'rejected')),
+    created_at        timestamp with time zone NOT NULL DEFAULT now(),
+    reviewed_at       timestamp with time zone
+);
+
+-- Accounts whose photos can't become challenges
+CREATE TABLE photographer_blocklist
+(
+    account    text PRIMARY KEY,
+    opt_out_id integer REFERENCES photographer_opt_outs (id) ON DELETE SET NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+-- The Flickr account in a photographer link, as repos.photographerAccount
+CREATE FUNCTION photographer_account(link text) RETURNS text AS
+$$
+SELECT lower(substring(link FROM '^https?://(?:www\.)?flickr\.com/(?:people|photos)/([^/?#]+)'));
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Refuses challenges from blocked accounts at ingest, and when restoring from
+-- the archive
+CREATE FUNCTION reject_blocked_photographer() RETURNS trigger AS
+$$
+BEGIN
+    IF EXISTS (SELECT 1 FROM photographer_blocklist WHERE account = photographer_account(NEW.photographer_link)) THEN
+        RAISE EXCEPTION 'photographer % has opted out', photographer_account(NEW.photographer_link)
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER challenges_photographer_blocklist
+    BEFORE INSERT OR UPDATE OF photographer_link
+    ON challenges
+    FOR EACH ROW
+EXECUTE FUNCTION reject_blocked_photographer();
@@ -0,0 +1,9 @@
+-- Placeholders computed from the regular size photo by a backfill in the API,
+-- and served with the challenge from the next reload
+CREATE TABLE challenge_blurhashes
+(
+    challenge_id bigint PRIMARY KEY REFERENCES challenges (id) ON DELETE CASCADE,
+    -- Empty if the photo couldn't be decoded, so that it isn't tried again
+    blurhash     text                     NOT NULL,
+    computed_at  timestamp with time zone NOT NULL DEFAULT now()
+);
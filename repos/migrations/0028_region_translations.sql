@@ -0,0 +1,17 @@
+-- Region names in other languages, where the English exonym isn't what locals
+-- call it. Languages are lowercase BCP 47 tags like fr or nb-no.
+CREATE TABLE region_translations
+(
+    region_id   integer                  NOT NULL REFERENCES regions (id) ON DELETE CASCADE,
+    lang        text                     NOT NULL CHECK (lang = lower(lang)),
+    name        text                     NOT NULL,
+    description text                     NOT NULL DEFAULT '',
+    updated_at  timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (region_id, lang)
+);
+
+CREATE TRIGGER region_translations_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+    ON region_translations
+    FOR EACH STATEMENT
+EXECUTE FUNCTION notify_regions_changed();
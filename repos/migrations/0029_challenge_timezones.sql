@@ -0,0 +1,9 @@
+-- The timezone of each dated challenge's location, looked up by a backfill in
+-- the API so that the wall-clock time Flickr gives can be placed in time
+CREATE TABLE challenge_timezones
+(
+    challenge_id bigint PRIMARY KEY REFERENCES challenges (id) ON DELETE CASCADE,
+    -- IANA name, or empty if the lookup found nothing so that it isn't tried again
+    timezone     text                     NOT NULL,
+    computed_at  timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,15 @@
+-- Guesses as reported by clients, for difficulty stats and heatmaps. Append
+-- only, and not tied to challenges by a foreign key so that archiving a
+-- challenge leaves its history in place.
+CREATE TABLE guess_events
+(
+    id             bigserial PRIMARY KEY,
+    challenge_id   bigint                   NOT NULL,
+    geo            geography(Point, 4326)   NOT NULL,
+    distance_m     double precision         NOT NULL,
+    hints_used     smallint                 NOT NULL,
+    client_version text                     NOT NULL,
+    received_at    timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX guess_events_challenge_id_idx ON guess_events (challenge_id);
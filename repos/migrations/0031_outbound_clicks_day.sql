@@ -0,0 +1,2 @@
+-- Recording a click counts the day's rows to enforce the per-day cap
+CREATE INDEX outbound_clicks_day_idx ON outbound_clicks (day);
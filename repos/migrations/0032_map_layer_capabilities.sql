@@ -0,0 +1,9 @@
+-- The last capabilities fetched for each map layer, so that the first fetch
+-- after a restart can still be diffed against what was served before
+CREATE TABLE map_layer_capabilities
+(
+    map_layer_id integer PRIMARY KEY,
+    url          text                     NOT NULL,
+    xml          text                     NOT NULL,
+    fetched_at   timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+-- Hints are recorded against the play token the challenge was served with,
+-- rather than a player ID anyone could send. Earlier rows can no longer
+-- match a token, so they're dropped.
+DELETE FROM challenge_hints;
+
+ALTER TABLE challenge_hints RENAME COLUMN player_id TO play_nonce;
+
+ALTER INDEX challenge_hints_player RENAME TO challenge_hints_play_nonce;
@@ -0,0 +1,22 @@
+-- Refuses challenges inside a privacy zone at ingest, and when restoring from
+-- the archive. Challenges already inside a zone when it's set are kept, and
+-- withheld when the cache is loaded, so that removing the zone brings them back.
+CREATE FUNCTION reject_private_challenge() RETURNS trigger AS
+$$
+DECLARE
+    zone text;
+BEGIN
+    SELECT name INTO zone FROM privacy_zones WHERE region_id = NEW.region_id AND ST_Covers(geo, NEW.geo) LIMIT 1;
+    IF zone IS NOT NULL THEN
+        RAISE EXCEPTION 'challenge is inside privacy zone %', zone
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER challenges_privacy_zones
+    BEFORE INSERT OR UPDATE OF geo, region_id
+    ON challenges
+    FOR EACH ROW
+EXECUTE FUNCTION reject_private_challenge();
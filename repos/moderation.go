@@ -0,0 +1,237 @@
+package repos
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+type ModerationState string
+
+const (
+	ModerationPublished   ModerationState = "published"
+	ModerationQuarantined ModerationState = "quarantined"
+	ModerationCleared     ModerationState = "cleared"
+	ModerationRemoved     ModerationState = "removed"
+)
+
+// moderationTransitions lists the states each state can move to. Partner
+// flags quarantine anything that hasn't been removed, including challenges a
+// reviewer already cleared, and only reviewers can leave quarantine.
+var moderationTransitions = map[ModerationState][]ModerationState{
+	ModerationPublished:   {ModerationQuarantined, ModerationRemoved},
+	ModerationQuarantined: {ModerationCleared, ModerationRemoved},
+	ModerationCleared:     {ModerationQuarantined, ModerationRemoved},
+	ModerationRemoved:     {ModerationCleared},
+}
+
+func canTransition(from ModerationState, to ModerationState) bool {
+	for _, s := range moderationTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+type Partner struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type ChallengeFlag struct {
+	Partner   string    `json:"partner"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChallengeModeration struct {
+	ChallengeID string          `json:"challenge_id"`
+	State       ModerationState `json:"state"`
+	Note        string          `json:"note"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Flags       []ChallengeFlag `json:"flags"`
+}
+
+var PartnerNotFoundError = errors.New("partner not found")
+var InvalidModerationTransitionError = errors.New("invalid moderation transition")
+
+// CreatePartner returns the new partner's bearer token, which isn't stored
+func (r *Repo) CreatePartner(ctx context.Context, name string) (Partner, string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return Partner{}, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	p := Partner{Name: name}
+	err := r.db.QueryRow(ctx, `INSERT INTO partners (name, token_hash) VALUES ($1, $2) RETURNING id`,
+		name, hashPartnerToken(token)).Scan(&p.ID)
+	if err != nil {
+		return Partner{}, "", err
+	}
+	return p, token, nil
+}
+
+func (r *Repo) PartnerByToken(ctx context.Context, token string) (Partner, error) {
+	var p Partner
+	err := r.db.QueryRow(ctx, `SELECT id, name FROM partners WHERE token_hash = $1`, hashPartnerToken(token)).
+		Scan(&p.ID, &p.Name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Partner{}, PartnerNotFoundError
+	}
+	return p, err
+}
+
+func hashPartnerToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// FlagChallenge records a partner's concern and quarantines the challenge
+// until a reviewer decides. Flags on removed challenges are recorded but
+// don't change anything.
+func (r *Repo) FlagChallenge(ctx context.Context, partner Partner, challengeID string, reason string) (ModerationState, error) {
+	internalID, err := decodeChallengeID(challengeID)
+	if err != nil {
+		return "", ChallengeNotFoundError
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	state, err := moderationState(ctx, tx, internalID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO challenge_flags (challenge_id, partner_id, reason) VALUES ($1, $2, $3)`,
+		internalID, partner.ID, reason)
+	if err != nil {
+		return "", err
+	}
+
+	if canTransition(state, ModerationQuarantined) {
+		if err := setModerationState(ctx, tx, internalID, ModerationQuarantined, ""); err != nil {
+			return "", err
+		}
+		state = ModerationQuarantined
+	}
+
+	return state, tx.Commit(ctx)
+}
+
+// ReviewChallenge moves a challenge to the reviewer's decision
+func (r *Repo) ReviewChallenge(ctx context.Context, challengeID string, to ModerationState, note string) error {
+	internalID, err := decodeChallengeID(challengeID)
+	if err != nil {
+		return ChallengeNotFoundError
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	from, err := moderationState(ctx, tx, internalID)
+	if err != nil {
+		return err
+	}
+	if !canTransition(from, to) {
+		return InvalidModerationTransitionError
+	}
+	if err := setModerationState(ctx, tx, internalID, to, note); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ModerationQueue lists challenges in state along with the flags raised
+// against them, oldest first
+func (r *Repo) ModerationQueue(ctx context.Context, state ModerationState) ([]ChallengeModeration, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT m.challenge_id, m.state, coalesce(m.note, ''), m.updated_at,
+			coalesce(f.partner, ''), coalesce(f.reason, ''), f.created_at
+		FROM challenge_moderation AS m
+		LEFT JOIN LATERAL (
+			SELECT p.name AS partner, cf.reason, cf.created_at
+			FROM challenge_flags AS cf
+			JOIN partners AS p ON p.id = cf.partner_id
+			WHERE cf.challenge_id = m.challenge_id
+		) AS f ON true
+		WHERE m.state = $1
+		ORDER BY m.updated_at, m.challenge_id, f.created_at
+	`, string(state))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ChallengeModeration, 0)
+	for rows.Next() {
+		var internalID int
+		var m ChallengeModeration
+		var flag ChallengeFlag
+		var flaggedAt *time.Time
+		if err := rows.Scan(&internalID, &m.State, &m.Note, &m.UpdatedAt, &flag.Partner, &flag.Reason, &flaggedAt); err != nil {
+			return nil, err
+		}
+		m.ChallengeID, err = encodeChallengeID(internalID)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(out) == 0 || out[len(out)-1].ChallengeID != m.ChallengeID {
+			m.Flags = make([]ChallengeFlag, 0)
+			out = append(out, m)
+		}
+		if flaggedAt != nil {
+			flag.CreatedAt = *flaggedAt
+			last := &out[len(out)-1]
+			last.Flags = append(last.Flags, flag)
+		}
+	}
+	return out, rows.Err()
+}
+
+func moderationState(ctx context.Context, tx pgx.Tx, internalID int) (ModerationState, error) {
+	var exists bool
+	var state *string
+	err := tx.QueryRow(ctx, `
+		SELECT true, m.state
+		FROM challenges AS c
+		LEFT JOIN challenge_moderation AS m ON m.challenge_id = c.id
+		WHERE c.id = $1
+		FOR UPDATE OF c
+	`, internalID).Scan(&exists, &state)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ChallengeNotFoundError
+	} else if err != nil {
+		return "", err
+	}
+	if state == nil {
+		return ModerationPublished, nil
+	}
+	return ModerationState(*state), nil
+}
+
+func setModerationState(ctx context.Context, tx pgx.Tx, internalID int, state ModerationState, note string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO challenge_moderation (challenge_id, state, note)
+		VALUES ($1, $2, nullif($3, ''))
+		ON CONFLICT (challenge_id) DO UPDATE SET
+			state = EXCLUDED.state,
+			note = EXCLUDED.note,
+			updated_at = now()
+	`, internalID, string(state), note)
+	return err
+}
@@ -0,0 +1,25 @@
+package repos
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from ModerationState
+		to   ModerationState
+		want bool
+	}{
+		{ModerationPublished, ModerationQuarantined, true},
+		{ModerationPublished, ModerationCleared, false},
+		{ModerationQuarantined, ModerationCleared, true},
+		{ModerationQuarantined, ModerationRemoved, true},
+		{ModerationQuarantined, ModerationQuarantined, false},
+		{ModerationCleared, ModerationQuarantined, true},
+		{ModerationRemoved, ModerationQuarantined, false},
+		{ModerationRemoved, ModerationCleared, true},
+	}
+	for _, test := range tests {
+		if got := canTransition(test.from, test.to); got != test.want {
+			t.Errorf("%s -> %s: expected %v, got %v", test.from, test.to, test.want, got)
+		}
+	}
+}
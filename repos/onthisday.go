@@ -0,0 +1,43 @@
+package repos
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// OnThisDay returns challenges photographed on the same month and day as
+// date in any year, oldest first. Photos from the 29th of February show up on
+// the 28th in other years.
+func (r *Repo) OnThisDay(date time.Time, region *int) []Challenge {
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+
+	days := []string{date.Format("01-02")}
+	if date.Month() == time.February && date.Day() == 28 && !isLeap(date.Year()) {
+		days = append(days, "02-29")
+	}
+
+	var regionID string
+	if region != nil {
+		regionID = strconv.Itoa(*region)
+	}
+
+	out := make([]Challenge, 0)
+	for _, day := range days {
+		for _, c := range s.challengesByDay[day] {
+			if regionID != "" && c.RegionID != regionID {
+				continue
+			}
+			out = append(out, *c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].DateTaken.Before(*out[j].DateTaken)
+	})
+	return out
+}
+
+func isLeap(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
@@ -0,0 +1,44 @@
+package repos
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOnThisDay(t *testing.T) {
+	r := &Repo{descriptions: newLRU(10)}
+	r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
+
+	challenge := func(id int, region string, taken time.Time) *Challenge {
+		return &Challenge{ID: testChallengeID(id), RegionID: region, DateTaken: &taken}
+	}
+	err := r.storeChallenges([]*Challenge{
+		challenge(1, "1", time.Date(2019, 6, 21, 5, 0, 0, 0, time.UTC)),
+		challenge(2, "1", time.Date(2015, 6, 21, 18, 0, 0, 0, time.UTC)),
+		challenge(3, "2", time.Date(2021, 6, 21, 12, 0, 0, 0, time.UTC)),
+		challenge(4, "1", time.Date(2021, 6, 22, 12, 0, 0, 0, time.UTC)),
+		challenge(5, "1", time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC)),
+		{ID: testChallengeID(6), RegionID: "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := r.OnThisDay(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), nil)
+	if len(got) != 3 || got[0].ID != testChallengeID(2) || got[2].ID != testChallengeID(3) {
+		t.Errorf("unexpected challenges %+v", got)
+	}
+
+	region := 1
+	if got := r.OnThisDay(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), &region); len(got) != 2 {
+		t.Errorf("expected 2 challenges in region 1, got %d", len(got))
+	}
+
+	if got := r.OnThisDay(time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC), nil); len(got) != 1 {
+		t.Errorf("expected leap day challenge on the 28th, got %d", len(got))
+	}
+	if got := r.OnThisDay(time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), nil); len(got) != 0 {
+		t.Errorf("expected no leap day challenge on the 28th of a leap year, got %d", len(got))
+	}
+}
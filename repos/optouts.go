@@ -0,0 +1,175 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type OptOutState string
+
+const (
+	OptOutPending  OptOutState = "pending"
+	OptOutApproved OptOutState = "approved"
+	OptOutRejected OptOutState = "rejected"
+)
+
+type OptOut struct {
+	ID               int         `json:"id"`
+	Account          string      `json:"account"`
+	PhotographerLink string      `json:"photographer_link"`
+	Contact          string      `json:"contact"`
+	Reason           string      `json:"reason"`
+	State            OptOutState `json:"state"`
+	CreatedAt        time.Time   `json:"created_at"`
+	ReviewedAt       *time.Time  `json:"reviewed_at"`
+}
+
+var OptOutNotFoundError = errors.New("opt-out not found")
+var OptOutAlreadyReviewedError = errors.New("opt-out already reviewed")
+
+// Kept in step with the photographer_account SQL function
+var photographerAccountPattern = regexp.MustCompile(`^https?://(?:www\.)?flickr\.com/(?:people|photos)/([^/?#]+)`)
+
+// PhotographerAccount is the Flickr account a photographer link points to, or
+// empty if it isn't a Flickr profile
+func PhotographerAccount(link string) string {
+	m := photographerAccountPattern.FindStringSubmatch(link)
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(m[1])
+}
+
+const optOutArchiveReason = "photographer opted out"
+
+func (r *Repo) RequestOptOut(ctx context.Context, link string, contact string, reason string) (OptOut, error) {
+	o := OptOut{
+		Account:          PhotographerAccount(link),
+		PhotographerLink: link,
+		Contact:          contact,
+		Reason:           reason,
+		State:            OptOutPending,
+	}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO photographer_opt_outs (account, photographer_link, contact, reason)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, o.Account, link, contact, reason).Scan(&o.ID, &o.CreatedAt)
+	if err != nil {
+		return OptOut{}, err
+	}
+	return o, nil
+}
+
+// OptOuts lists requests in state, oldest first
+func (r *Repo) OptOuts(ctx context.Context, state OptOutState) ([]OptOut, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, account, photographer_link, contact, reason, state, created_at, reviewed_at
+		FROM photographer_opt_outs
+		WHERE state = $1
+		ORDER BY id
+	`, state)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []OptOut
+	for rows.Next() {
+		var o OptOut
+		if err := rows.Scan(&o.ID, &o.Account, &o.PhotographerLink, &o.Contact, &o.Reason, &o.State, &o.CreatedAt, &o.ReviewedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// ReviewOptOut decides a pending request. Approving it blocks the account,
+// so the ingest trigger refuses its photos, and archives every challenge it
+// already has. The IDs of the archived challenges are returned.
+func (r *Repo) ReviewOptOut(ctx context.Context, id int, state OptOutState) (OptOut, []string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return OptOut{}, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var o OptOut
+	err = tx.QueryRow(ctx, `
+		SELECT id, account, photographer_link, contact, reason, state, created_at, reviewed_at
+		FROM photographer_opt_outs
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&o.ID, &o.Account, &o.PhotographerLink, &o.Contact, &o.Reason, &o.State, &o.CreatedAt, &o.ReviewedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return OptOut{}, nil, OptOutNotFoundError
+	} else if err != nil {
+		return OptOut{}, nil, err
+	}
+	if o.State != OptOutPending {
+		return OptOut{}, nil, OptOutAlreadyReviewedError
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE photographer_opt_outs SET state = $2, reviewed_at = now() WHERE id = $1 RETURNING state, reviewed_at
+	`, id, state).Scan(&o.State, &o.ReviewedAt)
+	if err != nil {
+		return OptOut{}, nil, err
+	}
+	if state == OptOutApproved {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO photographer_blocklist (account, opt_out_id) VALUES ($1, $2) ON CONFLICT (account) DO NOTHING
+		`, o.Account, id)
+		if err != nil {
+			return OptOut{}, nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return OptOut{}, nil, err
+	}
+	if state != OptOutApproved {
+		return o, nil, nil
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id FROM challenges WHERE photographer_account(photographer_link) = $1 ORDER BY id
+	`, o.Account)
+	if err != nil {
+		return o, nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var internalID int
+		if err := rows.Scan(&internalID); err != nil {
+			rows.Close()
+			return o, nil, err
+		}
+		encoded, err := encodeChallengeID(internalID)
+		if err != nil {
+			rows.Close()
+			return o, nil, err
+		}
+		ids = append(ids, encoded)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return o, nil, err
+	}
+
+	// The account is blocked even if retiring some of these fails, and
+	// approving again isn't possible, so carry on and report what was done
+	var archived []string
+	for _, challengeID := range ids {
+		if err := r.ArchiveChallenge(ctx, challengeID, optOutArchiveReason); err != nil {
+			slog.ErrorContext(ctx, "error archiving opted out challenge", "challenge", challengeID, "err", err)
+			continue
+		}
+		archived = append(archived, challengeID)
+	}
+	return o, archived, nil
+}
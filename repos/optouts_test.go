@@ -0,0 +1,88 @@
+package repos
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPhotographerAccount(t *testing.T) {
+	tests := map[string]string{
+		"https://www.flickr.com/people/example/":          "example",
+		"https://flickr.com/photos/12345678@N00/":         "12345678@n00",
+		"http://www.flickr.com/photos/Example/51234/":     "example",
+		"https://www.flickr.com/people/example?ref=share": "example",
+		"https://www.flickr.com/groups/example/":          "",
+		"https://example.com/people/example/":             "",
+		"":                                                "",
+	}
+	for link, want := range tests {
+		if got := PhotographerAccount(link); got != want {
+			t.Errorf("%q: expected %q, got %q", link, want, got)
+		}
+	}
+}
+
+func TestMemoryOptOutRetiresChallenges(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(DefaultFixtures())
+	all := len(m.SampleChallenges(10))
+
+	other, err := m.RequestOptOut(ctx, "https://www.flickr.com/people/someone-else/", "a@example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, archived, err := m.ReviewOptOut(ctx, other.ID, OptOutApproved); err != nil || len(archived) != 0 {
+		t.Errorf("expected nothing to be archived for another photographer, got %v %v", archived, err)
+	}
+
+	o, err := m.RequestOptOut(ctx, "https://www.flickr.com/people/Example/", "b@example.com", "moving on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := m.OptOuts(ctx, OptOutPending); len(pending) != 1 || pending[0].Account != "example" {
+		t.Errorf("expected the request to be pending, got %+v", pending)
+	}
+	reviewed, archived, err := m.ReviewOptOut(ctx, o.ID, OptOutApproved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reviewed.State != OptOutApproved || reviewed.ReviewedAt == nil || len(archived) != all {
+		t.Errorf("expected all %d fixture challenges to be archived, got %+v %v", all, reviewed, archived)
+	}
+	if got := len(m.SampleChallenges(10)); got != 0 {
+		t.Errorf("expected nothing left to serve, got %d", got)
+	}
+	if _, _, err := m.ReviewOptOut(ctx, o.ID, OptOutRejected); err != OptOutAlreadyReviewedError {
+		t.Errorf("expected reviewing twice to fail, got %v", err)
+	}
+}
+
+func TestMemoryPhotographerStats(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(DefaultFixtures())
+	sample := m.SampleChallenges(10)
+	m.RecordServe(sample[0])
+	m.RecordServe(sample[0])
+
+	stats, err := m.PhotographerStats(ctx, "Example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ID != "example" || stats.Live != len(sample) || stats.Played != 2 {
+		t.Errorf("expected %d live challenges played twice, got %+v", len(sample), stats)
+	}
+	live := 0
+	for _, region := range stats.Regions {
+		live += region.Live
+		if region.Name == "" {
+			t.Errorf("expected region %s to be named", region.RegionID)
+		}
+	}
+	if live != stats.Live {
+		t.Errorf("expected regions to add up to %d, got %d", stats.Live, live)
+	}
+
+	if _, err := m.PhotographerStats(ctx, "someone-else"); err != PhotographerNotFoundError {
+		t.Errorf("expected an unknown photographer not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package repos
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// WithOutboundLinks makes served challenges link through the redirect
+// endpoint at prefix rather than directly to Flickr
+func WithOutboundLinks(prefix string) Option {
+	return func(r *Repo) {
+		r.outboundPrefix = prefix
+	}
+}
+
+func outboundLink(prefix string, target string, challengeID string) string {
+	if prefix == "" || target == "" {
+		return target
+	}
+	q := url.Values{}
+	q.Set("target", target)
+	q.Set("challenge", challengeID)
+	return prefix + "?" + q.Encode()
+}
+
+// OutboundLink returns target as served in challenges
+func (r *Repo) OutboundLink(target string, challengeID string) string {
+	return outboundLink(r.outboundPrefix, target, challengeID)
+}
+
+// maxOutboundTargetsPerDay caps the distinct rows recorded each day. Clicks on
+// new targets beyond it are counted against otherOutboundTarget instead.
+const maxOutboundTargetsPerDay = 10000
+
+const otherOutboundTarget = "other"
+
+// clickTarget drops the query and fragment from target so that links which
+// only differ in tracking parameters are counted together
+func clickTarget(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return otherOutboundTarget
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+	return u.String()
+}
+
+// RecordOutboundClick counts a click on target for the day. Only challenges
+// currently being served are recorded against, and once
+// maxOutboundTargetsPerDay rows exist for the day clicks on new targets are
+// aggregated under otherOutboundTarget.
+func (r *Repo) RecordOutboundClick(ctx context.Context, target string, challengeID string) error {
+	var internalID int
+	if challengeID != "" {
+		if id, err := decodeChallengeID(challengeID); err == nil {
+			if _, ok := r.snapshot.Load().challenges[id]; ok {
+				internalID = id
+			}
+		}
+	}
+
+	_, err := r.db.Exec(ctx, `
+		WITH known AS (
+			SELECT EXISTS (
+				SELECT 1 FROM outbound_clicks
+				WHERE target = $1 AND day = current_date AND challenge_id = $2
+			) OR (
+				SELECT count(*) FROM outbound_clicks WHERE day = current_date
+			) < $3 AS ok
+		)
+		INSERT INTO outbound_clicks (target, challenge_id, clicks)
+		SELECT CASE WHEN ok THEN $1 ELSE $4 END, CASE WHEN ok THEN $2 ELSE 0 END, 1
+		FROM known
+		ON CONFLICT (target, day, challenge_id) DO UPDATE SET clicks = outbound_clicks.clicks + 1
+	`, clickTarget(target), internalID, maxOutboundTargetsPerDay, otherOutboundTarget)
+	return err
+}
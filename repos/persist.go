@@ -0,0 +1,185 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	persistInterval        = 10 * time.Minute
+	persistedRetryInterval = 30 * time.Second
+)
+
+// persistedSnapshot is written to disk periodically so that the server can
+// start and keep serving from it while the database is unreachable
+type persistedSnapshot struct {
+	WrittenAt        time.Time         `json:"written_at"`
+	RegionsUpdatedAt time.Time         `json:"regions_updated_at"`
+	Regions          []persistedRegion `json:"regions"`
+	Challenges       []*Challenge      `json:"challenges"`
+}
+
+type persistedRegion struct {
+	Region
+	SeasonalMapLayers []persistedSeasonalMapLayer  `json:"seasonal_map_layers"`
+	Translations      map[string]RegionTranslation `json:"translations,omitempty"`
+}
+
+type persistedSeasonalMapLayer struct {
+	Season string   `json:"season"`
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Layer  MapLayer `json:"layer"`
+}
+
+func WithSnapshotPath(path string) Option {
+	return func(r *Repo) {
+		r.persistedPath = path
+	}
+}
+
+func (r *Repo) persistedWriter(ctx context.Context) {
+	defer r.closeWg.Done()
+
+	write := func() {
+		// Only write once both halves come from the database, otherwise we
+		// could overwrite a good snapshot with a partially restored one
+		if !r.regionsLive.Load() || !r.challengesLive.Load() || !r.persistedDirty.Swap(false) {
+			return
+		}
+		if err := r.writePersisted(); err != nil {
+			slog.Error("error writing snapshot", "path", r.persistedPath, "err", err)
+			r.persistedDirty.Store(true)
+		}
+	}
+
+	t := time.NewTicker(persistInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			write()
+		case <-ctx.Done():
+			slog.Info("cancelling snapshot writer")
+			// Save anything since the last tick so the next start is fresh
+			write()
+			return
+		}
+	}
+}
+
+func (r *Repo) writePersisted() error {
+	s := r.snapshot.Load()
+
+	out := persistedSnapshot{
+		WrittenAt:        time.Now(),
+		RegionsUpdatedAt: s.regionsUpdatedAt,
+		Regions:          make([]persistedRegion, 0, len(s.regions)),
+		Challenges:       make([]*Challenge, 0, len(s.challenges)),
+	}
+	for _, region := range s.regions {
+		p := persistedRegion{Region: region, Translations: region.translations}
+		for _, variant := range region.seasonalMapLayers {
+			p.SeasonalMapLayers = append(p.SeasonalMapLayers, persistedSeasonalMapLayer{
+				Season: variant.season,
+				From:   variant.from,
+				To:     variant.to,
+				Layer:  variant.layer,
+			})
+		}
+		out.Regions = append(out.Regions, p)
+	}
+	for _, c := range s.challenges {
+		// Snapshots keep the sizes expanded, as they're restored through
+		// storeChallenges
+		persisted := *c
+		persisted.Src.Sizes = c.PictureSizes()
+		out.Challenges = append(out.Challenges, &persisted)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.persistedPath), filepath.Base(r.persistedPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(out); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.persistedPath)
+}
+
+func (r *Repo) loadPersisted() *persistedSnapshot {
+	r.persistedOnce.Do(func() {
+		if r.persistedPath == "" {
+			return
+		}
+
+		f, err := os.Open(r.persistedPath)
+		if err != nil {
+			slog.Warn("error opening snapshot", "err", err)
+			return
+		}
+		defer f.Close()
+
+		var s persistedSnapshot
+		if err := json.NewDecoder(f).Decode(&s); err != nil {
+			slog.Error("error reading snapshot", "path", r.persistedPath, "err", err)
+			return
+		}
+		slog.Info("loaded snapshot", "written_at", s.WrittenAt)
+		r.persistedLoaded = &s
+	})
+	return r.persistedLoaded
+}
+
+func (r *Repo) restoreRegions() bool {
+	s := r.loadPersisted()
+	if s == nil {
+		return false
+	}
+
+	regions := make(map[int]Region, len(s.Regions))
+	for _, p := range s.Regions {
+		internalID, err := strconv.Atoi(p.ID)
+		if err != nil {
+			slog.Error("invalid region in snapshot", "err", err)
+			return false
+		}
+		region := p.Region
+		region.translations = p.Translations
+		for _, variant := range p.SeasonalMapLayers {
+			region.seasonalMapLayers = append(region.seasonalMapLayers, seasonalMapLayer{
+				season: variant.Season,
+				from:   variant.From,
+				to:     variant.To,
+				layer:  variant.Layer,
+			})
+		}
+		regions[internalID] = region
+	}
+
+	r.storeRegions(regions, s.RegionsUpdatedAt)
+	return true
+}
+
+func (r *Repo) restoreChallenges() bool {
+	s := r.loadPersisted()
+	if s == nil {
+		return false
+	}
+	if err := r.storeChallenges(s.Challenges); err != nil {
+		slog.Error("invalid challenges in snapshot", "err", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package repos
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPersistedRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	newRepo := func() *Repo {
+		r := &Repo{persistedPath: path, descriptions: newLRU(10)}
+		r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
+		return r
+	}
+
+	src := newRepo()
+	src.storeRegions(map[int]Region{
+		7: {
+			ID:       "7",
+			Name:     "Lake District",
+			MapLayer: MapLayer{ID: "1", CapabilitiesXML: "<Capabilities/>"},
+			seasonalMapLayers: []seasonalMapLayer{
+				{season: "winter", from: "12-01", to: "02-28", layer: MapLayer{ID: "2", CapabilitiesXML: "<Capabilities/>"}},
+			},
+		},
+	}, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	challenge := &Challenge{ID: testChallengeID(42), RegionID: "7", Title: "Helvellyn"}
+	if err := src.storeChallenges([]*Challenge{challenge}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.writePersisted(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newRepo()
+	if !dst.restoreRegions() || !dst.restoreChallenges() {
+		t.Fatal("expected restore to succeed")
+	}
+
+	region := dst.Regions()[7]
+	if region.Name != "Lake District" || region.MapLayer.CapabilitiesXML != "<Capabilities/>" {
+		t.Errorf("unexpected region %+v", region)
+	}
+	if got := region.ForDate(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)).MapLayer.ID; got != "2" {
+		t.Errorf("expected seasonal layer to survive, got layer %s", got)
+	}
+	if !dst.RegionsUpdatedAt().Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected regions updated at %s", dst.RegionsUpdatedAt())
+	}
+
+	got, err := dst.Challenge(challenge.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Helvellyn" {
+		t.Errorf("unexpected challenge %+v", got)
+	}
+	if _, err := dst.RandomChallenge(nil); err != nil {
+		t.Errorf("expected restored challenges to be selectable: %v", err)
+	}
+}
+
+func TestRestoreWithoutSnapshot(t *testing.T) {
+	r := &Repo{persistedPath: filepath.Join(t.TempDir(), "missing.json")}
+	r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
+	if r.restoreRegions() {
+		t.Error("expected restore to fail without a snapshot")
+	}
+}
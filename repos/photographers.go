@@ -0,0 +1,117 @@
+package repos
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type PhotographerRegion struct {
+	RegionID string `json:"region_id"`
+	Name     string `json:"name"`
+	Live     int    `json:"live"`
+	Played   int64  `json:"played"`
+}
+
+// PhotographerStats counts a photographer's live challenges and how many times
+// they've been played
+type PhotographerStats struct {
+	ID      string               `json:"id"`
+	Name    string               `json:"name"`
+	Link    string               `json:"link"`
+	Live    int                  `json:"live"`
+	Played  int64                `json:"played"`
+	Regions []PhotographerRegion `json:"regions"`
+}
+
+var PhotographerNotFoundError = errors.New("photographer not found")
+
+// photographerChallenges finds the account's live challenges by internal ID
+func (s *snapshot) photographerChallenges(account string) map[int]*Challenge {
+	account = strings.ToLower(account)
+	out := make(map[int]*Challenge)
+	for id, c := range s.challenges {
+		if PhotographerAccount(c.Photographer.Link) == account {
+			out[id] = c
+		}
+	}
+	return out
+}
+
+// photographerStats adds up challenges, with played giving the serves of
+// each, most live first
+func (s *snapshot) photographerStats(account string, challenges map[int]*Challenge, played map[int]int64) PhotographerStats {
+	stats := PhotographerStats{ID: strings.ToLower(account)}
+	byRegion := make(map[string]*PhotographerRegion)
+	for _, id := range slices.Sorted(maps.Keys(challenges)) {
+		c := challenges[id]
+		if stats.Name == "" {
+			stats.Name = c.Photographer.Text
+			stats.Link = c.Photographer.Link
+		}
+		region, ok := byRegion[c.RegionID]
+		if !ok {
+			region = &PhotographerRegion{RegionID: c.RegionID}
+			if regionID, err := strconv.Atoi(c.RegionID); err == nil {
+				region.Name = s.regions[regionID].Name
+			}
+			byRegion[c.RegionID] = region
+		}
+		region.Live++
+		region.Played += played[id]
+		stats.Live++
+		stats.Played += played[id]
+	}
+
+	stats.Regions = make([]PhotographerRegion, 0, len(byRegion))
+	for _, region := range byRegion {
+		stats.Regions = append(stats.Regions, *region)
+	}
+	slices.SortFunc(stats.Regions, func(a, b PhotographerRegion) int {
+		if c := cmp.Compare(b.Live, a.Live); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return stats
+}
+
+// PhotographerStats looks up the photographer by the account in their Flickr
+// profile link, as given by PhotographerAccount
+func (r *Repo) PhotographerStats(ctx context.Context, account string) (PhotographerStats, error) {
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+	challenges := s.photographerChallenges(account)
+	if len(challenges) == 0 {
+		return PhotographerStats{}, PhotographerNotFoundError
+	}
+
+	ids := make([]int64, 0, len(challenges))
+	for id := range challenges {
+		ids = append(ids, int64(id))
+	}
+	rows, err := r.db.Query(ctx, `
+		SELECT challenge_id, served FROM challenge_serves WHERE challenge_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return PhotographerStats{}, err
+	}
+	defer rows.Close()
+	played := make(map[int]int64)
+	for rows.Next() {
+		var id int
+		var served int64
+		if err := rows.Scan(&id, &served); err != nil {
+			return PhotographerStats{}, err
+		}
+		played[id] = served
+	}
+	if err := rows.Err(); err != nil {
+		return PhotographerStats{}, err
+	}
+	return s.photographerStats(account, challenges, played), nil
+}
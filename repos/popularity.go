@@ -0,0 +1,238 @@
+package repos
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const servesFlushInterval = 1 * time.Minute
+
+type pendingServe struct {
+	regionID int
+	served   int64
+	lastAt   time.Time
+}
+
+// serveCounts batches up serves between flushes so that playing a round
+// doesn't cost a write
+type serveCounts struct {
+	mu      sync.Mutex
+	pending map[int]pendingServe
+}
+
+// RecordServe counts the challenge as having been served to a player
+func (r *Repo) RecordServe(challenge Challenge) {
+	internalID, err := decodeChallengeID(challenge.ID)
+	if err != nil {
+		return
+	}
+	regionID, err := strconv.Atoi(challenge.RegionID)
+	if err != nil {
+		return
+	}
+
+	r.serves.mu.Lock()
+	defer r.serves.mu.Unlock()
+	if r.serves.pending == nil {
+		r.serves.pending = make(map[int]pendingServe)
+	}
+	p := r.serves.pending[internalID]
+	p.regionID = regionID
+	p.served++
+	p.lastAt = time.Now()
+	r.serves.pending[internalID] = p
+}
+
+func (r *Repo) servesFlusher(ctx context.Context) {
+	defer r.closeWg.Done()
+
+	t := time.NewTicker(servesFlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := r.flushServes(ctx); err != nil {
+				slog.Error("error flushing serve counts", "err", err)
+			}
+		case <-ctx.Done():
+			slog.Info("cancelling serve counts flusher")
+			// Save anything since the last tick
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := r.flushServes(flushCtx); err != nil {
+				slog.Error("error flushing serve counts", "err", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// flushServes adds pending counts to the database. If that fails they're put
+// back to be retried with the next flush.
+func (r *Repo) flushServes(ctx context.Context) error {
+	r.serves.mu.Lock()
+	pending := r.serves.pending
+	r.serves.pending = nil
+	r.serves.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(pending))
+	regionIDs := make([]int32, 0, len(pending))
+	served := make([]int64, 0, len(pending))
+	lastAt := make([]time.Time, 0, len(pending))
+	for id, p := range pending {
+		ids = append(ids, int64(id))
+		regionIDs = append(regionIDs, int32(p.regionID))
+		served = append(served, p.served)
+		lastAt = append(lastAt, p.lastAt)
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO challenge_serves (challenge_id, region_id, served, last_served_at)
+		SELECT * FROM unnest($1::bigint[], $2::integer[], $3::bigint[], $4::timestamptz[])
+		ON CONFLICT (challenge_id) DO UPDATE
+		SET served = challenge_serves.served + excluded.served,
+			region_id = excluded.region_id,
+			last_served_at = greatest(challenge_serves.last_served_at, excluded.last_served_at)
+	`, ids, regionIDs, served, lastAt)
+	if err != nil {
+		r.serves.mu.Lock()
+		if r.serves.pending == nil {
+			r.serves.pending = make(map[int]pendingServe, len(pending))
+		}
+		for id, p := range pending {
+			merged := r.serves.pending[id]
+			merged.regionID = p.regionID
+			merged.served += p.served
+			if p.lastAt.After(merged.lastAt) {
+				merged.lastAt = p.lastAt
+			}
+			r.serves.pending[id] = merged
+		}
+		r.serves.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+type RegionPopularity struct {
+	RegionID string `json:"region_id"`
+	Name     string `json:"name"`
+	Served   int64  `json:"served"`
+	// Distinct challenges served at least once
+	ChallengesServed int        `json:"challenges_served"`
+	Challenges       int        `json:"challenges"`
+	LastServedAt     *time.Time `json:"last_served_at"`
+}
+
+type ChallengePopularity struct {
+	ID           string    `json:"id"`
+	RegionID     string    `json:"region_id"`
+	Title        string    `json:"title"`
+	Served       int64     `json:"served"`
+	LastServedAt time.Time `json:"last_served_at"`
+}
+
+// RegionPopularity lists every active region, including those never played,
+// most served first
+func (r *Repo) RegionPopularity(ctx context.Context) ([]RegionPopularity, error) {
+	r.initWg.Wait()
+	rows, err := r.db.Query(ctx, `
+		SELECT region_id, sum(served), count(*), max(last_served_at)
+		FROM challenge_serves
+		GROUP BY region_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type stats struct {
+		served           int64
+		challengesServed int
+		lastAt           time.Time
+	}
+	byRegion := make(map[int]stats)
+	for rows.Next() {
+		var id int
+		var s stats
+		if err := rows.Scan(&id, &s.served, &s.challengesServed, &s.lastAt); err != nil {
+			return nil, err
+		}
+		byRegion[id] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	snap := r.snapshot.Load()
+	out := make([]RegionPopularity, 0, len(snap.regions))
+	for id, region := range snap.regions {
+		p := RegionPopularity{
+			RegionID:   region.ID,
+			Name:       region.Name,
+			Challenges: len(snap.challengesByRegion[id]),
+		}
+		if s, ok := byRegion[id]; ok {
+			p.Served = s.served
+			p.ChallengesServed = s.challengesServed
+			p.LastServedAt = &s.lastAt
+		}
+		out = append(out, p)
+	}
+	sortPopularity(out)
+	return out, nil
+}
+
+func sortPopularity(regions []RegionPopularity) {
+	slices.SortFunc(regions, func(a, b RegionPopularity) int {
+		if c := cmp.Compare(b.Served, a.Served); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// ChallengePopularity returns the most served challenges, optionally only in
+// one region
+func (r *Repo) ChallengePopularity(ctx context.Context, regionID *int, limit int) ([]ChallengePopularity, error) {
+	r.initWg.Wait()
+	rows, err := r.db.Query(ctx, `
+		SELECT challenge_id, region_id, served, last_served_at
+		FROM challenge_serves
+		WHERE $1::integer IS NULL OR region_id = $1
+		ORDER BY served DESC, challenge_id
+		LIMIT $2
+	`, regionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	challenges := r.snapshot.Load().challenges
+	var out []ChallengePopularity
+	for rows.Next() {
+		var id, region int
+		var p ChallengePopularity
+		if err := rows.Scan(&id, &region, &p.Served, &p.LastServedAt); err != nil {
+			return nil, err
+		}
+		p.ID, err = encodeChallengeID(id)
+		if err != nil {
+			return nil, err
+		}
+		p.RegionID = strconv.Itoa(region)
+		// Archived challenges keep their counts but not their title
+		if c, ok := challenges[id]; ok {
+			p.Title = c.Title
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,40 @@
+package repos
+
+import "testing"
+
+func TestRecordServe(t *testing.T) {
+	r := &Repo{}
+	a := Challenge{ID: testChallengeID(10), RegionID: "1"}
+	b := Challenge{ID: testChallengeID(11), RegionID: "2"}
+	r.RecordServe(a)
+	r.RecordServe(a)
+	r.RecordServe(b)
+	r.RecordServe(Challenge{ID: "!", RegionID: "1"})
+
+	if len(r.serves.pending) != 2 {
+		t.Fatalf("expected 2 pending challenges, got %+v", r.serves.pending)
+	}
+	if p := r.serves.pending[10]; p.served != 2 || p.regionID != 1 || p.lastAt.IsZero() {
+		t.Errorf("unexpected pending serves %+v", p)
+	}
+}
+
+func TestSortPopularity(t *testing.T) {
+	regions := []RegionPopularity{
+		{Name: "Snowdonia"},
+		{Name: "Cairngorms", Served: 4},
+		{Name: "Brecon Beacons"},
+		{Name: "Lake District", Served: 9},
+	}
+	sortPopularity(regions)
+	var got []string
+	for _, region := range regions {
+		got = append(got, region.Name)
+	}
+	want := []string{"Lake District", "Cairngorms", "Brecon Beacons", "Snowdonia"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
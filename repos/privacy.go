@@ -0,0 +1,117 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+type PrivacyZone struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	// GeoJSON Polygon or MultiPolygon
+	Geometry json.RawMessage `json:"geometry"`
+	// Published challenges inside the zone, which are withheld from play
+	ExcludedChallenges []string `json:"excluded_challenges"`
+}
+
+var InvalidZoneGeometryError = errors.New("zone geometry must be a GeoJSON Polygon or MultiPolygon")
+
+func (r *Repo) PrivacyZones(ctx context.Context, regionID int) ([]PrivacyZone, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT z.name, coalesce(z.reason, ''), ST_AsGeoJSON(z.geo),
+			array_remove(array_agg(c.id ORDER BY c.id), NULL)
+		FROM privacy_zones AS z
+		LEFT JOIN challenges AS c ON c.region_id = z.region_id AND ST_Covers(z.geo, c.geo)
+		WHERE z.region_id = $1
+		GROUP BY z.name, z.reason, z.geo
+		ORDER BY z.name
+	`, regionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]PrivacyZone, 0)
+	for rows.Next() {
+		var zone PrivacyZone
+		var geometry string
+		var excluded []int
+		if err := rows.Scan(&zone.Name, &zone.Reason, &geometry, &excluded); err != nil {
+			return nil, err
+		}
+		zone.Geometry = json.RawMessage(geometry)
+		zone.ExcludedChallenges, err = encodeChallengeIDs(excluded)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, zone)
+	}
+	return out, rows.Err()
+}
+
+// SetPrivacyZone creates or replaces the named zone and returns the published
+// challenges it excludes.
+func (r *Repo) SetPrivacyZone(ctx context.Context, regionID int, zone PrivacyZone) ([]string, error) {
+	var geometry struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(zone.Geometry, &geometry); err != nil ||
+		(geometry.Type != "Polygon" && geometry.Type != "MultiPolygon") {
+		return nil, InvalidZoneGeometryError
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO privacy_zones (region_id, name, reason, geo)
+		SELECT id, $2, nullif($3, ''), ST_GeomFromGeoJSON($4)::geography FROM regions WHERE id = $1
+		ON CONFLICT (region_id, name) DO UPDATE SET
+			reason = EXCLUDED.reason,
+			geo = EXCLUDED.geo,
+			updated_at = now()
+	`, regionID, zone.Name, zone.Reason, string(zone.Geometry))
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, RegionNotFoundError
+	}
+
+	var excluded []int
+	err = tx.QueryRow(ctx, `
+		SELECT coalesce(array_agg(c.id ORDER BY c.id), '{}')
+		FROM challenges AS c
+		JOIN privacy_zones AS z ON z.region_id = c.region_id AND ST_Covers(z.geo, c.geo)
+		WHERE z.region_id = $1 AND z.name = $2
+	`, regionID, zone.Name).Scan(&excluded)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return encodeChallengeIDs(excluded)
+}
+
+func (r *Repo) DeletePrivacyZone(ctx context.Context, regionID int, name string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM privacy_zones WHERE region_id = $1 AND name = $2`, regionID, name)
+	return err
+}
+
+func encodeChallengeIDs(internalIDs []int) ([]string, error) {
+	out := make([]string, 0, len(internalIDs))
+	for _, id := range internalIDs {
+		encoded, err := encodeChallengeID(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, encoded)
+	}
+	return out, nil
+}
@@ -2,33 +2,89 @@ package repos
 
 import (
 	"context"
+	"contourguessr-api/elevation"
+	"contourguessr-api/selection"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/cenkalti/backoff/v4"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"io"
-	"log"
+	"log/slog"
 	"math/rand"
-	"net/http"
+	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
-	"unicode/utf8"
 )
 
 type Repo struct {
 	db *pgxpool.Pool
 
-	cancelUpdater context.CancelFunc
-	initWg        sync.WaitGroup
-	closeWg       sync.WaitGroup
+	cancelUpdater     context.CancelFunc
+	initWg            sync.WaitGroup
+	closeWg           sync.WaitGroup
+	challengesChanged chan struct{}
+	regionsChanged    chan struct{}
+	nearbyStale       chan struct{}
+	capabilitiesStale chan struct{}
 
-	mu                    sync.Mutex
-	regions               map[int]Region
+	// Readers load the current snapshot without locking. Updaters hold writeMu
+	// while building a modified copy so that they don't clobber each other.
+	snapshot atomic.Pointer[snapshot]
+	writeMu  sync.Mutex
+
+	descriptions   *lru
+	outboundPrefix string
+
+	persistedPath   string
+	persistedDirty  atomic.Bool
+	regionsLive     atomic.Bool
+	challengesLive  atomic.Bool
+	persistedOnce   sync.Once
+	persistedLoaded *persistedSnapshot
+
+	capabilitiesMu              sync.Mutex
+	capabilities                map[int]cachedCapabilities
+	capabilitiesChanges         []CapabilitiesChange
+	breakingCapabilitiesChanges atomic.Uint64
+	capabilitiesObserver        func(CapabilitiesFetch)
+	capabilitiesURLFunc         func(layer string, matrixSet string) string
+
+	archiveRemovedAfter time.Duration
+
+	serves serveCounts
+
+	downHosts           atomic.Pointer[map[string]bool]
+	skippedForDownHosts atomic.Uint64
+	upPools             atomic.Pointer[upPools]
+
+	regionsRefreshInterval    time.Duration
+	challengesRefreshInterval time.Duration
+	refreshObserver           func(RefreshResult)
+
+	startedAt time.Time
+	// Unix nanoseconds
+	regionsRefreshedAt    atomic.Int64
+	challengesRefreshedAt atomic.Int64
+}
+
+type Option func(r *Repo)
+
+// A snapshot must not be modified once stored
+type snapshot struct {
+	regions          map[int]Region
+	regionsUpdatedAt time.Time
+	regionsJSON      *sync.Map
+	// Languages that at least one region has been translated into
+	languages             map[string]bool
 	challenges            map[int]*Challenge
 	challengesByRegion    map[int][]*Challenge
 	regionsWithChallenges []int
+	pool                  *selection.Pool
+	poolsByRegion         map[int]*selection.Pool
+	challengesByDay       map[string][]*Challenge
+	spacingGrid           spacingGrid
 }
 
 type Challenge struct {
@@ -39,28 +95,66 @@ type Challenge struct {
 		Lat float64 `json:"lat"`
 	} `json:"geo"`
 	Title           string     `json:"title"`
-	DescriptionHTML string     `json:"description_html"`
+	DescriptionHTML string     `json:"description_html"` // Not cached, see ChallengeDescription
 	DateTaken       *time.Time `json:"date_taken"`
-	Link            string     `json:"link"`
-	Src             struct {
+	// How much of DateTaken is known, see setDateTaken
+	DateTakenPrecision string `json:"date_taken_precision,omitempty"`
+	// IANA name, empty if DateTaken is a wall-clock time in an unknown zone
+	Timezone string `json:"timezone,omitempty"`
+	Link     string `json:"link"`
+	Src      struct {
 		Regular PictureSrc `json:"regular"`
 		Large   PictureSrc `json:"large"`
+		// Every size of the photo, narrowest first
+		Sizes []PictureSrc `json:"sizes"`
 	} `json:"src"`
+	// Width over height, for clients to reserve space for the photo
+	AspectRatio float64 `json:"aspect_ratio"`
+	// The photo's average colour like #3f6a2b, once its blurhash is computed
+	Color        string `json:"color,omitempty"`
 	Photographer struct {
 		Icon string `json:"icon"`
 		Text string `json:"text"`
 		Link string `json:"link"`
 	} `json:"photographer"`
-	R struct {
+	// From the photo's source, and empty if it isn't known
+	License             string `json:"license"`
+	LicenseURL          string `json:"license_url"`
+	AttributionRequired bool   `json:"attribution_required"`
+	R                   struct {
 		X float64 `json:"x"`
 		Y float64 `json:"y"`
 	} `json:"r"`
+	// Set once computed from the DEM
+	Terrain *elevation.Terrain `json:"terrain,omitempty"`
+
+	// Of the description, to tell which cached descriptions a refresh made
+	// stale
+	descriptionHash int32
+	imageHost       string
+	// Set when the challenge is cached, see compactSizes and encodeChallenge
+	sizes       compactSizes
+	encodedHead []byte
+	encodedTail []byte
+}
+
+type NearbyPOI struct {
+	Kind  string `json:"kind"`
+	OSMID int64  `json:"osm_id"`
+	Name  string `json:"name"`
+	Geo   struct {
+		Lng float64 `json:"lng"`
+		Lat float64 `json:"lat"`
+	} `json:"geo"`
+	DistanceM float64 `json:"distance_m"`
 }
 
 type PictureSrc struct {
 	Src    string `json:"src"`
 	Width  int    `json:"width"`
 	Height int    `json:"height"`
+	// Set once computed, see the blurhash package
+	BlurHash string `json:"blurhash,omitempty"`
 }
 
 type Region struct {
@@ -75,7 +169,29 @@ type Region struct {
 		MaxLat float64 `json:"max_lat"`
 		MinLat float64 `json:"min_lat"`
 	} `json:"bbox"`
-	MapLayer MapLayer `json:"map_layer"`
+	MapLayer MapLayer        `json:"map_layer"`
+	Advisory *RegionAdvisory `json:"advisory"`
+	// Derived from the country and bounding box
+	Units RegionUnits `json:"units"`
+	// Only set by Localize, from a translation that has one
+	Description string `json:"description,omitempty"`
+
+	seasonalMapLayers []seasonalMapLayer
+	// Keyed by lowercase language tag
+	translations map[string]RegionTranslation
+}
+
+type RegionAdvisory struct {
+	AvalancheServiceURL  string                `json:"avalanche_service_url"`
+	AccessNotes          string                `json:"access_notes"`
+	SeasonalRestrictions []SeasonalRestriction `json:"seasonal_restrictions"`
+}
+
+type SeasonalRestriction struct {
+	// Inclusive MM-DD bounds, which may wrap around the new year
+	From string `json:"from"`
+	To   string `json:"to"`
+	Note string `json:"note"`
 }
 
 type MapLayer struct {
@@ -88,32 +204,96 @@ type MapLayer struct {
 	DefaultResolution float64   `json:"default_resolution"`
 	OSBranding        bool      `json:"os_branding"`
 	ExtraAttributions []string  `json:"extra_attributions"`
+	Season            string    `json:"season,omitempty"`
+
+	capabilitiesURL string
 }
 
+// Keeps a stuck query from wedging an updater
+const refreshQueryTimeout = 1 * time.Minute
+
 var NoChallengesAvailableError = errors.New("no challenges available")
 var ChallengeNotFoundError = errors.New("challenge not found")
+var RegionNotFoundError = errors.New("region not found")
 
-func New(db *pgxpool.Pool) *Repo {
+func New(db *pgxpool.Pool, opts ...Option) *Repo {
 	updaterCtx, cancelUpdater := context.WithCancel(context.Background())
 	r := &Repo{
-		db:            db,
-		cancelUpdater: cancelUpdater,
+		db:                db,
+		cancelUpdater:     cancelUpdater,
+		challengesChanged: make(chan struct{}, 1),
+		regionsChanged:    make(chan struct{}, 1),
+		nearbyStale:       make(chan struct{}, 1),
+		capabilitiesStale: make(chan struct{}, 1),
+		descriptions:      newLRU(descriptionCacheSize),
+		startedAt:         time.Now(),
+
+		regionsRefreshInterval:    24 * time.Hour,
+		challengesRefreshInterval: 15 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	r.snapshot.Store(&snapshot{regionsJSON: new(sync.Map)})
 
 	r.initWg.Add(2)
-	r.closeWg.Add(2)
+	r.closeWg.Add(6)
 	go r.challengesUpdater(updaterCtx)
 	go r.regionsUpdater(updaterCtx)
+	go r.changeListener(updaterCtx)
+	go r.nearbyUpdater(updaterCtx)
+	go r.capabilitiesUpdater(updaterCtx)
+	go r.servesFlusher(updaterCtx)
+	if r.persistedPath != "" {
+		r.closeWg.Add(1)
+		go r.persistedWriter(updaterCtx)
+	}
+	if r.archiveRemovedAfter > 0 {
+		r.closeWg.Add(1)
+		go r.archiveUpdater(updaterCtx)
+	}
 
 	return r
 }
 
+// RefreshResult describes one attempt to reload regions or challenges
+type RefreshResult struct {
+	// "regions" or "challenges"
+	Updater  string
+	Duration time.Duration
+	Rows     int
+	Err      error
+}
+
+// WithRefreshObserver calls observe after every reload of regions and
+// challenges, successful or not
+func WithRefreshObserver(observe func(RefreshResult)) Option {
+	return func(r *Repo) {
+		r.refreshObserver = observe
+	}
+}
+
+func (r *Repo) observeRefresh(res RefreshResult) {
+	if r.refreshObserver != nil {
+		r.refreshObserver(res)
+	}
+}
+
+// WithRefreshIntervals sets how often regions and challenges are reloaded in
+// full. Changes are normally picked up sooner through notifications.
+func WithRefreshIntervals(regions time.Duration, challenges time.Duration) Option {
+	return func(r *Repo) {
+		r.regionsRefreshInterval = regions
+		r.challengesRefreshInterval = challenges
+	}
+}
+
 func (r *Repo) WaitUntilReady() {
 	r.initWg.Wait()
 }
 
 func (r *Repo) Close() {
-	log.Println("closing repo")
+	slog.Info("closing repo")
 	r.cancelUpdater()
 	r.closeWg.Wait()
 	r.db.Close()
@@ -121,44 +301,71 @@ func (r *Repo) Close() {
 
 func (r *Repo) Regions() map[int]Region {
 	r.initWg.Wait()
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	return r.regions
+	return r.snapshot.Load().regions
+}
+
+func (r *Repo) RegionsUpdatedAt() time.Time {
+	r.initWg.Wait()
+	return r.snapshot.Load().regionsUpdatedAt
 }
 
 func (r *Repo) RandomChallenge(region *int) (Challenge, error) {
 	r.initWg.Wait()
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	s := r.snapshot.Load()
 
 	var regionID int
 	if region != nil {
 		regionID = *region
 	} else {
-		regionID = r.regionsWithChallenges[rand.Intn(len(r.regionsWithChallenges))]
+		if len(s.regionsWithChallenges) == 0 {
+			return Challenge{}, NoChallengesAvailableError
+		}
+		regionID = s.regionsWithChallenges[rand.Intn(len(s.regionsWithChallenges))]
 	}
 
-	list := r.challengesByRegion[regionID]
+	list := s.challengesByRegion[regionID]
 	if len(list) == 0 {
 		return Challenge{}, NoChallengesAvailableError
 	}
 
-	pick := *list[rand.Intn(len(list))]
+	pick := r.pickAvoidingDownHosts(func(int) *Challenge {
+		return list[rand.Intn(len(list))]
+	})
+	return *pick, nil
+}
+
+// PlayerChallenge returns the challenge at position seq in the player's
+// personal ordering of the region, or of every challenge if region is nil
+func (r *Repo) PlayerChallenge(region *int, player string, seq uint64) (Challenge, error) {
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+
+	// Challenges on a down host are left out of the ordering rather than
+	// skipped over, which would serve the next position's challenge twice
+	pool, poolsByRegion := r.pools(s)
+	if region != nil {
+		pool = poolsByRegion[*region]
+	}
+	if pool == nil {
+		return Challenge{}, NoChallengesAvailableError
+	}
 
-	return pick, nil
+	id, ok := pool.Pick(player, seq)
+	if !ok {
+		return Challenge{}, NoChallengesAvailableError
+	}
+	return *s.challenges[id], nil
 }
 
 func (r *Repo) Challenge(id string) (Challenge, error) {
 	r.initWg.Wait()
-	r.mu.Lock()
-	defer r.mu.Unlock()
 
 	internalID, err := decodeChallengeID(id)
 	if err != nil {
 		return Challenge{}, err
 	}
 
-	val, ok := r.challenges[internalID]
+	val, ok := r.snapshot.Load().challenges[internalID]
 	if !ok {
 		return Challenge{}, ChallengeNotFoundError
 	}
@@ -166,23 +373,163 @@ func (r *Repo) Challenge(id string) (Challenge, error) {
 	return *val, nil
 }
 
-func (r *Repo) ChallengesPerRegion() map[int]int {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// RecordGuess stores a guess, attributing it to player unless player is empty
+func (r *Repo) RecordGuess(ctx context.Context, id string, player string, lng float64, lat float64) (int64, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return 0, err
+	}
+
+	r.initWg.Wait()
+	if _, ok := r.snapshot.Load().challenges[internalID]; !ok {
+		return 0, ChallengeNotFoundError
+	}
 
+	var guessID int64
+	err = r.db.QueryRow(ctx, `
+		WITH guess AS (SELECT ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography AS geo)
+		INSERT INTO guesses (challenge_id, geo, player_id, distance_m)
+		SELECT $1, guess.geo, NULLIF($4, ''), ST_Distance(guess.geo, c.geo)
+		FROM guess, challenges AS c
+		WHERE c.id = $1
+		RETURNING id
+	`, internalID, lng, lat, player).Scan(&guessID)
+	if err != nil {
+		return 0, err
+	}
+	return guessID, nil
+}
+
+// The weather archive fills in recent days after a delay, so a challenge it
+// had no data for is asked about again after this long
+const missingWeatherTTL = 24 * time.Hour
+
+// ChallengeWeather returns the cached weather for the challenge, which is null
+// if the archive had none. A null older than missingWeatherTTL isn't returned.
+func (r *Repo) ChallengeWeather(ctx context.Context, id string) (json.RawMessage, bool, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var conditions json.RawMessage
+	err = r.db.QueryRow(ctx, `
+		SELECT conditions FROM challenge_weather
+		WHERE challenge_id = $1
+			AND (conditions != 'null'::jsonb OR fetched_at > now() - $2::interval)
+	`, internalID, missingWeatherTTL).Scan(&conditions)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return conditions, true, nil
+}
+
+func (r *Repo) SetChallengeWeather(ctx context.Context, id string, conditions json.RawMessage) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO challenge_weather (challenge_id, conditions)
+		VALUES ($1, $2)
+		ON CONFLICT (challenge_id) DO UPDATE SET conditions = EXCLUDED.conditions, fetched_at = now()
+	`, internalID, conditions)
+	return err
+}
+
+func (r *Repo) ChallengeNearby(ctx context.Context, id string) ([]NearbyPOI, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT kind, osm_id, coalesce(name, ''), ST_X(geo::geometry), ST_Y(geo::geometry), distance_m
+		FROM challenge_nearby_pois
+		WHERE challenge_id = $1
+		ORDER BY distance_m
+	`, internalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]NearbyPOI, 0)
+	for rows.Next() {
+		var p NearbyPOI
+		if err := rows.Scan(&p.Kind, &p.OSMID, &p.Name, &p.Geo.Lng, &p.Geo.Lat, &p.DistanceM); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) SetRegionAdvisory(ctx context.Context, regionID int, advisory RegionAdvisory) error {
+	if advisory.SeasonalRestrictions == nil {
+		advisory.SeasonalRestrictions = []SeasonalRestriction{}
+	}
+	restrictions, err := json.Marshal(advisory.SeasonalRestrictions)
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO region_advisories (region_id, avalanche_service_url, access_notes, seasonal_restrictions)
+		SELECT id, nullif($2, ''), nullif($3, ''), $4 FROM regions WHERE id = $1
+		ON CONFLICT (region_id) DO UPDATE SET
+			avalanche_service_url = EXCLUDED.avalanche_service_url,
+			access_notes = EXCLUDED.access_notes,
+			seasonal_restrictions = EXCLUDED.seasonal_restrictions,
+			updated_at = now()
+	`, regionID, advisory.AvalancheServiceURL, advisory.AccessNotes, restrictions)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return RegionNotFoundError
+	}
+	return nil
+}
+
+func (r *Repo) DeleteRegionAdvisory(ctx context.Context, regionID int) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM region_advisories WHERE region_id = $1`, regionID)
+	return err
+}
+
+func (r *Repo) ChallengesPerRegion() map[int]int {
 	out := make(map[int]int)
-	for k, v := range r.challengesByRegion {
+	for k, v := range r.snapshot.Load().challengesByRegion {
 		out[k] = len(v)
 	}
 	return out
 }
 
+// SampleChallenges picks up to n random challenges from each region
+func (r *Repo) SampleChallenges(n int) []Challenge {
+	r.initWg.Wait()
+	var out []Challenge
+	for _, challenges := range r.snapshot.Load().challengesByRegion {
+		perm := rand.Perm(len(challenges))
+		for _, i := range perm[:min(n, len(perm))] {
+			out = append(out, *challenges[i])
+		}
+	}
+	return out
+}
+
 func (r *Repo) ChallengeDebugInfoJSON(ctx context.Context, id string) (string, error) {
 	out := make(map[string]interface{})
 	challenge, err := r.Challenge(id)
 	if err != nil {
 		return "", err
 	}
+	challenge.DescriptionHTML, err = r.ChallengeDescription(ctx, id)
+	if err != nil {
+		return "", err
+	}
 	out["challenge"] = challenge
 
 	internalID, err := decodeChallengeID(id)
@@ -219,42 +566,75 @@ func (r *Repo) ChallengeDebugInfoJSON(ctx context.Context, id string) (string, e
 func (r *Repo) regionsUpdater(ctx context.Context) {
 	defer r.closeWg.Done()
 
+	var retry <-chan time.Time
 	err := r.updateRegions(ctx)
 	if err != nil {
-		log.Fatalf("failed to initially update regions: %v", err)
+		if !r.restoreRegions() {
+			slog.Error("failed to initially update regions", "err", err)
+			os.Exit(1)
+		}
+		slog.Error("failed to initially update regions, serving from snapshot", "err", err)
+		retry = time.After(persistedRetryInterval)
 	}
 	r.initWg.Done()
 
-	t := time.NewTicker(24 * time.Hour)
+	t := time.NewTicker(r.regionsRefreshInterval)
 	defer t.Stop()
 	for {
 		select {
 		case <-t.C:
 			err := r.updateRegions(ctx)
 			if err != nil {
-				log.Printf("error updating regions: %v", err)
+				slog.Error("error updating regions", "err", err)
+			}
+		case <-r.regionsChanged:
+			err := r.updateRegions(ctx)
+			if err != nil {
+				slog.Error("error updating regions", "err", err)
+			}
+		case <-retry:
+			retry = nil
+			err := r.updateRegions(ctx)
+			if err != nil {
+				slog.Error("error updating regions", "err", err)
+				retry = time.After(persistedRetryInterval)
 			}
 		case <-ctx.Done():
-			log.Println("cancelling regions updater")
+			slog.Info("cancelling regions updater")
 			return
 		}
 	}
 }
 
 func (r *Repo) updateRegions(ctx context.Context) error {
+	start := time.Now()
+	n, err := r.loadRegions(ctx)
+	r.observeRefresh(RefreshResult{Updater: "regions", Duration: time.Since(start), Rows: n, Err: err})
+	return err
+}
+
+func (r *Repo) loadRegions(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, refreshQueryTimeout)
+	defer cancel()
+
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback(ctx)
 
+	_, err = tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", refreshQueryTimeout.Milliseconds()))
+	if err != nil {
+		return 0, err
+	}
+
 	rows, err := tx.Query(ctx, `
 		SELECT id, ST_AsGeoJSON(ST_ForcePolygonCW(geo::geometry)), name, country_iso2, logo_url, min_lng, max_lng, min_lat, max_lat
 		FROM regions
 		WHERE active
 	`)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer rows.Close()
 	out := make(map[int]Region)
@@ -262,7 +642,7 @@ func (r *Repo) updateRegions(ctx context.Context) error {
 		var r Region
 		var internalID int
 		if err := rows.Scan(&internalID, &r.GeoJSON, &r.Name, &r.CountryISO2, &r.LogoURL, &r.BBox.MinLng, &r.BBox.MaxLng, &r.BBox.MinLat, &r.BBox.MaxLat); err != nil {
-			return err
+			return 0, err
 		}
 		r.ID = strconv.FormatInt(int64(internalID), 10)
 		out[internalID] = r
@@ -272,14 +652,20 @@ func (r *Repo) updateRegions(ctx context.Context) error {
 	rows, err = tx.Query(ctx, `
 		SELECT ml.id, ml.name, ml.capabilities_url, ml.layer, ml.matrix_set, ml.resolutions, ml.default_resolution, ml.os_branding, ml.extra_attributions
 		FROM map_layers as ml
-		JOIN region_map_layers ON ml.id = region_map_layers.map_layer_id
-		JOIN map_layers ON map_layers.id = region_map_layers.map_layer_id
-		JOIN regions ON regions.id = region_map_layers.region_id
-		WHERE regions.active
-		GROUP BY ml.id
+		WHERE ml.id IN (
+			SELECT rml.map_layer_id
+			FROM region_map_layers as rml
+			JOIN regions ON regions.id = rml.region_id
+			WHERE regions.active
+			UNION
+			SELECT rsml.map_layer_id
+			FROM region_seasonal_map_layers as rsml
+			JOIN regions ON regions.id = rsml.region_id
+			WHERE regions.active
+		)
 	`)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer rows.Close()
 	mapLayers := make(map[int]*MapLayer)
@@ -287,10 +673,13 @@ func (r *Repo) updateRegions(ctx context.Context) error {
 		var ml MapLayer
 		var internalID int
 		var osBranding *bool
-		if err := rows.Scan(&internalID, &ml.Name, &ml.CapabilitiesXML, &ml.Layer, &ml.MatrixSet, &ml.Resolutions, &ml.DefaultResolution, &osBranding, &ml.ExtraAttributions); err != nil {
-			return err
+		if err := rows.Scan(&internalID, &ml.Name, &ml.capabilitiesURL, &ml.Layer, &ml.MatrixSet, &ml.Resolutions, &ml.DefaultResolution, &osBranding, &ml.ExtraAttributions); err != nil {
+			return 0, err
 		}
 		ml.ID = strconv.FormatInt(int64(internalID), 10)
+		if r.capabilitiesURLFunc != nil {
+			ml.capabilitiesURL = r.capabilitiesURLFunc(ml.Layer, ml.MatrixSet)
+		}
 		if osBranding != nil {
 			ml.OSBranding = *osBranding
 		}
@@ -298,44 +687,18 @@ func (r *Repo) updateRegions(ctx context.Context) error {
 	}
 	rows.Close()
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	c := http.Client{
-		Timeout: 10 * time.Second,
-	}
-	for _, ml := range mapLayers {
-		wg.Add(1)
-		internalID, err := strconv.Atoi(ml.ID)
-		if err != nil {
-			panic(err)
-		}
-		go func(id int, url string) {
-			defer wg.Done()
-			xml, err := fetchCapabilities(ctx, &c, url)
-			mu.Lock()
-			defer mu.Unlock()
-			if err != nil {
-				log.Printf("error fetching capabilities for map layer %d from %s: %v", id, url, err)
-				delete(mapLayers, id)
-			} else {
-				mapLayers[id].CapabilitiesXML = xml
-			}
-		}(internalID, ml.CapabilitiesXML)
-	}
-	wg.Wait()
-
 	rows, err = tx.Query(ctx, `
 		SELECT region_id, map_layer_id
 		FROM region_map_layers
 	`)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var regionID, mlID int
 		if err := rows.Scan(&regionID, &mlID); err != nil {
-			return err
+			return 0, err
 		}
 
 		prevRegionValue, ok := out[regionID]
@@ -345,7 +708,7 @@ func (r *Repo) updateRegions(ctx context.Context) error {
 
 		ml, ok := mapLayers[mlID]
 		if !ok {
-			log.Printf("missing map layer %d for region %d", mlID, regionID)
+			slog.Warn("missing map layer", "map_layer", mlID, "region", regionID)
 			delete(out, regionID)
 			continue
 		}
@@ -353,123 +716,334 @@ func (r *Repo) updateRegions(ctx context.Context) error {
 		prevRegionValue.MapLayer = *ml
 		out[regionID] = prevRegionValue
 	}
+	rows.Close()
 
-	r.mu.Lock()
-	r.regions = out
-	r.mu.Unlock()
-	return nil
-}
+	rows, err = tx.Query(ctx, `
+		SELECT region_id, map_layer_id, season, from_day, to_day
+		FROM region_seasonal_map_layers
+		ORDER BY region_id, from_day
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var regionID, mlID int
+		var variant seasonalMapLayer
+		if err := rows.Scan(&regionID, &mlID, &variant.season, &variant.from, &variant.to); err != nil {
+			return 0, err
+		}
 
-func fetchCapabilities(ctx context.Context, c *http.Client, url string) (string, error) {
-	var out string
-	err := backoff.Retry(func() error {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-		if err != nil {
-			return err
+		region, ok := out[regionID]
+		if !ok {
+			continue
+		}
+
+		ml, ok := mapLayers[mlID]
+		if !ok {
+			slog.Warn("missing seasonal map layer, falling back to default", "map_layer", mlID, "region", regionID)
+			continue
 		}
-		req.Header.Set("User-Agent", "github.com/dzfranklin/contourguessr")
 
-		log.Printf("fetching capabilities from %s", url)
+		variant.layer = *ml
+		region.seasonalMapLayers = append(region.seasonalMapLayers, variant)
+		out[regionID] = region
+	}
+	rows.Close()
 
-		resp, err := c.Do(req)
-		if err != nil {
-			return err
+	rows, err = tx.Query(ctx, `
+		SELECT region_id, coalesce(avalanche_service_url, ''), coalesce(access_notes, ''), seasonal_restrictions
+		FROM region_advisories
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var regionID int
+		var advisory RegionAdvisory
+		if err := rows.Scan(&regionID, &advisory.AvalancheServiceURL, &advisory.AccessNotes, &advisory.SeasonalRestrictions); err != nil {
+			return 0, err
 		}
-		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK {
-			var body string
-			if v, err := io.ReadAll(resp.Body); err == nil {
-				body = string(v)
-			} else {
-				body = fmt.Sprintf("<error reading body: %v>", err)
-			}
-			err = fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
-			return err
+
+		region, ok := out[regionID]
+		if !ok {
+			continue
 		}
+		region.Advisory = &advisory
+		out[regionID] = region
+	}
+	rows.Close()
 
-		xml, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
+	rows, err = tx.Query(ctx, `
+		SELECT region_id, lang, name, description
+		FROM region_translations
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var regionID int
+		var lang string
+		var t RegionTranslation
+		if err := rows.Scan(&regionID, &lang, &t.Name, &t.Description); err != nil {
+			return 0, err
 		}
 
-		if !utf8.Valid(xml) {
-			return errors.New("invalid utf-8")
+		region, ok := out[regionID]
+		if !ok {
+			continue
+		}
+		if region.translations == nil {
+			region.translations = make(map[string]RegionTranslation)
 		}
+		region.translations[lang] = t
+		out[regionID] = region
+	}
+	rows.Close()
 
-		out = string(xml)
-		return nil
-	}, backoff.NewExponentialBackOff(backoff.WithMaxElapsedTime(1*time.Minute)))
-	return out, err
+	r.storeRegions(out, time.Now())
+	r.regionsLive.Store(true)
+	r.regionsRefreshedAt.Store(time.Now().UnixNano())
+	r.persistedDirty.Store(true)
+	signal(r.capabilitiesStale)
+	return len(out), nil
+}
+
+func (r *Repo) storeRegions(regions map[int]Region, updatedAt time.Time) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	regions = r.applyCapabilities(regions)
+	for id, region := range regions {
+		region.Units = unitsFor(region)
+		regions[id] = region
+	}
+	next := *r.snapshot.Load()
+	next.regions = regions
+	next.regionsUpdatedAt = updatedAt
+	next.regionsJSON = newRegionsJSONCache(regions)
+	next.languages = regionLanguages(regions)
+	r.snapshot.Store(&next)
 }
 
 func (r *Repo) challengesUpdater(ctx context.Context) {
 	defer r.closeWg.Done()
 
+	var retry <-chan time.Time
 	err := r.updateChallenges(ctx)
 	if err != nil {
-		log.Fatalf("failed to initially update challenges: %v", err)
+		if !r.restoreChallenges() {
+			slog.Error("failed to initially update challenges", "err", err)
+			os.Exit(1)
+		}
+		slog.Error("failed to initially update challenges, serving from snapshot", "err", err)
+		retry = time.After(persistedRetryInterval)
 	}
 	r.initWg.Done()
 
-	t := time.NewTicker(1 * time.Minute)
+	// Changes are normally picked up by changeListener, this is a fallback
+	t := time.NewTicker(r.challengesRefreshInterval)
 	defer t.Stop()
 	for {
 		select {
 		case <-t.C:
 			err := r.updateChallenges(ctx)
 			if err != nil {
-				log.Printf("error updating challenges: %v", err)
+				slog.Error("error updating challenges", "err", err)
+			}
+		case <-r.challengesChanged:
+			err := r.updateChallenges(ctx)
+			if err != nil {
+				slog.Error("error updating challenges", "err", err)
+			}
+			signal(r.nearbyStale)
+		case <-retry:
+			retry = nil
+			err := r.updateChallenges(ctx)
+			if err != nil {
+				slog.Error("error updating challenges", "err", err)
+				retry = time.After(persistedRetryInterval)
 			}
 		case <-ctx.Done():
-			log.Println("cancelling challenges updater")
+			slog.Info("cancelling challenges updater")
 			return
 		}
 	}
 }
 
 func (r *Repo) updateChallenges(ctx context.Context) error {
+	start := time.Now()
+	n, err := r.loadChallenges(ctx)
+	r.observeRefresh(RefreshResult{Updater: "challenges", Duration: time.Since(start), Rows: n, Err: err})
+	return err
+}
+
+func (r *Repo) loadChallenges(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, refreshQueryTimeout)
+	defer cancel()
+
 	rows, err := r.db.Query(ctx, `
-		SELECT c.id, c.region_id, ST_X(c.geo::geometry), ST_Y(c.geo::geometry), c.title, c.description_html, c.date_taken, c.link,
+		SELECT c.id, c.region_id, ST_X(c.geo::geometry), ST_Y(c.geo::geometry), c.title, c.date_taken, c.link,
 			c.regular_src, c.regular_width, c.regular_height, c.large_src, c.large_width, c.large_height,
 			c.photographer_icon, c.photographer_text, c.photographer_link,
-			c.rx, c.ry
+			c.rx, c.ry, t.slope_deg, t.aspect_deg, t.ruggedness_m, t.landform,
+			coalesce(p.info->>'license', p.info->'photo'->>'license', ''), coalesce(bh.blurhash, ''), p.sizes,
+			coalesce(p.info->'dates', p.info->'photo'->'dates'), coalesce(tz.timezone, ''),
+			hashtext(c.description_html)
 		FROM challenges as c
 		JOIN regions ON c.region_id = regions.id
+		LEFT JOIN challenge_terrain AS t ON t.challenge_id = c.id
+		LEFT JOIN challenge_blurhashes AS bh ON bh.challenge_id = c.id
+		LEFT JOIN challenge_timezones AS tz ON tz.challenge_id = c.id
+		LEFT JOIN flickr_challenge_sources AS src ON src.challenge_id = c.id
+		LEFT JOIN flickr_photos AS p ON p.flickr_id = src.flickr_id
 		WHERE regions.active
+			AND NOT EXISTS (
+				SELECT FROM privacy_zones AS z
+				WHERE z.region_id = c.region_id AND ST_Covers(z.geo, c.geo)
+			)
+			AND NOT EXISTS (
+				SELECT FROM challenge_moderation AS m
+				WHERE m.challenge_id = c.id AND m.state IN ('quarantined', 'removed')
+			)
 	`)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer rows.Close()
-	challenges := make(map[int]*Challenge)
-	challengesByRegion := make(map[int][]*Challenge)
+	var list []*Challenge
+	interned := make(interner)
 	for rows.Next() {
 		c := new(Challenge)
 		var internalID int
 		var internalRegionID int
-		err := rows.Scan(&internalID, &internalRegionID, &c.Geo.Lng, &c.Geo.Lat, &c.Title, &c.DescriptionHTML, &c.DateTaken, &c.Link,
+		var slope, aspect, ruggedness *float64
+		var landform *string
+		var license string
+		var sizes, dates json.RawMessage
+		var timezone string
+		err := rows.Scan(&internalID, &internalRegionID, &c.Geo.Lng, &c.Geo.Lat, &c.Title, &c.DateTaken, &c.Link,
 			&c.Src.Regular.Src, &c.Src.Regular.Width, &c.Src.Regular.Height,
 			&c.Src.Large.Src, &c.Src.Large.Width, &c.Src.Large.Height,
 			&c.Photographer.Icon, &c.Photographer.Text, &c.Photographer.Link,
-			&c.R.X, &c.R.Y)
+			&c.R.X, &c.R.Y, &slope, &aspect, &ruggedness, &landform, &license, &c.Src.Regular.BlurHash, &sizes,
+			&dates, &timezone, &c.descriptionHash)
+		if err != nil {
+			return 0, err
+		}
+		if landform != nil {
+			c.Terrain = &elevation.Terrain{
+				SlopeDeg:    *slope,
+				AspectDeg:   aspect,
+				RuggednessM: *ruggedness,
+				Landform:    interned.intern(*landform),
+			}
+		}
+		c.setLicense(flickrLicenses[license])
+		c.Src.Large.BlurHash = c.Src.Regular.BlurHash
+		c.setSizes(sizes)
+		c.setLayout()
+		c.setDateTaken(dates, timezone)
+		c.ID, err = encodeChallengeID(internalID)
+		if err != nil {
+			// One bad row shouldn't hold back the rest of the catalog
+			slog.Warn("skipping challenge", "err", err)
+			continue
+		}
+		c.RegionID = interned.intern(strconv.FormatInt(int64(internalRegionID), 10))
+		c.Photographer.Icon = interned.intern(c.Photographer.Icon)
+		c.Photographer.Text = interned.intern(c.Photographer.Text)
+		c.Photographer.Link = interned.intern(c.Photographer.Link)
+		list = append(list, c)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	prev := r.snapshot.Load().challenges
+	if err := r.storeChallenges(list); err != nil {
+		return 0, err
+	}
+	r.challengesLive.Store(true)
+	r.challengesRefreshedAt.Store(time.Now().UnixNano())
+	r.persistedDirty.Store(true)
+
+	r.forgetEditedDescriptions(prev, r.snapshot.Load().challenges)
+	return len(list), nil
+}
+
+func (r *Repo) storeChallenges(list []*Challenge) error {
+	challenges := make(map[int]*Challenge)
+	challengesByRegion := make(map[int][]*Challenge)
+	challengesByDay := make(map[string][]*Challenge)
+	idsByRegion := make(map[int][]int)
+	var allIDs []int
+	for _, c := range list {
+		internalID, err := decodeChallengeID(c.ID)
 		if err != nil {
 			return err
 		}
-		c.ID = encodeChallengeID(internalID)
-		c.RegionID = strconv.FormatInt(int64(internalRegionID), 10)
+		internalRegionID, err := strconv.Atoi(c.RegionID)
+		if err != nil {
+			return err
+		}
+		c.imageHost = imageHost(c)
+		c.compactSizes()
+		if err := encodeChallenge(c, r.outboundPrefix); err != nil {
+			return err
+		}
 		challenges[internalID] = c
 		challengesByRegion[internalRegionID] = append(challengesByRegion[internalRegionID], c)
+		if c.DateTaken != nil {
+			day := c.DateTaken.Format("01-02")
+			challengesByDay[day] = append(challengesByDay[day], c)
+		}
+		idsByRegion[internalRegionID] = append(idsByRegion[internalRegionID], internalID)
+		allIDs = append(allIDs, internalID)
 	}
 
 	var regionsWithChallenges []int
+	poolsByRegion := make(map[int]*selection.Pool)
 	for regionID := range challengesByRegion {
 		regionsWithChallenges = append(regionsWithChallenges, regionID)
+		poolsByRegion[regionID] = selection.NewPool(idsByRegion[regionID], selection.DefaultShardSize)
 	}
+	pool := selection.NewPool(allIDs, selection.DefaultShardSize)
 
-	r.mu.Lock()
-	r.challenges = challenges
-	r.challengesByRegion = challengesByRegion
-	r.regionsWithChallenges = regionsWithChallenges
-	r.mu.Unlock()
+	r.writeMu.Lock()
+	next := *r.snapshot.Load()
+	next.challenges = challenges
+	next.challengesByRegion = challengesByRegion
+	next.regionsWithChallenges = regionsWithChallenges
+	next.pool = pool
+	next.poolsByRegion = poolsByRegion
+	next.challengesByDay = challengesByDay
+	next.spacingGrid = newSpacingGrid(list)
+	r.snapshot.Store(&next)
+	r.writeMu.Unlock()
 	return nil
 }
+
+func (r *Repo) nearbyUpdater(ctx context.Context) {
+	defer r.closeWg.Done()
+
+	t := time.NewTicker(24 * time.Hour)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-r.nearbyStale:
+		case <-ctx.Done():
+			slog.Info("cancelling nearby updater")
+			return
+		}
+
+		for _, view := range []string{"challenge_nearby_pois", "challenge_landmarks", "challenge_admin_areas"} {
+			_, err := r.db.Exec(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY `+view)
+			if err != nil {
+				slog.Error("error refreshing view", "view", view, "err", err)
+			}
+		}
+	}
+}
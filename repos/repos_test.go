@@ -2,6 +2,7 @@ package repos
 
 import (
 	"context"
+	"contourguessr-api/wmts/wmtstest"
 	"encoding/xml"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
@@ -10,17 +11,17 @@ import (
 	"time"
 )
 
+// setupRepo connects to the database at DATABASE_URL, with map layers'
+// capabilities fetched from a stub
 func setupRepo(t *testing.T) (*Repo, func()) {
 	t.Helper()
 
-	err := godotenv.Load("../.env", "../.env.test.local")
-	if err != nil {
-		t.Fatal(err)
-	}
+	// The env files are optional when DATABASE_URL is set some other way
+	_ = godotenv.Load("../.env", "../.env.test.local")
 
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
-		t.Fatal("DATABASE_URL not set")
+		t.Skip("DATABASE_URL not set")
 	}
 
 	db, err := pgxpool.Connect(context.Background(), databaseURL)
@@ -28,23 +29,30 @@ func setupRepo(t *testing.T) (*Repo, func()) {
 		t.Fatal(err)
 	}
 
-	repo := New(db)
+	capabilities := wmtstest.NewServer()
+	repo := New(db, WithCapabilitiesURLs(func(layer string, matrixSet string) string {
+		return wmtstest.URL(capabilities.URL, layer, matrixSet)
+	}))
+	repo.WaitUntilReady()
 
 	teardown := func() {
 		t.Helper()
 		repo.Close()
+		capabilities.Close()
 	}
 
 	return repo, teardown
 }
 
 func TestRegions(t *testing.T) {
-	t.Skip("Hits capabilities endpoints")
-
 	repo, teardown := setupRepo(t)
 	defer teardown()
 
-	_ = repo.Regions()
+	// Regions are only served once their capabilities have been fetched
+	deadline := time.Now().Add(10 * time.Second)
+	for len(repo.Regions()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
 
 	start := time.Now()
 	for i := 0; i < 100; i++ {
@@ -63,7 +71,7 @@ func TestRegions(t *testing.T) {
 	t.Logf("regions: %d", len(regions))
 
 	for _, region := range regions {
-		t.Logf("region: %d %s", region.ID, region.Name)
+		t.Logf("region: %s %s", region.ID, region.Name)
 
 		if region.Name == "" {
 			t.Error("expected region name, got empty")
@@ -81,8 +89,6 @@ func TestRegions(t *testing.T) {
 }
 
 func TestRandomChallenge(t *testing.T) {
-	t.Skip("Hits capabilities endpoints")
-
 	repo, teardown := setupRepo(t)
 	defer teardown()
 
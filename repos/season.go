@@ -0,0 +1,35 @@
+package repos
+
+import "time"
+
+type seasonalMapLayer struct {
+	season string
+	from   string
+	to     string
+	layer  MapLayer
+}
+
+// ForDate returns a copy of the region with the map layer for the seasonal
+// variant covering t, if there is one. Variants whose capabilities haven't
+// been fetched yet fall back to the default layer.
+func (r Region) ForDate(t time.Time) Region {
+	for _, variant := range r.seasonalMapLayers {
+		if inSeason(variant.from, variant.to, t) && variant.layer.CapabilitiesXML != "" {
+			r.MapLayer = variant.layer
+			r.MapLayer.Season = variant.season
+			break
+		}
+	}
+	r.seasonalMapLayers = nil
+	return r
+}
+
+// inSeason reports whether t falls within the inclusive MM-DD bounds, which
+// wrap around the new year when from is after to.
+func inSeason(from string, to string, t time.Time) bool {
+	day := t.Format("01-02")
+	if from <= to {
+		return from <= day && day <= to
+	}
+	return day >= from || day <= to
+}
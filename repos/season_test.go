@@ -0,0 +1,41 @@
+package repos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForDate(t *testing.T) {
+	region := Region{
+		MapLayer: MapLayer{ID: "1", CapabilitiesXML: "<Capabilities/>"},
+		seasonalMapLayers: []seasonalMapLayer{
+			{season: "winter", from: "12-01", to: "02-28", layer: MapLayer{ID: "2", CapabilitiesXML: "<Capabilities/>"}},
+			{season: "spring", from: "03-01", to: "05-31", layer: MapLayer{ID: "3", CapabilitiesXML: "<Capabilities/>"}},
+			// Capabilities not fetched yet
+			{season: "summer", from: "06-01", to: "08-31", layer: MapLayer{ID: "4"}},
+		},
+	}
+
+	tests := []struct {
+		date   time.Time
+		layer  string
+		season string
+	}{
+		{time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC), "2", "winter"},
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "2", "winter"},
+		{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "3", "spring"},
+		{time.Date(2024, 7, 14, 0, 0, 0, 0, time.UTC), "1", ""},
+		{time.Date(2024, 9, 14, 0, 0, 0, 0, time.UTC), "1", ""},
+	}
+	for _, test := range tests {
+		got := region.ForDate(test.date)
+		if got.MapLayer.ID != test.layer || got.MapLayer.Season != test.season {
+			t.Errorf("%s: expected layer %s (%q), got %s (%q)", test.date.Format(time.DateOnly),
+				test.layer, test.season, got.MapLayer.ID, got.MapLayer.Season)
+		}
+	}
+
+	if region.MapLayer.ID != "1" {
+		t.Error("expected ForDate not to modify the original region")
+	}
+}
@@ -0,0 +1,170 @@
+package repos
+
+import (
+	"bytes"
+	"cmp"
+	"contourguessr-api/blurhash"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// flickrSize is an entry in the response to flickr.photos.getSizes, which
+// gives dimensions as numbers for some sizes and strings for others
+type flickrSize struct {
+	Label  string          `json:"label"`
+	Width  json.RawMessage `json:"width"`
+	Height json.RawMessage `json:"height"`
+	Source string          `json:"source"`
+	Media  string          `json:"media"`
+}
+
+func flickrDimension(raw json.RawMessage) int {
+	n, _ := strconv.Atoi(string(bytes.Trim(raw, `"`)))
+	return n
+}
+
+// setSizes fills in Src.Sizes from the flickr.photos.getSizes response, or
+// from Src.Regular and Src.Large if there isn't one. Square crops would
+// distort a srcset, and originals can still carry their EXIF location, so
+// neither is included.
+func (c *Challenge) setSizes(raw json.RawMessage) {
+	var resp struct {
+		Sizes struct {
+			Size []flickrSize `json:"size"`
+		} `json:"sizes"`
+	}
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &resp)
+	}
+
+	var sizes []PictureSrc
+	for _, s := range resp.Sizes.Size {
+		if s.Media != "" && s.Media != "photo" {
+			continue
+		}
+		if strings.Contains(s.Label, "Square") || s.Label == "Original" {
+			continue
+		}
+		p := PictureSrc{Src: s.Source, Width: flickrDimension(s.Width), Height: flickrDimension(s.Height)}
+		if p.Src == "" || p.Width <= 0 || p.Height <= 0 {
+			continue
+		}
+		sizes = append(sizes, p)
+	}
+	if len(sizes) == 0 {
+		for _, p := range []PictureSrc{c.Src.Regular, c.Src.Large} {
+			if p.Src != "" {
+				sizes = append(sizes, p)
+			}
+		}
+	}
+
+	slices.SortStableFunc(sizes, func(a, b PictureSrc) int {
+		return cmp.Compare(a.Width, b.Width)
+	})
+	sizes = slices.CompactFunc(sizes, func(a, b PictureSrc) bool {
+		return a.Width == b.Width
+	})
+	for i := range sizes {
+		sizes[i].BlurHash = c.Src.Regular.BlurHash
+	}
+	c.Src.Sizes = sizes
+}
+
+// compactSizes holds a cached challenge's sizes in place of Src.Sizes. The
+// URLs of a photo's sizes differ only in their suffix, so the prefix they
+// share is kept once, and is usually part of Src.Regular's URL.
+type compactSizes struct {
+	prefix string
+	sizes  []compactSize
+}
+
+type compactSize struct {
+	suffix string
+	width  int
+	height int
+}
+
+// compactSizes moves Src.Sizes into sizes. Every size has the regular size's
+// blurhash, as setSizes gives them, so it isn't kept either.
+func (c *Challenge) compactSizes() {
+	if c.Src.Sizes == nil {
+		return
+	}
+	prefix := c.Src.Sizes[0].Src
+	for _, size := range c.Src.Sizes[1:] {
+		n := 0
+		for n < len(prefix) && n < len(size.Src) && prefix[n] == size.Src[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	if strings.HasPrefix(c.Src.Regular.Src, prefix) {
+		prefix = c.Src.Regular.Src[:len(prefix)]
+	}
+
+	c.sizes = compactSizes{prefix: prefix, sizes: make([]compactSize, len(c.Src.Sizes))}
+	for i, size := range c.Src.Sizes {
+		c.sizes.sizes[i] = compactSize{suffix: size.Src[len(prefix):], width: size.Width, height: size.Height}
+	}
+	c.Src.Sizes = nil
+}
+
+func (c *Challenge) size(i int) PictureSrc {
+	s := c.sizes.sizes[i]
+	return PictureSrc{Src: c.sizes.prefix + s.suffix, Width: s.width, Height: s.height, BlurHash: c.Src.Regular.BlurHash}
+}
+
+// PictureSizes returns every size of the photo, narrowest first. Use it rather
+// than Src.Sizes, which is left empty in cached challenges.
+func (c *Challenge) PictureSizes() []PictureSrc {
+	if c.Src.Sizes != nil || c.sizes.sizes == nil {
+		return c.Src.Sizes
+	}
+	out := make([]PictureSrc, len(c.sizes.sizes))
+	for i := range out {
+		out[i] = c.size(i)
+	}
+	return out
+}
+
+// SizeByWidth finds the size that is width pixels wide
+func (c *Challenge) SizeByWidth(width int) (PictureSrc, bool) {
+	if c.Src.Sizes != nil {
+		i, ok := slices.BinarySearchFunc(c.Src.Sizes, width, func(p PictureSrc, width int) int {
+			return cmp.Compare(p.Width, width)
+		})
+		if !ok {
+			return PictureSrc{}, false
+		}
+		return c.Src.Sizes[i], true
+	}
+	i, ok := slices.BinarySearchFunc(c.sizes.sizes, width, func(s compactSize, width int) int {
+		return cmp.Compare(s.width, width)
+	})
+	if !ok {
+		return PictureSrc{}, false
+	}
+	return c.size(i), true
+}
+
+// setLayout fills in AspectRatio from the photo's dimensions and Color from
+// its blurhash
+func (c *Challenge) setLayout() {
+	c.AspectRatio = 0
+	for _, p := range []PictureSrc{c.Src.Large, c.Src.Regular} {
+		if p.Width > 0 && p.Height > 0 {
+			c.AspectRatio = math.Round(float64(p.Width)/float64(p.Height)*1e4) / 1e4
+			break
+		}
+	}
+
+	c.Color = ""
+	if rgb, err := blurhash.AverageColor(c.Src.Regular.BlurHash); err == nil {
+		c.Color = fmt.Sprintf("#%02x%02x%02x", rgb.R, rgb.G, rgb.B)
+	}
+}
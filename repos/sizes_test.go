@@ -0,0 +1,89 @@
+package repos
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestSetSizes(t *testing.T) {
+	raw := json.RawMessage(`{"sizes": {"canblog": 0, "size": [
+		{"label": "Square", "width": 75, "height": 75, "source": "https://live.staticflickr.com/1/2_s.jpg", "media": "photo"},
+		{"label": "Small 320", "width": "320", "height": "240", "source": "https://live.staticflickr.com/1/2_n.jpg", "media": "photo"},
+		{"label": "Large", "width": 1024, "height": 768, "source": "https://live.staticflickr.com/1/2_b.jpg", "media": "photo"},
+		{"label": "Medium 640", "width": 640, "height": 480, "source": "https://live.staticflickr.com/1/2_z.jpg", "media": "photo"},
+		{"label": "Original", "width": 4000, "height": 3000, "source": "https://live.staticflickr.com/1/2_o.jpg", "media": "photo"},
+		{"label": "Site MP4", "width": 640, "height": 480, "source": "https://www.flickr.com/1/2.mp4", "media": "video"}
+	]}}`)
+	var c Challenge
+	c.Src.Regular.BlurHash = "LEHV6nWB2yk8pyo0adR*.7kCMdnj"
+	c.setSizes(raw)
+
+	var widths []int
+	for _, s := range c.Src.Sizes {
+		widths = append(widths, s.Width)
+		if s.BlurHash != c.Src.Regular.BlurHash {
+			t.Errorf("expected every size to have the blurhash, got %+v", s)
+		}
+	}
+	if len(widths) != 3 || widths[0] != 320 || widths[1] != 640 || widths[2] != 1024 {
+		t.Errorf("expected 320, 640 and 1024 wide sizes, got %v", widths)
+	}
+	if s, ok := c.SizeByWidth(640); !ok || s.Src != "https://live.staticflickr.com/1/2_z.jpg" || s.Height != 480 {
+		t.Errorf("expected to find the 640 wide size, got %+v", s)
+	}
+	if _, ok := c.SizeByWidth(4000); ok {
+		t.Error("expected the original not to be found")
+	}
+}
+
+func TestCompactSizes(t *testing.T) {
+	var c Challenge
+	c.Src.Regular = PictureSrc{Src: "https://live.staticflickr.com/1/2_n.jpg", Width: 320, Height: 240, BlurHash: "LEHV6nWB2yk8pyo0adR*.7kCMdnj"}
+	c.Src.Sizes = []PictureSrc{
+		{Src: "https://live.staticflickr.com/1/2_n.jpg", Width: 320, Height: 240, BlurHash: c.Src.Regular.BlurHash},
+		{Src: "https://live.staticflickr.com/1/2_z.jpg", Width: 640, Height: 480, BlurHash: c.Src.Regular.BlurHash},
+		{Src: "https://live.staticflickr.com/1/2_b.jpg", Width: 1024, Height: 768, BlurHash: c.Src.Regular.BlurHash},
+	}
+	want := c.Src.Sizes
+	c.compactSizes()
+
+	if c.Src.Sizes != nil || c.sizes.prefix != "https://live.staticflickr.com/1/2_" {
+		t.Errorf("expected the sizes to be compacted, got prefix %q", c.sizes.prefix)
+	}
+	if got := c.PictureSizes(); !slices.Equal(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if s, ok := c.SizeByWidth(640); !ok || s != want[1] {
+		t.Errorf("expected to find the 640 wide size, got %+v", s)
+	}
+	if _, ok := c.SizeByWidth(500); ok {
+		t.Error("expected no 500 wide size")
+	}
+}
+
+func TestSetSizesFallsBackToRegularAndLarge(t *testing.T) {
+	var c Challenge
+	c.Src.Regular = PictureSrc{Src: "https://example.com/n.jpg", Width: 320, Height: 240}
+	c.Src.Large = PictureSrc{Src: "https://example.com/b.jpg", Width: 1024, Height: 768}
+	c.setSizes(nil)
+	if len(c.Src.Sizes) != 2 || c.Src.Sizes[0] != c.Src.Regular || c.Src.Sizes[1] != c.Src.Large {
+		t.Errorf("expected regular and large, got %+v", c.Src.Sizes)
+	}
+}
+
+func TestSetLayout(t *testing.T) {
+	var c Challenge
+	c.Src.Regular = PictureSrc{Src: "https://example.com/n.jpg", Width: 320, Height: 213}
+	c.setLayout()
+	if c.AspectRatio != 1.5023 || c.Color != "" {
+		t.Errorf("expected the regular size's aspect ratio and no colour yet, got %v %q", c.AspectRatio, c.Color)
+	}
+
+	c.Src.Large = PictureSrc{Src: "https://example.com/b.jpg", Width: 768, Height: 1024}
+	c.Src.Regular.BlurHash = "L00000fQfQfQfQfQfQfQfQfQfQfQ"
+	c.setLayout()
+	if c.AspectRatio != 0.75 || c.Color != "#000000" {
+		t.Errorf("expected the large size's aspect ratio and black, got %v %q", c.AspectRatio, c.Color)
+	}
+}
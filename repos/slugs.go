@@ -0,0 +1,75 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"regexp"
+)
+
+var SlugNotFoundError = errors.New("slug not found")
+var SlugTakenError = errors.New("slug is taken by another challenge")
+
+// Slugs always contain a hyphen, which encoded IDs never do, so the two can
+// share a path
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)+$`)
+
+const maxSlugLen = 80
+
+// Routes a slug would be shadowed by
+var reservedSlugs = map[string]bool{"on-this-day": true}
+
+// ValidSlug reports whether s can be assigned to a challenge
+func ValidSlug(s string) bool {
+	return len(s) <= maxSlugLen && slugPattern.MatchString(s) && !reservedSlugs[s]
+}
+
+// ChallengeIDBySlug returns the ID of the challenge the slug was assigned to.
+// The challenge may since have been withdrawn.
+func (r *Repo) ChallengeIDBySlug(ctx context.Context, slug string) (string, error) {
+	var internalID int
+	err := r.db.QueryRow(ctx, `SELECT challenge_id FROM challenge_slugs WHERE slug = $1`, slug).Scan(&internalID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", SlugNotFoundError
+	} else if err != nil {
+		return "", err
+	}
+	return encodeChallengeID(internalID)
+}
+
+// SetChallengeSlug assigns slug to the challenge, replacing any slug it had
+func (r *Repo) SetChallengeSlug(ctx context.Context, id string, slug string) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return ChallengeNotFoundError
+	}
+
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO challenge_slugs (challenge_id, slug)
+		SELECT id, $2 FROM challenges WHERE id = $1
+		ON CONFLICT (challenge_id) DO UPDATE SET slug = excluded.slug, created_at = now()
+	`, internalID, slug)
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) && pgErr.SQLState() == "23505" {
+		return SlugTakenError
+	} else if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ChallengeNotFoundError
+	}
+	return nil
+}
+
+// DeleteChallengeSlug reports whether the challenge had a slug
+func (r *Repo) DeleteChallengeSlug(ctx context.Context, id string) (bool, error) {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return false, nil
+	}
+	tag, err := r.db.Exec(ctx, `DELETE FROM challenge_slugs WHERE challenge_id = $1`, internalID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
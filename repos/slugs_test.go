@@ -0,0 +1,47 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidSlug(t *testing.T) {
+	tests := map[string]bool{
+		"ben-nevis-north-face": true,
+		"k2-from-base-camp":    true,
+		"bennevis":             false,
+		"Ben-Nevis":            false,
+		"ben--nevis":           false,
+		"-ben-nevis":           false,
+		"ben nevis":            false,
+		"on-this-day":          false,
+	}
+	for slug, want := range tests {
+		if got := ValidSlug(slug); got != want {
+			t.Errorf("%q: expected %v, got %v", slug, want, got)
+		}
+	}
+}
+
+func TestMemorySlugs(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(DefaultFixtures())
+	first, second := testChallengeID(1), testChallengeID(2)
+
+	if err := m.SetChallengeSlug(ctx, first, "first-slug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetChallengeSlug(ctx, second, "first-slug"); !errors.Is(err, SlugTakenError) {
+		t.Errorf("expected the slug to be taken, got %v", err)
+	}
+	if err := m.SetChallengeSlug(ctx, first, "renamed-slug"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ChallengeIDBySlug(ctx, "first-slug"); !errors.Is(err, SlugNotFoundError) {
+		t.Errorf("expected the old slug to be replaced, got %v", err)
+	}
+	if id, err := m.ChallengeIDBySlug(ctx, "renamed-slug"); err != nil || id != first {
+		t.Errorf("expected %s, got %s, %v", first, id, err)
+	}
+}
@@ -0,0 +1,128 @@
+package repos
+
+import (
+	"contourguessr-api/geo"
+	"math"
+)
+
+// MinGameSpacing is how far apart the challenges picked for one game should
+// be, so that a game doesn't have two photos of the same corrie
+const MinGameSpacing = 2_000.0
+
+// How far along a player's ordering to look for a challenge far enough from
+// those already picked
+const spacingAttempts = 20
+
+var spacingCellDeg = MinGameSpacing / geo.EarthRadius * 180 / math.Pi
+
+type gridCell struct{ x, y int }
+
+// spacingGrid buckets challenges into cells about MinGameSpacing across, so
+// that the challenges near one are found without measuring to all of them.
+// Cells are narrowed in longitude by the cosine of the latitude.
+type spacingGrid map[gridCell][]*Challenge
+
+func spacingCell(c *Challenge) gridCell {
+	x := c.Geo.Lng * math.Cos(c.Geo.Lat*math.Pi/180) / spacingCellDeg
+	return gridCell{int(math.Floor(x)), int(math.Floor(c.Geo.Lat / spacingCellDeg))}
+}
+
+func newSpacingGrid(list []*Challenge) spacingGrid {
+	g := make(spacingGrid)
+	for _, c := range list {
+		cell := spacingCell(c)
+		g[cell] = append(g[cell], c)
+	}
+	return g
+}
+
+// near calls f with each other challenge within MinGameSpacing of c until f
+// returns true
+func (g spacingGrid) near(c *Challenge, f func(other *Challenge) bool) bool {
+	cell := spacingCell(c)
+	p := geo.Point{Lng: c.Geo.Lng, Lat: c.Geo.Lat}
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for _, other := range g[gridCell{cell.x + dx, cell.y + dy}] {
+				if other.ID == c.ID {
+					continue
+				}
+				if geo.Distance(p, geo.Point{Lng: other.Geo.Lng, Lat: other.Geo.Lat}) < MinGameSpacing && f(other) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// gamePicks are the challenges picked for a game so far
+type gamePicks struct {
+	grid   spacingGrid
+	picked map[string]bool
+}
+
+func (s *snapshot) newGamePicks() *gamePicks {
+	return &gamePicks{grid: s.spacingGrid, picked: make(map[string]bool)}
+}
+
+func (p *gamePicks) add(c *Challenge) {
+	p.picked[c.ID] = true
+}
+
+// spaced reports whether c is far enough from every challenge picked so far.
+// Challenges the grid doesn't know about, such as those archived since the
+// snapshot, are never too close.
+func (p *gamePicks) spaced(c *Challenge) bool {
+	if p.picked[c.ID] {
+		return false
+	}
+	return !p.grid.near(c, func(other *Challenge) bool { return p.picked[other.ID] })
+}
+
+// pick tries candidates in order for one far enough from those picked so far.
+// If none in reach is, spacing gives way to the first candidate rather than
+// cutting the game short.
+func (p *gamePicks) pick(candidate func(skip int) *Challenge) *Challenge {
+	first := candidate(0)
+	for skip := 0; skip < spacingAttempts; skip++ {
+		c := first
+		if skip > 0 {
+			c = candidate(skip)
+		}
+		if c == nil {
+			break
+		}
+		if p.spaced(c) {
+			return c
+		}
+	}
+	return first
+}
+
+// spreadOut takes up to n of list in order, passing over any too close to
+// those already taken. If that leaves the game short it's topped up with the
+// passed over challenges.
+func (s *snapshot) spreadOut(list []Challenge, n int) []Challenge {
+	picks := s.newGamePicks()
+	out := make([]Challenge, 0, n)
+	var passed []Challenge
+	for i := range list {
+		if len(out) == n {
+			break
+		}
+		if !picks.spaced(&list[i]) {
+			passed = append(passed, list[i])
+			continue
+		}
+		picks.add(&list[i])
+		out = append(out, list[i])
+	}
+	for _, c := range passed {
+		if len(out) == n {
+			break
+		}
+		out = append(out, c)
+	}
+	return out
+}
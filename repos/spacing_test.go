@@ -0,0 +1,68 @@
+package repos
+
+import (
+	"slices"
+	"testing"
+)
+
+func spacingTestChallenge(id string, lng float64, lat float64) Challenge {
+	c := Challenge{ID: id}
+	c.Geo.Lng, c.Geo.Lat = lng, lat
+	return c
+}
+
+func TestSpreadOut(t *testing.T) {
+	list := []Challenge{
+		spacingTestChallenge("corrie", -3.0, 56.0),
+		// About 600m away, in the same corrie
+		spacingTestChallenge("lochan", -3.01, 56.0),
+		spacingTestChallenge("summit", -3.1, 56.0090),
+		// Across a cell boundary from the summit but only 100m from it
+		spacingTestChallenge("cairn", -3.1, 56.0099),
+	}
+	ptrs := make([]*Challenge, len(list))
+	for i := range list {
+		ptrs[i] = &list[i]
+	}
+	s := &snapshot{spacingGrid: newSpacingGrid(ptrs)}
+
+	ids := func(challenges []Challenge) []string {
+		var out []string
+		for _, c := range challenges {
+			out = append(out, c.ID)
+		}
+		return out
+	}
+	if got := ids(s.spreadOut(list, 2)); !slices.Equal(got, []string{"corrie", "summit"}) {
+		t.Errorf("expected the near duplicates to be passed over, got %v", got)
+	}
+	if got := ids(s.spreadOut(list, 3)); !slices.Equal(got, []string{"corrie", "summit", "lochan"}) {
+		t.Errorf("expected to be topped up with a near duplicate, got %v", got)
+	}
+}
+
+func TestGamePicksPick(t *testing.T) {
+	list := []Challenge{
+		spacingTestChallenge("a", 10, 45),
+		spacingTestChallenge("b", 10.001, 45),
+		spacingTestChallenge("c", 11, 45),
+	}
+	ptrs := []*Challenge{&list[0], &list[1], &list[2]}
+	s := &snapshot{spacingGrid: newSpacingGrid(ptrs)}
+
+	picks := s.newGamePicks()
+	picks.add(ptrs[0])
+	candidates := func(skip int) *Challenge {
+		if skip+1 < len(ptrs) {
+			return ptrs[skip+1]
+		}
+		return nil
+	}
+	if got := picks.pick(candidates); got.ID != "c" {
+		t.Errorf("expected to skip past b, got %s", got.ID)
+	}
+	picks.add(ptrs[2])
+	if got := picks.pick(candidates); got.ID != "b" {
+		t.Errorf("expected to fall back to the first candidate, got %s", got.ID)
+	}
+}
@@ -0,0 +1,122 @@
+package repos
+
+import (
+	"context"
+	"contourguessr-api/elevation"
+	"encoding/json"
+	"time"
+)
+
+// Store is everything the API needs from a Repo, so that handlers can be
+// tested against Memory instead of Postgres
+type Store interface {
+	WaitUntilReady()
+	Close()
+	Ping(ctx context.Context) error
+	Populated() bool
+	LastRefreshed() (regions time.Time, challenges time.Time)
+	CacheAge() (regions time.Duration, challenges time.Duration)
+	CacheStats() map[string]CacheStats
+
+	Regions() map[int]Region
+	RegionsUpdatedAt() time.Time
+	RegionsJSON(date time.Time, langs []string) (EncodedRegions, error)
+	RegionsV2JSON(langs []string) (EncodedRegions, error)
+	SetRegionAdvisory(ctx context.Context, regionID int, advisory RegionAdvisory) error
+	DeleteRegionAdvisory(ctx context.Context, regionID int) error
+	SetRegionTranslation(ctx context.Context, regionID int, lang string, t RegionTranslation) error
+	DeleteRegionTranslation(ctx context.Context, regionID int, lang string) error
+	PrivacyZones(ctx context.Context, regionID int) ([]PrivacyZone, error)
+	SetPrivacyZone(ctx context.Context, regionID int, zone PrivacyZone) ([]string, error)
+	DeletePrivacyZone(ctx context.Context, regionID int, name string) error
+	CapabilitiesChanges() []CapabilitiesChange
+	BreakingCapabilitiesChanges() uint64
+
+	Challenge(id string) (Challenge, error)
+	ServedChallenge(ctx context.Context, c Challenge) Challenge
+	ChallengeJSON(ctx context.Context, c Challenge) ([]byte, error)
+	ChallengeDescription(ctx context.Context, id string) (string, error)
+	ChallengeDebugInfoJSON(ctx context.Context, id string) (string, error)
+	ChallengesPerRegion() map[int]int
+	RandomChallenge(region *int) (Challenge, error)
+	PlayerChallenge(region *int, player string, seq uint64) (Challenge, error)
+	SampleChallenges(n int) []Challenge
+	SearchChallenges(f ChallengeFilter, limit int) []Challenge
+	OnThisDay(date time.Time, region *int) []Challenge
+	WorldTour(player string, seq uint64, rounds int) ([]Challenge, error)
+	RecentlyAdded(ctx context.Context, region int, limit int) ([]AddedChallenge, error)
+	ExportChallenges(ctx context.Context, region *int, row func(c Challenge, license string) error) error
+	ChallengeNearby(ctx context.Context, id string) ([]NearbyPOI, error)
+	ChallengeLandmarks(ctx context.Context, id string) (Landmarks, error)
+	ChallengeWeather(ctx context.Context, id string) (json.RawMessage, bool, error)
+	SetChallengeWeather(ctx context.Context, id string, conditions json.RawMessage) error
+	ChallengeElevation(ctx context.Context, id string) (float64, bool, error)
+	SetChallengeElevation(ctx context.Context, id string, elevation float64) error
+	ChallengesWithoutTerrain(ctx context.Context, limit int) ([]ChallengePoint, error)
+	SetChallengeTerrain(ctx context.Context, id string, t elevation.Terrain) error
+	ChallengesWithoutBlurHash(ctx context.Context, limit int) ([]ChallengePhoto, error)
+	SetChallengeBlurHash(ctx context.Context, id string, hash string) error
+	ChallengesWithoutTimezone(ctx context.Context, limit int) ([]ChallengeLocation, error)
+	SetChallengeTimezone(ctx context.Context, id string, timezone string) error
+	ArchiveChallenge(ctx context.Context, id string, reason string) error
+	RestoreChallenge(ctx context.Context, id string) error
+	ChallengeIDBySlug(ctx context.Context, slug string) (string, error)
+	SetChallengeSlug(ctx context.Context, id string, slug string) error
+	DeleteChallengeSlug(ctx context.Context, id string) (bool, error)
+
+	ImageHostsDown() []string
+	SetImageHostsDown(hosts []string)
+	SkippedForDownHosts() uint64
+
+	RecordGuess(ctx context.Context, id string, player string, lng float64, lat float64) (int64, error)
+	Guesses(ctx context.Context, ids []int64) (map[int64]Guess, error)
+	SetGuessElevation(ctx context.Context, guessID int64, elevation float64) error
+	RecordHint(ctx context.Context, id string, play string, level int) error
+	RecordGuessEvent(ctx context.Context, e GuessEvent) error
+	ChallengeGuessStats(ctx context.Context, id string, edges []float64, distance float64) (GuessStats, error)
+	GuessHeatmap(ctx context.Context, id string, lngStep float64, latStep float64, minGuesses int) ([]HeatmapCell, error)
+	HintLevel(ctx context.Context, id string, play string) (int, error)
+	BestRounds(ctx context.Context, player string, limit int) ([]BestRound, error)
+	RecordServe(challenge Challenge)
+	RegionPopularity(ctx context.Context) ([]RegionPopularity, error)
+	ChallengePopularity(ctx context.Context, regionID *int, limit int) ([]ChallengePopularity, error)
+	OutboundLink(target string, challengeID string) string
+	RecordOutboundClick(ctx context.Context, target string, challengeID string) error
+
+	StartCampaign(ctx context.Context, player string, regionID int) (Campaign, error)
+	Campaign(ctx context.Context, player string, regionID int) (Campaign, error)
+	AdvanceCampaign(ctx context.Context, player string, regionID int, challengeID string) (Campaign, error)
+	SubmitScore(ctx context.Context, board string, game string, player string, name string, total int, rounds int) error
+	Leaderboard(ctx context.Context, board string, limit int) ([]LeaderboardEntry, error)
+	CreateCustomGame(ctx context.Context, creator string, name string, challengeIDs []string) (CustomGame, error)
+	CreateCustomGameFromFilter(ctx context.Context, creator string, name string, f ChallengeFilter, n int) (CustomGame, error)
+	CustomGame(ctx context.Context, code string) (CustomGame, error)
+
+	CreatePartner(ctx context.Context, name string) (Partner, string, error)
+	PartnerByToken(ctx context.Context, token string) (Partner, error)
+	FlagChallenge(ctx context.Context, partner Partner, challengeID string, reason string) (ModerationState, error)
+	ReviewChallenge(ctx context.Context, challengeID string, to ModerationState, note string) error
+	ModerationQueue(ctx context.Context, state ModerationState) ([]ChallengeModeration, error)
+
+	CreateAPIKey(ctx context.Context, name string, dailyQuota int) (APIKey, string, error)
+	APIKeyByToken(ctx context.Context, token string) (APIKey, error)
+	APIKeys(ctx context.Context) ([]APIKey, error)
+	SetAPIKeyQuota(ctx context.Context, id int, dailyQuota int) (APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+	RequestOptOut(ctx context.Context, link string, contact string, reason string) (OptOut, error)
+	OptOuts(ctx context.Context, state OptOutState) ([]OptOut, error)
+	ReviewOptOut(ctx context.Context, id int, state OptOutState) (OptOut, []string, error)
+	PhotographerStats(ctx context.Context, account string) (PhotographerStats, error)
+
+	ScheduledEvents(ctx context.Context, since time.Time) ([]ScheduledEvent, error)
+	CreateScheduledEvent(ctx context.Context, e ScheduledEvent) (ScheduledEvent, error)
+	DeleteScheduledEvent(ctx context.Context, id int64) (bool, error)
+
+	CachedGeocode(ctx context.Context, kind string, lng float64, lat float64) (json.RawMessage, bool, error)
+	SetCachedGeocode(ctx context.Context, kind string, lng float64, lat float64, result json.RawMessage, ttl time.Duration) error
+	Setting(ctx context.Context, key string, v any) (bool, error)
+	SetSetting(ctx context.Context, key string, v any) error
+}
+
+var _ Store = (*Repo)(nil)
+var _ Store = (*Memory)(nil)
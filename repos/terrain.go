@@ -0,0 +1,60 @@
+package repos
+
+import (
+	"context"
+	"contourguessr-api/elevation"
+	"contourguessr-api/geo"
+)
+
+// ChallengePoint is where a challenge was taken
+type ChallengePoint struct {
+	ID  string
+	Geo geo.Point
+}
+
+// ChallengesWithoutTerrain lists challenges whose terrain hasn't been computed
+// yet, oldest first
+func (r *Repo) ChallengesWithoutTerrain(ctx context.Context, limit int) ([]ChallengePoint, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id, ST_X(c.geo::geometry), ST_Y(c.geo::geometry)
+		FROM challenges AS c
+		LEFT JOIN challenge_terrain AS t ON t.challenge_id = c.id
+		WHERE t.challenge_id IS NULL
+		ORDER BY c.id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChallengePoint
+	for rows.Next() {
+		var internalID int
+		var p ChallengePoint
+		if err := rows.Scan(&internalID, &p.Geo.Lng, &p.Geo.Lat); err != nil {
+			return nil, err
+		}
+		p.ID, err = encodeChallengeID(internalID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) SetChallengeTerrain(ctx context.Context, id string, t elevation.Terrain) error {
+	internalID, err := decodeChallengeID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO challenge_terrain (challenge_id, slope_deg, aspect_deg, ruggedness_m, landform)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (challenge_id) DO UPDATE
+		SET slope_deg = EXCLUDED.slope_deg, aspect_deg = EXCLUDED.aspect_deg,
+			ruggedness_m = EXCLUDED.ruggedness_m, landform = EXCLUDED.landform, computed_at = now()
+	`, internalID, t.SlopeDeg, t.AspectDeg, t.RuggednessM, t.Landform)
+	return err
+}
@@ -0,0 +1,92 @@
+package repos
+
+import (
+	"math"
+)
+
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+
+	GridOSGB  = "osgb"  // Ordnance Survey National Grid, like SH 6094 5437
+	GridIrish = "irish" // Irish Grid, like J 3345 7432
+	GridLV95  = "lv95"  // Swiss LV95, like 2 600 000 / 1 200 000
+	GridUTM   = "utm"
+)
+
+// RegionUnits is how distances and grid references are conventionally given
+// in a region
+type RegionUnits struct {
+	System string     `json:"system"`
+	Grid   GridFormat `json:"grid"`
+}
+
+type GridFormat struct {
+	System string `json:"system"`
+	// Only for UTM
+	Zone       int    `json:"zone,omitempty"`
+	Hemisphere string `json:"hemisphere,omitempty"`
+}
+
+// Countries that still give distances in miles
+var imperialCountries = map[string]bool{
+	"GB": true,
+	"US": true,
+	"LR": true,
+	"MM": true,
+}
+
+var nationalGrids = map[string]string{
+	"GB": GridOSGB,
+	"IM": GridOSGB,
+	"IE": GridIrish,
+	"CH": GridLV95,
+	"LI": GridLV95,
+}
+
+// unitsFor picks the conventions for a region from its country, falling back
+// to the UTM zone of the middle of its bounding box
+func unitsFor(r Region) RegionUnits {
+	out := RegionUnits{System: UnitsMetric}
+	if imperialCountries[r.CountryISO2] {
+		out.System = UnitsImperial
+	}
+
+	lng := (r.BBox.MinLng + r.BBox.MaxLng) / 2
+	lat := (r.BBox.MinLat + r.BBox.MaxLat) / 2
+	if grid, ok := nationalGrids[r.CountryISO2]; ok {
+		out.Grid.System = grid
+		// Northern Ireland is mapped on the Irish Grid
+		if grid == GridOSGB && lng < -5.4 && lat > 54 && lat < 55.4 {
+			out.Grid.System = GridIrish
+		}
+		return out
+	}
+
+	out.Grid = GridFormat{System: GridUTM, Zone: utmZone(lng, lat), Hemisphere: "N"}
+	if lat < 0 {
+		out.Grid.Hemisphere = "S"
+	}
+	return out
+}
+
+// utmZone includes the exceptions for southwestern Norway and Svalbard
+func utmZone(lng float64, lat float64) int {
+	zone := int(math.Floor((lng+180)/6)) + 1
+	if lat >= 56 && lat < 64 && lng >= 3 && lng < 12 {
+		return 32
+	}
+	if lat >= 72 && lat < 84 {
+		switch {
+		case lng >= 0 && lng < 9:
+			return 31
+		case lng >= 9 && lng < 21:
+			return 33
+		case lng >= 21 && lng < 33:
+			return 35
+		case lng >= 33 && lng < 42:
+			return 37
+		}
+	}
+	return max(1, min(60, zone))
+}
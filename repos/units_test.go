@@ -0,0 +1,32 @@
+package repos
+
+import (
+	"testing"
+)
+
+func TestUnitsFor(t *testing.T) {
+	region := func(country string, minLng, minLat, maxLng, maxLat float64) Region {
+		r := Region{CountryISO2: country}
+		r.BBox.MinLng, r.BBox.MinLat, r.BBox.MaxLng, r.BBox.MaxLat = minLng, minLat, maxLng, maxLat
+		return r
+	}
+	cases := []struct {
+		name   string
+		region Region
+		want   RegionUnits
+	}{
+		{"Snowdonia", region("GB", -4.2, 52.7, -3.5, 53.2), RegionUnits{UnitsImperial, GridFormat{System: GridOSGB}}},
+		{"Mourne", region("GB", -6.1, 54.1, -5.8, 54.3), RegionUnits{UnitsImperial, GridFormat{System: GridIrish}}},
+		{"Wicklow", region("IE", -6.5, 52.9, -6.1, 53.2), RegionUnits{UnitsMetric, GridFormat{System: GridIrish}}},
+		{"Bernese Oberland", region("CH", 7.5, 46.4, 8.2, 46.7), RegionUnits{UnitsMetric, GridFormat{System: GridLV95}}},
+		{"Chamonix", region("FR", 6.8, 45.8, 7.0, 46.0), RegionUnits{UnitsMetric, GridFormat{GridUTM, 32, "N"}}},
+		{"Jotunheimen", region("NO", 7.8, 61.3, 8.9, 61.8), RegionUnits{UnitsMetric, GridFormat{GridUTM, 32, "N"}}},
+		{"Yosemite", region("US", -119.9, 37.5, -119.2, 38.2), RegionUnits{UnitsImperial, GridFormat{GridUTM, 11, "N"}}},
+		{"Aoraki", region("NZ", 170.0, -43.8, 170.3, -43.5), RegionUnits{UnitsMetric, GridFormat{GridUTM, 59, "S"}}},
+	}
+	for _, c := range cases {
+		if got := unitsFor(c.region); got != c.want {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.want, got)
+		}
+	}
+}
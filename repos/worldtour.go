@@ -0,0 +1,48 @@
+package repos
+
+import (
+	"contourguessr-api/selection"
+	"errors"
+	"sort"
+)
+
+var NotEnoughRegionsError = errors.New("not enough regions for a world tour")
+
+// WorldTour picks one challenge from each of rounds different regions. Like
+// PlayerChallenge, players that step through seq get a fresh tour each time.
+func (r *Repo) WorldTour(player string, seq uint64, rounds int) ([]Challenge, error) {
+	r.initWg.Wait()
+	s := r.snapshot.Load()
+
+	_, poolsByRegion := r.pools(s)
+	regionIDs := make([]int, 0, len(poolsByRegion))
+	for id, pool := range poolsByRegion {
+		if pool.Len() > 0 {
+			regionIDs = append(regionIDs, id)
+		}
+	}
+	if len(regionIDs) < rounds {
+		return nil, NotEnoughRegionsError
+	}
+	sort.Ints(regionIDs)
+
+	out := make([]Challenge, 0, rounds)
+	picks := s.newGamePicks()
+	for _, i := range selection.Sample(player, seq, len(regionIDs), rounds) {
+		pool := poolsByRegion[regionIDs[i]]
+		// Neighbouring regions can share a summit
+		pick := picks.pick(func(skip int) *Challenge {
+			id, ok := pool.Pick(player, seq+uint64(skip))
+			if !ok {
+				return nil
+			}
+			return s.challenges[id]
+		})
+		if pick == nil {
+			return nil, NoChallengesAvailableError
+		}
+		picks.add(pick)
+		out = append(out, *pick)
+	}
+	return out, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"contourguessr-api/seed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runSeed is the seed subcommand, which writes demo regions and challenges
+// for serving locally:
+//
+//	contourguessr-api seed && FIXTURES_PATH=fixtures.seed.json contourguessr-api
+func runSeed(args []string) int {
+	opts := seed.DefaultOptions
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	out := fs.String("o", "fixtures.seed.json", "file to write, or - for stdout")
+	fs.IntVar(&opts.ChallengesPerRegion, "challenges", opts.ChallengesPerRegion, "challenges per region")
+	fs.Uint64Var(&opts.Seed, "seed", opts.Seed, "seed for the generator")
+	fs.StringVar(&opts.ImageBaseURL, "images", opts.ImageBaseURL, "where placeholder images are served from")
+	_ = fs.Parse(args)
+	if opts.ChallengesPerRegion < 1 {
+		fmt.Fprintln(os.Stderr, "seed: -challenges must be at least 1")
+		return 2
+	}
+
+	fixtures := seed.Generate(opts)
+
+	f := os.Stdout
+	if *out != "-" {
+		var err error
+		f, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "seed:", err)
+			return 1
+		}
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(fixtures)
+	if *out != "-" {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "seed:", err)
+		return 1
+	}
+
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "wrote %d regions and %d challenges to %s, serve them with FIXTURES_PATH=%s\n",
+			len(fixtures.Regions), len(fixtures.Challenges), *out, *out)
+	}
+	return 0
+}
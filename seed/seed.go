@@ -0,0 +1,133 @@
+// Package seed makes up demo regions and challenges for running the API
+// locally, without the photo pipeline or a database.
+package seed
+
+import (
+	"contourguessr-api/repos"
+	"contourguessr-api/wmts/wmtstest"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	ChallengesPerRegion int
+	// Seeds the generator, so the same options always give the same
+	// challenges
+	Seed uint64
+	// Placeholder images are fetched from ImageBaseURL/{seed}/{width}/{height},
+	// which is what picsum.photos serves
+	ImageBaseURL string
+}
+
+var DefaultOptions = Options{
+	ChallengesPerRegion: 25,
+	Seed:                1,
+	ImageBaseURL:        "https://picsum.photos/seed",
+}
+
+type demoRegion struct {
+	name                           string
+	countryISO2                    string
+	minLng, minLat, maxLng, maxLat float64
+}
+
+var demoRegions = []demoRegion{
+	{"Demo Cairngorms", "GB", -3.9, 56.9, -3.4, 57.2},
+	{"Demo Mont Blanc", "FR", 6.75, 45.8, 7.05, 46.0},
+}
+
+// The stub layer's zoom levels, which are OpenStreetMap's. They stand in for
+// a national mapping agency's, which need an API key.
+const (
+	stubMinZoom     = 8
+	stubMaxZoom     = 16
+	stubDefaultZoom = 13
+)
+
+// Generate returns a fixture for each demo region, sharing one stub map
+// layer, with synthetic challenges scattered over each region
+func Generate(opts Options) repos.Fixtures {
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	layer := stubMapLayer()
+	baseURL := strings.TrimSuffix(opts.ImageBaseURL, "/")
+	epoch := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+	added := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var f repos.Fixtures
+	n := 0
+	for i, demo := range demoRegions {
+		id := strconv.Itoa(i + 1)
+		f.Regions = append(f.Regions, region(id, demo, layer))
+
+		for range opts.ChallengesPerRegion {
+			n++
+			var c repos.Challenge
+			c.RegionID = id
+			c.Geo.Lng = round(demo.minLng + rng.Float64()*(demo.maxLng-demo.minLng))
+			c.Geo.Lat = round(demo.minLat + rng.Float64()*(demo.maxLat-demo.minLat))
+			c.Title = fmt.Sprintf("Demo challenge %d", n)
+			c.DescriptionHTML = "<p>A made up challenge in " + demo.name + ".</p>"
+			taken := epoch.Add(time.Duration(rng.IntN(10*365*24)) * time.Hour)
+			c.DateTaken = &taken
+			imageSeed := "contourguessr-" + strconv.FormatUint(opts.Seed, 10) + "-" + strconv.Itoa(n)
+			c.Src.Regular = repos.PictureSrc{Src: baseURL + "/" + imageSeed + "/320/240", Width: 320, Height: 240}
+			c.Src.Large = repos.PictureSrc{Src: baseURL + "/" + imageSeed + "/1024/768", Width: 1024, Height: 768}
+			c.Link = c.Src.Large.Src
+			c.Photographer.Text = "Seed data"
+			c.R.X = 0.5
+			c.R.Y = 0.5
+
+			f.Challenges = append(f.Challenges, repos.FixtureChallenge{
+				Challenge: c,
+				License:   "Placeholder",
+				AddedAt:   added.Add(time.Duration(n) * time.Hour),
+			})
+		}
+	}
+	return f
+}
+
+func region(id string, demo demoRegion, layer repos.MapLayer) repos.Region {
+	r := repos.Region{
+		ID:          id,
+		Name:        demo.name,
+		CountryISO2: demo.countryISO2,
+	}
+	r.GeoJSON, _ = json.Marshal(map[string]any{
+		"type": "Polygon",
+		"coordinates": [][][2]float64{{
+			{demo.minLng, demo.minLat}, {demo.maxLng, demo.minLat}, {demo.maxLng, demo.maxLat},
+			{demo.minLng, demo.maxLat}, {demo.minLng, demo.minLat},
+		}},
+	})
+	r.BBox.MinLng, r.BBox.MinLat, r.BBox.MaxLng, r.BBox.MaxLat = demo.minLng, demo.minLat, demo.maxLng, demo.maxLat
+	layer.ID = id
+	r.MapLayer = layer
+	return r
+}
+
+func stubMapLayer() repos.MapLayer {
+	var resolutions []float64
+	for z := stubMinZoom; z <= stubMaxZoom; z++ {
+		resolutions = append(resolutions, wmtstest.Resolution(z))
+	}
+	return repos.MapLayer{
+		Name:              "OpenStreetMap",
+		CapabilitiesXML:   wmtstest.Capabilities("osm", "GoogleMapsCompatible"),
+		Layer:             "osm",
+		MatrixSet:         "GoogleMapsCompatible",
+		Resolutions:       resolutions,
+		DefaultResolution: wmtstest.Resolution(stubDefaultZoom),
+		ExtraAttributions: []string{"© OpenStreetMap contributors"},
+	}
+}
+
+// round keeps coordinates to about a metre
+func round(v float64) float64 {
+	return math.Round(v*1e5) / 1e5
+}
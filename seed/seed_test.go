@@ -0,0 +1,71 @@
+package seed
+
+import (
+	"contourguessr-api/repos"
+	"contourguessr-api/wmts/wmtstest"
+	"encoding/json"
+	"testing"
+)
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	a, _ := json.Marshal(Generate(DefaultOptions))
+	b, _ := json.Marshal(Generate(DefaultOptions))
+	if string(a) != string(b) {
+		t.Error("expected the same options to give the same fixtures")
+	}
+
+	other := DefaultOptions
+	other.Seed = 2
+	c, _ := json.Marshal(Generate(other))
+	if string(a) == string(c) {
+		t.Error("expected a different seed to give different fixtures")
+	}
+}
+
+func TestGenerateIsPlayable(t *testing.T) {
+	opts := DefaultOptions
+	opts.ChallengesPerRegion = 4
+	f := Generate(opts)
+	if len(f.Challenges) != len(demoRegions)*4 {
+		t.Fatalf("expected %d challenges, got %d", len(demoRegions)*4, len(f.Challenges))
+	}
+
+	regions := make(map[string]repos.Region)
+	for _, r := range f.Regions {
+		regions[r.ID] = r
+	}
+	for _, fc := range f.Challenges {
+		c := fc.Challenge
+		r := regions[c.RegionID]
+		if c.Geo.Lng < r.BBox.MinLng || c.Geo.Lng > r.BBox.MaxLng || c.Geo.Lat < r.BBox.MinLat || c.Geo.Lat > r.BBox.MaxLat {
+			t.Errorf("expected %s to be in %s, got %v", c.Title, r.Name, c.Geo)
+		}
+	}
+
+	m := repos.NewMemory(f)
+	if !m.Populated() {
+		t.Fatal("expected memory to be populated")
+	}
+	encoded, err := m.RegionsV2JSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Regions []struct {
+			MapLayers []struct {
+				TileMatrices []json.RawMessage `json:"tile_matrices"`
+			} `json:"map_layers"`
+		} `json:"regions"`
+	}
+	if err := json.Unmarshal(encoded.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Regions) != len(demoRegions) {
+		t.Fatalf("expected %d regions, got %d", len(demoRegions), len(body.Regions))
+	}
+	for _, r := range body.Regions {
+		if len(r.MapLayers) != 1 || len(r.MapLayers[0].TileMatrices) != wmtstest.MaxZoom+1 {
+			t.Errorf("expected the stub layer's zoom levels to be served, got %+v", r.MapLayers)
+		}
+	}
+}
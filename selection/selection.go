@@ -0,0 +1,143 @@
+package selection
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// DefaultShardSize keeps each player's home shard small enough to cycle
+// through within a few weeks of regular play
+const DefaultShardSize = 1000
+
+// Pool hands each player a personal ordering of every item in it. Items are
+// spread over shards with a consistent hash of their ID, so shards stay mostly
+// stable as the pool grows. A player starts in a home shard picked by a
+// consistent hash of their ID, takes every item there in a personal shuffled
+// order, and then moves on to the following shards. Only once the whole pool
+// has been seen does a new cycle start, with a different shuffle.
+type Pool struct {
+	shards [][]int
+	size   int
+}
+
+func NewPool(ids []int, shardSize int) *Pool {
+	if shardSize <= 0 {
+		shardSize = DefaultShardSize
+	}
+	count := (len(ids) + shardSize - 1) / shardSize
+	if count == 0 {
+		count = 1
+	}
+
+	shards := make([][]int, count)
+	for _, id := range ids {
+		shard := jumpHash(mix(uint64(id)), count)
+		shards[shard] = append(shards[shard], id)
+	}
+	for _, shard := range shards {
+		sort.Ints(shard)
+	}
+
+	return &Pool{shards: shards, size: len(ids)}
+}
+
+func (p *Pool) Len() int {
+	return p.size
+}
+
+func (p *Pool) Shards() int {
+	return len(p.shards)
+}
+
+// Pick returns the item at position seq in the player's ordering. Any run of
+// Len() consecutive positions starting at a multiple of Len() contains every
+// item exactly once.
+func (p *Pool) Pick(player string, seq uint64) (int, bool) {
+	if p.size == 0 {
+		return 0, false
+	}
+
+	key := hashString(player)
+	cycle := seq / uint64(p.size)
+	offset := seq % uint64(p.size)
+
+	home := jumpHash(key, len(p.shards))
+	for i := range p.shards {
+		shardIndex := (home + i) % len(p.shards)
+		shard := p.shards[shardIndex]
+		if offset >= uint64(len(shard)) {
+			offset -= uint64(len(shard))
+			continue
+		}
+
+		seed := mix(key ^ mix(cycle) ^ mix(uint64(shardIndex)<<32))
+		return shard[permute(offset, uint64(len(shard)), seed)], true
+	}
+	panic("unreachable")
+}
+
+// Sample returns k distinct indices in [0, n), for example regions to draw
+// from, in an order personal to the player. Each seq gives a different sample.
+func Sample(player string, seq uint64, n int, k int) []int {
+	if k > n {
+		k = n
+	}
+	seed := mix(hashString(player) ^ mix(seq^0x5a4d))
+	out := make([]int, 0, k)
+	for i := 0; i < k; i++ {
+		out = append(out, int(permute(uint64(i), uint64(n), seed)))
+	}
+	return out
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// permute maps i to a position in [0, n) using a four round Feistel network
+// over the smallest even-bit domain covering n, cycle walking until the result
+// lands inside the range. Each seed gives a different bijection.
+func permute(i uint64, n uint64, seed uint64) uint64 {
+	if n <= 1 {
+		return 0
+	}
+	bits := uint(1)
+	for uint64(1)<<(2*bits) < n {
+		bits++
+	}
+	mask := uint64(1)<<bits - 1
+
+	for {
+		left, right := i>>bits, i&mask
+		for round := uint64(0); round < 4; round++ {
+			left, right = right, left^(mix(right^seed^(round<<56))&mask)
+		}
+		i = left<<bits | right
+		if i < n {
+			return i
+		}
+	}
+}
+
+// mix is the splitmix64 finalizer
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return mix(h.Sum64())
+}
@@ -0,0 +1,140 @@
+package selection
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func ids(n int) []int {
+	out := make([]int, n)
+	for i := range out {
+		out[i] = i + 1
+	}
+	return out
+}
+
+func TestPickCoversPoolBeforeRepeating(t *testing.T) {
+	for _, n := range []int{1, 2, 7, 1000, 2500} {
+		p := NewPool(ids(n), 1000)
+		for _, player := range []string{"alice", "bob"} {
+			for cycle := uint64(0); cycle < 2; cycle++ {
+				seen := make(map[int]bool)
+				for i := uint64(0); i < uint64(n); i++ {
+					id, ok := p.Pick(player, cycle*uint64(n)+i)
+					if !ok {
+						t.Fatal("expected pick")
+					}
+					if seen[id] {
+						t.Fatalf("n=%d %s cycle %d: repeated %d at %d", n, player, cycle, id, i)
+					}
+					seen[id] = true
+				}
+			}
+		}
+	}
+}
+
+func TestPickOrderIsPersonal(t *testing.T) {
+	p := NewPool(ids(500), 1000)
+	same := 0
+	for i := uint64(0); i < 500; i++ {
+		a, _ := p.Pick("alice", i)
+		b, _ := p.Pick("bob", i)
+		if a == b {
+			same++
+		}
+	}
+	if same > 10 {
+		t.Errorf("expected different orders, %d positions matched", same)
+	}
+
+	first, _ := p.Pick("alice", 0)
+	second, _ := p.Pick("alice", 500)
+	third, _ := p.Pick("alice", 1000)
+	if first == second && second == third {
+		t.Error("expected each cycle to be shuffled differently")
+	}
+}
+
+func TestFirstPickFairness(t *testing.T) {
+	const n = 50
+	const players = 50000
+	p := NewPool(ids(n), 1000)
+
+	counts := make(map[int]int)
+	for i := 0; i < players; i++ {
+		id, _ := p.Pick("player-"+strconv.Itoa(i), 0)
+		counts[id]++
+	}
+
+	expected := float64(players) / n
+	var chi2 float64
+	for _, id := range ids(n) {
+		d := float64(counts[id]) - expected
+		chi2 += d * d / expected
+	}
+	// The 99.9th percentile of chi-squared with 49 degrees of freedom is ~85
+	if chi2 > 85 {
+		t.Errorf("first picks are not uniform: chi2 = %.1f", chi2)
+	}
+}
+
+func TestShardBalance(t *testing.T) {
+	p := NewPool(ids(10000), 1000)
+	if p.Shards() != 10 {
+		t.Fatalf("expected 10 shards, got %d", p.Shards())
+	}
+	for i, shard := range p.shards {
+		if math.Abs(float64(len(shard))-1000) > 150 {
+			t.Errorf("shard %d has %d items", i, len(shard))
+		}
+	}
+
+	homes := make([]int, p.Shards())
+	for i := 0; i < 10000; i++ {
+		homes[jumpHash(hashString(strconv.Itoa(i)), p.Shards())]++
+	}
+	for i, count := range homes {
+		if math.Abs(float64(count)-1000) > 150 {
+			t.Errorf("shard %d is home to %d players", i, count)
+		}
+	}
+}
+
+func TestJumpHashIsConsistent(t *testing.T) {
+	moved := 0
+	for key := uint64(0); key < 10000; key++ {
+		if jumpHash(mix(key), 10) != jumpHash(mix(key), 11) {
+			moved++
+		}
+	}
+	// Growing from 10 to 11 buckets should move about 1/11 of keys
+	if moved > 1200 {
+		t.Errorf("expected about 909 keys to move, %d did", moved)
+	}
+}
+
+func TestSampleIsWithoutReplacement(t *testing.T) {
+	for _, n := range []int{1, 5, 30} {
+		for seq := uint64(0); seq < 20; seq++ {
+			got := Sample("alice", seq, n, 5)
+			if len(got) != min(n, 5) {
+				t.Fatalf("n=%d: expected %d indices, got %d", n, min(n, 5), len(got))
+			}
+			seen := make(map[int]bool)
+			for _, i := range got {
+				if i < 0 || i >= n || seen[i] {
+					t.Fatalf("n=%d seq=%d: bad sample %v", n, seq, got)
+				}
+				seen[i] = true
+			}
+		}
+	}
+
+	a, b := Sample("alice", 0, 30, 5), Sample("alice", 1, 30, 5)
+	if fmt.Sprint(a) == fmt.Sprint(b) {
+		t.Error("expected each seq to sample differently")
+	}
+}
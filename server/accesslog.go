@@ -0,0 +1,66 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (aw *accessLogWriter) WriteHeader(status int) {
+	if aw.status == 0 {
+		aw.status = status
+	}
+	aw.ResponseWriter.WriteHeader(status)
+}
+
+func (aw *accessLogWriter) Write(b []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	n, err := aw.ResponseWriter.Write(b)
+	aw.bytes += n
+	return n, err
+}
+
+func (aw *accessLogWriter) Flush() {
+	if f, ok := aw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (aw *accessLogWriter) Unwrap() http.ResponseWriter {
+	return aw.ResponseWriter
+}
+
+// accessLogMiddleware logs one line per request once it has been served. It
+// needs to run inside requestIDMiddleware for the line to carry the ID.
+func accessLogMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			aw := &accessLogWriter{ResponseWriter: w}
+			next.ServeHTTP(aw, r)
+
+			status := aw.status
+			if status == 0 {
+				// Nothing written, so net/http sends an empty 200
+				status = http.StatusOK
+			}
+			logger.InfoContext(r.Context(), "request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"latency_ms", float64(time.Since(start).Microseconds())/1000,
+				"bytes", aw.bytes,
+				"client_ip", clientIP(r),
+				"user_agent", r.UserAgent(),
+			)
+		})
+	}
+}
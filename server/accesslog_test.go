@@ -0,0 +1,47 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)})
+
+	handler := requestIDMiddleware(accessLogMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("short and stout"))
+	})))
+
+	req := httptest.NewRequest("GET", "/api/v1/region?date=2024-01-01", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("X-Request-ID", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"msg":        "request",
+		"method":     "GET",
+		"path":       "/api/v1/region",
+		"status":     float64(http.StatusTeapot),
+		"bytes":      float64(len("short and stout")),
+		"client_ip":  "203.0.113.7",
+		"request_id": "abc",
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("expected %s %v, got %v", k, v, record[k])
+		}
+	}
+	if _, ok := record["latency_ms"]; !ok {
+		t.Error("expected latency_ms")
+	}
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/repos"
+	"encoding/json"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Daily usage is counted in the shared store, kept a little past the end of
+// the day so that it can still be read in the admin list
+const apiKeyUsageTTL = 48 * time.Hour
+
+var apiKeyRequestsCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "api_key_requests_total",
+		Help:      "Requests made with an API key partitioned by key name and whether they were within quota",
+	},
+	[]string{"key", "result"},
+)
+
+type apiKeyContextKey struct{}
+
+// requestAPIKey is the validated key the request was made with, if any
+func requestAPIKey(r *http.Request) (repos.APIKey, bool) {
+	key, ok := r.Context().Value(apiKeyContextKey{}).(repos.APIKey)
+	return key, ok
+}
+
+func apiKeyUsageKey(id int, day time.Time) string {
+	return "apikey:" + strconv.Itoa(id) + ":" + day.UTC().Format(time.DateOnly)
+}
+
+// apiKeyUsedToday reads how many requests the key has made since midnight UTC
+func apiKeyUsedToday(ctx context.Context, id int) (int64, error) {
+	v, ok, err := sharedStore.Get(ctx, apiKeyUsageKey(id, time.Now()))
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// Lookups are cached briefly, including for tokens that aren't keys, so that
+// a client sending the same key on every request doesn't cost a query each
+// time. Revoking a key takes up to apiKeyCacheTTL to reach other replicas.
+const (
+	apiKeyCacheTTL  = 30 * time.Second
+	maxAPIKeyCached = 10_000
+)
+
+type apiKeyCacheEntry struct {
+	key     repos.APIKey
+	err     error
+	expires time.Time
+}
+
+type apiKeyCache struct {
+	mu      sync.Mutex
+	entries map[string]apiKeyCacheEntry
+}
+
+var apiKeys = &apiKeyCache{entries: make(map[string]apiKeyCacheEntry)}
+
+func (c *apiKeyCache) get(token string) (apiKeyCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[token]
+	if !ok || time.Now().After(e.expires) {
+		return apiKeyCacheEntry{}, false
+	}
+	return e, true
+}
+
+func (c *apiKeyCache) add(token string, key repos.APIKey, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxAPIKeyCached {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	// Still full of live entries, most likely made-up tokens
+	if len(c.entries) >= maxAPIKeyCached {
+		clear(c.entries)
+	}
+	c.entries[token] = apiKeyCacheEntry{key: key, err: err, expires: now.Add(apiKeyCacheTTL)}
+}
+
+// reset drops every entry, after a key is changed on this replica
+func (c *apiKeyCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// apiKeyMiddleware checks the X-API-Key of API requests and counts them
+// against the key's daily quota. Requests without a key are let through to
+// be rate limited by IP. Tokens that aren't cached are charged to the
+// client's IP before they're looked up, so that a stream of made-up keys is
+// rate limited before it reaches the database.
+func apiKeyMiddleware(limiter *rateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get("X-API-Key")
+			if token == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cached, ok := apiKeys.get(token)
+			key, err := cached.key, cached.err
+			if !ok {
+				if !limiter.allow(w, r, "ip:"+clientIP(r), limiter.ip, requestCost(r)) {
+					return
+				}
+				key, err = repo.APIKeyByToken(r.Context(), token)
+				if err == nil || errors.Is(err, repos.APIKeyNotFoundError) {
+					apiKeys.add(token, key, err)
+				}
+			}
+			if errors.Is(err, repos.APIKeyNotFoundError) {
+				httpError(w, r, "invalid API key", http.StatusUnauthorized)
+				return
+			} else if writeContextError(w, r, err) {
+				return
+			} else if err != nil {
+				slog.ErrorContext(r.Context(), "error looking up api key", "err", err)
+				httpError(w, r, "internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			now := time.Now()
+			used, err := sharedStore.Incr(r.Context(), apiKeyUsageKey(key.ID, now), apiKeyUsageTTL)
+			if err != nil {
+				// Better to serve the request uncounted than fail it
+				slog.ErrorContext(r.Context(), "error counting api key usage", "key", key.Name, "err", err)
+			} else {
+				w.Header().Set("X-Quota-Limit", strconv.Itoa(key.DailyQuota))
+				w.Header().Set("X-Quota-Remaining", strconv.FormatInt(max(0, int64(key.DailyQuota)-used), 10))
+				if used > int64(key.DailyQuota) {
+					apiKeyRequestsCounter.WithLabelValues(key.Name, "over_quota").Inc()
+					midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+					w.Header().Set("Retry-After", strconv.Itoa(int(midnight.Sub(now).Seconds())+1))
+					httpError(w, r, "daily quota exceeded", http.StatusTooManyRequests)
+					return
+				}
+			}
+			apiKeyRequestsCounter.WithLabelValues(key.Name, "ok").Inc()
+
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type apiKeyUsage struct {
+	repos.APIKey
+	UsedToday int64 `json:"used_today"`
+}
+
+func handleGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := repo.APIKeys(r.Context())
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error listing api keys", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]apiKeyUsage, 0, len(keys))
+	for _, key := range keys {
+		used, err := apiKeyUsedToday(r.Context(), key.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error reading api key usage", "key", key.Name, "err", err)
+		}
+		out = append(out, apiKeyUsage{APIKey: key, UsedToday: used})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+const maxAPIKeyQuota = 10_000_000
+
+func handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name       string `json:"name"`
+		DailyQuota int    `json:"daily_quota"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.bodyString("name", body.Name, true, 128)
+		v.check(body.DailyQuota >= 1 && body.DailyQuota <= maxAPIKeyQuota, "daily_quota", "out_of_range", "daily_quota must be between 1 and 10000000")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	key, token, err := repo.CreateAPIKey(r.Context(), body.Name, body.DailyQuota)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating api key", "key", body.Name, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(struct {
+		repos.APIKey
+		Token string `json:"token"`
+	}{key, token})
+}
+
+func handlePutAPIKey(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DailyQuota int `json:"daily_quota"`
+	}
+	v := newValidator(r)
+	id := v.pathInt("id")
+	if v.decodeBody(&body) {
+		v.check(body.DailyQuota >= 1 && body.DailyQuota <= maxAPIKeyQuota, "daily_quota", "out_of_range", "daily_quota must be between 1 and 10000000")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	key, err := repo.SetAPIKeyQuota(r.Context(), id, body.DailyQuota)
+	if errors.Is(err, repos.APIKeyNotFoundError) {
+		httpError(w, r, "api key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error updating api key", "key", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	apiKeys.reset()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(key)
+}
+
+func handleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	id := v.pathInt("id")
+	if !v.valid(w) {
+		return
+	}
+
+	err := repo.RevokeAPIKey(r.Context(), id)
+	if errors.Is(err, repos.APIKeyNotFoundError) {
+		httpError(w, r, "api key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error revoking api key", "key", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	apiKeys.reset()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/repos"
+	"contourguessr-api/shared"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyQuotas(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+	admin := func(method string, path string, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	get := func(key string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/region", nil)
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if status := admin("POST", "/admin/api-key", `{"name": "bot", "daily_quota": 2}`); status != http.StatusCreated {
+		t.Fatalf("expected the key to be created, got %d", status)
+	}
+	for i := range 2 {
+		resp := get("apikey-1")
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Quota-Remaining") != []string{"1", "0"}[i] {
+			t.Fatalf("request %d: expected 200 within quota, got %d with %q remaining", i, resp.StatusCode, resp.Header.Get("X-Quota-Remaining"))
+		}
+	}
+	if resp := get("apikey-1"); resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("expected 429 once over quota, got %d", resp.StatusCode)
+	}
+	if status := admin("PUT", "/admin/api-key/1", `{"daily_quota": 10}`); status != http.StatusOK {
+		t.Fatalf("expected the quota to be raised, got %d", status)
+	}
+	if resp := get("apikey-1"); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a raised quota to let requests through, got %d", resp.StatusCode)
+	}
+
+	if resp := get("apikey-2"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an unknown key to be refused, got %d", resp.StatusCode)
+	}
+	if status := admin("DELETE", "/admin/api-key/1", ""); status != http.StatusNoContent {
+		t.Fatalf("expected the key to be revoked, got %d", status)
+	}
+	if resp := get("apikey-1"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a revoked key to be refused, got %d", resp.StatusCode)
+	}
+	if status := admin("DELETE", "/admin/api-key/2", ""); status != http.StatusNotFound {
+		t.Errorf("expected revoking an unknown key to 404, got %d", status)
+	}
+}
+
+type countingKeyStore struct {
+	repos.Store
+	lookups int
+}
+
+func (s *countingKeyStore) APIKeyByToken(ctx context.Context, token string) (repos.APIKey, error) {
+	s.lookups++
+	return s.Store.APIKeyByToken(ctx, token)
+}
+
+func TestAPIKeyLookups(t *testing.T) {
+	keepServerState(t)
+	store := &countingKeyStore{Store: setupFixtureRepo(t)}
+	repo = store
+	sharedStore = shared.NewMemory()
+	if _, _, err := repo.CreateAPIKey(context.Background(), "bot", 100); err != nil {
+		t.Fatal(err)
+	}
+	limiter := newRateLimiter(rateLimit{rate: 0.001, burst: 3}, rateLimit{rate: 10, burst: 30})
+	handler := apiKeyMiddleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func(key string) int {
+		req := httptest.NewRequest("GET", "/api/v1/region", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for range 5 {
+		if code := get("apikey-1"); code != http.StatusOK {
+			t.Fatalf("expected the key to be accepted, got %d", code)
+		}
+		if code := get("made-up"); code != http.StatusUnauthorized {
+			t.Fatalf("expected a made-up key to be refused, got %d", code)
+		}
+	}
+	if store.lookups != 2 {
+		t.Errorf("expected each token to be looked up once, got %d lookups", store.lookups)
+	}
+
+	// One token of the IP's burst is left, then new tokens are refused
+	// without being looked up
+	get("made-up-2")
+	if code := get("made-up-3"); code != http.StatusTooManyRequests {
+		t.Errorf("expected uncached keys to be rate limited by IP, got %d", code)
+	}
+	if store.lookups != 3 {
+		t.Errorf("expected rate limited keys not to be looked up, got %d lookups", store.lookups)
+	}
+}
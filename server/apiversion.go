@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/elevation"
+	"contourguessr-api/repos"
+	"github.com/gorilla/mux"
+	"google.golang.org/protobuf/proto"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Each API version is mounted under its own prefix. Handlers whose contract
+// didn't change are shared between versions and branch on apiVersion where it
+// did, so v1 keeps its exact responses while clients migrate.
+type apiVersionKey struct{}
+
+func apiVersionMiddleware(version int) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("API-Version", strconv.Itoa(version))
+			ctx := context.WithValue(r.Context(), apiVersionKey{}, version)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// apiVersion is 1 for requests outside a versioned prefix
+func apiVersion(r *http.Request) int {
+	if v, ok := r.Context().Value(apiVersionKey{}).(int); ok {
+		return v
+	}
+	return 1
+}
+
+func mountAPIv2(router *mux.Router) {
+	v2 := router.PathPrefix("/api/v2").Subrouter()
+	v2.Use(apiVersionMiddleware(2))
+	v2.NotFoundHandler = apiVersionMiddleware(2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpError(w, r, "not found", http.StatusNotFound)
+	}))
+	v2.MethodNotAllowedHandler = apiVersionMiddleware(2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpError(w, r, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	v2.HandleFunc("/region", handleGetRegions).Methods("GET")
+	v2.HandleFunc("/challenge/random", handleGetRandomChallenge).Methods("GET")
+	v2.HandleFunc("/challenge/{id}", handleGetChallenge).Methods("GET")
+	v2.HandleFunc("/challenge/{id}/guess", handlePostGuess).Methods("POST")
+	v2.HandleFunc("/challenge/{id}/elevation-guess", handlePostElevationGuess).Methods("POST")
+	v2.HandleFunc("/challenge/{id}/full-metadata", handleGetChallengeFullMetadata).Methods("GET")
+}
+
+// challengeV2 leaves out the location, which clients only learn by guessing.
+// Its links go through linkURL for the same reason.
+type challengeV2 struct {
+	ID              string             `json:"id"`
+	RegionID        string             `json:"region_id"`
+	Title           string             `json:"title"`
+	DescriptionHTML string             `json:"description_html"`
+	DateTaken       *time.Time         `json:"date_taken"`
+	DatePrecision   string             `json:"date_taken_precision,omitempty"`
+	Timezone        string             `json:"timezone,omitempty"`
+	Link            string             `json:"link"`
+	Src             any                `json:"src"`
+	AspectRatio     float64            `json:"aspect_ratio"`
+	Color           string             `json:"color,omitempty"`
+	Photographer    any                `json:"photographer"`
+	License         string             `json:"license"`
+	LicenseURL      string             `json:"license_url"`
+	Attribution     bool               `json:"attribution_required"`
+	R               any                `json:"r"`
+	Terrain         *elevation.Terrain `json:"terrain,omitempty"`
+	PlayToken       string             `json:"play_token"`
+}
+
+// writeChallenge responds with the challenge in the request's API version
+func writeChallenge(w http.ResponseWriter, r *http.Request, challenge repos.Challenge) {
+	format := responseFormat(w, r)
+	if apiVersion(r) < 2 {
+		if format == msgpackContentType {
+			writeAs(w, r, format, repo.ServedChallenge(r.Context(), challenge))
+			return
+		}
+		b, err := repo.ChallengeJSON(r.Context(), challenge)
+		if err != nil {
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", jsonContentType)
+		_, _ = w.Write(b)
+		return
+	}
+
+	if format == protobufContentType {
+		pb, err := grpcChallenge(r.Context(), challenge)
+		if writeContextError(w, r, err) {
+			return
+		} else if err != nil {
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		b, err := proto.Marshal(pb)
+		if err != nil {
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		setProtobufHeaders(w, "contourguessr.v1.Challenge")
+		_, _ = w.Write(b)
+		return
+	}
+
+	description, err := repo.ChallengeDescription(r.Context(), challenge.ID)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		// As in v1, the rest of the challenge is still playable
+		slog.ErrorContext(r.Context(), "error getting description", "challenge", challenge.ID, "err", err)
+	}
+	photographer := challenge.Photographer
+	photographer.Link = servedLink(challenge.ID, "photographer", photographer.Link)
+	playToken, err := newPlayToken(challenge.ID)
+	if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeAs(w, r, format, challengeV2{
+		ID:              challenge.ID,
+		RegionID:        challenge.RegionID,
+		Title:           challenge.Title,
+		DescriptionHTML: description,
+		DateTaken:       challenge.DateTaken,
+		DatePrecision:   challenge.DateTakenPrecision,
+		Timezone:        challenge.Timezone,
+		Link:            servedLink(challenge.ID, "photo", challenge.Link),
+		Src:             proxiedSrc(challenge),
+		AspectRatio:     challenge.AspectRatio,
+		Color:           challenge.Color,
+		Photographer:    photographer,
+		License:         challenge.License,
+		LicenseURL:      challenge.LicenseURL,
+		Attribution:     challenge.AttributionRequired,
+		R:               challenge.R,
+		Terrain:         challenge.Terrain,
+		PlayToken:       playToken,
+	})
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIVersionMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+	}
+	router.HandleFunc("/api/v1/challenge/{id}", notFound)
+	v2 := router.PathPrefix("/api/v2").Subrouter()
+	v2.Use(apiVersionMiddleware(2))
+	v2.HandleFunc("/challenge/{id}", notFound)
+	handler := requestIDMiddleware(router)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/v1/challenge/abc")
+	if rec.Header().Get("API-Version") != "" {
+		t.Error("expected no API-Version on v1")
+	}
+
+	rec = get("/api/v2/challenge/abc")
+	if rec.Header().Get("API-Version") != "2" {
+		t.Errorf("expected API-Version 2, got %q", rec.Header().Get("API-Version"))
+	}
+	var body problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusNotFound || body.Code != "challenge_not_found" {
+		t.Errorf("expected a challenge_not_found problem, got %d %+v", rec.Code, body)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/blurhash"
+	"contourguessr-api/repos"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Photos fetched per blurhash backfill interval, kept low so as not to compete
+// with players for Flickr's bandwidth
+const blurHashBatchSize = 10
+
+// Flickr's regular size is 320px, anything much larger is not what we asked for
+const maxBlurHashPhotoBytes = 4 << 20
+
+// backfillBlurHashes computes placeholders for challenges that don't have them
+// yet. Photos that can't be decoded are recorded with an empty hash so that
+// they aren't fetched again, but ones that failed to download are retried.
+func backfillBlurHashes(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		n, err := backfillBlurHashBatch(ctx)
+		cancel()
+		if err != nil {
+			slog.Error("error backfilling blurhashes", "err", err)
+		} else if n > 0 {
+			slog.Info("computed blurhashes", "challenges", n)
+		}
+	}
+}
+
+func backfillBlurHashBatch(ctx context.Context) (int, error) {
+	pending, err := repo.ChallengesWithoutBlurHash(ctx, blurHashBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range pending {
+		hash, err := photoBlurHash(ctx, p)
+		if err != nil {
+			slog.WarnContext(ctx, "error fetching photo for blurhash", "challenge", p.ID, "err", err)
+			continue
+		}
+		if err := repo.SetChallengeBlurHash(ctx, p.ID, hash); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// photoBlurHash is empty if the photo is gone or isn't an image we can
+// decode, and an error if it should be tried again later
+func photoBlurHash(ctx context.Context, p repos.ChallengePhoto) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.Src, nil)
+	if err != nil {
+		slog.WarnContext(ctx, "invalid photo url", "challenge", p.ID, "err", err)
+		return "", nil
+	}
+	resp, err := photoClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		slog.WarnContext(ctx, "photo gone", "challenge", p.ID, "status", resp.StatusCode)
+		return "", nil
+	} else if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(resp.Body, maxBlurHashPhotoBytes))
+	if err != nil {
+		slog.WarnContext(ctx, "undecodable photo", "challenge", p.ID, "err", err)
+		return "", nil
+	}
+	return blurhash.Encode(img, blurhash.DefaultXComponents, blurhash.DefaultYComponents)
+}
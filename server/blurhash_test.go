@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/repos"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestBackfillBlurHashes(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0.png":
+			img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+			for x := 0; x < 32; x++ {
+				for y := 0; y < 24; y++ {
+					img.Set(x, y, color.RGBA{R: uint8(x * 8), G: 120, B: uint8(y * 10), A: 255})
+				}
+			}
+			w.Header().Set("Content-Type", "image/png")
+			_ = png.Encode(w, img)
+		case "/1.png":
+			_, _ = w.Write([]byte("not a photo"))
+		case "/2.png":
+			http.NotFound(w, r)
+		default:
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	f := repos.DefaultFixtures()
+	for i := range f.Challenges {
+		f.Challenges[i].Challenge.Src.Regular.Src = upstream.URL + "/" + strconv.Itoa(i) + ".png"
+	}
+	prev := repo
+	repo = repos.NewMemory(f)
+	t.Cleanup(func() { repo = prev })
+
+	n, err := backfillBlurHashBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected the decoded, undecodable and missing photos to be recorded, got %d", n)
+	}
+	pending, err := repo.ChallengesWithoutBlurHash(context.Background(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(f.Challenges)-3 {
+		t.Errorf("expected photos that failed to download to be retried, got %+v", pending)
+	}
+
+	c := fixtureChallenge(t, f.Challenges[0].Challenge.Title)
+	if len(c.Src.Regular.BlurHash) != 28 || c.Src.Large.BlurHash != c.Src.Regular.BlurHash {
+		t.Errorf("expected both sizes to have the blurhash, got %+v", c.Src)
+	}
+	if len(c.Color) != 7 || c.Color[0] != '#' {
+		t.Errorf("expected the average colour to be filled in, got %q", c.Color)
+	}
+	if c := fixtureChallenge(t, f.Challenges[1].Challenge.Title); c.Src.Regular.BlurHash != "" {
+		t.Errorf("expected no blurhash for an undecodable photo, got %q", c.Src.Regular.BlurHash)
+	}
+}
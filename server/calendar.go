@@ -0,0 +1,119 @@
+package server
+
+import (
+	"contourguessr-api/ical"
+	"contourguessr-api/repos"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Subscribers keep past events they've already seen, so the feed only needs
+// to cover recent history
+const calendarHistory = 30 * 24 * time.Hour
+
+func handleGetCalendar(w http.ResponseWriter, r *http.Request) {
+	events, err := repo.ScheduledEvents(r.Context(), time.Now().Add(-calendarHistory))
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting scheduled events", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	cal := ical.Calendar{
+		ProdID: "-//ContourGuessr//Events//EN",
+		Name:   "ContourGuessr",
+	}
+	regions := repo.Regions()
+	for _, e := range events {
+		description := e.Description
+		if e.RegionID != nil {
+			if region, ok := regions[*e.RegionID]; ok {
+				description = strings.TrimSpace("Region: " + region.Name + "\n\n" + description)
+			}
+		}
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         "event-" + strconv.FormatInt(e.ID, 10) + "@contourguessr.org",
+			Summary:     e.Title,
+			Description: description,
+			Start:       e.StartsAt,
+			End:         e.EndsAt,
+			RRule:       e.Recurrence,
+			Modified:    e.UpdatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=900")
+	if err := ical.Write(w, cal); err != nil {
+		slog.ErrorContext(r.Context(), "error writing calendar", "err", err)
+	}
+}
+
+func handleGetScheduledEvents(w http.ResponseWriter, r *http.Request) {
+	events, err := repo.ScheduledEvents(r.Context(), time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting scheduled events", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(events)
+}
+
+func handleCreateScheduledEvent(w http.ResponseWriter, r *http.Request) {
+	var body repos.ScheduledEvent
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.check(slices.Contains(repos.EventKinds, body.Kind), "kind", "invalid_value", "kind must be one of "+strings.Join(repos.EventKinds, ", "))
+		v.bodyString("title", body.Title, true, 256)
+		v.check(body.EndsAt.After(body.StartsAt), "ends_at", "out_of_range", "ends_at must be after starts_at")
+		// Passed through to subscribers verbatim, so keep it to one line
+		v.check(!strings.ContainsAny(body.Recurrence, "\r\n"), "recurrence", "invalid_value", "recurrence must be one line")
+		if body.RegionID != nil {
+			_, ok := repo.Regions()[*body.RegionID]
+			v.check(ok, "region_id", "not_found", "region not found")
+		}
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	event, err := repo.CreateScheduledEvent(r.Context(), body)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating scheduled event", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(event)
+}
+
+func handleDeleteScheduledEvent(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	id := int64(v.pathInt("id"))
+	if !v.valid(w) {
+		return
+	}
+
+	found, err := repo.DeleteScheduledEvent(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error deleting scheduled event", "event", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		httpError(w, r, "event not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
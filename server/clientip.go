@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type clientIPKey struct{}
+
+// clientIPResolver finds the real client of a request that came through
+// proxies we trust, like the load balancer. Headers from anyone else are
+// ignored, since they can be set to anything.
+type clientIPResolver struct {
+	trusted []netip.Prefix
+	// Either X-Forwarded-For or X-Real-IP
+	header string
+}
+
+func newClientIPResolver(trusted []string, header string) (*clientIPResolver, error) {
+	header = http.CanonicalHeaderKey(header)
+	if header != "X-Forwarded-For" && header != "X-Real-Ip" {
+		return nil, fmt.Errorf("unsupported client IP header %s", header)
+	}
+	res := &clientIPResolver{header: header}
+	for _, s := range trusted {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(s)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %s: %w", s, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		res.trusted = append(res.trusted, prefix.Masked())
+	}
+	return res, nil
+}
+
+func (res *clientIPResolver) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (res *clientIPResolver) resolve(r *http.Request) string {
+	peer := remoteIP(r)
+	addr, err := netip.ParseAddr(peer)
+	if err != nil || !res.isTrusted(addr) {
+		return peer
+	}
+
+	if res.header == "X-Real-Ip" {
+		if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return ip.Unmap().String()
+		}
+		return peer
+	}
+
+	// Each proxy appends the address it received the request from, so walk
+	// back from the nearest until we leave our own infrastructure. Anything
+	// further left was supplied by the client.
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	client := addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = hop
+		if !res.isTrusted(hop) {
+			break
+		}
+	}
+	return client.Unmap().String()
+}
+
+// clientIPMiddleware resolves the client once so that the access log and rate
+// limiter agree on it. It needs to run outside both.
+func clientIPMiddleware(res *clientIPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPKey{}, res.resolve(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// proxyProtocolListener reads the PROXY protocol header (v1 or v2) that load
+// balancers like HAProxy and AWS NLB send ahead of the connection, so that
+// RemoteAddr is the client's. Connections from untrusted peers are passed
+// through untouched.
+type proxyProtocolListener struct {
+	net.Listener
+	resolver *clientIPResolver
+}
+
+const proxyHeaderTimeout = 5 * time.Second
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		if ip, ok := netip.AddrFromSlice(addr.IP); ok && l.resolver.isTrusted(ip) {
+			return &proxyProtocolConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+		}
+	}
+	return conn, nil
+}
+
+// proxyProtocolConn reads the header on first use rather than in Accept so
+// that a slow proxy can't hold up other connections
+type proxyProtocolConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.once.Do(func() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.remote, c.err = readProxyHeader(c.r)
+		_ = c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+var InvalidProxyHeaderError = errors.New("invalid PROXY protocol header")
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// readProxyHeader returns the client address in the header, or nil if the
+// proxy sent the connection on its own behalf, like a health check
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err == nil && bytes.Equal(sig, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+
+	// v1 headers are a single line of at most 107 bytes
+	line, err := r.ReadSlice('\n')
+	if err != nil || len(line) > 107 || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, InvalidProxyHeaderError
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, InvalidProxyHeaderError
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, InvalidProxyHeaderError
+	}
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, InvalidProxyHeaderError
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, InvalidProxyHeaderError
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, InvalidProxyHeaderError
+	}
+	if head[12]>>4 != 2 {
+		return nil, InvalidProxyHeaderError
+	}
+	command := head[12] & 0xf
+	family := head[13] >> 4
+	body := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, InvalidProxyHeaderError
+	}
+
+	// LOCAL connections come from the proxy itself
+	if command == 0 {
+		return nil, nil
+	}
+	switch family {
+	case 1: // IPv4
+		if len(body) < 12 {
+			return nil, InvalidProxyHeaderError
+		}
+		ip := netip.AddrFrom4([4]byte(body[0:4]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, binary.BigEndian.Uint16(body[8:10]))), nil
+	case 2: // IPv6
+		if len(body) < 36 {
+			return nil, InvalidProxyHeaderError
+		}
+		ip := netip.AddrFrom16([16]byte(body[0:16]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, binary.BigEndian.Uint16(body[32:34]))), nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	res, err := newClientIPResolver([]string{"10.0.0.0/8", "192.0.2.1"}, "X-Forwarded-For")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		want       string
+	}{
+		{"direct", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"untrusted peer can't spoof", "203.0.113.7:1234", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"through load balancer", "10.1.2.3:1234", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"skips trusted hops", "10.1.2.3:1234", []string{"198.51.100.1, 203.0.113.7, 192.0.2.1"}, "203.0.113.7"},
+		{"across repeated headers", "10.1.2.3:1234", []string{"198.51.100.1", "203.0.113.7"}, "203.0.113.7"},
+		{"stops at garbage", "10.1.2.3:1234", []string{"203.0.113.7, nonsense, 10.9.9.9"}, "10.9.9.9"},
+		{"no header", "10.1.2.3:1234", nil, "10.1.2.3"},
+		{"ipv4-mapped peer", "[::ffff:10.1.2.3]:1234", []string{"203.0.113.7"}, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		for _, v := range tt.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if got := res.resolve(req); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestClientIPResolverRealIP(t *testing.T) {
+	res, err := newClientIPResolver([]string{"10.0.0.0/8"}, "X-Real-IP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := res.resolve(req); got != "203.0.113.7" {
+		t.Errorf("expected X-Real-IP to be used, got %s", got)
+	}
+
+	if _, err := newClientIPResolver([]string{"not a cidr"}, "X-Forwarded-For"); err == nil {
+		t.Error("expected invalid CIDR to fail")
+	}
+	if _, err := newClientIPResolver(nil, "Forwarded"); err == nil {
+		t.Error("expected unsupported header to fail")
+	}
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	res, _ := newClientIPResolver([]string{"10.0.0.0/8"}, "X-Forwarded-For")
+	var got string
+	handler := clientIPMiddleware(res)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientIP(r)
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "203.0.113.7" {
+		t.Errorf("expected handlers to see the client, got %s", got)
+	}
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n"))
+	addr, err := readProxyHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "203.0.113.7:56324" {
+		t.Errorf("unexpected v1 address %s", addr)
+	}
+	if rest, _ := r.ReadString('\n'); rest != "GET / HTTP/1.1\r\n" {
+		t.Errorf("expected the request to follow the header, got %q", rest)
+	}
+
+	var v2 bytes.Buffer
+	v2.Write(proxyV2Signature)
+	v2.Write([]byte{0x21, 0x11})
+	_ = binary.Write(&v2, binary.BigEndian, uint16(12))
+	v2.Write([]byte{203, 0, 113, 7, 10, 0, 0, 1})
+	_ = binary.Write(&v2, binary.BigEndian, uint16(56324))
+	_ = binary.Write(&v2, binary.BigEndian, uint16(443))
+	addr, err = readProxyHeader(bufio.NewReader(&v2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "203.0.113.7:56324" {
+		t.Errorf("unexpected v2 address %s", addr)
+	}
+
+	if addr, err := readProxyHeader(bufio.NewReader(strings.NewReader("PROXY UNKNOWN\r\n"))); err != nil || addr != nil {
+		t.Errorf("expected UNKNOWN to keep the proxy's address, got %v %v", addr, err)
+	}
+	if _, err := readProxyHeader(bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n"))); err == nil {
+		t.Error("expected a missing header to fail")
+	}
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	},
+}
+
+// Suffix of the ETag of a gzipped body, as the gzipped bytes differ from the
+// ones the handler's ETag names
+const gzipETagSuffix = `-gzip"`
+
+// compressMiddleware gzips textual responses for clients that accept it.
+// Responses that already have an encoding (such as /metrics) pass through.
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w}
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			// Handlers compare against the identity ETag
+			var stripped string
+			stripped, cw.matchedGzip = stripGzipETags(inm)
+			r = r.Clone(r.Context())
+			r.Header.Set("If-None-Match", stripped)
+		}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// stripGzipETags removes gzipETagSuffix from each ETag in an If-None-Match
+// header, and reports whether any had it
+func stripGzipETags(header string) (string, bool) {
+	var stripped bool
+	etags := strings.Split(header, ",")
+	for i, etag := range etags {
+		etag = strings.TrimSpace(etag)
+		if trimmed, ok := strings.CutSuffix(etag, gzipETagSuffix); ok {
+			etag = trimmed + `"`
+			stripped = true
+		}
+		etags[i] = etag
+	}
+	return strings.Join(etags, ", "), stripped
+}
+
+func gzipETag(etag string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + gzipETagSuffix
+}
+
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+	// Whether If-None-Match named a gzipped body, so that a 304 names it too
+	matchedGzip bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	if shouldCompress(status, h) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		cw.gz = gzipWriterPool.Get().(*gzip.Writer)
+		cw.gz.Reset(cw.ResponseWriter)
+	}
+	if etag := h.Get("ETag"); etag != "" && (cw.gz != nil || status == http.StatusNotModified && cw.matchedGzip) {
+		h.Set("ETag", gzipETag(etag))
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.gz != nil {
+		return cw.gz.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressWriter) Flush() {
+	if cw.gz != nil {
+		_ = cw.gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Close() {
+	if cw.gz == nil {
+		return
+	}
+	_ = cw.gz.Close()
+	gzipWriterPool.Put(cw.gz)
+	cw.gz = nil
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func shouldCompress(status int, h http.Header) bool {
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		// Each event needs to reach the client as soon as it's flushed
+		return false
+	}
+	return strings.HasPrefix(contentType, "application/json") ||
+		strings.HasSuffix(strings.Split(contentType, ";")[0], "+json") ||
+		strings.HasPrefix(contentType, "text/")
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressMiddleware(t *testing.T) {
+	body := strings.Repeat(`{"hello": "world"}`, 100)
+	h := compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+
+	t.Run("compresses when accepted", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("expected gzip encoding, got %q", got)
+		}
+		gz, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != body {
+			t.Fatal("decompressed body does not match")
+		}
+	})
+
+	t.Run("passes through otherwise", func(t *testing.T) {
+		for _, accept := range []string{"", "identity", "gzip;q=0"} {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", accept)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("%q: expected no encoding, got %q", accept, got)
+			}
+			if rec.Body.String() != body {
+				t.Errorf("%q: body does not match", accept)
+			}
+		}
+	})
+
+	t.Run("skips not modified", func(t *testing.T) {
+		h := compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotModified)
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() != 0 {
+			t.Error("expected empty uncompressed 304")
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// contractRequest is a request recorded in testdata/contract/requests.json.
+// {{name}} in the target or body is replaced with a value captured from an
+// earlier response.
+type contractRequest struct {
+	Method string          `json:"method"`
+	Target string          `json:"target"`
+	Body   json.RawMessage `json:"body"`
+	Admin  bool            `json:"admin"`
+	// Sent as the Authorization bearer token, after substitution
+	Bearer string `json:"bearer"`
+	Status int    `json:"status"`
+	// Captures top level fields of the response by name
+	Capture map[string]string `json:"capture"`
+}
+
+var contractVariable = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// TestContract replays recorded requests against the whole server, serving
+// the fixtures, and checks each response against openapi.json. Fields that
+// aren't documented fail the test, so that renaming one the frontend relies
+// on can't go unnoticed.
+func TestContract(t *testing.T) {
+	b, err := os.ReadFile("testdata/contract/requests.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests []contractRequest
+	if err := json.Unmarshal(b, &requests); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enrichment degrades gracefully without its upstream APIs
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	cfg := testConfig()
+	cfg.SiteURL = "https://contourguessr.org"
+	cfg.WeatherAPIURL = unavailable.URL
+	cfg.GeocodeAPIURL = unavailable.URL
+	cfg.ElevationAPIURL = unavailable.URL
+	// Every request comes from the same client
+	cfg.RateLimit.Burst = 1000
+	ts := newTestServer(t, cfg)
+
+	spec := loadOpenAPISpec(t)
+	router := testRouter()
+	vars := make(map[string]string)
+	substitute := func(s string) string {
+		return contractVariable.ReplaceAllStringFunc(s, func(m string) string {
+			name := contractVariable.FindStringSubmatch(m)[1]
+			v, ok := vars[name]
+			if !ok {
+				t.Fatalf("%s not captured", name)
+			}
+			return v
+		})
+	}
+
+	for _, cr := range requests {
+		target := substitute(cr.Target)
+		ok := t.Run(cr.Method+" "+target, func(t *testing.T) {
+			var body io.Reader
+			if len(cr.Body) > 0 {
+				body = strings.NewReader(substitute(string(cr.Body)))
+			}
+			req, err := http.NewRequest(cr.Method, ts.URL+target, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if cr.Admin {
+				req.Header.Set("Authorization", "Bearer admin")
+			} else if cr.Bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+substitute(cr.Bearer))
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			respBody, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != cr.Status {
+				t.Fatalf("expected %d, got %d: %s", cr.Status, resp.StatusCode, respBody)
+			}
+
+			path := documentedPath(t, router, req)
+			op, ok := spec["paths"].(map[string]any)[path].(map[string]any)[strings.ToLower(cr.Method)].(map[string]any)
+			if !ok {
+				t.Fatalf("%s %s not documented", cr.Method, path)
+			}
+			responses := op["responses"].(map[string]any)
+			documented, ok := responses[strconv.Itoa(resp.StatusCode)]
+			if !ok {
+				documented, ok = responses["default"]
+			}
+			if !ok {
+				t.Fatalf("status %d not documented", resp.StatusCode)
+			}
+			content, _ := resolve(spec, documented).(map[string]any)["content"].(map[string]any)
+			if len(respBody) == 0 && content == nil {
+				return
+			}
+			contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+			media, ok := content[contentType].(map[string]any)
+			if !ok {
+				t.Fatalf("content type %q not documented for %d", contentType, resp.StatusCode)
+			}
+			if !strings.HasSuffix(contentType, "json") {
+				return
+			}
+
+			var v any
+			if err := json.Unmarshal(respBody, &v); err != nil {
+				t.Fatal(err)
+			}
+			for _, problem := range validateSchema(spec, media["schema"], v, "body", true) {
+				t.Error(problem)
+			}
+			for name, field := range cr.Capture {
+				obj, _ := v.(map[string]any)
+				s, ok := obj[field].(string)
+				if !ok {
+					t.Fatalf("no %s to capture", field)
+				}
+				vars[name] = s
+			}
+		})
+		if !ok && len(cr.Capture) > 0 {
+			// Later requests depend on this one
+			t.FailNow()
+		}
+	}
+}
+
+// documentedPath is the path template of the route serving req
+func documentedPath(t *testing.T, router *mux.Router, req *http.Request) string {
+	t.Helper()
+	var match mux.RouteMatch
+	if !router.Match(req, &match) || match.Route == nil {
+		t.Fatalf("no route for %s %s", req.Method, req.URL.Path)
+	}
+	tmpl, err := match.Route.GetPathTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpl
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type corsConfig struct {
+	// "*" allows any origin
+	allowedOrigins []string
+	allowedHeaders []string
+	maxAge         time.Duration
+}
+
+var corsAllowedMethods = "GET, POST, PUT, DELETE, OPTIONS"
+
+// corsMiddleware wraps the whole router rather than being added with
+// router.Use, because mux only runs middleware for matched routes and
+// preflights are OPTIONS requests to routes that don't accept OPTIONS
+func corsMiddleware(cfg corsConfig) func(http.Handler) http.Handler {
+	allowAll := false
+	allowed := make(map[string]bool)
+	for _, origin := range cfg.allowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	allowedHeaders := strings.Join(cfg.allowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.maxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+			if allowAll {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Add("Vary", "Origin")
+				if origin != "" && allowed[origin] {
+					h.Set("Access-Control-Allow-Origin", origin)
+				}
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+				h.Set("Access-Control-Allow-Headers", allowedHeaders)
+				h.Set("Access-Control-Max-Age", maxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			h.Set("Access-Control-Expose-Headers", "ETag")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/challenge/{id}/guess", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	handler := corsMiddleware(corsConfig{
+		allowedOrigins: []string{"https://contourguessr.org"},
+		allowedHeaders: []string{"Content-Type", "X-Custom"},
+		maxAge:         time.Hour,
+	})(router)
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/challenge/abc/guess", nil)
+	req.Header.Set("Origin", "https://contourguessr.org")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type, x-custom")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	h := rec.Header()
+	if got := h.Get("Access-Control-Allow-Origin"); got != "https://contourguessr.org" {
+		t.Errorf("unexpected allow origin %q", got)
+	}
+	if got := h.Get("Access-Control-Allow-Headers"); got != "Content-Type, X-Custom" {
+		t.Errorf("unexpected allow headers %q", got)
+	}
+	if got := h.Get("Access-Control-Max-Age"); got != "3600" {
+		t.Errorf("unexpected max age %q", got)
+	}
+	if got := h.Get("Vary"); got != "Origin" {
+		t.Errorf("expected Vary: Origin, got %q", got)
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		allowed []string
+		origin  string
+		want    string
+	}{
+		{[]string{"*"}, "https://example.com", "*"},
+		{[]string{"https://contourguessr.org"}, "https://contourguessr.org", "https://contourguessr.org"},
+		{[]string{"https://contourguessr.org/"}, "https://contourguessr.org", "https://contourguessr.org"},
+		{[]string{"https://contourguessr.org"}, "https://evil.example", ""},
+	}
+	for _, test := range tests {
+		handler := corsMiddleware(corsConfig{allowedOrigins: test.allowed})(next)
+		req := httptest.NewRequest("GET", "/api/v1/region", nil)
+		req.Header.Set("Origin", test.origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != test.want {
+			t.Errorf("%v %s: expected %q, got %q", test.allowed, test.origin, test.want, got)
+		}
+	}
+
+	handler := corsMiddleware(corsConfig{allowedOrigins: []string{"*"}})(next)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/admin/moderation", nil))
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no CORS headers outside /api/, got %q", got)
+	}
+}
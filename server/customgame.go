@@ -0,0 +1,168 @@
+package server
+
+import (
+	"contourguessr-api/repos"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Custom games each get a leaderboard named with this prefix and their code
+const customGameBoardPrefix = "custom-"
+
+var validShareCode = regexp.MustCompile(`^[a-z0-9]{8}$`)
+
+var validSeasons = map[string]bool{"winter": true, "spring": true, "summer": true, "autumn": true}
+
+func customGameMode(code string) string {
+	return "custom:" + code
+}
+
+func handleSearchChallenges(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	f := repos.ChallengeFilter{
+		RegionIDs: v.queryInts("region"),
+		Seasons:   v.queryEnums("season", validSeasons),
+		Query:     v.queryString("q", false, 256),
+		Landforms: v.queryEnums("landform", validLandforms),
+	}
+	limit := v.queryInt("limit", 20, 1, 100)
+	if !v.valid(w) {
+		return
+	}
+
+	writeChallenges(w, r, repo.SearchChallenges(f, limit))
+}
+
+func writeChallenges(w http.ResponseWriter, r *http.Request, challenges []repos.Challenge) {
+	out := make([]json.RawMessage, 0, len(challenges))
+	for _, challenge := range challenges {
+		b, err := repo.ChallengeJSON(r.Context(), challenge)
+		if err != nil {
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		out = append(out, b)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// handleCreateCustomGame freezes either the listed challenges or a sample of
+// those matching the filter
+func handleCreateCustomGame(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Player     string   `json:"player"`
+		Name       string   `json:"name"`
+		Challenges []string `json:"challenges"`
+		Filter     *struct {
+			RegionIDs []int    `json:"region_ids"`
+			Seasons   []string `json:"seasons"`
+			Query     string   `json:"query"`
+			Landforms []string `json:"landforms"`
+		} `json:"filter"`
+		Rounds int `json:"rounds"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		body.Name = strings.TrimSpace(body.Name)
+		v.bodyString("player", body.Player, true, 128)
+		v.bodyString("name", body.Name, true, 64)
+		v.check((len(body.Challenges) > 0) != (body.Filter != nil), "challenges", "invalid_value", "expected either challenges or filter")
+		if body.Rounds == 0 {
+			body.Rounds = 5
+		}
+		v.check(body.Rounds >= 1 && body.Rounds <= repos.MaxCustomGameChallenges, "rounds", "out_of_range",
+			fmt.Sprintf("rounds must be between 1 and %d", repos.MaxCustomGameChallenges))
+		if body.Filter != nil {
+			for _, season := range body.Filter.Seasons {
+				v.check(validSeasons[season], "filter.seasons", "invalid_value", season+" is not a valid season")
+			}
+			for _, landform := range body.Filter.Landforms {
+				v.check(validLandforms[landform], "filter.landforms", "invalid_value", landform+" is not a valid landform")
+			}
+		}
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	var game repos.CustomGame
+	var err error
+	if body.Filter == nil {
+		game, err = repo.CreateCustomGame(r.Context(), body.Player, body.Name, body.Challenges)
+	} else {
+		game, err = repo.CreateCustomGameFromFilter(r.Context(), body.Player, body.Name, repos.ChallengeFilter{
+			RegionIDs: body.Filter.RegionIDs,
+			Seasons:   body.Filter.Seasons,
+			Query:     body.Filter.Query,
+			Landforms: body.Filter.Landforms,
+		}, body.Rounds)
+	}
+	if errors.Is(err, repos.InvalidCustomGameError) {
+		httpError(w, r, "invalid or no matching challenges", http.StatusBadRequest)
+		return
+	} else if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error creating custom game", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(customGameHead(game))
+}
+
+func handleGetCustomGame(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+	if !validShareCode.MatchString(code) {
+		httpError(w, r, "custom game not found", http.StatusNotFound)
+		return
+	}
+
+	game, err := repo.CustomGame(r.Context(), code)
+	if errors.Is(err, repos.CustomGameNotFoundError) {
+		httpError(w, r, "custom game not found", http.StatusNotFound)
+		return
+	} else if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting custom game", "code", code, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(game.Challenges) == 0 {
+		httpError(w, r, "every challenge in this game has been retired", http.StatusGone)
+		return
+	}
+
+	token, err := signTour(customGameMode(code), game.Challenges)
+	if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	head, err := json.Marshal(customGameHead(game))
+	if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	recordServed(game.Challenges...)
+	writeTour(w, r, head, token, game.Challenges)
+}
+
+func customGameHead(game repos.CustomGame) map[string]interface{} {
+	return map[string]interface{}{
+		"code":        game.Code,
+		"name":        game.Name,
+		"created_at":  game.CreatedAt,
+		"rounds":      len(game.Challenges),
+		"leaderboard": customGameBoardPrefix + game.Code,
+	}
+}
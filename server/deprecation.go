@@ -0,0 +1,107 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var deprecatedRequestsCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "deprecated_requests_total",
+		Help:      "Requests using a deprecated endpoint or parameter partitioned by endpoint and client",
+	},
+	[]string{"endpoint", "client"},
+)
+
+// deprecation is signalled with the Deprecation (RFC 9745) and Sunset
+// (RFC 8594) headers
+type deprecation struct {
+	Since time.Time
+	// Zero until removal is scheduled
+	Sunset time.Time
+	// Migration guide, if any
+	Link string
+}
+
+func (d deprecation) setHeaders(w http.ResponseWriter) {
+	w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	if !d.Sunset.IsZero() {
+		w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		w.Header().Add("Link", "<"+d.Link+">; rel=\"deprecation\"; type=\"text/html\"")
+	}
+}
+
+// deprecated marks every route it's used on as deprecated, or with params only
+// requests using one of those query parameters
+func deprecated(d deprecation, params ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endpoint := r.Method + " " + routeTemplate(r)
+			if len(params) > 0 {
+				query := r.URL.Query()
+				used := ""
+				for _, param := range params {
+					if query.Has(param) {
+						used = param
+						break
+					}
+				}
+				if used == "" {
+					next.ServeHTTP(w, r)
+					return
+				}
+				endpoint += "?" + used
+			}
+			d.setHeaders(w)
+			deprecatedRequestsCounter.WithLabelValues(endpoint, deprecatedClients.name(r)).Inc()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Beyond this many distinct clients the rest are counted as "other"
+const maxDeprecatedClients = 100
+
+var deprecatedClients = &clientNames{seen: make(map[string]bool)}
+
+// clientNames labels requests by the product in their User-Agent, like
+// "contourguessr-ios" for "contourguessr-ios/2.3 (iPhone)". Browsers all send
+// "Mozilla/5.0" so are lumped together.
+type clientNames struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (c *clientNames) name(r *http.Request) string {
+	product, _, _ := strings.Cut(r.UserAgent(), "/")
+	product = strings.ToLower(strings.TrimSpace(product))
+	switch {
+	case product == "":
+		return "unknown"
+	case product == "mozilla":
+		return "browser"
+	case len(product) > 32 || strings.ContainsFunc(product, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.')
+	}):
+		return "other"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.seen[product] {
+		if len(c.seen) >= maxDeprecatedClients {
+			return "other"
+		}
+		c.seen[product] = true
+	}
+	return product
+}
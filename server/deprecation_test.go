@@ -0,0 +1,72 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeprecated(t *testing.T) {
+	d := deprecation{
+		Since:  time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+		Sunset: time.Date(2025, 7, 31, 0, 0, 0, 0, time.UTC),
+		Link:   "https://example.com/migrate",
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router := mux.NewRouter()
+	router.Handle("/api/v1/challenge/{id}", deprecated(d)(http.HandlerFunc(ok)))
+	router.Handle("/api/v1/region", deprecated(d, "date")(http.HandlerFunc(ok)))
+
+	get := func(path string, userAgent string) http.Header {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("User-Agent", userAgent)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Header()
+	}
+
+	h := get("/api/v1/challenge/abc", "contourguessr-ios/2.3 (iPhone)")
+	if h.Get("Deprecation") != "@1738281600" {
+		t.Errorf("unexpected Deprecation %q", h.Get("Deprecation"))
+	}
+	if h.Get("Sunset") != "Thu, 31 Jul 2025 00:00:00 GMT" {
+		t.Errorf("unexpected Sunset %q", h.Get("Sunset"))
+	}
+	if h.Get("Link") != `<https://example.com/migrate>; rel="deprecation"; type="text/html"` {
+		t.Errorf("unexpected Link %q", h.Get("Link"))
+	}
+	counter := deprecatedRequestsCounter.WithLabelValues("GET /api/v1/challenge/{id}", "contourguessr-ios")
+	if got := counterValue(t, counter); got != 1 {
+		t.Errorf("expected 1 deprecated request, got %v", got)
+	}
+
+	if h := get("/api/v1/region", "Mozilla/5.0"); h.Get("Deprecation") != "" {
+		t.Error("expected no Deprecation without the deprecated parameter")
+	}
+	if h := get("/api/v1/region?date=2024-01-01", "Mozilla/5.0"); h.Get("Deprecation") == "" {
+		t.Error("expected Deprecation with the deprecated parameter")
+	}
+	counter = deprecatedRequestsCounter.WithLabelValues("GET /api/v1/region?date", "browser")
+	if got := counterValue(t, counter); got != 1 {
+		t.Errorf("expected 1 deprecated parameter use, got %v", got)
+	}
+}
+
+func TestClientNames(t *testing.T) {
+	names := &clientNames{seen: make(map[string]bool)}
+	tests := map[string]string{
+		"":                                "unknown",
+		"Mozilla/5.0 (X11; Linux x86_64)": "browser",
+		"okhttp/4.12.0":                   "okhttp",
+		"Some Bot <script>":               "other",
+	}
+	for userAgent, want := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User-Agent", userAgent)
+		if got := names.name(req); got != want {
+			t.Errorf("%q: expected %q, got %q", userAgent, want, got)
+		}
+	}
+}
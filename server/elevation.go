@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/geo"
+	"contourguessr-api/repos"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Elevation guesses score half of maxRoundScore at about 140m off
+const scoreElevationScale = 200.0
+
+type revealedElevation struct {
+	ChallengeM *float64 `json:"challenge_m"`
+	GuessM     *float64 `json:"guess_m"`
+}
+
+func scoreForElevationError(meters float64) int {
+	return int(math.Round(maxRoundScore * math.Exp(-math.Abs(meters)/scoreElevationScale)))
+}
+
+// revealElevation looks up the elevation of the challenge and, if guessID is
+// set, of the guess. Whichever aren't stored yet are fetched from the DEM in
+// one request and stored. It's nil if the challenge's elevation is unknown.
+func revealElevation(ctx context.Context, challenge repos.Challenge, guessID int64) *revealedElevation {
+	var out revealedElevation
+	var missing []geo.Point
+
+	elevation, ok, err := repo.ChallengeElevation(ctx, challenge.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error getting cached elevation", "challenge", challenge.ID, "err", err)
+		return nil
+	} else if ok {
+		out.ChallengeM = &elevation
+	} else {
+		missing = append(missing, geo.Point{Lng: challenge.Geo.Lng, Lat: challenge.Geo.Lat})
+	}
+
+	var guess *repos.Guess
+	if guessID != 0 {
+		guesses, err := repo.Guesses(ctx, []int64{guessID})
+		if err != nil {
+			slog.ErrorContext(ctx, "error getting guess", "guess", guessID, "err", err)
+		} else if g, ok := guesses[guessID]; ok && g.ElevationM != nil {
+			out.GuessM = g.ElevationM
+		} else if ok {
+			guess = &g
+			missing = append(missing, geo.Point{Lng: g.Lng, Lat: g.Lat})
+		}
+	}
+
+	if len(missing) > 0 {
+		elevations, err := elevationClient.Lookup(ctx, missing)
+		if err != nil {
+			slog.ErrorContext(ctx, "error looking up elevation", "challenge", challenge.ID, "err", err)
+			elevations = nil
+		}
+		if len(elevations) > 0 && out.ChallengeM == nil {
+			out.ChallengeM = &elevations[0]
+			elevations = elevations[1:]
+			if err := repo.SetChallengeElevation(ctx, challenge.ID, *out.ChallengeM); err != nil {
+				slog.ErrorContext(ctx, "error storing elevation", "challenge", challenge.ID, "err", err)
+			}
+		}
+		if len(elevations) > 0 && guess != nil {
+			out.GuessM = &elevations[0]
+			if err := repo.SetGuessElevation(ctx, guess.ID, *out.GuessM); err != nil {
+				slog.ErrorContext(ctx, "error storing guess elevation", "guess", guess.ID, "err", err)
+			}
+		}
+	}
+
+	if out.ChallengeM == nil {
+		return nil
+	}
+	return &out
+}
+
+// handlePostElevationGuess plays the elevation mode, where the player guesses
+// how high the photo was taken instead of where. Only v2 serves it, as v1
+// challenges give away their location.
+func handlePostElevationGuess(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var body struct {
+		ElevationM float64 `json:"elevation_m"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.check(body.ElevationM >= -500 && body.ElevationM <= 9000, "elevation_m", "out_of_range", "elevation_m must be between -500 and 9000")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	challenge, err := repo.Challenge(id)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	elevation := revealElevation(r.Context(), challenge, 0)
+	if elevation == nil {
+		httpError(w, r, "elevation unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	actual := *elevation.ChallengeM
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"elevation_m": math.Round(actual),
+		"error_m":     math.Round(math.Abs(body.ElevationM - actual)),
+		"score":       scoreForElevationError(body.ElevationM - actual),
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRevealElevationIsStored(t *testing.T) {
+	var lookups atomic.Int32
+	dem := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		n := len(strings.Split(r.URL.Query().Get("latitude"), ","))
+		_, _ = w.Write([]byte(`{"elevation": [` + strings.TrimSuffix(strings.Repeat("700,", n), ",") + `]}`))
+	}))
+	defer dem.Close()
+
+	cfg := testConfig()
+	cfg.ElevationAPIURL = dem.URL
+	ts := newTestServer(t, cfg)
+	c, err := repo.RandomChallenge(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(map[string]any{"lng": c.Geo.Lng + 0.1, "lat": c.Geo.Lat, "play_token": servePlayToken(t, ts, c.ID)})
+	resp, err := http.Post(ts.URL+"/api/v2/challenge/"+c.ID+"/guess", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var guess struct {
+		RevealToken string `json:"reveal_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&guess); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 2 {
+		resp, err := http.Get(fmt.Sprintf("%s/api/v2/challenge/%s/full-metadata?reveal_token=%s", ts.URL, c.ID, guess.RevealToken))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var reveal struct {
+			Elevation *struct {
+				ChallengeM *float64 `json:"challenge_m"`
+				GuessM     *float64 `json:"guess_m"`
+			} `json:"elevation"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&reveal); err != nil {
+			t.Fatal(err)
+		}
+		if e := reveal.Elevation; e == nil || e.ChallengeM == nil || e.GuessM == nil || *e.GuessM != 700 {
+			t.Fatalf("reveal %d: expected both elevations, got %+v", i, e)
+		}
+	}
+	if got := lookups.Load(); got != 1 {
+		t.Errorf("expected one lookup for both points, got %d", got)
+	}
+}
+
+func TestScoreForElevationError(t *testing.T) {
+	if got := scoreForElevationError(0); got != maxRoundScore {
+		t.Errorf("expected a perfect score, got %d", got)
+	}
+	if scoreForElevationError(-100) != scoreForElevationError(100) {
+		t.Error("expected too low and too high to score the same")
+	}
+	if got := scoreForElevationError(140); got < 2400 || got > 2600 {
+		t.Errorf("expected about half marks at 140m off, got %d", got)
+	}
+}
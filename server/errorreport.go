@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/getsentry/sentry-go"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Set when SENTRY_DSN is
+var errorReporter *sentry.Client
+
+// errorReportMiddleware gives each request its own Sentry hub so that errors
+// logged while serving it are reported with its details. It needs to run
+// inside requestIDMiddleware and clientIPMiddleware.
+func errorReportMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// sentry.NewRequest would pass through headers like X-API-Key, so
+		// credentials in headers and cookies are deliberately left out here
+		req := &sentry.Request{
+			Method:      r.Method,
+			URL:         "https://" + r.Host + r.URL.Path,
+			QueryString: r.URL.RawQuery,
+			Headers:     map[string]string{"User-Agent": r.UserAgent(), "Referer": r.Referer()},
+			Env:         map[string]string{"REMOTE_ADDR": clientIP(r)},
+		}
+		scope := sentry.NewScope()
+		if id := requestID(r.Context()); id != "" {
+			scope.SetTag("request_id", id)
+		}
+		scope.AddEventProcessor(func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+			event.Request = req
+			return event
+		})
+		hub := sentry.NewHub(errorReporter, scope)
+		next.ServeHTTP(w, r.WithContext(sentry.SetHubOnContext(r.Context(), hub)))
+	})
+}
+
+// reportingHandler reports records at error level, which covers handler
+// errors, updater failures and recovered panics since they're all logged
+type reportingHandler struct {
+	slog.Handler
+	client *sentry.Client
+	attrs  []slog.Attr
+	group  string
+}
+
+func (h reportingHandler) Handle(ctx context.Context, r slog.Record) error {
+	err := h.Handler.Handle(ctx, r)
+	if r.Level < slog.LevelError {
+		return err
+	}
+
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	event.Logger = "slog"
+	event.Message = r.Message
+	var reported error
+	add := func(a slog.Attr) bool {
+		switch v := a.Value.Resolve().Any().(type) {
+		case error:
+			if a.Key == "err" && reported == nil {
+				reported = v
+				return true
+			}
+			event.Extra[a.Key] = v.Error()
+		case string:
+			event.Extra[a.Key] = v
+		default:
+			event.Extra[a.Key] = a.Value.String()
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if h.group != "" {
+			a.Key = h.group + "." + a.Key
+		}
+		return add(a)
+	})
+	if reported != nil {
+		event.Exception = []sentry.Exception{{
+			Type:  fmt.Sprintf("%T", reported),
+			Value: reported.Error(),
+		}}
+	}
+
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.NewHub(h.client, sentry.NewScope())
+	}
+	hub.CaptureEvent(event)
+	return err
+}
+
+func (h reportingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := h
+	next.Handler = h.Handler.WithAttrs(attrs)
+	next.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+	if h.group != "" {
+		for i := len(h.attrs); i < len(next.attrs); i++ {
+			next.attrs[i].Key = h.group + "." + next.attrs[i].Key
+		}
+	}
+	return next
+}
+
+func (h reportingHandler) WithGroup(name string) slog.Handler {
+	next := h
+	next.Handler = h.Handler.WithGroup(name)
+	if h.group != "" {
+		name = h.group + "." + name
+	}
+	next.group = name
+	return next
+}
+
+// buildRevision is the commit the binary was built from, if known
+func buildRevision() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return ""
+}
+
+// FlushErrorReports gives queued reports a moment to be sent before exiting
+func FlushErrorReports() {
+	if errorReporter == nil {
+		return
+	}
+	errorReporter.Flush(2 * time.Second)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"github.com/getsentry/sentry-go"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingTransport keeps events instead of sending them
+type recordingTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *recordingTransport) Configure(sentry.ClientOptions) {}
+func (t *recordingTransport) Flush(time.Duration) bool       { return true }
+func (t *recordingTransport) Close()                         {}
+
+func (t *recordingTransport) SendEvent(e *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, e)
+}
+
+func TestReportingHandler(t *testing.T) {
+	transport := &recordingTransport{}
+	client, err := sentry.NewClient(sentry.ClientOptions{Dsn: "https://key@sentry.example.com/1", Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := errorReporter
+	errorReporter = client
+	t.Cleanup(func() { errorReporter = prev })
+	logger := slog.New(reportingHandler{Handler: slog.NewTextHandler(io.Discard, nil), client: client}).With("updater", "regions")
+
+	handler := requestIDMiddleware(errorReportMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "not reported")
+		logger.ErrorContext(r.Context(), "error getting regions", "err", errors.New("timeout"))
+	})))
+	req := httptest.NewRequest("GET", "/api/v1/region?date=2024-01-01", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-API-Key", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(transport.events) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(transport.events))
+	}
+	e := transport.events[0]
+	if e.Message != "error getting regions" {
+		t.Errorf("unexpected message %q", e.Message)
+	}
+	if len(e.Exception) != 1 || e.Exception[0].Value != "timeout" {
+		t.Errorf("expected the err attr as the exception, got %+v", e.Exception)
+	}
+	if e.Tags["request_id"] != "req-1" {
+		t.Errorf("expected request_id tag, got %v", e.Tags)
+	}
+	if e.Extra["updater"] != "regions" {
+		t.Errorf("expected updater extra, got %v", e.Extra)
+	}
+	if e.Request == nil || e.Request.QueryString != "date=2024-01-01" {
+		t.Fatalf("expected the request to be attached, got %+v", e.Request)
+	}
+	for k, v := range e.Request.Headers {
+		if strings.Contains(v, "secret") {
+			t.Errorf("expected credentials to be left out, got %s: %s", k, v)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"contourguessr-api/gpx"
+	"contourguessr-api/repos"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var challengeCSVHeader = []string{
+	"id", "region_id", "lng", "lat", "title", "photographer", "photographer_link", "license", "date_taken", "link",
+}
+
+func handleExportChallengesCSV(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.queryOptionalInt("region")
+	if !v.valid(w) {
+		return
+	}
+
+	filename := "challenges.csv"
+	if regionID != nil {
+		filename = "challenges-region-" + strconv.Itoa(*regionID) + ".csv"
+	}
+	cw := csv.NewWriter(w)
+	exportChallenges(w, r, regionID, "text/csv; charset=utf-8", filename,
+		func() error {
+			return cw.Write(challengeCSVHeader)
+		},
+		func(c repos.Challenge, license string) error {
+			return cw.Write(challengeCSVRecord(c, license))
+		},
+	)
+	cw.Flush()
+}
+
+// exportChallenges responds with a file of the challenges being served in
+// region, or every region if nil. begin writes whatever comes before the first
+// challenge. Errors before anything is written get a problem response. It
+// returns false if the export failed, and callers then leave the file
+// unfinished so that it doesn't look complete.
+func exportChallenges(w http.ResponseWriter, r *http.Request, region *int, contentType string, filename string, begin func() error, row func(c repos.Challenge, license string) error) bool {
+	began := false
+	start := func() error {
+		began = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.Header().Set("Cache-Control", "no-store")
+		return begin()
+	}
+	err := repo.ExportChallenges(r.Context(), region, func(c repos.Challenge, license string) error {
+		if !began {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return row(c, license)
+	})
+	if !began && err == nil {
+		err = start()
+	}
+
+	if !began {
+		if writeContextError(w, r, err) {
+			return false
+		}
+		slog.ErrorContext(r.Context(), "error exporting challenges", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return false
+	} else if err != nil {
+		// Too late to change the status
+		slog.ErrorContext(r.Context(), "error writing challenges export", "err", err)
+		return false
+	}
+	return true
+}
+
+func handleExportRegionGeoJSON(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.pathInt("id")
+	if !v.valid(w) {
+		return
+	}
+	if _, ok := repo.Regions()[regionID]; !ok {
+		httpError(w, r, "region not found", http.StatusNotFound)
+		return
+	}
+
+	first := true
+	ok := exportChallenges(w, r, &regionID, "application/geo+json", "region-"+strconv.Itoa(regionID)+"-challenges.geojson",
+		func() error {
+			_, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`)
+			return err
+		},
+		func(c repos.Challenge, license string) error {
+			b, err := json.Marshal(challengeFeature(c, license))
+			if err != nil {
+				return err
+			}
+			if !first {
+				b = append([]byte{','}, b...)
+			}
+			first = false
+			_, err = w.Write(b)
+			return err
+		},
+	)
+	if ok {
+		_, _ = io.WriteString(w, "]}\n")
+	}
+}
+
+type geoJSONFeature struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Geometry struct {
+		Type        string     `json:"type"`
+		Coordinates [2]float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties challengeFeatureProperties `json:"properties"`
+}
+
+// challengeFeatureProperties are flat so that they show up in the attribute
+// tables and popups of tools like QGIS and geojson.io
+type challengeFeatureProperties struct {
+	ID               string     `json:"id"`
+	Title            string     `json:"title"`
+	Photographer     string     `json:"photographer"`
+	PhotographerLink string     `json:"photographer_link"`
+	License          string     `json:"license"`
+	DateTaken        *time.Time `json:"date_taken"`
+	Link             string     `json:"link"`
+	ImageURL         string     `json:"image_url"`
+}
+
+func challengeFeature(c repos.Challenge, license string) geoJSONFeature {
+	f := geoJSONFeature{Type: "Feature", ID: c.ID}
+	f.Geometry.Type = "Point"
+	f.Geometry.Coordinates = [2]float64{c.Geo.Lng, c.Geo.Lat}
+	f.Properties = challengeFeatureProperties{
+		ID:               c.ID,
+		Title:            c.Title,
+		Photographer:     c.Photographer.Text,
+		PhotographerLink: c.Photographer.Link,
+		License:          license,
+		DateTaken:        c.DateTaken,
+		Link:             c.Link,
+		ImageURL:         c.Src.Regular.Src,
+	}
+	return f
+}
+
+func challengeCSVRecord(c repos.Challenge, license string) []string {
+	var dateTaken string
+	if c.DateTaken != nil {
+		dateTaken = c.DateTaken.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		c.ID,
+		c.RegionID,
+		strconv.FormatFloat(c.Geo.Lng, 'f', -1, 64),
+		strconv.FormatFloat(c.Geo.Lat, 'f', -1, 64),
+		spreadsheetSafe(c.Title),
+		spreadsheetSafe(c.Photographer.Text),
+		c.Photographer.Link,
+		license,
+		dateTaken,
+		c.Link,
+	}
+}
+
+// spreadsheetSafe stops spreadsheets treating text from Flickr as a formula
+func spreadsheetSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// handlePostGameGPX turns a game's result tokens into a GPX file of where
+// each round's photo was taken and where the player guessed, for loading into
+// GPS apps
+func handlePostGameGPX(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Results []string `json:"results"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.check(len(body.Results) > 0, "results", "required", "results is required")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	game, rounds, total, err := verifyResultChain(body.Results)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	guessIDs := make([]int64, 0, len(rounds))
+	for _, round := range rounds {
+		guessIDs = append(guessIDs, round.GuessID)
+	}
+	guesses, err := repo.Guesses(r.Context(), guessIDs)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting guesses", "game", game, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	doc := gpx.GPX{
+		Creator: "ContourGuessr",
+		Name:    fmt.Sprintf("ContourGuessr game (%d points)", total),
+	}
+	for i, round := range rounds {
+		n := i + 1
+		// Challenges retired since the game was played have no location to
+		// show, but the guess still does
+		if c, err := repo.Challenge(round.ChallengeID); err == nil {
+			wp := gpx.Waypoint{
+				Lng:  c.Geo.Lng,
+				Lat:  c.Geo.Lat,
+				Name: fmt.Sprintf("Round %d: %s", n, c.Title),
+				Link: repo.OutboundLink(c.Link, c.ID),
+				Type: "photo",
+			}
+			if c.Photographer.Text != "" {
+				wp.Description = "Photo by " + c.Photographer.Text
+			}
+			if c.DateTaken != nil {
+				wp.Time = *c.DateTaken
+			}
+			doc.Waypoints = append(doc.Waypoints, wp)
+		}
+		if g, ok := guesses[round.GuessID]; ok {
+			doc.Waypoints = append(doc.Waypoints, gpx.Waypoint{
+				Lng:         g.Lng,
+				Lat:         g.Lat,
+				Name:        fmt.Sprintf("Round %d guess", n),
+				Description: fmt.Sprintf("%.1f km off, %d points", round.DistanceM/1000, round.Score),
+				Time:        g.GuessedAt,
+				Type:        "guess",
+			})
+			if g.GuessedAt.After(doc.Time) {
+				doc.Time = g.GuessedAt
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/gpx+xml")
+	w.Header().Set("Content-Disposition", `attachment; filename="contourguessr-`+game+`.gpx"`)
+	if err := gpx.Write(w, doc); err != nil {
+		slog.ErrorContext(r.Context(), "error writing GPX", "err", err)
+	}
+}
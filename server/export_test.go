@@ -0,0 +1,65 @@
+package server
+
+import (
+	"contourguessr-api/repos"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestChallengeCSVRecord(t *testing.T) {
+	taken := time.Date(2023, 6, 1, 13, 30, 0, 0, time.FixedZone("BST", 3600))
+	var c repos.Challenge
+	c.ID = "abc"
+	c.RegionID = "3"
+	c.Geo.Lng = -3.5
+	c.Geo.Lat = 53.25
+	c.Title = "=HYPERLINK(\"http://example.com\")"
+	c.Photographer.Text = "someone"
+	c.DateTaken = &taken
+
+	got := challengeCSVRecord(c, "CC BY 2.0")
+	want := []string{"abc", "3", "-3.5", "53.25", "'=HYPERLINK(\"http://example.com\")", "someone", "", "CC BY 2.0", "2023-06-01T12:30:00Z", ""}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if len(got) != len(challengeCSVHeader) {
+		t.Errorf("expected %d columns, got %d", len(challengeCSVHeader), len(got))
+	}
+}
+
+func TestSpreadsheetSafe(t *testing.T) {
+	for in, want := range map[string]string{"": "", "Tryfan": "Tryfan", "+44": "'+44", "-": "'-", "@x": "'@x"} {
+		if got := spreadsheetSafe(in); got != want {
+			t.Errorf("%q: expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestChallengeFeature(t *testing.T) {
+	var c repos.Challenge
+	c.ID = "abc"
+	c.Geo.Lng = -3.5
+	c.Geo.Lat = 53.25
+	c.Title = "Tryfan"
+	c.Src.Regular.Src = "https://example.com/r.jpg"
+
+	b, err := json.Marshal(challengeFeature(c, "CC BY 2.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"Feature","id":"abc","geometry":{"type":"Point","coordinates":[-3.5,53.25]},` +
+		`"properties":{"id":"abc","title":"Tryfan","photographer":"","photographer_link":"","license":"CC BY 2.0",` +
+		`"date_taken":null,"link":"","image_url":"https://example.com/r.jpg"}}`
+	if string(b) != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, b)
+	}
+
+	spec := loadOpenAPISpec(t)
+	var v any
+	_ = json.Unmarshal(b, &v)
+	for _, problem := range validateSchema(spec, resolveRef(spec, "#/components/schemas/ChallengeFeature"), v, "feature", false) {
+		t.Error(problem)
+	}
+}
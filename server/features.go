@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type featureOverridesKey struct{}
+
+// featureOverrides lets staff switch features for a single request with the
+// X-CG-Features header, in the same "a,-b" form as FEATURES, so that QA builds
+// can try in-development payloads against production data. Only features on
+// the allowlist can be switched, and the header is ignored from anyone else.
+type featureOverrides struct {
+	allowed     map[string]bool
+	staffTokens [][]byte
+}
+
+func newFeatureOverrides(allowed []string, staffTokens []string) *featureOverrides {
+	fo := &featureOverrides{allowed: make(map[string]bool)}
+	for _, name := range allowed {
+		fo.allowed[name] = true
+	}
+	for _, token := range staffTokens {
+		fo.staffTokens = append(fo.staffTokens, []byte(token))
+	}
+	return fo
+}
+
+func (fo *featureOverrides) isStaff(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return true
+	}
+	for _, staff := range fo.staffTokens {
+		if subtle.ConstantTimeCompare([]byte(token), staff) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (fo *featureOverrides) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses may differ by the header, so caches mustn't mix them up
+		w.Header().Add("Vary", "X-CG-Features")
+
+		header := r.Header.Get("X-CG-Features")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !fo.isStaff(r) {
+			slog.DebugContext(r.Context(), "ignoring X-CG-Features from non-staff")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		overrides := make(map[string]bool)
+		for _, name := range strings.Split(header, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			name, off := strings.CutPrefix(name, "-")
+			if !fo.allowed[name] {
+				httpErrorCode(w, r, "feature_not_switchable", "feature "+name+" cannot be switched per request", http.StatusBadRequest)
+				return
+			}
+			overrides[name] = !off
+		}
+
+		applied := make([]string, 0, len(overrides))
+		for name, on := range overrides {
+			if !on {
+				name = "-" + name
+			}
+			applied = append(applied, name)
+		}
+		sort.Strings(applied)
+		w.Header().Set("X-CG-Features", strings.Join(applied, ","))
+
+		ctx := context.WithValue(r.Context(), featureOverridesKey{}, overrides)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// feature reports whether the flag is on for this request
+func feature(r *http.Request, name string) bool {
+	if overrides, ok := r.Context().Value(featureOverridesKey{}).(map[string]bool); ok {
+		if on, ok := overrides[name]; ok {
+			return on
+		}
+	}
+	return features[name]
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFeatureOverrides(t *testing.T) {
+	features = map[string]bool{"world_tour": true, "new_scoring": false}
+	adminToken = "admin"
+	t.Cleanup(func() {
+		features = nil
+		adminToken = ""
+	})
+
+	fo := newFeatureOverrides([]string{"world_tour", "new_scoring"}, []string{"qa-token"})
+	var got map[string]bool
+	handler := fo.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = map[string]bool{
+			"world_tour":  feature(r, "world_tour"),
+			"new_scoring": feature(r, "new_scoring"),
+		}
+	}))
+
+	serve := func(token string, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/region", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("X-CG-Features", header)
+		rec := httptest.NewRecorder()
+		got = nil
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("qa-token", "new_scoring, -world_tour")
+	if !got["new_scoring"] || got["world_tour"] {
+		t.Errorf("expected staff overrides to apply, got %v", got)
+	}
+	if h := rec.Header().Get("X-CG-Features"); h != "-world_tour,new_scoring" {
+		t.Errorf("expected applied features to be echoed, got %q", h)
+	}
+
+	serve("admin", "new_scoring")
+	if !got["new_scoring"] {
+		t.Error("expected the admin to count as staff")
+	}
+
+	for _, token := range []string{"", "guess"} {
+		rec = serve(token, "new_scoring,-world_tour")
+		if got["new_scoring"] || !got["world_tour"] || rec.Header().Get("X-CG-Features") != "" {
+			t.Errorf("expected header from %q to be ignored, got %v", token, got)
+		}
+	}
+
+	rec = serve("qa-token", "debug_everything")
+	if rec.Code != http.StatusBadRequest || got != nil {
+		t.Errorf("expected features off the allowlist to be rejected, got %d", rec.Code)
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) != 1 || vary[0] != "X-CG-Features" {
+		t.Errorf("expected Vary: X-CG-Features, got %v", vary)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"contourguessr-api/atom"
+	"html"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// publicURL is where the API is served from, for links back to it
+var publicURL string
+
+const feedEntries = 50
+
+// handleGetRegionFeed lists the challenges most recently added to a region,
+// so that people can subscribe to new photos of places they know. Entries
+// link to the challenge on the site rather than to the photo's page, which
+// would give away the location.
+func handleGetRegionFeed(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.pathInt("id")
+	if !v.valid(w) {
+		return
+	}
+	region, ok := repo.Regions()[regionID]
+	if !ok {
+		httpError(w, r, "region not found", http.StatusNotFound)
+		return
+	}
+
+	added, err := repo.RecentlyAdded(r.Context(), regionID, feedEntries)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting recently added challenges", "region", regionID, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	site := strings.TrimSuffix(siteURL, "/")
+	self := strings.TrimSuffix(publicURL, "/") + "/api/v1/region/" + strconv.Itoa(regionID) + "/feed.atom"
+	feed := atom.Feed{
+		ID:       self,
+		Title:    "New ContourGuessr challenges in " + region.Name,
+		Link:     site + "/",
+		SelfLink: self,
+		Updated:  time.Now(),
+		Author:   "ContourGuessr",
+	}
+	if len(added) > 0 {
+		feed.Updated = added[0].AddedAt
+	}
+	for _, a := range added {
+		c := a.Challenge
+		link := site + "/challenge/" + c.ID
+		title := c.Title
+		if title == "" {
+			title = "Untitled"
+		}
+		entry := atom.Entry{
+			ID:          link,
+			Title:       title,
+			Link:        link,
+			Published:   a.AddedAt,
+			Updated:     a.AddedAt,
+			Author:      c.Photographer.Text,
+			ContentHTML: feedEntryHTML(c.Src.Regular.Src, c.Src.Regular.Width, c.Src.Regular.Height, title, c.Photographer.Text),
+		}
+		if c.Photographer.Link != "" {
+			entry.AuthorURI = repo.OutboundLink(c.Photographer.Link, c.ID)
+		}
+		if c.Src.Regular.Src != "" {
+			entry.Thumbnail = &atom.Thumbnail{URL: c.Src.Regular.Src, Width: c.Src.Regular.Width, Height: c.Src.Regular.Height}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=900")
+	if err := atom.Write(w, feed); err != nil {
+		slog.ErrorContext(r.Context(), "error writing feed", "err", err)
+	}
+}
+
+func feedEntryHTML(src string, width int, height int, title string, photographer string) string {
+	var b strings.Builder
+	if src != "" {
+		b.WriteString(`<p><img src="` + html.EscapeString(src) + `" width="` + strconv.Itoa(width) +
+			`" height="` + strconv.Itoa(height) + `" alt="` + html.EscapeString(title) + `"></p>`)
+	}
+	if photographer != "" {
+		b.WriteString("<p>Photo by " + html.EscapeString(photographer) + "</p>")
+	}
+	return b.String()
+}
@@ -0,0 +1,16 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestFeedEntryHTML(t *testing.T) {
+	got := feedEntryHTML("https://example.com/a.jpg?x=1&y=2", 500, 375, `"Crib Goch"`, "<someone>")
+	want := `<p><img src="https://example.com/a.jpg?x=1&amp;y=2" width="500" height="375" alt="&#34;Crib Goch&#34;"></p><p>Photo by &lt;someone&gt;</p>`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got := feedEntryHTML("", 0, 0, "Untitled", ""); got != "" {
+		t.Errorf("expected nothing without a picture or photographer, got %s", got)
+	}
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"contourguessr-api/geo"
+	"encoding/json"
+	"math"
+	"net/http"
+)
+
+// handleGetGeoDistance measures the way guesses are scored, so that clients
+// can display distances that agree with the scores they're given
+func handleGetGeoDistance(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	from := v.queryPoint("from")
+	to := v.queryPoint("to")
+	if !v.valid(w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		// Rounded like the distances of guesses
+		"distance_m":      math.Round(geo.Distance(from, to)),
+		"initial_bearing": math.Round(geo.InitialBearing(from, to)*10) / 10,
+	})
+}
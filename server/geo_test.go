@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGeoDistanceMatchesGuess(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+	c, err := repo.RandomChallenge(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guessLng, guessLat := c.Geo.Lng+0.1234, c.Geo.Lat-0.0567
+
+	body, _ := json.Marshal(map[string]any{"lng": guessLng, "lat": guessLat, "practice": true})
+	resp, err := http.Post(ts.URL+"/api/v1/challenge/"+c.ID+"/guess", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var guess struct {
+		DistanceM float64 `json:"distance_m"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&guess); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("%s/api/v1/geo/distance?from=%v,%v&to=%v,%v", ts.URL, guessLng, guessLat, c.Geo.Lng, c.Geo.Lat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var measured struct {
+		DistanceM float64 `json:"distance_m"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&measured); err != nil {
+		t.Fatal(err)
+	}
+	if measured.DistanceM != guess.DistanceM || measured.DistanceM == 0 {
+		t.Errorf("expected the guess's distance %v, got %v", guess.DistanceM, measured.DistanceM)
+	}
+}
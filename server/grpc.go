@@ -0,0 +1,322 @@
+package server
+
+import (
+	"context"
+	pb "contourguessr-api/proto/contourguessr/v1"
+	"contourguessr-api/repos"
+	"encoding/json"
+	"errors"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log/slog"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var grpcRequestsCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "grpc_requests_total",
+		Help:      "gRPC calls partitioned by method and status code",
+	},
+	[]string{"method", "code"},
+)
+
+var grpcRequestDurationHistogram = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "contourguessr",
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Time to handle gRPC calls partitioned by method",
+		Buckets:   prometheus.DefBuckets,
+	},
+	[]string{"method"},
+)
+
+// Requests are all small, so anything bigger is a mistake or abuse
+const maxGRPCRequestSize = 64 << 10
+
+// newGRPCServer serves gRPC, and the same methods as JSON through grpc-gateway
+// for clients that can't speak HTTP/2. Both go through grpcInterceptor.
+func newGRPCServer(addr string) *http.Server {
+	svc := &grpcService{}
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(grpcInterceptor), grpc.MaxRecvMsgSize(maxGRPCRequestSize))
+	pb.RegisterContourGuessrServer(grpcServer, svc)
+
+	gateway := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+		// Field names match the REST API
+		MarshalOptions: protojson.MarshalOptions{UseProtoNames: true},
+	}))
+	// Only fails if the context is done, which Background never is
+	_ = pb.RegisterContourGuessrHandlerServer(context.Background(), gateway, gatewayService{svc: svc})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		gateway.ServeHTTP(w, r)
+	})
+	server := &http.Server{Addr: addr, Handler: requestIDMiddleware(handler)}
+	// gRPC clients speak HTTP/2 without upgrading from HTTP/1
+	server.Protocols = new(http.Protocols)
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetUnencryptedHTTP2(true)
+	return server
+}
+
+// grpcInterceptor records metrics, recovers panics and keeps the messages of
+// unexpected errors from clients
+func grpcInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	method := path.Base(info.FullMethod)
+	start := time.Now()
+	defer func() {
+		if v := recover(); v != nil {
+			slog.ErrorContext(ctx, "grpc handler panicked", "method", method, "err", v, "stack", string(debug.Stack()))
+			resp, err = nil, status.Error(codes.Internal, "internal error")
+		}
+		grpcRequestsCounter.WithLabelValues(method, status.Code(err).String()).Inc()
+		grpcRequestDurationHistogram.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	}()
+
+	resp, err = handler(ctx, req)
+	if _, ok := status.FromError(err); ok {
+		return resp, err
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	case errors.Is(err, context.Canceled):
+		return nil, status.Error(codes.Canceled, "canceled")
+	default:
+		slog.ErrorContext(ctx, "grpc error", "method", method, "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+}
+
+// grpcService serves the same data as the v2 play endpoints
+type grpcService struct {
+	pb.UnimplementedContourGuessrServer
+}
+
+// gatewayService runs grpc-gateway's calls through grpcInterceptor, which it
+// would otherwise skip by calling the service directly
+type gatewayService struct {
+	pb.UnimplementedContourGuessrServer
+	svc *grpcService
+}
+
+func intercept[Req, Resp any](ctx context.Context, method string, req *Req, handler func(context.Context, *Req) (*Resp, error)) (*Resp, error) {
+	resp, err := grpcInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+		return handler(ctx, req.(*Req))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*Resp), nil
+}
+
+func (g gatewayService) ListRegions(ctx context.Context, req *pb.ListRegionsRequest) (*pb.ListRegionsResponse, error) {
+	return intercept(ctx, pb.ContourGuessr_ListRegions_FullMethodName, req, g.svc.ListRegions)
+}
+
+func (g gatewayService) GetChallenge(ctx context.Context, req *pb.GetChallengeRequest) (*pb.Challenge, error) {
+	return intercept(ctx, pb.ContourGuessr_GetChallenge_FullMethodName, req, g.svc.GetChallenge)
+}
+
+func (g gatewayService) GetRandomChallenge(ctx context.Context, req *pb.GetRandomChallengeRequest) (*pb.Challenge, error) {
+	return intercept(ctx, pb.ContourGuessr_GetRandomChallenge_FullMethodName, req, g.svc.GetRandomChallenge)
+}
+
+func (s *grpcService) ListRegions(_ context.Context, _ *pb.ListRegionsRequest) (*pb.ListRegionsResponse, error) {
+	regions, err := protoRegions()
+	if err != nil {
+		return nil, err
+	}
+	return regions.resp, nil
+}
+
+// Converted from the v2 JSON, which is cached already, each time its ETag
+// changes
+var protoRegionsCache atomic.Pointer[cachedProtoRegions]
+
+type cachedProtoRegions struct {
+	etag string
+	resp *pb.ListRegionsResponse
+	body []byte
+}
+
+// protoRegions returns the regions as served by GET /api/v2/region, along with
+// their protobuf encoding
+func protoRegions() (*cachedProtoRegions, error) {
+	encoded, err := repo.RegionsV2JSON(nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached := protoRegionsCache.Load(); cached != nil && cached.etag == encoded.ETag {
+		return cached, nil
+	}
+
+	var body struct {
+		Regions []struct {
+			ID          string          `json:"id"`
+			Name        string          `json:"name"`
+			CountryISO2 string          `json:"country_iso2"`
+			LogoURL     string          `json:"logo_url"`
+			GeoJSON     json.RawMessage `json:"geo_json"`
+			BBox        struct {
+				MinLng float64 `json:"min_lng"`
+				MinLat float64 `json:"min_lat"`
+				MaxLng float64 `json:"max_lng"`
+				MaxLat float64 `json:"max_lat"`
+			} `json:"bbox"`
+			MapLayers []repos.ParsedMapLayer `json:"map_layers"`
+		} `json:"regions"`
+	}
+	if err := json.Unmarshal(encoded.Body, &body); err != nil {
+		return nil, err
+	}
+	resp := &pb.ListRegionsResponse{Regions: make([]*pb.Region, 0, len(body.Regions))}
+	for _, region := range body.Regions {
+		out := &pb.Region{
+			Id:          region.ID,
+			Name:        region.Name,
+			CountryIso2: region.CountryISO2,
+			LogoUrl:     region.LogoURL,
+			GeoJson:     string(region.GeoJSON),
+			Bbox: &pb.BBox{
+				MinLng: region.BBox.MinLng,
+				MinLat: region.BBox.MinLat,
+				MaxLng: region.BBox.MaxLng,
+				MaxLat: region.BBox.MaxLat,
+			},
+		}
+		for _, layer := range region.MapLayers {
+			out.MapLayers = append(out.MapLayers, grpcMapLayer(layer))
+		}
+		resp.Regions = append(resp.Regions, out)
+	}
+	b, err := proto.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	cached := &cachedProtoRegions{etag: encoded.ETag, resp: resp, body: b}
+	protoRegionsCache.Store(cached)
+	return cached, nil
+}
+
+func grpcMapLayer(layer repos.ParsedMapLayer) *pb.MapLayer {
+	out := &pb.MapLayer{
+		Id:                layer.ID,
+		Name:              layer.Name,
+		Season:            layer.Season,
+		From:              layer.From,
+		To:                layer.To,
+		Layer:             layer.Layer,
+		Format:            layer.Format,
+		TileUrlTemplate:   layer.TileURLTemplate,
+		Crs:               layer.CRS,
+		Resolutions:       layer.Resolutions,
+		DefaultResolution: layer.DefaultResolution,
+		OsBranding:        layer.OSBranding,
+		ExtraAttributions: layer.ExtraAttributions,
+	}
+	for _, m := range layer.TileMatrices {
+		out.TileMatrices = append(out.TileMatrices, &pb.TileMatrix{
+			Id:               m.ID,
+			ScaleDenominator: m.ScaleDenominator,
+			TopLeftX:         m.TopLeft[0],
+			TopLeftY:         m.TopLeft[1],
+			TileWidth:        int32(m.TileWidth),
+			TileHeight:       int32(m.TileHeight),
+			MatrixWidth:      int32(m.MatrixWidth),
+			MatrixHeight:     int32(m.MatrixHeight),
+		})
+	}
+	return out
+}
+
+func (s *grpcService) GetChallenge(ctx context.Context, req *pb.GetChallengeRequest) (*pb.Challenge, error) {
+	challenge, err := repo.Challenge(req.Id)
+	if err != nil {
+		// The only other error is an ID that doesn't decode
+		return nil, status.Error(codes.NotFound, "challenge not found")
+	}
+	recordServed(challenge)
+	return grpcChallenge(ctx, challenge)
+}
+
+func (s *grpcService) GetRandomChallenge(ctx context.Context, req *pb.GetRandomChallengeRequest) (*pb.Challenge, error) {
+	if len(req.Player) > 128 {
+		return nil, status.Error(codes.InvalidArgument, "player must be at most 128 characters")
+	}
+	var regionID *int
+	if req.RegionId != nil {
+		id := int(*req.RegionId)
+		regionID = &id
+	}
+
+	var challenge repos.Challenge
+	var err error
+	if req.Player != "" {
+		challenge, err = repo.PlayerChallenge(regionID, req.Player, req.Seq)
+	} else {
+		challenge, err = repo.RandomChallenge(regionID)
+	}
+	if errors.Is(err, repos.NoChallengesAvailableError) {
+		return nil, status.Error(codes.NotFound, "no challenges available")
+	} else if err != nil {
+		return nil, err
+	}
+	recordServed(challenge)
+	return grpcChallenge(ctx, challenge)
+}
+
+func grpcChallenge(ctx context.Context, challenge repos.Challenge) (*pb.Challenge, error) {
+	description, err := repo.ChallengeDescription(ctx, challenge.ID)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	} else if err != nil {
+		// As in REST, the rest of the challenge is still playable
+		slog.ErrorContext(ctx, "error getting description", "challenge", challenge.ID, "err", err)
+	}
+	var dateTaken *timestamppb.Timestamp
+	if challenge.DateTaken != nil {
+		dateTaken = timestamppb.New(*challenge.DateTaken)
+	}
+	return &pb.Challenge{
+		Id:              challenge.ID,
+		RegionId:        challenge.RegionID,
+		Title:           challenge.Title,
+		DescriptionHtml: description,
+		DateTaken:       dateTaken,
+		Link:            servedLink(challenge.ID, "photo", challenge.Link),
+		Regular: &pb.Picture{
+			Src:    photoURL(challenge.ID, "regular"),
+			Width:  int32(challenge.Src.Regular.Width),
+			Height: int32(challenge.Src.Regular.Height),
+		},
+		Large: &pb.Picture{
+			Src:    photoURL(challenge.ID, "large"),
+			Width:  int32(challenge.Src.Large.Width),
+			Height: int32(challenge.Src.Large.Height),
+		},
+		Photographer: &pb.Photographer{
+			Icon: challenge.Photographer.Icon,
+			Text: challenge.Photographer.Text,
+			Link: servedLink(challenge.ID, "photographer", challenge.Photographer.Link),
+		},
+		RX: challenge.R.X,
+		RY: challenge.R.Y,
+	}, nil
+}
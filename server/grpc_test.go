@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	pb "contourguessr-api/proto/contourguessr/v1"
+	"encoding/json"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func startGRPCServer(t *testing.T) string {
+	t.Helper()
+	server := newGRPCServer("")
+	ts := httptest.NewUnstartedServer(server.Handler)
+	ts.Config.Protocols = server.Protocols
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return strings.TrimPrefix(ts.URL, "http://")
+}
+
+func TestGRPCServer(t *testing.T) {
+	setupFixtureRepo(t)
+	want := fixtureChallenge(t, "")
+	addr := startGRPCServer(t)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewContourGuessrClient(conn)
+	ctx := context.Background()
+
+	regions, err := client.ListRegions(ctx, &pb.ListRegionsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions.Regions) == 0 || regions.Regions[0].Bbox == nil {
+		t.Errorf("expected regions with bounding boxes, got %v", regions.Regions)
+	}
+
+	challenge, err := client.GetChallenge(ctx, &pb.GetChallengeRequest{Id: want.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Id != want.ID || challenge.Title != want.Title {
+		t.Errorf("expected challenge %s, got %s", want.ID, challenge.Id)
+	}
+
+	_, err = client.GetChallenge(ctx, &pb.GetChallengeRequest{Id: "nope"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown challenge, got %v", err)
+	}
+
+	_, err = client.GetRandomChallenge(ctx, &pb.GetRandomChallengeRequest{Player: strings.Repeat("x", 129)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a long player, got %v", err)
+	}
+}
+
+func TestGRPCGateway(t *testing.T) {
+	setupFixtureRepo(t)
+	want := fixtureChallenge(t, "")
+	addr := startGRPCServer(t)
+
+	resp, err := http.Get("http://" + addr + "/v1/challenges/" + want.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["id"] != want.ID || body["region_id"] != want.RegionID {
+		t.Errorf("expected the challenge with REST field names, got %v", body)
+	}
+
+	resp, err = http.Get("http://" + addr + "/v1/challenges/nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown challenge, got %d", resp.StatusCode)
+	}
+}
+
+func TestGRPCInterceptorHidesErrors(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: pb.ContourGuessr_GetChallenge_FullMethodName}
+	cases := map[string]grpc.UnaryHandler{
+		"error": func(context.Context, any) (any, error) {
+			return nil, errors.New("connection refused to db.internal")
+		},
+		"panic": func(context.Context, any) (any, error) {
+			panic("db.internal")
+		},
+	}
+	for name, handler := range cases {
+		_, err := grpcInterceptor(context.Background(), nil, info, handler)
+		if s := status.Convert(err); s.Code() != codes.Internal || strings.Contains(s.Message(), "db.internal") {
+			t.Errorf("%s: expected an opaque Internal error, got %v", name, err)
+		}
+	}
+
+	_, err := grpcInterceptor(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		return nil, context.DeadlineExceeded
+	})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/geo"
+	"contourguessr-api/repos"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var InvalidGuessEventTokenError = errors.New("invalid guess event token")
+var GuessEventRecordedError = errors.New("guess event already recorded")
+
+// claimGuessEvent checks the event is for a guess that was made, going by the
+// result token the guess was answered with or the play token it redeemed, and
+// that no event was recorded for the guess before
+func claimGuessEvent(ctx context.Context, challengeID string, resultToken string, playToken string) error {
+	var key string
+	if resultToken != "" {
+		var claims resultClaims
+		if err := signer.Verify(resultToken, &claims); err != nil || claims.Kind != "result" ||
+			claims.ChallengeID != challengeID || time.Now().Unix() > claims.Expires {
+			return InvalidGuessEventTokenError
+		}
+		key = "guessevent:guess:" + strconv.FormatInt(claims.GuessID, 10)
+	} else {
+		claims, err := verifyPlayToken(playToken, challengeID)
+		if err != nil {
+			return InvalidGuessEventTokenError
+		}
+		_, redeemed, err := sharedStore.Get(ctx, "playtoken:"+claims.Nonce)
+		if err != nil {
+			return err
+		} else if !redeemed {
+			return InvalidGuessEventTokenError
+		}
+		key = "guessevent:play:" + claims.Nonce
+	}
+	// Neither token can be used once it has expired
+	ok, err := sharedStore.SetNX(ctx, key, "1", max(resultTokenTTL, playTokenTTL)+time.Minute)
+	if err != nil {
+		return err
+	} else if !ok {
+		return GuessEventRecordedError
+	}
+	return nil
+}
+
+// handlePostGuessEvent records a guess for analytics. The distance is worked
+// out here rather than trusted from the client. Each guess can only be
+// recorded once, and as the event gives away how far off the guess was, it's
+// throttled like the guess.
+func handlePostGuessEvent(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ChallengeID   string  `json:"challenge_id"`
+		Lng           float64 `json:"lng"`
+		Lat           float64 `json:"lat"`
+		HintsUsed     int     `json:"hints_used"`
+		ClientVersion string  `json:"client_version"`
+		// From the guess response, or for practice guesses the play token
+		// the guess was made with
+		ResultToken string `json:"result_token"`
+		PlayToken   string `json:"play_token"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.bodyString("challenge_id", body.ChallengeID, true, 64)
+		v.check(body.Lng >= -180 && body.Lng <= 180, "lng", "out_of_range", "lng must be between -180 and 180")
+		v.check(body.Lat >= -90 && body.Lat <= 90, "lat", "out_of_range", "lat must be between -90 and 90")
+		v.check(body.HintsUsed >= 0 && body.HintsUsed < len(hintPenalties), "hints_used", "out_of_range",
+			"hints_used must be between 0 and 3")
+		v.bodyString("client_version", body.ClientVersion, true, 64)
+		v.check(body.ResultToken != "" || body.PlayToken != "", "result_token", "required",
+			"result_token or play_token is required")
+	}
+	if !v.valid(w) || !throttleReveals(w, r, "") {
+		return
+	}
+
+	challenge, err := repo.Challenge(body.ChallengeID)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = claimGuessEvent(r.Context(), challenge.ID, body.ResultToken, body.PlayToken)
+	if errors.Is(err, InvalidGuessEventTokenError) {
+		httpError(w, r, "invalid result_token or play_token", http.StatusForbidden)
+		return
+	} else if errors.Is(err, GuessEventRecordedError) {
+		httpError(w, r, "guess event already recorded", http.StatusConflict)
+		return
+	} else if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error claiming guess event", "challenge", challenge.ID, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = repo.RecordGuessEvent(r.Context(), repos.GuessEvent{
+		ChallengeID:   challenge.ID,
+		Lng:           body.Lng,
+		Lat:           body.Lat,
+		DistanceM:     geo.Distance(geo.Point{Lng: body.Lng, Lat: body.Lat}, geo.Point{Lng: challenge.Geo.Lng, Lat: challenge.Geo.Lat}),
+		HintsUsed:     body.HintsUsed,
+		ClientVersion: body.ClientVersion,
+	})
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error recording guess event", "challenge", challenge.ID, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Width of each band of the score distribution
+const scoreBandWidth = 500
+
+type scoreBand struct {
+	MinScore int   `json:"min_score"`
+	MaxScore int   `json:"max_score"`
+	Plays    int64 `json:"plays"`
+}
+
+// scoreBandEdges are the distances at which the score drops into each lower
+// band, nearest first
+func scoreBandEdges() []float64 {
+	var edges []float64
+	for minScore := maxRoundScore - scoreBandWidth; minScore > 0; minScore -= scoreBandWidth {
+		// Scores are rounded, so the band starts half a point below
+		edges = append(edges, -scoreDistanceScale*math.Log((float64(minScore)-0.5)/maxRoundScore))
+	}
+	return edges
+}
+
+// handleGetChallengeStats summarizes the guess events of a challenge. Given
+// distance_m, the caller's result is placed among them. Like the heatmap, the
+// distances narrow down where the challenge is, so it needs a reveal token.
+func handleGetChallengeStats(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	v := newValidator(r)
+	distance := v.queryOptionalFloat("distance_m")
+	if distance != nil {
+		v.check(*distance >= 0, "distance_m", "out_of_range", "distance_m must not be negative")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	if _, err := verifyRevealToken(r.URL.Query().Get("reveal_token"), id); err != nil {
+		httpError(w, r, "valid reveal_token required", http.StatusForbidden)
+		return
+	}
+
+	if _, err := repo.Challenge(id); errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var d float64
+	if distance != nil {
+		d = *distance
+	}
+	stats, err := repo.ChallengeGuessStats(r.Context(), id, scoreBandEdges(), d)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting challenge stats", "challenge", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	distribution := make([]scoreBand, len(stats.Bands))
+	for i, plays := range stats.Bands {
+		band := scoreBand{MaxScore: maxRoundScore - i*scoreBandWidth - 1, Plays: plays}
+		band.MinScore = band.MaxScore - scoreBandWidth + 1
+		if i == 0 {
+			band.MaxScore = maxRoundScore
+		}
+		distribution[i] = band
+	}
+	out := map[string]interface{}{
+		"plays":              stats.Plays,
+		"median_distance_m":  math.Round(stats.MedianM),
+		"score_distribution": distribution,
+	}
+	if distance != nil && stats.Plays > 0 {
+		out["percentile"] = int(math.Round(100 * float64(stats.Further) / float64(stats.Plays)))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// The token is in the URL, so only the player's own cache has a use for it
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	_ = json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"contourguessr-api/shared"
+	"contourguessr-api/tokens"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testGuessIDs atomic.Int64
+
+// guessEventBody is an event with fields for a new guess of the challenge,
+// with the result token the guess would have been answered with
+func guessEventBody(t *testing.T, challengeID string, fields string) string {
+	t.Helper()
+	token, err := signer.Sign(resultClaims{
+		Kind:        "result",
+		ChallengeID: challengeID,
+		GuessID:     testGuessIDs.Add(1),
+		Expires:     time.Now().Add(resultTokenTTL).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return `{"challenge_id": "` + challengeID + `", "result_token": "` + token + `", ` + fields + `}`
+}
+
+// setupGuessEvents gives guess event tests a signer and shared store
+func setupGuessEvents(t *testing.T) {
+	t.Helper()
+	keepServerState(t)
+	setupFixtureRepo(t)
+	signer = tokens.NewSigner([]byte("test secret"))
+	sharedStore = shared.NewMemory()
+}
+
+func TestPostGuessEvent(t *testing.T) {
+	setupGuessEvents(t)
+	c := fixtureChallenge(t, "Snowdon")
+
+	body := guessEventBody(t, c.ID, `"lng": -4.0, "lat": 53.0, "hints_used": 2, "client_version": "web 2.4.0"`)
+	rec := serveFixtureRequest(t, "POST", "/api/v1/events/guess", body)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serveFixtureRequest(t, "POST", "/api/v1/events/guess", body); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a second event for the guess, got %d", rec.Code)
+	}
+
+	rec = serveFixtureRequest(t, "POST", "/api/v1/events/guess",
+		`{"challenge_id": "`+c.ID+`", "lng": -4.0, "lat": 53.0, "client_version": "web 2.4.0"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a token, got %d", rec.Code)
+	}
+	rec = serveFixtureRequest(t, "POST", "/api/v1/events/guess",
+		`{"challenge_id": "`+c.ID+`", "result_token": "forged", "lng": -4.0, "lat": 53.0, "client_version": "web 2.4.0"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a forged token, got %d", rec.Code)
+	}
+	other := fixtureChallenge(t, "Scafell")
+	rec = serveFixtureRequest(t, "POST", "/api/v1/events/guess",
+		strings.Replace(guessEventBody(t, other.ID, `"lng": -4.0, "lat": 53.0, "client_version": "web 2.4.0"`), other.ID, c.ID, 1))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for another challenge's token, got %d", rec.Code)
+	}
+
+	rec = serveFixtureRequest(t, "POST", "/api/v1/events/guess",
+		guessEventBody(t, "zzzzzz", `"lng": -4.0, "lat": 53.0, "client_version": "web 2.4.0"`))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown challenge, got %d", rec.Code)
+	}
+
+	rec = serveFixtureRequest(t, "POST", "/api/v1/events/guess", guessEventBody(t, c.ID, `"lng": -4.0, "lat": 53.0`))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a client version, got %d", rec.Code)
+	}
+}
+
+func TestPostGuessEventWithPlayToken(t *testing.T) {
+	setupGuessEvents(t)
+	c := fixtureChallenge(t, "Snowdon")
+	token, err := newPlayToken(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"challenge_id": "` + c.ID + `", "play_token": "` + token + `", "lng": -4.0, "lat": 53.0, "client_version": "test"}`
+
+	if rec := serveFixtureRequest(t, "POST", "/api/v1/events/guess", body); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 before the token has been guessed with, got %d", rec.Code)
+	}
+	if _, err := redeemPlayToken(t.Context(), token, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serveFixtureRequest(t, "POST", "/api/v1/events/guess", body); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 once the token has been guessed with, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serveFixtureRequest(t, "POST", "/api/v1/events/guess", body); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a second event, got %d", rec.Code)
+	}
+}
+
+func TestPostGuessEventIsThrottled(t *testing.T) {
+	setupGuessEvents(t)
+	revealLimit = rateLimit{rate: 0.001, burst: 1}
+	revealLimiter = newRateLimiter(revealLimit, revealLimit)
+	c := fixtureChallenge(t, "Snowdon")
+
+	for i, want := range []int{http.StatusNoContent, http.StatusTooManyRequests} {
+		rec := serveFixtureRequest(t, "POST", "/api/v1/events/guess",
+			guessEventBody(t, c.ID, `"lng": -4.0, "lat": 53.0, "client_version": "test"`))
+		if rec.Code != want {
+			t.Errorf("event %d: expected %d, got %d", i, want, rec.Code)
+		}
+	}
+}
+
+func TestScoreBandEdges(t *testing.T) {
+	edges := scoreBandEdges()
+	if len(edges) != maxRoundScore/scoreBandWidth-1 {
+		t.Fatalf("expected %d edges, got %d", maxRoundScore/scoreBandWidth-1, len(edges))
+	}
+	for i, edge := range edges {
+		minScore := maxRoundScore - (i+1)*scoreBandWidth
+		if got := scoreForDistance(edge - 1); got < minScore {
+			t.Errorf("expected a score of at least %d just inside edge %d, got %d", minScore, i, got)
+		}
+		if got := scoreForDistance(edge + 1); got >= minScore {
+			t.Errorf("expected a score below %d just beyond edge %d, got %d", minScore, i, got)
+		}
+	}
+}
+
+func TestGetChallengeStats(t *testing.T) {
+	setupGuessEvents(t)
+	c := fixtureChallenge(t, "Snowdon")
+
+	// Right on it, a few km off and across the country
+	for _, lngLat := range [][2]string{{"-4.0765", "53.0685"}, {"-4.0", "53.0"}, {"-1.5", "52.0"}} {
+		rec := serveFixtureRequest(t, "POST", "/api/v1/events/guess",
+			guessEventBody(t, c.ID, `"lng": `+lngLat[0]+`, "lat": `+lngLat[1]+`, "client_version": "test"`))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	rec := serveFixtureRequest(t, "GET", "/api/v1/challenge/"+c.ID+"/stats", "")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without a reveal token, got %d", rec.Code)
+	}
+
+	play, err := signer.Sign(playClaims{Kind: "play", ChallengeID: c.ID, Nonce: "n", Expires: time.Now().Add(playTokenTTL).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = serveFixtureRequest(t, "GET", "/api/v1/challenge/"+c.ID+"/stats?reveal_token="+play, "")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 with a play token, got %d", rec.Code)
+	}
+
+	token, err := signer.Sign(revealClaims{Kind: "practice", ChallengeID: c.ID, Expires: time.Now().Add(revealTokenTTL).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = serveFixtureRequest(t, "GET", "/api/v1/challenge/"+c.ID+"/stats?distance_m=20000&reveal_token="+token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		Plays             int64       `json:"plays"`
+		MedianDistanceM   float64     `json:"median_distance_m"`
+		ScoreDistribution []scoreBand `json:"score_distribution"`
+		Percentile        *int        `json:"percentile"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Plays != 3 || got.MedianDistanceM < 1000 || got.MedianDistanceM > 20_000 {
+		t.Errorf("expected 3 plays with the middle one a few km off, got %+v", got)
+	}
+	var total int64
+	for _, band := range got.ScoreDistribution {
+		total += band.Plays
+	}
+	if len(got.ScoreDistribution) != 10 || total != 3 || got.ScoreDistribution[0].MaxScore != maxRoundScore || got.ScoreDistribution[9].MinScore != 0 {
+		t.Errorf("unexpected score distribution %+v", got.ScoreDistribution)
+	}
+	if got.Percentile == nil || *got.Percentile != 33 {
+		t.Errorf("expected to be better than only the guess across the country, got %+v", got)
+	}
+}
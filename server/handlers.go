@@ -0,0 +1,1513 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"contourguessr-api/astro"
+	"contourguessr-api/elevation"
+	"contourguessr-api/geo"
+	"contourguessr-api/geocode"
+	"contourguessr-api/pacing"
+	"contourguessr-api/probe"
+	"contourguessr-api/repos"
+	"contourguessr-api/shared"
+	"contourguessr-api/tokens"
+	"contourguessr-api/weather"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var repo repos.Store
+var signer *tokens.Signer
+var weatherClient *weather.Client
+var geocodeClient *geocode.Client
+var elevationClient *elevation.Client
+var sharedStore shared.Store
+var pacingConfig atomic.Pointer[pacing.Config]
+
+const placeCacheTTL = 90 * 24 * time.Hour
+
+var adminToken string
+
+// Feature flags, see config.Config
+var features map[string]bool
+var outboundAllowedHosts []string
+
+var outboundClicksCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "outbound_clicks_total",
+		Help:      "Number of outbound link redirects partitioned by allowlisted host",
+	},
+	[]string{"host"},
+)
+
+var outboundClicksOverQuotaCounter = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "outbound_clicks_over_quota_total",
+		Help:      "Outbound link redirects that weren't recorded because the client was over its quota",
+	},
+)
+
+// A client following more outbound links than this an hour is still
+// redirected, but its clicks stop being recorded so it can't skew the counts
+const (
+	outboundClickQuota       = 100
+	outboundClickQuotaWindow = time.Hour
+)
+
+const revealTokenTTL = 24 * time.Hour
+
+type revealClaims struct {
+	Kind        string `json:"k"`
+	ChallengeID string `json:"c"`
+	GuessID     int64  `json:"g,omitempty"`
+	Expires     int64  `json:"exp"`
+}
+
+var InvalidRevealTokenError = errors.New("invalid reveal token")
+
+// verifyRevealToken checks the token was given out by a guess on the
+// challenge and hasn't expired. Every kind of token is signed with the same
+// key, so the kind is what keeps a play token from being used to reveal the
+// answer before guessing.
+func verifyRevealToken(token string, challengeID string) (revealClaims, error) {
+	var claims revealClaims
+	if err := signer.Verify(token, &claims); err != nil || (claims.Kind != "guess" && claims.Kind != "practice") {
+		return revealClaims{}, InvalidRevealTokenError
+	}
+	if claims.ChallengeID != challengeID || time.Now().Unix() > claims.Expires {
+		return revealClaims{}, InvalidRevealTokenError
+	}
+	return claims, nil
+}
+
+var imageHostUpGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "contourguessr",
+		Name:      "image_host_up",
+		Help:      "Whether the last probe of an image host succeeded",
+	},
+	[]string{"host"},
+)
+
+var _ = promauto.NewCounterFunc(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "challenges_skipped_image_host_down_total",
+		Help:      "Random picks redrawn because the challenge's image host was down",
+	},
+	func() float64 {
+		if repo == nil {
+			return 0
+		}
+		return float64(repo.SkippedForDownHosts())
+	},
+)
+
+var _ = promauto.NewCounterFunc(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "capabilities_breaking_changes_total",
+		Help:      "Capabilities refreshes that changed a layer or tile matrix set in use",
+	},
+	func() float64 {
+		if repo == nil {
+			return 0
+		}
+		return float64(repo.BreakingCapabilitiesChanges())
+	},
+)
+
+var challengesPerRegionGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "contourguessr",
+		Name:      "challenges_per_region",
+		Help:      "Number of challenges partitioned by region",
+	},
+	[]string{"region"},
+)
+
+// registerRoutes adds every endpoint to router. v1Replaced wraps the v1
+// endpoints that have a v2 replacement.
+func registerRoutes(router *mux.Router, v1Replaced func(http.HandlerFunc) http.Handler) {
+	router.HandleFunc("/livez", handleLivez)
+	router.HandleFunc("/readyz", handleReadyz)
+	router.HandleFunc("/out", handleOutboundRedirect).Methods("GET")
+	router.HandleFunc("/calendar.ics", handleGetCalendar).Methods("GET")
+	router.HandleFunc("/api/oembed", handleGetOEmbed).Methods("GET")
+	router.Handle("/metrics", promhttp.Handler())
+	router.HandleFunc("/openapi.json", handleGetOpenAPI).Methods("GET")
+	router.HandleFunc("/docs", handleGetDocs).Methods("GET")
+	router.HandleFunc(protoSchemaPath, handleGetProtoSchema).Methods("GET")
+
+	router.HandleFunc("/debug/challenge", handleDebugChallenge).Methods("GET")
+
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(adminAuthMiddleware)
+	admin.HandleFunc("/region/{id}/advisory", handlePutRegionAdvisory).Methods("PUT")
+	admin.HandleFunc("/region/{id}/advisory", handleDeleteRegionAdvisory).Methods("DELETE")
+	admin.HandleFunc("/region/{id}/translation/{lang}", handlePutRegionTranslation).Methods("PUT")
+	admin.HandleFunc("/region/{id}/translation/{lang}", handleDeleteRegionTranslation).Methods("DELETE")
+	admin.HandleFunc("/region/{id}/privacy-zone", handleGetPrivacyZones).Methods("GET")
+	admin.HandleFunc("/region/{id}/privacy-zone/{name}", handlePutPrivacyZone).Methods("PUT")
+	admin.HandleFunc("/region/{id}/privacy-zone/{name}", handleDeletePrivacyZone).Methods("DELETE")
+	admin.HandleFunc("/cdn-probe", handleCDNProbe).Methods("GET")
+	admin.HandleFunc("/capabilities-changes", handleGetCapabilitiesChanges).Methods("GET")
+	admin.HandleFunc("/pacing", handleGetPacingConfig).Methods("GET")
+	admin.HandleFunc("/pacing", handlePutPacingConfig).Methods("PUT")
+	admin.HandleFunc("/partner", handleCreatePartner).Methods("POST")
+	admin.HandleFunc("/api-key", handleGetAPIKeys).Methods("GET")
+	admin.HandleFunc("/api-key", handleCreateAPIKey).Methods("POST")
+	admin.HandleFunc("/api-key/{id}", handlePutAPIKey).Methods("PUT")
+	admin.HandleFunc("/api-key/{id}", handleDeleteAPIKey).Methods("DELETE")
+	admin.HandleFunc("/opt-out", handleGetOptOuts).Methods("GET")
+	admin.HandleFunc("/opt-out/{id}", handlePutOptOut).Methods("PUT")
+	admin.HandleFunc("/moderation", handleGetModerationQueue).Methods("GET")
+	admin.HandleFunc("/challenge/{id}/moderation", handlePutChallengeModeration).Methods("PUT")
+	admin.HandleFunc("/challenge/{id}/archive", handleArchiveChallenge).Methods("POST")
+	admin.HandleFunc("/challenge/{id}/restore", handleRestoreChallenge).Methods("POST")
+	admin.HandleFunc("/challenge/{id}/slug", handlePutChallengeSlug).Methods("PUT")
+	admin.HandleFunc("/challenge/{id}/slug", handleDeleteChallengeSlug).Methods("DELETE")
+	admin.HandleFunc("/event", handleGetScheduledEvents).Methods("GET")
+	admin.HandleFunc("/event", handleCreateScheduledEvent).Methods("POST")
+	admin.HandleFunc("/event/{id}", handleDeleteScheduledEvent).Methods("DELETE")
+	admin.HandleFunc("/popularity", handleGetPopularity).Methods("GET")
+	mountPprof(admin)
+
+	export := router.PathPrefix("/api/v1/export").Subrouter()
+	export.Use(adminAuthMiddleware)
+	export.HandleFunc("/challenges.csv", handleExportChallengesCSV).Methods("GET")
+
+	partner := router.PathPrefix("/partner/v1").Subrouter()
+	partner.Use(partnerAuthMiddleware)
+	partner.HandleFunc("/challenge/{id}/flag", handleFlagChallenge).Methods("POST")
+
+	router.Handle("/api/v1/region", v1Replaced(handleGetRegions)).Methods("GET")
+	// The answers to every challenge in the region, so for curators only
+	router.Handle("/api/v1/region/{id}/challenges.geojson", adminAuthMiddleware(http.HandlerFunc(handleExportRegionGeoJSON))).Methods("GET")
+	router.HandleFunc("/api/v1/region/{id}/feed.atom", handleGetRegionFeed).Methods("GET")
+	router.HandleFunc("/api/v1/pacing", handleGetPacing).Methods("GET")
+	router.HandleFunc(eventsRoute, handleGetEvents).Methods("GET")
+	router.HandleFunc(eventsRoute+"/guess", handlePostGuessEvent).Methods("POST")
+	router.HandleFunc("/api/v1/campaign/{region}", handleGetCampaign).Methods("GET")
+	router.HandleFunc("/api/v1/campaign/{region}", handleStartCampaign).Methods("POST")
+	router.HandleFunc("/api/v1/campaign/{region}/advance", handleAdvanceCampaign).Methods("POST")
+	router.HandleFunc("/api/v1/player", handleCreatePlayer).Methods("POST")
+	router.HandleFunc("/api/v1/player/me/best", handleGetBestRounds).Methods("GET")
+	router.HandleFunc("/api/v1/leaderboard/{board}", handleGetLeaderboard).Methods("GET")
+	router.HandleFunc("/api/v1/leaderboard/{board}", handlePostScore).Methods("POST")
+	router.HandleFunc("/api/v1/game/gpx", handlePostGameGPX).Methods("POST")
+	router.HandleFunc("/api/v1/world-tour", handleGetWorldTour).Methods("GET")
+	router.HandleFunc("/api/v1/geo/distance", handleGetGeoDistance).Methods("GET")
+	router.HandleFunc("/api/v1/custom-game", handleCreateCustomGame).Methods("POST")
+	router.HandleFunc("/api/v1/custom-game/{code}", handleGetCustomGame).Methods("GET")
+	router.HandleFunc("/api/v1/challenge/search", handleSearchChallenges).Methods("GET")
+	router.Handle("/api/v1/challenge/random", v1Replaced(handleGetRandomChallenge)).Methods("GET")
+	router.HandleFunc("/api/v1/challenge/on-this-day", handleGetOnThisDay).Methods("GET")
+	router.Handle("/api/v1/challenge/{id}", v1Replaced(handleGetChallenge)).Methods("GET")
+	router.Handle("/api/v1/challenge/{id}/guess", v1Replaced(handlePostGuess)).Methods("POST")
+	router.Handle("/api/v1/challenge/{id}/full-metadata", v1Replaced(handleGetChallengeFullMetadata)).Methods("GET")
+	router.HandleFunc("/api/v1/challenge/{id}/hint", handleGetChallengeHint).Methods("GET")
+	router.HandleFunc("/api/v1/challenge/{id}/stats", handleGetChallengeStats).Methods("GET")
+	router.HandleFunc("/api/v1/challenge/{id}/heatmap.geojson", handleGetGuessHeatmap).Methods("GET")
+	router.HandleFunc("/api/v1/photo/{id}/{size}", handleGetPhoto).Methods("GET")
+	router.HandleFunc("/api/v1/link/{id}/{kind}", handleGetChallengeLink).Methods("GET")
+	router.HandleFunc("/api/v1/photographer/opt-out", handlePostOptOut).Methods("POST")
+	router.HandleFunc("/api/v1/photographer/{id}", handleGetPhotographer).Methods("GET")
+	mountAPIv2(router)
+}
+
+func handleGetRegions(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	date := v.queryDate("date", time.Time{})
+	langs := requestLanguages(v, r)
+	if !v.valid(w) {
+		return
+	}
+
+	// Seasonal map layers are picked for today unless the client is showing a
+	// historical challenge, in which case they follow the photo's date
+	modTime := repo.RegionsUpdatedAt()
+	if date.IsZero() {
+		date = time.Now().UTC()
+		if id := r.URL.Query().Get("challenge"); id != "" {
+			challenge, err := repo.Challenge(id)
+			if errors.Is(err, repos.ChallengeNotFoundError) {
+				httpError(w, r, "challenge not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				httpError(w, r, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if challenge.DateTaken != nil {
+				date = *challenge.DateTaken
+			}
+		} else if today := date.Truncate(24 * time.Hour); today.After(modTime) {
+			modTime = today
+		}
+	}
+
+	var encoded repos.EncodedRegions
+	var err error
+	if apiVersion(r) >= 2 {
+		// Every seasonal layer is listed, so the date doesn't matter
+		encoded, err = repo.RegionsV2JSON(langs)
+		modTime = repo.RegionsUpdatedAt()
+	} else {
+		encoded, err = repo.RegionsJSON(date, langs)
+	}
+	if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	body := encoded.Body
+	format := responseFormat(w, r)
+	switch format {
+	case protobufContentType:
+		var regions *cachedProtoRegions
+		regions, err = protoRegions()
+		if regions != nil {
+			body = regions.body
+		}
+		setProtobufHeaders(w, "contourguessr.v1.ListRegionsResponse")
+	case msgpackContentType:
+		body, err = msgpackRegionsBody(encoded)
+		w.Header().Set("Content-Type", msgpackContentType)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error encoding regions", "format", format, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Protobuf regions aren't localized yet
+	w.Header().Add("Vary", "Accept-Language")
+	if encoded.Language != "" && format != protobufContentType {
+		w.Header().Set("Content-Language", encoded.Language)
+	}
+	w.Header().Set("ETag", formatETag(encoded.ETag, format))
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(body))
+}
+
+func handleGetRandomChallenge(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.queryOptionalInt("region")
+	player := v.queryString("player", false, 128)
+	seq := v.queryUint64("seq")
+	if !v.valid(w) {
+		return
+	}
+
+	// Players that identify themselves get every challenge once before repeats,
+	// stepping through their personal ordering with seq
+	var challenge repos.Challenge
+	var err error
+	if player != "" {
+		challenge, err = repo.PlayerChallenge(regionID, player, seq)
+	} else {
+		challenge, err = repo.RandomChallenge(regionID)
+	}
+	if errors.Is(err, repos.NoChallengesAvailableError) {
+		httpError(w, r, "no challenges available", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	recordServed(challenge)
+	writeChallenge(w, r, challenge)
+}
+
+func handleGetOnThisDay(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.queryOptionalInt("region")
+	date := v.queryDate("date", time.Now().UTC())
+	limit := v.queryInt("limit", 20, 1, 100)
+	if !v.valid(w) {
+		return
+	}
+
+	challenges := repo.OnThisDay(date, regionID)
+	if len(challenges) > limit {
+		challenges = challenges[:limit]
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"date":"` + date.Format("01-02") + `","challenges":[`)
+	for i, challenge := range challenges {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, err := repo.ChallengeJSON(r.Context(), challenge)
+		if err != nil {
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		buf.Write(b)
+	}
+	buf.WriteString(`]}`)
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// handleGetChallenge also accepts the slugs admins give curated challenges, so
+// that articles can link to them by name
+func handleGetChallenge(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if repos.ValidSlug(id) {
+		resolved, err := repo.ChallengeIDBySlug(r.Context(), id)
+		if errors.Is(err, repos.SlugNotFoundError) {
+			httpError(w, r, "challenge not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "error resolving slug", "slug", id, "err", err)
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		id = resolved
+	}
+	challenge, err := repo.Challenge(id)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	recordServed(challenge)
+	writeChallenge(w, r, challenge)
+}
+
+func handlePostGuess(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var body struct {
+		Lng      float64 `json:"lng"`
+		Lat      float64 `json:"lat"`
+		Practice bool    `json:"practice"`
+		// Optional, attributes the guess to the player's history
+		Player string `json:"player"`
+		// The result token of the game's previous round, if any
+		PrevResult string `json:"prev_result"`
+		// Starts a world tour or custom game, in place of prev_result on the
+		// first round
+		Tour string `json:"tour"`
+		// From the v2 challenge response
+		PlayToken string `json:"play_token"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.check(body.Lng >= -180 && body.Lng <= 180, "lng", "out_of_range", "lng must be between -180 and 180")
+		v.check(body.Lat >= -90 && body.Lat <= 90, "lat", "out_of_range", "lat must be between -90 and 90")
+		v.bodyString("player", body.Player, false, 128)
+	}
+	if !v.valid(w) || !throttleReveals(w, r, body.Player) {
+		return
+	}
+
+	challenge, err := repo.Challenge(id)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Tokens are always required in v2, and in v1 once the play_tokens flag
+	// is on. They're redeemed whenever they're sent, even where they aren't
+	// required, as they carry the hints asked for.
+	var play playClaims
+	if body.PlayToken != "" || apiVersion(r) >= 2 || feature(r, "play_tokens") {
+		play, err = redeemPlayToken(r.Context(), body.PlayToken, challenge.ID)
+		if errors.Is(err, InvalidPlayTokenError) {
+			httpError(w, r, "invalid play_token", http.StatusForbidden)
+			return
+		} else if errors.Is(err, PlayTokenUsedError) {
+			httpError(w, r, "play_token already used", http.StatusConflict)
+			return
+		} else if writeContextError(w, r, err) {
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "error redeeming play token", "challenge", id, "err", err)
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	distance := geo.Distance(geo.Point{Lng: body.Lng, Lat: body.Lat}, geo.Point{Lng: challenge.Geo.Lng, Lat: challenge.Geo.Lat})
+	out := map[string]interface{}{
+		"geo":        challenge.Geo,
+		"distance_m": math.Round(distance),
+		"score":      scoreForDistance(distance),
+	}
+
+	claims := revealClaims{
+		Kind:        "practice",
+		ChallengeID: challenge.ID,
+		Expires:     time.Now().Add(revealTokenTTL).Unix(),
+	}
+	if !body.Practice {
+		// Otherwise the hints asked for with a token would go unpenalized
+		if play.Nonce == "" {
+			hinted, err := clientWasHinted(r.Context(), r, challenge.ID, body.Player)
+			if writeContextError(w, r, err) {
+				return
+			} else if err != nil {
+				slog.ErrorContext(r.Context(), "error checking for hints", "challenge", id, "err", err)
+				httpError(w, r, "internal server error", http.StatusInternalServerError)
+				return
+			} else if hinted {
+				httpError(w, r, "play_token required after a hint", http.StatusForbidden)
+				return
+			}
+		}
+
+		var result resultClaims
+		if body.Tour != "" && body.PrevResult == "" {
+			result, err = startTour(body.Tour)
+		} else {
+			result, err = nextResult(body.PrevResult)
+		}
+		if err != nil {
+			httpError(w, r, "invalid prev_result", http.StatusBadRequest)
+			return
+		}
+		if result.Tour != nil {
+			if result.Round > len(result.Tour) || result.Tour[result.Round-1] != challenge.ID {
+				httpError(w, r, "challenge is not next in tour", http.StatusBadRequest)
+				return
+			}
+		}
+		err = claimNextRound(r.Context(), body.PrevResult)
+		if errors.Is(err, ResultAlreadyExtendedError) {
+			httpError(w, r, "prev_result already used", http.StatusConflict)
+			return
+		} else if writeContextError(w, r, err) {
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "error claiming next round", "challenge", id, "err", err)
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// The token carries the hints asked for with it, and the record
+		// catches an older copy being sent from before the last hint
+		hintLevel := play.HintLevel
+		if play.Nonce != "" {
+			recorded, err := repo.HintLevel(r.Context(), id, play.Nonce)
+			if writeContextError(w, r, err) {
+				return
+			} else if err != nil {
+				slog.ErrorContext(r.Context(), "error getting hint level", "challenge", id, "err", err)
+				httpError(w, r, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			hintLevel = max(hintLevel, recorded)
+		}
+
+		guessID, err := repo.RecordGuess(r.Context(), id, body.Player, body.Lng, body.Lat)
+		if writeContextError(w, r, err) {
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "error recording guess", "challenge", id, "err", err)
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		claims.Kind = "guess"
+		claims.GuessID = guessID
+
+		result.ChallengeID = challenge.ID
+		result.GuessID = guessID
+		result.DistanceM = math.Round(distance)
+		result.Score = scoreForDistance(distance)
+		if result.Mode == worldTourMode {
+			result.Score = scoreForDistanceIn(distance, regionScoreScale(challenge))
+			out["score"] = result.Score
+		}
+		if hintLevel > 0 {
+			result.Score = penalizeHints(result.Score, hintLevel)
+			result.HintLevel = hintLevel
+			out["score"] = result.Score
+			out["hint_level"] = hintLevel
+		}
+		result.Expires = time.Now().Add(resultTokenTTL).Unix()
+		resultToken, err := signer.Sign(result)
+		if err != nil {
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		out["result_token"] = resultToken
+		out["round"] = result.Round
+	}
+
+	token, err := signer.Sign(claims)
+	if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	out["reveal_token"] = token
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// handleGetBestRounds lists the closest guesses of the player the bearer token
+// was issued for. Each links to the challenge so it can be replayed with
+// practice guesses.
+func handleGetBestRounds(w http.ResponseWriter, r *http.Request) {
+	player, ok := requestPlayer(r)
+	if !ok {
+		httpError(w, r, "valid player token required", http.StatusUnauthorized)
+		return
+	}
+	v := newValidator(r)
+	limit := v.queryInt("limit", 20, 1, 100)
+	if !v.valid(w) {
+		return
+	}
+
+	rounds, err := repo.BestRounds(r.Context(), player, limit)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting best rounds", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type entry struct {
+		repos.BestRound
+		ReplayURL string `json:"replay_url"`
+	}
+	out := make([]entry, 0, len(rounds))
+	for _, round := range rounds {
+		out = append(out, entry{
+			BestRound: round,
+			ReplayURL: "/api/v1/challenge/" + round.ChallengeID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+var validLeaderboard = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+
+func (v *validator) pathLeaderboard() string {
+	board := mux.Vars(v.r)["board"]
+	if !validLeaderboard.MatchString(board) {
+		v.add("path", "board", "invalid_value", "board must be 1-32 lowercase letters, digits or dashes")
+		return ""
+	}
+	return board
+}
+
+// handlePostScore adds a finished game to a leaderboard. The client sends the
+// result token from every round, and the total is worked out from those
+// rather than trusted from the client.
+func handlePostScore(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	board := v.pathLeaderboard()
+
+	var body struct {
+		Player  string   `json:"player"`
+		Name    string   `json:"name"`
+		Results []string `json:"results"`
+	}
+	if v.decodeBody(&body) {
+		body.Name = strings.TrimSpace(body.Name)
+		v.bodyString("player", body.Player, true, 128)
+		v.bodyString("name", body.Name, true, 64)
+		v.check(len(body.Results) > 0, "results", "required", "results is required")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	// Scores can't be trusted from players who were revealing answers faster
+	// than they could have played
+	flagged, err := revealClientFlagged(r.Context(), "player:"+body.Player)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking reveal flag", "err", err)
+	} else if flagged {
+		httpError(w, r, "player was flagged for revealing answers too quickly", http.StatusForbidden)
+		return
+	}
+
+	game, rounds, total, err := verifyResultChain(body.Results)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if rounds[0].Mode != boardMode(board) {
+		httpErrorCode(w, r, "wrong_mode", "game was not played in this leaderboard's mode", http.StatusBadRequest)
+		return
+	}
+
+	err = repo.SubmitScore(r.Context(), board, game, body.Player, body.Name, total, len(rounds))
+	if errors.Is(err, repos.GameAlreadySubmittedError) {
+		httpError(w, r, "game already submitted", http.StatusConflict)
+		return
+	} else if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error submitting score", "board", board, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"game":   game,
+		"total":  total,
+		"rounds": len(rounds),
+	})
+}
+
+func handleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	board := v.pathLeaderboard()
+	if !v.valid(w) {
+		return
+	}
+
+	entries, err := repo.Leaderboard(r.Context(), board, 50)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting leaderboard", "board", board, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+func handleGetChallengeFullMetadata(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	claims, err := verifyRevealToken(r.URL.Query().Get("reveal_token"), id)
+	if err != nil {
+		httpError(w, r, "valid reveal_token required", http.StatusForbidden)
+		return
+	}
+
+	challenge, err := repo.Challenge(id)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	description, err := repo.ChallengeDescription(r.Context(), id)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting description", "challenge", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":               challenge.ID,
+		"title":            challenge.Title,
+		"description_html": description,
+		"photographer":     challenge.Photographer,
+		"weather":          challengeWeather(r.Context(), challenge),
+		"astronomy":        challengeAstronomy(challenge),
+		"nearby":           challengeNearby(r.Context(), challenge),
+		"landmarks":        challengeLandmarks(r.Context(), challenge),
+		"elevation":        revealElevation(r.Context(), challenge, claims.GuessID),
+		"place":            challengePlace(r.Context(), challenge),
+	})
+}
+
+func challengePlace(ctx context.Context, challenge repos.Challenge) json.RawMessage {
+	cached, ok, err := repo.CachedGeocode(ctx, "reverse", challenge.Geo.Lng, challenge.Geo.Lat)
+	if err != nil {
+		slog.ErrorContext(ctx, "error getting cached place", "challenge", challenge.ID, "err", err)
+		return nil
+	} else if ok {
+		return cached
+	}
+
+	place, err := geocodeClient.Reverse(ctx, challenge.Geo.Lng, challenge.Geo.Lat)
+	if err != nil {
+		slog.ErrorContext(ctx, "error reverse geocoding", "challenge", challenge.ID, "err", err)
+		return nil
+	}
+
+	b, err := json.Marshal(place)
+	if err != nil {
+		return nil
+	}
+	err = repo.SetCachedGeocode(ctx, "reverse", challenge.Geo.Lng, challenge.Geo.Lat, b, placeCacheTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "error caching place", "challenge", challenge.ID, "err", err)
+	}
+	return b
+}
+
+func challengeLandmarks(ctx context.Context, challenge repos.Challenge) *repos.Landmarks {
+	landmarks, err := repo.ChallengeLandmarks(ctx, challenge.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error getting landmarks", "challenge", challenge.ID, "err", err)
+		return nil
+	}
+	return &landmarks
+}
+
+func challengeNearby(ctx context.Context, challenge repos.Challenge) []repos.NearbyPOI {
+	nearby, err := repo.ChallengeNearby(ctx, challenge.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error getting nearby pois", "challenge", challenge.ID, "err", err)
+		return nil
+	}
+	return nearby
+}
+
+func challengeAstronomy(challenge repos.Challenge) *astro.Context {
+	if challenge.DateTaken == nil {
+		return nil
+	}
+	t := challenge.DateTaken.UTC()
+	if challenge.Timezone == "" {
+		t = astro.LocalMeanTimeToUTC(*challenge.DateTaken, challenge.Geo.Lng)
+	}
+	out, ok := astro.Describe(t, challenge.Geo.Lng, challenge.Geo.Lat)
+	if !ok {
+		return nil
+	}
+	return &out
+}
+
+func challengeWeather(ctx context.Context, challenge repos.Challenge) json.RawMessage {
+	if challenge.DateTaken == nil {
+		return nil
+	}
+
+	cached, ok, err := repo.ChallengeWeather(ctx, challenge.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error getting cached weather", "challenge", challenge.ID, "err", err)
+		return nil
+	} else if ok {
+		return cached
+	}
+
+	conditions, err := weatherClient.Historic(ctx, challenge.Geo.Lng, challenge.Geo.Lat, *challenge.DateTaken)
+	if err != nil {
+		slog.ErrorContext(ctx, "error fetching weather", "challenge", challenge.ID, "err", err)
+		return nil
+	}
+
+	b, err := json.Marshal(conditions)
+	if err != nil {
+		return nil
+	}
+	if err := repo.SetChallengeWeather(ctx, challenge.ID, b); err != nil {
+		slog.ErrorContext(ctx, "error caching weather", "challenge", challenge.ID, "err", err)
+	}
+	return b
+}
+
+func handleOutboundRedirect(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	target := v.queryString("target", true, 2048)
+	u, err := url.Parse(target)
+	if target != "" && (err != nil || (u.Scheme != "https" && u.Scheme != "http") || !outboundHostAllowed(u.Hostname())) {
+		v.add("query", "target", "not_allowed", "target must be a link to an allowed host")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	recordOutboundClick(r, target, u.Hostname(), r.URL.Query().Get("challenge"))
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// recordOutboundClick counts a click through to target, recording it for the
+// challenge if the client is within its quota
+func recordOutboundClick(r *http.Request, target string, host string, challengeID string) {
+	outboundClicksCounter.WithLabelValues(outboundHostLabel(host)).Inc()
+	if outboundClickWithinQuota(r) {
+		if err := repo.RecordOutboundClick(r.Context(), target, challengeID); err != nil {
+			slog.ErrorContext(r.Context(), "error recording outbound click", "err", err)
+		}
+	} else {
+		outboundClicksOverQuotaCounter.Inc()
+	}
+}
+
+// outboundClickWithinQuota counts the click against the client's hourly
+// quota. If the shared store is unavailable the click is let through.
+func outboundClickWithinQuota(r *http.Request) bool {
+	window := time.Now().Truncate(outboundClickQuotaWindow).Unix()
+	key := fmt.Sprintf("outbound:%s:%d", clientIP(r), window)
+	n, err := sharedStore.Incr(r.Context(), key, outboundClickQuotaWindow)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error counting outbound click", "err", err)
+		return true
+	}
+	return n <= outboundClickQuota
+}
+
+// outboundHostAllowed matches allowlisted hosts and their subdomains
+func outboundHostAllowed(host string) bool {
+	return outboundHostLabel(host) != "other"
+}
+
+// outboundHostLabel returns the allowlist entry host matches, or "other", so
+// that the metric has one series per configured host rather than one per
+// subdomain
+func outboundHostLabel(host string) string {
+	host = strings.ToLower(host)
+	for _, allowed := range outboundAllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed != "" && (host == allowed || strings.HasSuffix(host, "."+allowed)) {
+			return allowed
+		}
+	}
+	return "other"
+}
+
+func handleDebugChallenge(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	info, err := repo.ChallengeDebugInfoJSON(r.Context(), id)
+	if writeContextError(w, r, err) {
+		return
+	} else if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting debug info", "challenge", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(info))
+}
+
+func handleGetCampaign(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	player, regionID := campaignParams(v)
+	if !v.valid(w) {
+		return
+	}
+	campaign, err := repo.Campaign(r.Context(), player, regionID)
+	writeCampaign(w, r, campaign, err)
+}
+
+func handleStartCampaign(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	player, regionID := campaignParams(v)
+	if !v.valid(w) {
+		return
+	}
+	campaign, err := repo.StartCampaign(r.Context(), player, regionID)
+	writeCampaign(w, r, campaign, err)
+}
+
+func handleAdvanceCampaign(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	player, regionID := campaignParams(v)
+	var body struct {
+		ChallengeID string `json:"challenge_id"`
+	}
+	if v.decodeBody(&body) {
+		v.bodyString("challenge_id", body.ChallengeID, true, 64)
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	campaign, err := repo.AdvanceCampaign(r.Context(), player, regionID, body.ChallengeID)
+	writeCampaign(w, r, campaign, err)
+}
+
+func campaignParams(v *validator) (string, int) {
+	return v.queryString("player", true, 128), v.pathInt("region")
+}
+
+func writeCampaign(w http.ResponseWriter, r *http.Request, campaign repos.Campaign, err error) {
+	if errors.Is(err, repos.CampaignNotFoundError) {
+		httpError(w, r, "campaign not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, repos.NoChallengesAvailableError) {
+		httpError(w, r, "no challenges available", http.StatusNotFound)
+		return
+	} else if errors.Is(err, repos.NotNextInCampaignError) {
+		httpError(w, r, "challenge is not next in campaign", http.StatusConflict)
+		return
+	} else if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "campaign error", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var next json.RawMessage
+	if campaign.Next != nil {
+		next, err = repo.ChallengeJSON(r.Context(), *campaign.Next)
+		if err != nil {
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		// GET only resumes the challenge already served by starting or
+		// advancing
+		if r.Method == http.MethodPost {
+			recordServed(*campaign.Next)
+		}
+	}
+
+	var badge map[string]interface{}
+	if campaign.CompletedAt != nil {
+		regionID, _ := strconv.Atoi(campaign.RegionID)
+		badge = map[string]interface{}{
+			"id":         "campaign-" + campaign.RegionID,
+			"name":       "Completed " + repo.Regions()[regionID].Name,
+			"awarded_at": campaign.CompletedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"region_id":    campaign.RegionID,
+		"total":        campaign.Total,
+		"completed":    campaign.Completed,
+		"started_at":   campaign.StartedAt,
+		"completed_at": campaign.CompletedAt,
+		"next":         next,
+		"badge":        badge,
+		"pacing":       pacingConfig.Load().For(r.URL.Query().Get("player")),
+	})
+}
+
+func handleGetPacing(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pacingConfig.Load().For(r.URL.Query().Get("player")))
+}
+
+func handleGetPacingConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pacingConfig.Load())
+}
+
+func handlePutPacingConfig(w http.ResponseWriter, r *http.Request) {
+	var config pacing.Config
+	v := newValidator(r)
+	if v.decodeBody(&config) {
+		if err := config.Validate(); err != nil {
+			v.add("body", "", "invalid_pacing_config", err.Error())
+		}
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	if err := repo.SetSetting(r.Context(), "pacing", config); err != nil {
+		slog.ErrorContext(r.Context(), "error saving pacing config", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	// Other replicas pick it up on their next refresh
+	pacingConfig.Store(&config)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handlePutRegionAdvisory(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.pathInt("id")
+
+	var advisory repos.RegionAdvisory
+	if v.decodeBody(&advisory) {
+		if advisory.AvalancheServiceURL != "" {
+			u, err := url.Parse(advisory.AvalancheServiceURL)
+			v.check(err == nil && (u.Scheme == "https" || u.Scheme == "http"), "avalanche_service_url", "invalid_url", "avalanche_service_url must be an http(s) URL")
+		}
+		for i, restriction := range advisory.SeasonalRestrictions {
+			_, fromErr := time.Parse("01-02", restriction.From)
+			_, toErr := time.Parse("01-02", restriction.To)
+			v.check(fromErr == nil && toErr == nil, fmt.Sprintf("seasonal_restrictions[%d]", i), "invalid_date", "expected MM-DD bounds")
+		}
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	err := repo.SetRegionAdvisory(r.Context(), regionID, advisory)
+	if errors.Is(err, repos.RegionNotFoundError) {
+		httpError(w, r, "region not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error setting advisory", "region", regionID, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDeleteRegionAdvisory(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.pathInt("id")
+	if !v.valid(w) {
+		return
+	}
+
+	if err := repo.DeleteRegionAdvisory(r.Context(), regionID); err != nil {
+		slog.ErrorContext(r.Context(), "error deleting advisory", "region", regionID, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetPrivacyZones(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.pathInt("id")
+	if !v.valid(w) {
+		return
+	}
+
+	zones, err := repo.PrivacyZones(r.Context(), regionID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting privacy zones", "region", regionID, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(zones)
+}
+
+func handlePutPrivacyZone(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.pathInt("id")
+	var zone repos.PrivacyZone
+	v.decodeBody(&zone)
+	if !v.valid(w) {
+		return
+	}
+	zone.Name = mux.Vars(r)["name"]
+
+	excluded, err := repo.SetPrivacyZone(r.Context(), regionID, zone)
+	if errors.Is(err, repos.InvalidZoneGeometryError) {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	} else if errors.Is(err, repos.RegionNotFoundError) {
+		httpError(w, r, "region not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error setting privacy zone", "region", regionID, "zone", zone.Name, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(excluded) > 0 {
+		slog.InfoContext(r.Context(), "privacy zone excludes published challenges", "region", regionID, "zone", zone.Name, "excluded", len(excluded))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"excluded_challenges": excluded,
+	})
+}
+
+func handleDeletePrivacyZone(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.pathInt("id")
+	if !v.valid(w) {
+		return
+	}
+	name := mux.Vars(r)["name"]
+
+	if err := repo.DeletePrivacyZone(r.Context(), regionID, name); err != nil {
+		slog.ErrorContext(r.Context(), "error deleting privacy zone", "region", regionID, "zone", name, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetCapabilitiesChanges lists recent changes to map layers'
+// capabilities, breaking or not, newest first
+func handleGetCapabilitiesChanges(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(repo.CapabilitiesChanges())
+}
+
+// handleCDNProbe checks whether the image hosts are healthy by requesting the
+// images of a sample of challenges from each region
+func handleCDNProbe(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	perRegion := v.queryInt("per_region", 5, 1, 50)
+	if !v.valid(w) {
+		return
+	}
+
+	var urls []string
+	for _, challenge := range repo.SampleChallenges(perRegion) {
+		for _, u := range []string{challenge.Src.Regular.Src, challenge.Src.Large.Src, challenge.Photographer.Icon} {
+			if u != "" {
+				urls = append(urls, u)
+			}
+		}
+	}
+
+	c := &http.Client{Timeout: 10 * time.Second}
+	hosts := probe.Probe(r.Context(), c, urls, 16)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"probed": len(urls),
+		"hosts":  hosts,
+	})
+}
+
+func handleCreatePartner(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.bodyString("name", body.Name, true, 128)
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	partner, token, err := repo.CreatePartner(r.Context(), body.Name)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating partner", "partner", body.Name, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":    partner.ID,
+		"name":  partner.Name,
+		"token": token,
+	})
+}
+
+var validModerationStates = map[string]bool{
+	string(repos.ModerationPublished):   true,
+	string(repos.ModerationQuarantined): true,
+	string(repos.ModerationCleared):     true,
+	string(repos.ModerationRemoved):     true,
+}
+
+func handleGetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	state := repos.ModerationQuarantined
+	if states := v.queryEnums("state", validModerationStates); len(states) == 1 {
+		state = repos.ModerationState(states[0])
+	} else if len(states) > 1 {
+		v.add("query", "state", "invalid_value", "state must be a single state")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	queue, err := repo.ModerationQueue(r.Context(), state)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting moderation queue", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(queue)
+}
+
+func handlePutChallengeModeration(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var body struct {
+		State repos.ModerationState `json:"state"`
+		Note  string                `json:"note"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.check(body.State != "", "state", "required", "state is required")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	err := repo.ReviewChallenge(r.Context(), id, body.State, body.Note)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, repos.InvalidModerationTransitionError) {
+		httpError(w, r, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error reviewing challenge", "challenge", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleArchiveChallenge(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.bodyString("reason", body.Reason, true, 1024)
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	err := repo.ArchiveChallenge(r.Context(), id, body.Reason)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error archiving challenge", "challenge", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRestoreChallenge(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := repo.RestoreChallenge(r.Context(), id)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "archived challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error restoring challenge", "challenge", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handlePutChallengeSlug(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var body struct {
+		Slug string `json:"slug"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.check(repos.ValidSlug(body.Slug), "slug", "invalid",
+			"slug must be lowercase words separated by hyphens, like ben-nevis-north-face")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	err := repo.SetChallengeSlug(r.Context(), id, body.Slug)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, repos.SlugTakenError) {
+		httpError(w, r, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error setting slug", "challenge", id, "slug", body.Slug, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDeleteChallengeSlug(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	found, err := repo.DeleteChallengeSlug(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error deleting slug", "challenge", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		httpError(w, r, "challenge has no slug", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleFlagChallenge lets land managers report a published challenge as
+// sensitive, for example because it shows a lek or an active nest site. The
+// challenge is withdrawn straight away and queued for review.
+func handleFlagChallenge(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	partner := r.Context().Value(partnerContextKey{}).(repos.Partner)
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		v.bodyString("reason", strings.TrimSpace(body.Reason), true, 1024)
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	state, err := repo.FlagChallenge(r.Context(), partner, id, body.Reason)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error flagging challenge", "challenge", id, "partner", partner.ID, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "partner flagged challenge", "challenge", id, "partner", partner.Name, "reason", body.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"challenge_id": id,
+		"state":        state,
+	})
+}
+
+type partnerContextKey struct{}
+
+func partnerAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			httpError(w, r, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		partner, err := repo.PartnerByToken(r.Context(), token)
+		if errors.Is(err, repos.PartnerNotFoundError) {
+			httpError(w, r, "unauthorized", http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "error authenticating partner", "err", err)
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		ctx := context.WithValue(r.Context(), partnerContextKey{}, partner)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func adminAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if adminToken == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			httpError(w, r, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func refreshPacingConfig() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var config pacing.Config
+	ok, err := repo.Setting(ctx, "pacing", &config)
+	if err != nil {
+		slog.Error("error loading pacing config", "err", err)
+		return
+	}
+	if ok {
+		pacingConfig.Store(&config)
+	}
+}
+
+// monitorImageHosts probes a sample of challenge images and takes hosts that
+// are mostly failing out of random selection until they recover
+func monitorImageHosts(interval time.Duration) {
+	c := &http.Client{Timeout: 10 * time.Second}
+	for range time.Tick(interval) {
+		var urls []string
+		for _, challenge := range repo.SampleChallenges(3) {
+			urls = append(urls, challenge.Src.Regular.Src)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		hosts := probe.Probe(ctx, c, urls, 8)
+		cancel()
+
+		wasDown := make(map[string]bool)
+		for _, host := range repo.ImageHostsDown() {
+			wasDown[host] = true
+		}
+		var down []string
+		for _, stats := range hosts {
+			isDown := stats.Requests >= 3 && stats.Errors*2 >= stats.Requests
+			if isDown {
+				down = append(down, stats.Host)
+				imageHostUpGauge.WithLabelValues(stats.Host).Set(0)
+			} else {
+				imageHostUpGauge.WithLabelValues(stats.Host).Set(1)
+			}
+			if isDown && !wasDown[stats.Host] {
+				slog.Warn("image host down, skipping its challenges", "host", stats.Host,
+					"errors", stats.Errors, "requests", stats.Requests, "examples", stats.Examples)
+			} else if !isDown && wasDown[stats.Host] {
+				slog.Info("image host recovered", "host", stats.Host)
+			}
+		}
+		repo.SetImageHostsDown(down)
+	}
+}
+
+func updateChallengesPerRegionCounter() {
+	ticker := time.NewTicker(1 * time.Second)
+	for range ticker.C {
+		counts := repo.ChallengesPerRegion()
+		for region, count := range counts {
+			challengesPerRegionGauge.WithLabelValues(strconv.Itoa(region)).Set(float64(count))
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bytes"
+	"contourguessr-api/repos"
+	"contourguessr-api/shared"
+	"contourguessr-api/tokens"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// setupFixtureRepo serves repos.DefaultFixtures for the rest of the test
+func setupFixtureRepo(t *testing.T) *repos.Memory {
+	t.Helper()
+	m := repos.NewMemory(repos.DefaultFixtures())
+	prev := repo
+	repo = m
+	t.Cleanup(func() {
+		repo = prev
+	})
+	return m
+}
+
+func serveFixtureRequest(t *testing.T, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	router := mux.NewRouter()
+	registerRoutes(router, func(h http.HandlerFunc) http.Handler { return h })
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func fixtureChallenge(t *testing.T, title string) repos.Challenge {
+	t.Helper()
+	list := repo.SearchChallenges(repos.ChallengeFilter{Query: title}, 1)
+	if len(list) == 0 {
+		t.Fatalf("no fixture challenge titled %q", title)
+	}
+	return list[0]
+}
+
+func TestHandleGetRegionsFromFixtures(t *testing.T) {
+	setupFixtureRepo(t)
+
+	rec := serveFixtureRequest(t, "GET", "/api/v1/region", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var regions []repos.Region
+	if err := json.Unmarshal(rec.Body.Bytes(), &regions); err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != 2 || regions[0].Name != "Snowdonia" || regions[1].Name != "Lake District" {
+		t.Errorf("expected both fixture regions in ID order, got %+v", regions)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Fatalf("expected a strong ETag, got %q", etag)
+	}
+
+	router := mux.NewRouter()
+	registerRoutes(router, func(h http.HandlerFunc) http.Handler { return h })
+	serveGzip := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/region", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rec := httptest.NewRecorder()
+		compressMiddleware(router).ServeHTTP(rec, req)
+		return rec
+	}
+	gzipped := serveGzip("")
+	gzipETag := gzipped.Header().Get("ETag")
+	if gzipped.Code != http.StatusOK || gzipETag != strings.TrimSuffix(etag, `"`)+`-gzip"` {
+		t.Fatalf("expected the gzipped body to have its own ETag, got %d %q", gzipped.Code, gzipETag)
+	}
+	if rec := serveGzip(gzipETag); rec.Code != http.StatusNotModified || rec.Header().Get("ETag") != gzipETag {
+		t.Errorf("expected the gzip ETag to match, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	if rec := serveGzip(etag); rec.Code != http.StatusNotModified || rec.Header().Get("ETag") != etag {
+		t.Errorf("expected the identity ETag to still match, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestHandleGetChallengeFromFixtures(t *testing.T) {
+	setupFixtureRepo(t)
+	c := fixtureChallenge(t, "Tryfan")
+
+	rec := serveFixtureRequest(t, "GET", "/api/v1/challenge/"+c.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var got repos.Challenge
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != c.ID || got.Title != "Tryfan north ridge" || got.DescriptionHTML != "<p>Tryfan north ridge</p>" {
+		t.Errorf("unexpected challenge %+v", got)
+	}
+
+	rec = serveFixtureRequest(t, "GET", "/api/v1/challenge/zzzzzz", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown challenge, got %d", rec.Code)
+	}
+}
+
+func TestHandlePostGuessFromFixtures(t *testing.T) {
+	keepServerState(t)
+	m := setupFixtureRepo(t)
+	signer = tokens.NewSigner([]byte("test secret"))
+	sharedStore = shared.NewMemory()
+	c := fixtureChallenge(t, "Scafell")
+
+	body, _ := json.Marshal(map[string]any{"lng": c.Geo.Lng, "lat": c.Geo.Lat + 0.01, "player": "p1"})
+	rec := serveFixtureRequest(t, "POST", "/api/v1/challenge/"+c.ID+"/guess", string(body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		DistanceM float64 `json:"distance_m"`
+		Score     int     `json:"score"`
+		Result    string  `json:"result_token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.DistanceM < 1100 || got.DistanceM > 1130 || got.Result == "" {
+		t.Errorf("expected a result about 1.1km off, got %+v", got)
+	}
+
+	best, err := m.BestRounds(t.Context(), "p1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(best) != 1 || best[0].ChallengeID != c.ID {
+		t.Errorf("expected the guess in the player's history, got %+v", best)
+	}
+}
+
+func TestHandleGetRegionFeedFromFixtures(t *testing.T) {
+	setupFixtureRepo(t)
+	siteURL = "https://contourguessr.org"
+	t.Cleanup(func() {
+		siteURL = ""
+	})
+
+	rec := serveFixtureRequest(t, "GET", "/api/v1/region/2/feed.atom", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	feed := rec.Body.String()
+	first := strings.Index(feed, "Langdale Pikes")
+	last := strings.Index(feed, "Scafell Pike summit")
+	if first < 0 || last < 0 || first > last {
+		t.Errorf("expected the region's challenges newest first, got\n%s", feed)
+	}
+	if strings.Contains(feed, "Tryfan") {
+		t.Error("expected only challenges in the region")
+	}
+
+	rec = serveFixtureRequest(t, "GET", "/api/v1/region/99/feed.atom", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown region, got %d", rec.Code)
+	}
+}
+
+func TestHandleExportChallengesCSVFromFixtures(t *testing.T) {
+	setupFixtureRepo(t)
+	prevToken := adminToken
+	adminToken = "admin"
+	t.Cleanup(func() {
+		adminToken = prevToken
+	})
+
+	router := mux.NewRouter()
+	registerRoutes(router, func(h http.HandlerFunc) http.Handler { return h })
+	req := httptest.NewRequest("GET", "/api/v1/export/challenges.csv?region=1", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	lines := bytes.Split(bytes.TrimSpace(rec.Body.Bytes()), []byte("\n"))
+	if len(lines) != 4 {
+		t.Errorf("expected a header and three challenges, got\n%s", rec.Body)
+	}
+}
+
+func TestOutboundHostLabel(t *testing.T) {
+	keepServerState(t)
+	outboundAllowedHosts = []string{"flickr.com", " Geograph.org.uk"}
+	tests := map[string]string{
+		"flickr.com":            "flickr.com",
+		"live.staticflickr.com": "other",
+		"a.b.c.flickr.com":      "flickr.com",
+		"www.geograph.org.uk":   "geograph.org.uk",
+		"notflickr.com":         "other",
+	}
+	for host, want := range tests {
+		if got := outboundHostLabel(host); got != want {
+			t.Errorf("%s: expected %q, got %q", host, want, got)
+		}
+	}
+}
+
+func TestHandleOutboundRedirectQuota(t *testing.T) {
+	keepServerState(t)
+	setupFixtureRepo(t)
+	sharedStore = shared.NewMemory()
+	outboundAllowedHosts = []string{"flickr.com"}
+
+	for i := range outboundClickQuota + 1 {
+		rec := serveFixtureRequest(t, "GET", "/out?target=https://www.flickr.com/photos/x/1", "")
+		if rec.Code != http.StatusFound {
+			t.Fatalf("click %d: expected 302 even over quota, got %d", i, rec.Code)
+		}
+	}
+	if outboundClickWithinQuota(httptest.NewRequest("GET", "/out", nil)) {
+		t.Fatal("expected the client to be over quota")
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type healthCheck struct {
+	OK            bool       `json:"ok"`
+	Error         string     `json:"error,omitempty"`
+	LastRefreshed *time.Time `json:"last_refreshed,omitempty"`
+}
+
+// Set from the config's ready section
+var readyMaxRegionsAge time.Duration
+var readyMaxChallengesAge time.Duration
+
+// handleLivez only checks the process can serve requests. Failing it gets the
+// pod restarted, which wouldn't help with anything handleReadyz checks.
+func handleLivez(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK"))
+}
+
+// handleReadyz is ready as long as there's a snapshot to serve. The database
+// and how fresh the snapshot is are reported under dependencies, but don't
+// take the replica out of rotation: while the database is down a stale
+// snapshot is better than no replicas at all.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	checks := make(map[string]healthCheck)
+	dependencies := make(map[string]healthCheck)
+
+	if repo.Populated() {
+		checks["caches"] = healthCheck{OK: true}
+	} else {
+		checks["caches"] = healthCheck{Error: "no regions or challenges loaded"}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := repo.Ping(ctx); err != nil {
+		dependencies["database"] = healthCheck{Error: err.Error()}
+	} else {
+		dependencies["database"] = healthCheck{OK: true}
+	}
+
+	regions, challenges := repo.LastRefreshed()
+	dependencies["regions_refresh"] = freshnessCheck(regions, readyMaxRegionsAge, now)
+	dependencies["challenges_refresh"] = freshnessCheck(challenges, readyMaxChallengesAge, now)
+
+	ok := true
+	for _, check := range checks {
+		ok = ok && check.OK
+	}
+	degraded := false
+	for _, check := range dependencies {
+		degraded = degraded || !check.OK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"ok":           ok,
+		"degraded":     degraded,
+		"checks":       checks,
+		"dependencies": dependencies,
+	})
+}
+
+func freshnessCheck(last time.Time, maxAge time.Duration, now time.Time) healthCheck {
+	if last.IsZero() {
+		return healthCheck{Error: "never refreshed from the database"}
+	}
+	check := healthCheck{OK: true, LastRefreshed: &last}
+	if now.Sub(last) > maxAge {
+		check.OK = false
+		check.Error = "last refresh older than " + maxAge.String()
+	}
+	return check
+}
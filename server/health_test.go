@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFreshnessCheck(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if check := freshnessCheck(time.Time{}, time.Hour, now); check.OK {
+		t.Error("expected never refreshed to fail")
+	}
+	if check := freshnessCheck(now.Add(-30*time.Minute), time.Hour, now); !check.OK {
+		t.Errorf("expected recent refresh to pass, got %+v", check)
+	}
+	if check := freshnessCheck(now.Add(-2*time.Hour), time.Hour, now); check.OK || check.LastRefreshed == nil {
+		t.Errorf("expected stale refresh to fail with its time, got %+v", check)
+	}
+}
+
+func TestReadyzWithoutDatabase(t *testing.T) {
+	keepServerState(t)
+	setupFixtureRepo(t)
+
+	// The fixtures have no database and were never refreshed from one, but
+	// there's still a snapshot to serve
+	rec := serveFixtureRequest(t, "GET", "/readyz", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		OK           bool                   `json:"ok"`
+		Degraded     bool                   `json:"degraded"`
+		Dependencies map[string]healthCheck `json:"dependencies"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if !body.OK || !body.Degraded || body.Dependencies["challenges_refresh"].OK {
+		t.Errorf("expected ready but degraded, got %s", rec.Body)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"contourguessr-api/repos"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"math"
+	"net/http"
+)
+
+// Cells with fewer guesses than this are left out of heatmaps so that no one
+// player's guess can be picked out
+const minHeatmapGuesses = 3
+
+const metersPerDegreeLat = 111_320.0
+
+type heatmapFeature struct {
+	Type     string `json:"type"`
+	Geometry struct {
+		Type        string           `json:"type"`
+		Coordinates [1][5][2]float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties struct {
+		Guesses int64 `json:"guesses"`
+	} `json:"properties"`
+}
+
+// handleGetGuessHeatmap serves where players have guessed a challenge as a
+// GeoJSON grid of roughly square cells cell_m across. Like the full metadata
+// it gives the answer away, so it needs a reveal token.
+func handleGetGuessHeatmap(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	v := newValidator(r)
+	cellM := v.queryInt("cell_m", 1000, 100, 50_000)
+	if !v.valid(w) {
+		return
+	}
+
+	if _, err := verifyRevealToken(r.URL.Query().Get("reveal_token"), id); err != nil {
+		httpError(w, r, "valid reveal_token required", http.StatusForbidden)
+		return
+	}
+
+	challenge, err := repo.Challenge(id)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Degrees of longitude shrink towards the poles, so cells are sized for
+	// the challenge's latitude
+	latStep := float64(cellM) / metersPerDegreeLat
+	lngStep := latStep / max(0.01, math.Cos(challenge.Geo.Lat*math.Pi/180))
+	cells, err := repo.GuessHeatmap(r.Context(), id, lngStep, latStep, minHeatmapGuesses)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting guess heatmap", "challenge", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	features := make([]heatmapFeature, 0, len(cells))
+	for _, c := range cells {
+		west, south := float64(c.X)*lngStep, float64(c.Y)*latStep
+		east, north := west+lngStep, south+latStep
+		f := heatmapFeature{Type: "Feature"}
+		f.Geometry.Type = "Polygon"
+		f.Geometry.Coordinates = [1][5][2]float64{{{west, south}, {east, south}, {east, north}, {west, north}, {west, south}}}
+		f.Properties.Guesses = c.Guesses
+		features = append(features, f)
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	// The token is in the URL, so only the player's own cache has a use for it
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
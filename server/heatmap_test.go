@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetGuessHeatmap(t *testing.T) {
+	setupGuessEvents(t)
+	c := fixtureChallenge(t, "Snowdon")
+
+	// Three close together and one off on its own
+	for _, lngLat := range []string{`"lng": -4.0001, "lat": 53.0001`, `"lng": -4.0002, "lat": 53.0002`, `"lng": -4.0003, "lat": 53.0003`, `"lng": -3.0, "lat": 52.0`} {
+		rec := serveFixtureRequest(t, "POST", "/api/v1/events/guess", guessEventBody(t, c.ID, lngLat+`, "client_version": "test"`))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	rec := serveFixtureRequest(t, "GET", "/api/v1/challenge/"+c.ID+"/heatmap.geojson", "")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without a reveal token, got %d", rec.Code)
+	}
+
+	play, err := signer.Sign(playClaims{Kind: "play", ChallengeID: c.ID, Nonce: "n", Expires: time.Now().Add(playTokenTTL).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = serveFixtureRequest(t, "GET", "/api/v1/challenge/"+c.ID+"/heatmap.geojson?reveal_token="+play, "")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 with a play token, got %d", rec.Code)
+	}
+
+	token, err := signer.Sign(revealClaims{Kind: "practice", ChallengeID: c.ID, Expires: time.Now().Add(revealTokenTTL).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = serveFixtureRequest(t, "GET", "/api/v1/challenge/"+c.ID+"/heatmap.geojson?cell_m=500&reveal_token="+token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		Features []heatmapFeature `json:"features"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Features) != 1 || got.Features[0].Properties.Guesses != 3 {
+		t.Fatalf("expected only the cell with three guesses, got %+v", got.Features)
+	}
+	ring := got.Features[0].Geometry.Coordinates[0]
+	if ring[0][0] > -4.0003 || ring[2][0] < -4.0001 || ring[0][1] > 53.0001 || ring[2][1] < 53.0003 {
+		t.Errorf("expected the cell to contain the guesses, got %v", ring)
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/geo"
+	"contourguessr-api/repos"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	hintElevationBandM = 250.0
+	minHintRadiusM     = 2_000.0
+)
+
+// hintPenalties is the share of a round's score lost for asking for a hint of
+// each level, indexed by the highest level asked for
+var hintPenalties = [...]float64{0, 0.1, 0.25, 0.5}
+
+// hintKey places the level 3 circle. Its center is fixed per challenge so that
+// asking again and again can't be averaged out to the answer. It's derived
+// from the token secret rather than being it, as the circles would otherwise
+// be MACs under the signing key.
+var hintKey []byte
+
+func deriveHintKey(tokenSecret []byte) ([]byte, error) {
+	return hkdf.Key(sha256.New, tokenSecret, nil, "contourguessr hint circles", sha256.Size)
+}
+
+type elevationBand struct {
+	MinM float64 `json:"min_m"`
+	MaxM float64 `json:"max_m"`
+}
+
+type hintCircle struct {
+	Center  geo.Point `json:"center"`
+	RadiusM float64   `json:"radius_m"`
+}
+
+type hint struct {
+	Level         int            `json:"level"`
+	Penalty       float64        `json:"penalty"`
+	ElevationBand *elevationBand `json:"elevation_band,omitempty"`
+	Quadrant      string         `json:"quadrant,omitempty"`
+	Circle        *hintCircle    `json:"circle,omitempty"`
+	// Replaces the play token the hint was asked for with
+	PlayToken string `json:"play_token"`
+}
+
+func penalizeHints(score int, level int) int {
+	return int(math.Round(float64(score) * (1 - hintPenalties[level])))
+}
+
+// handleGetChallengeHint narrows down where the challenge is, more with each
+// level: its elevation band, then which quadrant of the region it's in, then a
+// circle around it. Hints are recorded against the play token the challenge
+// was served with, and penalize the score of the guess made with it. The
+// client is also remembered, so its scored guesses need the token.
+func handleGetChallengeHint(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	v := newValidator(r)
+	playToken := v.queryString("play_token", true, 512)
+	// Only picks the pacing variant
+	player := v.queryString("player", false, 128)
+	level := v.queryInt("level", 1, 1, len(hintPenalties)-1)
+	if !v.valid(w) {
+		return
+	}
+
+	if !pacingConfig.Load().For(player).HintsEnabled {
+		httpError(w, r, "hints are disabled", http.StatusForbidden)
+		return
+	}
+
+	challenge, err := repo.Challenge(id)
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	play, err := verifyPlayToken(playToken, challenge.ID)
+	if err != nil {
+		httpError(w, r, "invalid play_token", http.StatusForbidden)
+		return
+	}
+
+	out := hint{Level: level, Penalty: hintPenalties[level]}
+	switch level {
+	case 1:
+		elevation := revealElevation(r.Context(), challenge, 0)
+		if elevation == nil {
+			httpError(w, r, "hint unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		low := math.Floor(*elevation.ChallengeM/hintElevationBandM) * hintElevationBandM
+		out.ElevationBand = &elevationBand{MinM: low, MaxM: low + hintElevationBandM}
+	case 2:
+		region, ok := challengeRegion(challenge)
+		if !ok {
+			httpError(w, r, "hint unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		out.Quadrant = regionQuadrant(region, challenge)
+	case 3:
+		region, ok := challengeRegion(challenge)
+		if !ok {
+			httpError(w, r, "hint unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		out.Circle = hintCircleFor(region, challenge)
+	}
+
+	if err := repo.RecordHint(r.Context(), challenge.ID, play.Nonce, level); writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error recording hint", "challenge", challenge.ID, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := markHintedClient(r.Context(), r, challenge.ID, player); writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error marking hinted client", "challenge", challenge.ID, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	play.HintLevel = max(play.HintLevel, level)
+	out.PlayToken, err = signer.Sign(play)
+	if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// hintedClients are the IP and player, if there is one, that a hint was asked
+// for by
+func hintedClients(r *http.Request, challengeID string, player string) []string {
+	clients := []string{"hinted:" + challengeID + ":ip:" + clientIP(r)}
+	if player != "" {
+		clients = append(clients, "hinted:"+challengeID+":player:"+player)
+	}
+	return clients
+}
+
+// markHintedClient remembers that the client asked for a hint on the
+// challenge for as long as its play token could be used, so that it can't
+// guess without the token to avoid the penalty
+func markHintedClient(ctx context.Context, r *http.Request, challengeID string, player string) error {
+	for _, key := range hintedClients(r, challengeID, player) {
+		if _, err := sharedStore.SetNX(ctx, key, "1", playTokenTTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clientWasHinted reports whether the client asked for a hint on the
+// challenge with a play token it could still guess with
+func clientWasHinted(ctx context.Context, r *http.Request, challengeID string, player string) (bool, error) {
+	for _, key := range hintedClients(r, challengeID, player) {
+		_, ok, err := sharedStore.Get(ctx, key)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+func challengeRegion(challenge repos.Challenge) (repos.Region, bool) {
+	id, err := strconv.Atoi(challenge.RegionID)
+	if err != nil {
+		return repos.Region{}, false
+	}
+	region, ok := repo.Regions()[id]
+	return region, ok
+}
+
+// regionQuadrant is like "north-west", relative to the middle of the region's
+// bounding box
+func regionQuadrant(region repos.Region, challenge repos.Challenge) string {
+	ns, ew := "south", "west"
+	if challenge.Geo.Lat >= (region.BBox.MinLat+region.BBox.MaxLat)/2 {
+		ns = "north"
+	}
+	if challenge.Geo.Lng >= (region.BBox.MinLng+region.BBox.MaxLng)/2 {
+		ew = "east"
+	}
+	return ns + "-" + ew
+}
+
+// hintCircleFor covers the challenge with a circle an eighth of the region's
+// diagonal across, its center moved off the challenge by up to most of the
+// radius
+func hintCircleFor(region repos.Region, challenge repos.Challenge) *hintCircle {
+	diagonal := geo.Distance(geo.Point{Lng: region.BBox.MinLng, Lat: region.BBox.MinLat}, geo.Point{Lng: region.BBox.MaxLng, Lat: region.BBox.MaxLat})
+	radius := math.Max(minHintRadiusM, diagonal/8)
+
+	mac := hmac.New(sha256.New, hintKey)
+	mac.Write([]byte(challenge.ID))
+	sum := mac.Sum(nil)
+	bearing := float64(binary.BigEndian.Uint32(sum[0:4])) / math.MaxUint32 * 360
+	offset := float64(binary.BigEndian.Uint32(sum[4:8])) / math.MaxUint32 * 0.8 * radius
+
+	center := geo.Destination(geo.Point{Lng: challenge.Geo.Lng, Lat: challenge.Geo.Lat}, bearing, offset)
+	// Rounded so as not to give away more than the circle does
+	center.Lng = math.Round(center.Lng*1e4) / 1e4
+	center.Lat = math.Round(center.Lat*1e4) / 1e4
+	return &hintCircle{Center: center, RadiusM: math.Round(radius)}
+}
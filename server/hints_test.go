@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"contourguessr-api/geo"
+	"contourguessr-api/pacing"
+	"contourguessr-api/repos"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHintsPenalizeGuesses(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+	c, err := repo.RandomChallenge(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func() string {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/v2/challenge/" + c.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out challengeV2
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return out.PlayToken
+	}
+	getHint := func(token string, level int) (int, hint) {
+		t.Helper()
+		resp, err := http.Get(fmt.Sprintf("%s/api/v1/challenge/%s/hint?play_token=%s&level=%d", ts.URL, c.ID, url.QueryEscape(token), level))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var h hint
+		_ = json.NewDecoder(resp.Body).Decode(&h)
+		return resp.StatusCode, h
+	}
+	guess := func(body map[string]any) (score int, hintLevel int) {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		resp, err := http.Post(ts.URL+"/api/v2/challenge/"+c.ID+"/guess", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Score     int `json:"score"`
+			HintLevel int `json:"hint_level"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return out.Score, out.HintLevel
+	}
+
+	token := serve()
+	if status, _ := getHint(token, 3); status != http.StatusForbidden {
+		t.Fatalf("expected hints to be disabled by default, got %d", status)
+	}
+	enabled := pacing.DefaultConfig
+	enabled.Default.HintsEnabled = true
+	pacingConfig.Store(&enabled)
+
+	if status, _ := getHint("forged", 1); status != http.StatusForbidden {
+		t.Errorf("expected a hint without a valid play token to be forbidden, got %d", status)
+	}
+
+	status, first := getHint(token, 3)
+	if status != http.StatusOK || first.Circle == nil || first.Penalty != 0.5 || first.PlayToken == "" {
+		t.Fatalf("expected a circle, got %d %+v", status, first)
+	}
+	if d := geo.Distance(first.Circle.Center, geo.Point{Lng: c.Geo.Lng, Lat: c.Geo.Lat}); d > first.Circle.RadiusM {
+		t.Errorf("expected the circle to contain the challenge, it's %fm from the center", d)
+	}
+	if _, again := getHint(first.PlayToken, 3); *again.Circle != *first.Circle {
+		t.Errorf("expected the same circle each time, got %+v and %+v", first.Circle, again.Circle)
+	}
+
+	practice, _ := guess(map[string]any{"lng": c.Geo.Lng + 0.01, "lat": c.Geo.Lat, "practice": true, "play_token": serve()})
+	// The token from before the hint still gets the penalty
+	penalized, level := guess(map[string]any{"lng": c.Geo.Lng + 0.01, "lat": c.Geo.Lat, "play_token": token})
+	if level != 3 || penalized != penalizeHints(practice, 3) || penalized >= practice {
+		t.Errorf("expected %d to be halved for a level 3 hint, got %d at level %d", practice, penalized, level)
+	}
+	if other, level := guess(map[string]any{"lng": c.Geo.Lng + 0.01, "lat": c.Geo.Lat, "play_token": serve()}); other != practice || level != 0 {
+		t.Errorf("expected a guess with another play token to be left alone, got %d at level %d", other, level)
+	}
+
+	// Guessing through v1 without the token would dodge the penalty
+	b, _ := json.Marshal(map[string]any{"lng": c.Geo.Lng + 0.01, "lat": c.Geo.Lat})
+	resp, err := http.Post(ts.URL+"/api/v1/challenge/"+c.ID+"/guess", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a scored guess without a play token after a hint to be forbidden, got %d", resp.StatusCode)
+	}
+}
+
+func TestHintKeyIsDerived(t *testing.T) {
+	secret := []byte("token secret")
+	key, err := deriveHintKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 32 || bytes.Equal(key, secret) {
+		t.Errorf("expected a separate 32 byte key, got %x", key)
+	}
+}
+
+func TestRegionQuadrant(t *testing.T) {
+	var region repos.Region
+	region.BBox.MinLng, region.BBox.MaxLng, region.BBox.MinLat, region.BBox.MaxLat = -4.2, -3.6, 52.8, 53.3
+	tests := []struct {
+		lng, lat float64
+		want     string
+	}{
+		{-3.9966, 53.1148, "north-west"},
+		{-3.7, 53.2, "north-east"},
+		{-3.7, 52.9, "south-east"},
+		{-4.1, 52.9, "south-west"},
+	}
+	for _, tt := range tests {
+		var c repos.Challenge
+		c.Geo.Lng, c.Geo.Lat = tt.lng, tt.lat
+		if got := regionQuadrant(region, c); got != tt.want {
+			t.Errorf("%v,%v: expected %s, got %s", tt.lng, tt.lat, tt.want, got)
+		}
+	}
+}
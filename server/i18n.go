@@ -0,0 +1,139 @@
+package server
+
+import (
+	"contourguessr-api/repos"
+	"errors"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Preferences past the first few almost never change which translation is
+// picked, and each distinct list is cached separately
+const maxRequestLanguages = 5
+
+var languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// normalizeLanguageTag lowercases a BCP 47 tag, also accepting the
+// underscores of POSIX locales like nb_NO
+func normalizeLanguageTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	return tag, languageTagPattern.MatchString(tag)
+}
+
+// requestLanguages is the repos.LanguageChain for the comma separated tags in
+// ?lang= if given, and otherwise for Accept-Language
+func requestLanguages(v *validator, r *http.Request) []string {
+	if s := v.query.Get("lang"); s != "" {
+		var tags []string
+		for _, part := range strings.Split(s, ",") {
+			tag, ok := normalizeLanguageTag(part)
+			if !ok {
+				v.add("query", "lang", "invalid_value", part+" is not a valid language tag")
+				return nil
+			}
+			tags = append(tags, tag)
+		}
+		return repos.LanguageChain(tags[:min(len(tags), maxRequestLanguages)])
+	}
+	return repos.LanguageChain(acceptedLanguages(r.Header.Get("Accept-Language")))
+}
+
+// acceptedLanguages lists the tags in an Accept-Language header by
+// descending quality, ignoring the wildcard and anything malformed
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag, ok := normalizeLanguageTag(tag)
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, weighted{tag: tag, q: q})
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b weighted) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	out := make([]string, 0, min(len(ranges), maxRequestLanguages))
+	for _, r := range ranges[:min(len(ranges), maxRequestLanguages)] {
+		out = append(out, r.tag)
+	}
+	return out
+}
+
+// pathLanguage validates the {lang} path variable as a language tag
+func pathLanguage(v *validator) string {
+	tag, ok := normalizeLanguageTag(mux.Vars(v.r)["lang"])
+	if !ok {
+		v.add("path", "lang", "invalid_value", "lang must be a language tag like fr or nb-NO")
+	}
+	return tag
+}
+
+func handlePutRegionTranslation(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.pathInt("id")
+	lang := pathLanguage(v)
+	var t repos.RegionTranslation
+	if v.decodeBody(&t) {
+		v.bodyString("name", t.Name, true, 256)
+		v.bodyString("description", t.Description, false, 4096)
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	err := repo.SetRegionTranslation(r.Context(), regionID, lang, t)
+	if errors.Is(err, repos.RegionNotFoundError) {
+		httpError(w, r, "region not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error setting translation", "region", regionID, "lang", lang, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDeleteRegionTranslation(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.pathInt("id")
+	lang := pathLanguage(v)
+	if !v.valid(w) {
+		return
+	}
+
+	if err := repo.DeleteRegionTranslation(r.Context(), regionID, lang); err != nil {
+		slog.ErrorContext(r.Context(), "error deleting translation", "region", regionID, "lang", lang, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/repos"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAcceptedLanguages(t *testing.T) {
+	tests := map[string][]string{
+		"":                                   {},
+		"fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5": {"fr-ch", "fr", "en"},
+		"en;q=0.5, ja":                       {"ja", "en"},
+		"nb_NO, de;q=0, <script>":            {"nb-no"},
+	}
+	for header, want := range tests {
+		if got := acceptedLanguages(header); !slices.Equal(got, want) {
+			t.Errorf("%q: expected %v, got %v", header, want, got)
+		}
+	}
+}
+
+func TestRequestLanguagesPrefersQuery(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/v2/region?lang=ja,en", nil)
+	r.Header.Set("Accept-Language", "fr")
+	v := newValidator(r)
+	if got := requestLanguages(v, r); !slices.Equal(got, []string{"ja", "en"}) {
+		t.Errorf("expected ?lang= to win, got %v", got)
+	}
+
+	r = httptest.NewRequest("GET", "/api/v2/region?lang=ja,-", nil)
+	v = newValidator(r)
+	requestLanguages(v, r)
+	if rec := httptest.NewRecorder(); v.valid(rec) || rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid tag to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestGetRegionsLocalized(t *testing.T) {
+	m := setupFixtureRepo(t)
+	if err := m.SetRegionTranslation(context.Background(), 1, "nb", repos.RegionTranslation{Name: "Snødonia"}); err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	registerRoutes(router, func(h http.HandlerFunc) http.Handler { return h })
+	req := httptest.NewRequest("GET", "/api/v2/region", nil)
+	req.Header.Set("Accept-Language", "no")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Language") != "nb" || !strings.Contains(rec.Body.String(), "Snødonia") {
+		t.Errorf("expected Norwegian to fall back to Bokmål, got %d %v", rec.Code, rec.Header())
+	}
+	if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Accept-Language") {
+		t.Errorf("expected the response to vary by language, got %v", vary)
+	}
+}
@@ -0,0 +1,431 @@
+//go:build integration
+
+// Integration tests run the API against Postgres, started in Docker with
+// Testcontainers:
+//
+//	go test -tags integration -run Integration ./server
+//
+// Set INTEGRATION_DATABASE_URL to use an existing, empty database instead.
+// The tests skip themselves if neither is available.
+package server
+
+import (
+	"bytes"
+	"context"
+	"contourguessr-api/config"
+	"contourguessr-api/repos"
+	"contourguessr-api/wmts/wmtstest"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const integrationImage = "postgis/postgis:16-3.4"
+
+var integration struct {
+	once      sync.Once
+	skip      string
+	err       error
+	container *postgres.PostgresContainer
+	db        *pgxpool.Pool
+	server    *httptest.Server
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if integration.server != nil {
+		integration.server.Close()
+	}
+	if repo != nil {
+		repo.Close()
+	}
+	if integration.container != nil {
+		_ = testcontainers.TerminateContainer(integration.container)
+	}
+	os.Exit(code)
+}
+
+// integrationServer returns the API serving fixture data from Postgres,
+// starting it on first use
+func integrationServer(t *testing.T) string {
+	t.Helper()
+	integration.once.Do(startIntegration)
+	if integration.skip != "" {
+		t.Skip(integration.skip)
+	}
+	if integration.err != nil {
+		t.Fatal(integration.err)
+	}
+	return integration.server.URL
+}
+
+func startIntegration() {
+	ctx := context.Background()
+	databaseURL := os.Getenv("INTEGRATION_DATABASE_URL")
+	if databaseURL == "" {
+		if !dockerAvailable(ctx) {
+			integration.skip = "docker not available and INTEGRATION_DATABASE_URL not set"
+			return
+		}
+		databaseURL, integration.err = startPostgresContainer(ctx)
+		if integration.err != nil {
+			return
+		}
+	}
+
+	db, err := connectWithRetry(ctx, databaseURL)
+	if err != nil {
+		integration.err = err
+		return
+	}
+	integration.db = db
+
+	// Stands in for the map provider so that nothing leaves the machine
+	capabilities := wmtstest.NewServer()
+	if err := loadIntegrationFixtures(ctx, db, wmtstest.URL(capabilities.URL, "Outdoor_3857", "EPSG:3857")); err != nil {
+		integration.err = err
+		return
+	}
+
+	cfg := config.Default()
+	cfg.TokenSecret = "integration secret"
+	cfg.AdminToken = "integration-admin"
+	r := repos.New(db, repos.WithRefreshIntervals(time.Hour, time.Hour))
+	r.WaitUntilReady()
+	srv, err := New(cfg, r)
+	if err != nil {
+		integration.err = err
+		return
+	}
+	integration.server = httptest.NewServer(srv.Handler())
+}
+
+func dockerAvailable(ctx context.Context) (ok bool) {
+	// Testcontainers panics rather than erroring on some broken setups
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	return err == nil && provider.Health(ctx) == nil
+}
+
+func startPostgresContainer(ctx context.Context) (string, error) {
+	container, err := postgres.Run(ctx, integrationImage,
+		postgres.WithDatabase("contourguessr"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("integration"),
+		postgres.BasicWaitStrategies(),
+	)
+	if container != nil {
+		integration.container = container
+	}
+	if err != nil {
+		return "", fmt.Errorf("starting postgres: %w", err)
+	}
+	return container.ConnectionString(ctx, "sslmode=disable")
+}
+
+// connectWithRetry waits for the database to accept connections, which an
+// existing one may not yet if it was started alongside the tests
+func connectWithRetry(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
+	var db *pgxpool.Pool
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = time.Minute
+	err := backoff.Retry(func() error {
+		var err error
+		db, err = pgxpool.Connect(ctx, databaseURL)
+		if err != nil {
+			return err
+		}
+		var ready bool
+		if err := db.QueryRow(ctx, `SELECT true FROM pg_extension WHERE extname = 'plpgsql'`).Scan(&ready); err != nil {
+			db.Close()
+			return err
+		}
+		return nil
+	}, b)
+	return db, err
+}
+
+func loadIntegrationFixtures(ctx context.Context, db *pgxpool.Pool, capabilitiesURL string) error {
+	schema, err := os.ReadFile("testdata/integration/schema.sql")
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ctx, string(schema)); err != nil {
+		return fmt.Errorf("applying schema: %w", err)
+	}
+	if err := repos.Migrate(ctx, db); err != nil {
+		return fmt.Errorf("migrating: %w", err)
+	}
+	fixtures, err := os.ReadFile("testdata/integration/fixtures.sql")
+	if err != nil {
+		return err
+	}
+	sql := strings.ReplaceAll(string(fixtures), "{{capabilities_url}}", strings.ReplaceAll(capabilitiesURL, "'", "''"))
+	if _, err := db.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("loading fixtures: %w", err)
+	}
+	return nil
+}
+
+func integrationRequest(t *testing.T, method string, path string, token string, body any) (int, []byte) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, integrationServer(t)+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, b
+}
+
+// eventually retries check until it passes, for changes that reach the
+// snapshot through notifications
+func eventually(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestIntegrationRegions(t *testing.T) {
+	integrationServer(t)
+
+	// Regions are only served once their capabilities have been fetched
+	var regions []repos.Region
+	eventually(t, "regions", func() bool {
+		status, body := integrationRequest(t, "GET", "/api/v1/region", "", nil)
+		return status == http.StatusOK && json.Unmarshal(body, &regions) == nil && len(regions) == 2
+	})
+	if regions[0].Name != "Snowdonia" || regions[1].Name != "Lake District" {
+		t.Errorf("unexpected regions %+v", regions)
+	}
+}
+
+func TestIntegrationPlayRound(t *testing.T) {
+	integrationServer(t)
+
+	status, body := integrationRequest(t, "POST", "/api/v1/player", "", nil)
+	var player struct {
+		Player      string `json:"player"`
+		PlayerToken string `json:"player_token"`
+	}
+	if status != http.StatusCreated || json.Unmarshal(body, &player) != nil {
+		t.Fatalf("expected a player, got %d: %s", status, body)
+	}
+
+	status, body = integrationRequest(t, "GET", "/api/v1/challenge/random?region=2", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", status, body)
+	}
+	var c repos.Challenge
+	if err := json.Unmarshal(body, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.RegionID != "2" || c.DescriptionHTML != "<p>"+c.Title+"</p>" {
+		t.Errorf("expected a challenge in region 2 with its description, got %+v", c)
+	}
+
+	status, body = integrationRequest(t, "POST", "/api/v1/challenge/"+c.ID+"/guess", "",
+		map[string]any{"lng": c.Geo.Lng, "lat": c.Geo.Lat, "player": player.Player})
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", status, body)
+	}
+	var guess struct {
+		DistanceM   float64 `json:"distance_m"`
+		ResultToken string  `json:"result_token"`
+	}
+	if err := json.Unmarshal(body, &guess); err != nil {
+		t.Fatal(err)
+	}
+	if guess.DistanceM > 1 || guess.ResultToken == "" {
+		t.Errorf("expected a result for a spot-on guess, got %s", body)
+	}
+
+	status, body = integrationRequest(t, "GET", "/api/v1/player/me/best", player.PlayerToken, nil)
+	var best []repos.BestRound
+	if status != http.StatusOK || json.Unmarshal(body, &best) != nil || len(best) != 1 || best[0].ChallengeID != c.ID {
+		t.Errorf("expected the guess in the player's history, got %d %s", status, body)
+	}
+}
+
+func TestIntegrationModeration(t *testing.T) {
+	integrationServer(t)
+
+	status, body := integrationRequest(t, "POST", "/admin/partner", adminToken, map[string]string{"name": "Park authority"})
+	if status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", status, body)
+	}
+	var partner struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &partner); err != nil {
+		t.Fatal(err)
+	}
+
+	c := repo.SearchChallenges(repos.ChallengeFilter{Query: "Tryfan"}, 1)[0]
+	status, body = integrationRequest(t, "POST", "/partner/v1/challenge/"+c.ID+"/flag", partner.Token,
+		map[string]string{"reason": "nesting birds"})
+	if status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", status, body)
+	}
+	eventually(t, "the quarantined challenge to be withheld", func() bool {
+		_, err := repo.Challenge(c.ID)
+		return errors.Is(err, repos.ChallengeNotFoundError)
+	})
+
+	status, body = integrationRequest(t, "PUT", "/admin/challenge/"+c.ID+"/moderation", adminToken,
+		map[string]string{"state": "cleared"})
+	if status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", status, body)
+	}
+	eventually(t, "the cleared challenge to be served again", func() bool {
+		status, _ := integrationRequest(t, "GET", "/api/v1/challenge/"+c.ID, "", nil)
+		return status == http.StatusOK
+	})
+}
+
+func TestIntegrationPrivacyZones(t *testing.T) {
+	integrationServer(t)
+	ctx := context.Background()
+
+	c := repo.SearchChallenges(repos.ChallengeFilter{Query: "Helvellyn"}, 1)[0]
+	zone := map[string]any{
+		"reason":   "nesting birds",
+		"geometry": json.RawMessage(`{"type": "Polygon", "coordinates": [[[-3.03, 54.52], [-3.0, 54.52], [-3.0, 54.535], [-3.03, 54.535], [-3.03, 54.52]]]}`),
+	}
+	status, body := integrationRequest(t, "PUT", "/admin/region/2/privacy-zone/edge", adminToken, zone)
+	var set struct {
+		ExcludedChallenges []string `json:"excluded_challenges"`
+	}
+	if status != http.StatusOK || json.Unmarshal(body, &set) != nil {
+		t.Fatalf("expected 200, got %d: %s", status, body)
+	}
+	if len(set.ExcludedChallenges) != 1 || set.ExcludedChallenges[0] != c.ID {
+		t.Errorf("expected only %s to be excluded, got %v", c.ID, set.ExcludedChallenges)
+	}
+	eventually(t, "the zoned challenge to be withheld", func() bool {
+		_, err := repo.Challenge(c.ID)
+		return errors.Is(err, repos.ChallengeNotFoundError)
+	})
+
+	// As the ingest pipeline would add a photo
+	_, err := integration.db.Exec(ctx, `
+		INSERT INTO challenges (region_id, geo, title, link, regular_src, regular_width, regular_height,
+		                        large_src, large_width, large_height, rx, ry)
+		VALUES (2, ST_MakePoint(-3.015, 54.528)::geography, 'Swirral Edge', 'https://www.flickr.com/photos/example/7',
+		        'https://live.staticflickr.com/7_n.jpg', 320, 240, 'https://live.staticflickr.com/7_b.jpg', 1024, 768, 0.5, 0.5)
+	`)
+	if err == nil || !strings.Contains(err.Error(), "privacy zone edge") {
+		t.Errorf("expected a photo inside the zone to be refused, got %v", err)
+	}
+
+	status, body = integrationRequest(t, "DELETE", "/admin/region/2/privacy-zone/edge", adminToken, nil)
+	if status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", status, body)
+	}
+	eventually(t, "the challenge to be served again", func() bool {
+		status, _ := integrationRequest(t, "GET", "/api/v1/challenge/"+c.ID, "", nil)
+		return status == http.StatusOK
+	})
+}
+
+func TestIntegrationMigrateIsIdempotent(t *testing.T) {
+	integrationServer(t)
+	if err := repos.Migrate(context.Background(), integration.db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIntegrationArchiveRestore(t *testing.T) {
+	integrationServer(t)
+	ctx := context.Background()
+
+	c := repo.SearchChallenges(repos.ChallengeFilter{Query: "Langdale"}, 1)[0]
+	var flickrID string
+	if err := integration.db.QueryRow(ctx, `
+		SELECT flickr_id FROM flickr_challenge_sources AS s
+		JOIN challenges ON challenges.id = s.challenge_id
+		WHERE challenges.title = $1
+	`, c.Title).Scan(&flickrID); err != nil {
+		t.Fatal(err)
+	}
+
+	status, body := integrationRequest(t, "POST", "/admin/challenge/"+c.ID+"/archive", adminToken,
+		map[string]string{"reason": "integration"})
+	if status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", status, body)
+	}
+	var sources, photos int
+	if err := integration.db.QueryRow(ctx, `
+		SELECT (SELECT count(*) FROM flickr_challenge_sources WHERE flickr_id = $1),
+		       (SELECT count(*) FROM flickr_photos WHERE flickr_id = $1)
+	`, flickrID).Scan(&sources, &photos); err != nil {
+		t.Fatal(err)
+	}
+	if sources != 0 || photos != 1 {
+		t.Errorf("expected the source archived and the photo kept, got %d sources and %d photos", sources, photos)
+	}
+
+	status, body = integrationRequest(t, "POST", "/admin/challenge/"+c.ID+"/restore", adminToken, nil)
+	if status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", status, body)
+	}
+	if err := integration.db.QueryRow(ctx, `SELECT count(*) FROM flickr_challenge_sources WHERE flickr_id = $1`, flickrID).Scan(&sources); err != nil {
+		t.Fatal(err)
+	}
+	if sources != 1 {
+		t.Errorf("expected the source to be restored, got %d", sources)
+	}
+}
+
+func TestIntegrationCapabilitiesStored(t *testing.T) {
+	integrationServer(t)
+
+	// So that the first fetch after a restart has something to diff against
+	eventually(t, "capabilities to be stored", func() bool {
+		var n int
+		err := integration.db.QueryRow(context.Background(), `SELECT count(*) FROM map_layer_capabilities`).Scan(&n)
+		return err == nil && n == 1
+	})
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/config"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/getsentry/sentry-go"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+type requestIDKey struct{}
+
+// Upstream proxies may already have assigned an ID, which we keep if it looks
+// sane so that their logs line up with ours
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			b := make([]byte, 8)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID to records logged with a request's
+// context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// newLogger logs JSON unless LOG_FORMAT is text, which is easier to read
+// locally
+func newLogger(format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	if format == "text" {
+		h = slog.NewTextHandler(os.Stderr, opts)
+	} else {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// ConfigureLogging sets the default logger from cfg, reporting errors to
+// Sentry if SENTRY_DSN is set
+func ConfigureLogging(cfg config.Config) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(newLogger(cfg.Log.Format, level))
+	if cfg.Sentry.DSN == "" {
+		return nil
+	}
+
+	hostname, _ := os.Hostname()
+	reporter, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.Sentry.DSN,
+		Environment: cfg.Sentry.Environment,
+		Release:     buildRevision(),
+		ServerName:  hostname,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid SENTRY_DSN: %w", err)
+	}
+	errorReporter = reporter
+	sentry.CurrentHub().BindClient(reporter)
+	slog.SetDefault(slog.New(reportingHandler{Handler: slog.Default().Handler(), client: errorReporter}))
+	return nil
+}
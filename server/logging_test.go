@@ -0,0 +1,48 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)})
+
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.ErrorContext(r.Context(), "something failed")
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "upstream-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "upstream-123" {
+		t.Errorf("expected upstream request id to be kept, got %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), "upstream-123") {
+		t.Errorf("expected request id in error body, got %q", rec.Body.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["request_id"] != "upstream-123" {
+		t.Errorf("expected request id in log record, got %v", record)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "not valid\n")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); len(got) != 16 {
+		t.Errorf("expected a generated request id, got %q", got)
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/repos"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var requestsCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "http_requests_total",
+		Help:      "Requests served partitioned by route, method and status code",
+	},
+	[]string{"route", "method", "code"},
+)
+
+var requestDurationHistogram = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "contourguessr",
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to serve requests partitioned by route, method and status class",
+		// Up to the default request timeout
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15},
+	},
+	[]string{"route", "method", "status"},
+)
+
+var refreshDurationHistogram = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "contourguessr",
+		Name:      "refresh_duration_seconds",
+		Help:      "Time to reload from the database partitioned by updater and result",
+		// Up to refreshQueryTimeout in repos
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	},
+	[]string{"updater", "result"},
+)
+
+var refreshRowsGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "contourguessr",
+		Name:      "refresh_rows",
+		Help:      "Rows loaded by the last successful reload partitioned by updater",
+	},
+	[]string{"updater"},
+)
+
+var refreshLastSuccessGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "contourguessr",
+		Name:      "refresh_last_success_timestamp_seconds",
+		Help:      "When the last successful reload finished partitioned by updater",
+	},
+	[]string{"updater"},
+)
+
+var refreshConsecutiveFailuresGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "contourguessr",
+		Name:      "refresh_consecutive_failures",
+		Help:      "Reloads that have failed since the last success partitioned by updater",
+	},
+	[]string{"updater"},
+)
+
+// observeRefresh is passed to repos so that a cache silently going stale can
+// be alerted on
+func observeRefresh(res repos.RefreshResult) {
+	result := "success"
+	if res.Err != nil {
+		result = "error"
+	}
+	refreshDurationHistogram.WithLabelValues(res.Updater, result).Observe(res.Duration.Seconds())
+	if res.Err != nil {
+		refreshConsecutiveFailuresGauge.WithLabelValues(res.Updater).Inc()
+		return
+	}
+	refreshRowsGauge.WithLabelValues(res.Updater).Set(float64(res.Rows))
+	refreshLastSuccessGauge.WithLabelValues(res.Updater).SetToCurrentTime()
+	refreshConsecutiveFailuresGauge.WithLabelValues(res.Updater).Set(0)
+}
+
+var capabilitiesFetchesCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "capabilities_fetches_total",
+		Help:      "Capabilities fetch attempts partitioned by map layer and result (success, retrying, stale or dropped)",
+	},
+	[]string{"map_layer", "result"},
+)
+
+var capabilitiesFetchDurationHistogram = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "contourguessr",
+		Name:      "capabilities_fetch_duration_seconds",
+		Help:      "Time taken by capabilities fetch attempts partitioned by map layer",
+		// Up to the fetch timeout
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	},
+	[]string{"map_layer"},
+)
+
+// observeCapabilitiesFetch is passed to repos so that layers dropped because
+// their WMTS server was flaky show up as more than a missing region
+func observeCapabilitiesFetch(f repos.CapabilitiesFetch) {
+	layer := strconv.Itoa(f.MapLayerID)
+	capabilitiesFetchesCounter.WithLabelValues(layer, f.Result).Inc()
+	capabilitiesFetchDurationHistogram.WithLabelValues(layer).Observe(f.Duration.Seconds())
+}
+
+var (
+	cacheEntriesDesc = prometheus.NewDesc("contourguessr_cache_entries",
+		"Entries in each in-memory cache", []string{"cache"}, nil)
+	cacheBytesDesc = prometheus.NewDesc("contourguessr_cache_bytes",
+		"Rough estimate of the memory held by each in-memory cache", []string{"cache"}, nil)
+	cacheAgeDesc = prometheus.NewDesc("contourguessr_cache_age_seconds",
+		"Time since regions and challenges were last loaded from the database", []string{"cache"}, nil)
+)
+
+// cacheCollector reads every cache's stats once per scrape rather than once
+// per metric
+type cacheCollector struct{}
+
+func (cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheEntriesDesc
+	ch <- cacheBytesDesc
+	ch <- cacheAgeDesc
+}
+
+func (cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	if repo == nil {
+		return
+	}
+	for name, stats := range repo.CacheStats() {
+		ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries), name)
+		ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(stats.Bytes), name)
+	}
+	regions, challenges := repo.CacheAge()
+	ch <- prometheus.MustNewConstMetric(cacheAgeDesc, prometheus.GaugeValue, regions.Seconds(), "regions")
+	ch <- prometheus.MustNewConstMetric(cacheAgeDesc, prometheus.GaugeValue, challenges.Seconds(), "challenges")
+}
+
+func init() {
+	prometheus.MustRegister(cacheCollector{})
+}
+
+// routeTemplate keeps labels bounded by using the path the route was
+// registered with rather than the requested one
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unknown"
+}
+
+type routeLabelKey struct{}
+
+// metricsMiddleware goes outside everything that can respond, like the rate
+// limiter, so that every request is observed. Only the router knows which
+// route matched, so routeLabelMiddleware passes it back out.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := "unmatched"
+		ctx := context.WithValue(r.Context(), routeLabelKey{}, &route)
+		aw := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r.WithContext(ctx))
+
+		status := aw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		method := methodLabel(r.Method)
+		requestsCounter.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+		requestDurationHistogram.
+			WithLabelValues(route, method, strconv.Itoa(status/100)+"xx").
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+func routeLabelMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeLabelKey{}).(*string); ok {
+			*route = routeTemplate(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// methodLabel stops clients inventing methods from adding label values
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "other"
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"contourguessr-api/repos"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(routeLabelMiddleware)
+	router.HandleFunc("/api/v1/challenge/{id}", func(w http.ResponseWriter, r *http.Request) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+	})
+	router.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {})
+	handler := metricsMiddleware(router)
+
+	// Metrics are global, so other tests serving requests also count
+	tests := []struct {
+		route, method, status string
+		want                  uint64
+	}{
+		{"/api/v1/challenge/{id}", "GET", "4xx", 2},
+		{"/livez", "GET", "2xx", 1},
+		{"unmatched", "GET", "4xx", 1},
+		{"/livez", "other", "2xx", 1},
+	}
+	before := make([]uint64, len(tests))
+	for i, tt := range tests {
+		before[i] = histogramCount(t, requestDurationHistogram.WithLabelValues(tt.route, tt.method, tt.status))
+	}
+	notFoundBefore := counterValue(t, requestsCounter.WithLabelValues("/api/v1/challenge/{id}", "GET", "404"))
+	unmatchedBefore := counterValue(t, requestsCounter.WithLabelValues("unmatched", "GET", "404"))
+
+	for _, path := range []string{"/api/v1/challenge/abc", "/api/v1/challenge/def", "/livez", "/nope"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	req := httptest.NewRequest("BREW", "/livez", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	for i, tt := range tests {
+		if got := histogramCount(t, requestDurationHistogram.WithLabelValues(tt.route, tt.method, tt.status)) - before[i]; got != tt.want {
+			t.Errorf("%s %s %s: expected %d observations, got %d", tt.method, tt.route, tt.status, tt.want, got)
+		}
+	}
+
+	if got := counterValue(t, requestsCounter.WithLabelValues("/api/v1/challenge/{id}", "GET", "404")) - notFoundBefore; got != 2 {
+		t.Errorf("expected 2 not found challenges counted, got %v", got)
+	}
+	if got := counterValue(t, requestsCounter.WithLabelValues("unmatched", "GET", "404")) - unmatchedBefore; got != 1 {
+		t.Errorf("expected 1 unmatched request counted, got %v", got)
+	}
+}
+
+func histogramCount(t *testing.T, o prometheus.Observer) uint64 {
+	var m dto.Metric
+	if err := o.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestObserveRefresh(t *testing.T) {
+	failures := refreshConsecutiveFailuresGauge.WithLabelValues("test")
+	observeRefresh(repos.RefreshResult{Updater: "test", Err: errors.New("timeout")})
+	observeRefresh(repos.RefreshResult{Updater: "test", Err: errors.New("timeout")})
+	if got := gaugeValue(t, failures); got != 2 {
+		t.Errorf("expected 2 consecutive failures, got %v", got)
+	}
+
+	observeRefresh(repos.RefreshResult{Updater: "test", Duration: time.Second, Rows: 1200})
+	if got := gaugeValue(t, failures); got != 0 {
+		t.Errorf("expected failures reset after success, got %v", got)
+	}
+	if got := gaugeValue(t, refreshRowsGauge.WithLabelValues("test")); got != 1200 {
+		t.Errorf("expected 1200 rows, got %v", got)
+	}
+	if got := gaugeValue(t, refreshLastSuccessGauge.WithLabelValues("test")); got < float64(time.Now().Add(-time.Minute).Unix()) {
+		t.Errorf("expected a recent last success, got %v", got)
+	}
+	if got := histogramCount(t, refreshDurationHistogram.WithLabelValues("test", "error")); got != 2 {
+		t.Errorf("expected 2 failed refreshes observed, got %d", got)
+	}
+}
+
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestObserveCapabilitiesFetch(t *testing.T) {
+	observeCapabilitiesFetch(repos.CapabilitiesFetch{MapLayerID: 90, Duration: time.Second, Result: repos.CapabilitiesFetchDropped})
+	if got := counterValue(t, capabilitiesFetchesCounter.WithLabelValues("90", "dropped")); got != 1 {
+		t.Errorf("expected 1 dropped fetch, got %v", got)
+	}
+	if got := histogramCount(t, capabilitiesFetchDurationHistogram.WithLabelValues("90")); got != 1 {
+		t.Errorf("expected 1 fetch observed, got %d", got)
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"contourguessr-api/proto"
+	"contourguessr-api/repos"
+	"encoding/json"
+	"github.com/vmihailenco/msgpack/v5"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The challenge and region endpoints can respond with smaller encodings for
+// the mobile client. Protobuf follows proto/contourguessr/v1/contourguessr.proto,
+// which only describes v2, and MessagePack is transcoded from the JSON.
+const (
+	jsonContentType     = "application/json"
+	msgpackContentType  = "application/msgpack"
+	protobufContentType = "application/x-protobuf"
+)
+
+var contentTypeAliases = map[string]string{
+	"application/x-msgpack": msgpackContentType,
+	"application/protobuf":  protobufContentType,
+}
+
+const protoSchemaPath = "/proto/contourguessr/v1/contourguessr.proto"
+
+func handleGetProtoSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_, _ = w.Write(proto.ContourguessrV1)
+}
+
+// responseFormat picks the content type for a challenge or region response
+func responseFormat(w http.ResponseWriter, r *http.Request) string {
+	w.Header().Add("Vary", "Accept")
+	offers := []string{jsonContentType, msgpackContentType}
+	if apiVersion(r) >= 2 {
+		offers = append(offers, protobufContentType)
+	}
+	return negotiate(r.Header.Get("Accept"), offers)
+}
+
+// negotiate returns the offer with the highest quality in accept, preferring
+// earlier offers on ties. Clients that accept none of them get the first
+// rather than a 406.
+func negotiate(accept string, offers []string) string {
+	if accept == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, _ := strings.Cut(part, ";")
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if alias, ok := contentTypeAliases[typ]; ok {
+			typ = alias
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, q: q})
+	}
+
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		// The most specific matching range sets the offer's quality
+		q, specificity := 0.0, -1
+		for _, mr := range ranges {
+			s := -1
+			switch {
+			case mr.typ == offer:
+				s = 2
+			case strings.HasSuffix(mr.typ, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mr.typ, "*")):
+				s = 1
+			case mr.typ == "*/*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = mr.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// writeAs writes v in format, which is JSON unless it's MessagePack. Protobuf
+// has its own encoders.
+func writeAs(w http.ResponseWriter, r *http.Request, format string, v any) {
+	var body []byte
+	var err error
+	if format == msgpackContentType {
+		body, err = marshalMsgpack(v)
+	} else {
+		format = jsonContentType
+		body, err = json.Marshal(v)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error encoding response", "format", format, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", format)
+	_, _ = w.Write(body)
+}
+
+// marshalMsgpack encodes v with the same shape as its JSON encoding. Fields
+// are named by their json tags, and map keys are sorted so that the output is
+// deterministic.
+func marshalMsgpack(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func init() {
+	// Times are strings in JSON, rather than the MessagePack timestamp
+	// extension clients may not support
+	msgpack.Register(time.Time{}, func(e *msgpack.Encoder, v reflect.Value) error {
+		return e.EncodeString(v.Interface().(time.Time).Format(time.RFC3339Nano))
+	}, nil)
+	// Would otherwise be binary, but it's GeoJSON and the like, which clients
+	// want decoded
+	msgpack.Register(json.RawMessage(nil), func(e *msgpack.Encoder, v reflect.Value) error {
+		if v.Len() == 0 {
+			return e.EncodeNil()
+		}
+		var decoded any
+		if err := json.Unmarshal(v.Bytes(), &decoded); err != nil {
+			return err
+		}
+		return e.Encode(decoded)
+	}, nil)
+}
+
+// setProtobufHeaders tells clients which message of the schema the body is
+func setProtobufHeaders(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", protobufContentType)
+	w.Header().Set("X-Protobuf-Schema", protoSchemaPath)
+	w.Header().Set("X-Protobuf-Message", message)
+}
+
+// formatETag distinguishes the ETags of each format of the same regions.
+// compressMiddleware gives gzipped bodies their own ETag in the same way.
+func formatETag(etag string, format string) string {
+	switch format {
+	case msgpackContentType:
+		etag = strings.TrimSuffix(etag, `"`) + `-msgpack"`
+	case protobufContentType:
+		etag = strings.TrimSuffix(etag, `"`) + `-pb"`
+	}
+	return etag
+}
+
+// The regions only change every few hours, so the last conversion is kept
+var msgpackRegionsCache atomic.Pointer[msgpackRegions]
+
+type msgpackRegions struct {
+	etag string
+	body []byte
+}
+
+func msgpackRegionsBody(encoded repos.EncodedRegions) ([]byte, error) {
+	if cached := msgpackRegionsCache.Load(); cached != nil && cached.etag == encoded.ETag {
+		return cached.body, nil
+	}
+	body, err := marshalMsgpack(encoded.Value)
+	if err != nil {
+		return nil, err
+	}
+	msgpackRegionsCache.Store(&msgpackRegions{etag: encoded.ETag, body: body})
+	return body, nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/vmihailenco/msgpack/v5"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{jsonContentType, msgpackContentType, protobufContentType}
+	tests := map[string]string{
+		"":                                 "application/json",
+		"*/*":                              "application/json",
+		"application/x-protobuf":           "application/x-protobuf",
+		"application/protobuf":             "application/x-protobuf",
+		"application/x-msgpack, */*;q=0.1": "application/msgpack",
+		"application/json;q=0.5, application/msgpack": "application/msgpack",
+		"application/*, application/json;q=0":         "application/msgpack",
+		"text/html":                                   "application/json",
+		"application/x-protobuf;q=0, */*":             "application/json",
+	}
+	for accept, want := range tests {
+		if got := negotiate(accept, offers); got != want {
+			t.Errorf("%q: expected %s, got %s", accept, want, got)
+		}
+	}
+
+	if got := negotiate("application/x-protobuf", offers[:2]); got != jsonContentType {
+		t.Errorf("expected JSON when protobuf isn't offered, got %s", got)
+	}
+}
+
+func TestFormatETag(t *testing.T) {
+	if got := formatETag(`"abc"`, msgpackContentType); got != `"abc-msgpack"` {
+		t.Errorf("unexpected %s", got)
+	}
+	if got := formatETag(`"abc"`, jsonContentType); got != `"abc"` {
+		t.Errorf("unexpected %s", got)
+	}
+}
+
+func TestWriteAs(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeAs(rec, httptest.NewRequest("GET", "/", nil), msgpackContentType, map[string]string{"id": "a"})
+	if rec.Header().Get("Content-Type") != msgpackContentType || rec.Body.String() != "\x81\xa2id\xa1a" {
+		t.Errorf("unexpected response %s %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	writeAs(rec, httptest.NewRequest("GET", "/", nil), jsonContentType, map[string]string{"id": "a"})
+	if rec.Header().Get("Content-Type") != jsonContentType || rec.Body.String() != `{"id":"a"}` {
+		t.Errorf("unexpected response %s %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
+
+// The MessagePack encodings should decode to the same documents as the JSON
+func TestMarshalMsgpackMatchesJSON(t *testing.T) {
+	setupFixtureRepo(t)
+	regions, err := repo.RegionsJSON(time.Now(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regionsV2, err := repo.RegionsV2JSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := repo.ServedChallenge(context.Background(), fixtureChallenge(t, ""))
+
+	for name, v := range map[string]any{"regions": regions.Value, "regions v2": regionsV2.Value, "challenge": challenge} {
+		b, err := marshalMsgpack(v)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var fromMsgpack any
+		if err := msgpack.Unmarshal(b, &fromMsgpack); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// Normalizes the integer types MessagePack decodes to
+		normalized, err := json.Marshal(fromMsgpack)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want, _ := json.Marshal(v)
+
+		var got, expected any
+		_ = json.Unmarshal(normalized, &got)
+		_ = json.Unmarshal(want, &expected)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, want, normalized)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// siteURL is the game's website, which along with the API's own public URL
+// is where shared challenge links point
+var siteURL string
+var oembedHosts []string
+
+// Matches the site's and the API's challenge paths
+var challengePathPattern = regexp.MustCompile(`^/(?:api/v[12]/)?challenge/([A-Za-z0-9_-]+)/?$`)
+
+type oembedResponse struct {
+	Type            string `json:"type"`
+	Version         string `json:"version"`
+	Title           string `json:"title"`
+	AuthorName      string `json:"author_name,omitempty"`
+	AuthorURL       string `json:"author_url,omitempty"`
+	ProviderName    string `json:"provider_name"`
+	ProviderURL     string `json:"provider_url"`
+	CacheAge        int    `json:"cache_age"`
+	URL             string `json:"url"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	ThumbnailURL    string `json:"thumbnail_url"`
+	ThumbnailWidth  int    `json:"thumbnail_width"`
+	ThumbnailHeight int    `json:"thumbnail_height"`
+}
+
+// handleGetOEmbed describes shared challenge links as oEmbed photos so that
+// forums and chat apps can unfurl them. Nothing that gives away the location
+// is included.
+func handleGetOEmbed(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	target := v.queryString("url", true, 2048)
+	maxWidth := v.queryInt("maxwidth", 0, 0, 10_000)
+	maxHeight := v.queryInt("maxheight", 0, 0, 10_000)
+	if !v.valid(w) {
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" {
+		httpError(w, r, "only the json format is supported", http.StatusNotImplemented)
+		return
+	}
+
+	id, ok := oembedChallengeID(target, oembedHosts)
+	if !ok {
+		httpError(w, r, "not a challenge url", http.StatusNotFound)
+		return
+	}
+	challenge, err := repo.Challenge(id)
+	if err != nil {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	}
+
+	// The large picture unless it doesn't fit, in which case the regular one
+	// is scaled down to fit
+	picture := challenge.Src.Large
+	if !fits(picture.Width, picture.Height, maxWidth, maxHeight) || picture.Src == "" {
+		picture = challenge.Src.Regular
+	}
+	width, height := fitWithin(picture.Width, picture.Height, maxWidth, maxHeight)
+	thumbWidth, thumbHeight := fitWithin(challenge.Src.Regular.Width, challenge.Src.Regular.Height, maxWidth, maxHeight)
+
+	resp := oembedResponse{
+		Type:            "photo",
+		Version:         "1.0",
+		Title:           challenge.Title,
+		AuthorName:      challenge.Photographer.Text,
+		ProviderName:    "ContourGuessr",
+		ProviderURL:     siteURL,
+		CacheAge:        3600,
+		URL:             picture.Src,
+		Width:           width,
+		Height:          height,
+		ThumbnailURL:    challenge.Src.Regular.Src,
+		ThumbnailWidth:  thumbWidth,
+		ThumbnailHeight: thumbHeight,
+	}
+	if challenge.Photographer.Link != "" {
+		resp.AuthorURL = repo.OutboundLink(challenge.Photographer.Link, challenge.ID)
+	}
+	if resp.Title == "" {
+		resp.Title = "ContourGuessr challenge"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// oembedChallengeID returns the challenge that target links to, if it's a
+// challenge link on one of hosts. Links are either to a challenge's path or to
+// a page with a challenge query parameter.
+func oembedChallengeID(target string, hosts []string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", false
+	}
+	hostOK := false
+	for _, host := range hosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			hostOK = true
+		}
+	}
+	if !hostOK {
+		return "", false
+	}
+	if m := challengePathPattern.FindStringSubmatch(u.Path); m != nil {
+		return m[1], true
+	}
+	if id := u.Query().Get("challenge"); id != "" {
+		return id, true
+	}
+	return "", false
+}
+
+func fits(width int, height int, maxWidth int, maxHeight int) bool {
+	return (maxWidth == 0 || width <= maxWidth) && (maxHeight == 0 || height <= maxHeight)
+}
+
+// fitWithin scales width and height down, keeping their ratio, until they
+// fit within the limits that are non-zero
+func fitWithin(width int, height int, maxWidth int, maxHeight int) (int, int) {
+	if maxWidth > 0 && width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if maxHeight > 0 && height > maxHeight {
+		width = width * maxHeight / height
+		height = maxHeight
+	}
+	return width, height
+}
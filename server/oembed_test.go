@@ -0,0 +1,45 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestOEmbedChallengeID(t *testing.T) {
+	hosts := []string{"contourguessr.org", "api.contourguessr.org"}
+	tests := map[string]string{
+		"https://contourguessr.org/challenge/abc":             "abc",
+		"https://CONTOURGUESSR.org/challenge/abc/":            "abc",
+		"https://api.contourguessr.org/api/v2/challenge/abc":  "abc",
+		"https://contourguessr.org/?challenge=abc&region=3":   "abc",
+		"https://contourguessr.org/challenge/abc/guess":       "",
+		"https://example.com/challenge/abc":                   "",
+		"https://contourguessr.org.example.com/challenge/abc": "",
+		"javascript:alert(1)":                                 "",
+		"https://contourguessr.org/about":                     "",
+	}
+	for target, want := range tests {
+		got, ok := oembedChallengeID(target, hosts)
+		if got != want || ok != (want != "") {
+			t.Errorf("%s: expected %q, got %q %v", target, want, got, ok)
+		}
+	}
+}
+
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		w, h, maxW, maxH int
+		wantW, wantH     int
+	}{
+		{1024, 768, 0, 0, 1024, 768},
+		{1024, 768, 512, 0, 512, 384},
+		{1024, 768, 0, 384, 512, 384},
+		{1024, 768, 800, 300, 400, 300},
+		{500, 300, 800, 600, 500, 300},
+	}
+	for _, tt := range tests {
+		w, h := fitWithin(tt.w, tt.h, tt.maxW, tt.maxH)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%dx%d within %dx%d: expected %dx%d, got %dx%d", tt.w, tt.h, tt.maxW, tt.maxH, tt.wantW, tt.wantH, w, h)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openapiSpec is written by hand and kept in step with the routes by
+// TestOpenAPIDocumentsEveryRoute
+//
+//go:embed openapi.json
+var openapiSpec []byte
+
+func handleGetOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_, _ = w.Write(openapiSpec)
+}
+
+// Swagger UI is loaded from a CDN rather than vendored, since the page is only
+// for people reading the docs
+const swaggerUIVersion = "5.17.14"
+
+const swaggerUIPage = `<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>ContourGuessr API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
+
+func handleGetDocs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_, _ = w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"contourguessr-api/pacing"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Operational endpoints that aren't part of the API
+var undocumentedPrefixes = []string{"/metrics", "/debug/", "/admin/debug/", "/openapi.json", "/docs"}
+
+func loadOpenAPISpec(t *testing.T) map[string]any {
+	t.Helper()
+	var spec map[string]any
+	if err := json.Unmarshal(openapiSpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func testRouter() *mux.Router {
+	router := mux.NewRouter()
+	registerRoutes(router, func(h http.HandlerFunc) http.Handler { return h })
+	return router
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	paths := spec["paths"].(map[string]any)
+
+	routed := make(map[string]bool)
+	err := testRouter().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			// A subrouter's prefix
+			return nil
+		}
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		for _, prefix := range undocumentedPrefixes {
+			if strings.HasPrefix(tmpl, prefix) {
+				return nil
+			}
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Any method, of which only GET is meaningful
+			methods = []string{"GET"}
+		}
+		for _, method := range methods {
+			routed[method+" "+tmpl] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range paths {
+		for method := range item.(map[string]any) {
+			if method == "parameters" {
+				continue
+			}
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for op := range routed {
+		if !documented[op] {
+			t.Errorf("%s is not in openapi.json", op)
+		}
+	}
+	for op := range documented {
+		if !routed[op] {
+			t.Errorf("%s is in openapi.json but not routed", op)
+		}
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				if resolveRef(spec, ref) == nil {
+					t.Errorf("unresolved %s", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
+
+// TestOpenAPIResponsesMatchSpec checks responses that don't need the database
+// against their documented schemas
+func TestOpenAPIResponsesMatchSpec(t *testing.T) {
+	pacingConfig.Store(&pacing.DefaultConfig)
+	spec := loadOpenAPISpec(t)
+	router := testRouter()
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+		// The documented path the target matches
+		path string
+		want int
+	}{
+		{"GET", "/api/v1/pacing?player=p1", "", "/api/v1/pacing", 200},
+		{"GET", "/api/v1/challenge/on-this-day?limit=0&date=x", "", "/api/v1/challenge/on-this-day", 400},
+		{"GET", "/api/v1/player/me/best?limit=0", "", "/api/v1/player/me/best", 401},
+		{"GET", "/api/v2/challenge/random?region=x", "", "/api/v2/challenge/random", 400},
+		{"POST", "/api/v2/challenge/abc/guess", `{"lng":500}`, "/api/v2/challenge/{id}/guess", 400},
+		{"POST", "/api/v1/leaderboard/NOPE", `{}`, "/api/v1/leaderboard/{board}", 400},
+		{"GET", "/admin/pacing", "", "/admin/pacing", 401},
+		{"POST", "/partner/v1/challenge/abc/flag", `{}`, "/partner/v1/challenge/{id}/flag", 401},
+		{"GET", "/out?target=https://evil.example.com", "", "/out", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body)
+			}
+
+			op, ok := spec["paths"].(map[string]any)[tt.path].(map[string]any)[strings.ToLower(tt.method)].(map[string]any)
+			if !ok {
+				t.Fatalf("%s %s not documented", tt.method, tt.path)
+			}
+			resp, ok := op["responses"].(map[string]any)[strconv.Itoa(rec.Code)]
+			if !ok {
+				resp, ok = op["responses"].(map[string]any)["default"]
+			}
+			if !ok {
+				t.Fatalf("status %d not documented", rec.Code)
+			}
+			resp = resolve(spec, resp)
+			contentType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+			media, ok := resp.(map[string]any)["content"].(map[string]any)[contentType].(map[string]any)
+			if !ok {
+				t.Fatalf("content type %q not documented for %d", contentType, rec.Code)
+			}
+
+			var body any = rec.Body.String()
+			if strings.HasSuffix(contentType, "json") {
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, problem := range validateSchema(spec, media["schema"], body, "body", false) {
+				t.Error(problem)
+			}
+		})
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	schema := map[string]any{"$ref": "#/components/schemas/Problem"}
+	var body any
+	_ = json.Unmarshal([]byte(`{"type":"about:blank","title":"Bad Request","status":400.5,"violations":[{"in":"header"}]}`), &body)
+	got := validateSchema(spec, schema, body, "body", false)
+	want := []string{
+		"body.code: required",
+		"body.status: expected an integer, got 400.5",
+		"body.violations[0].code: required",
+		"body.violations[0].detail: required",
+		"body.violations[0].field: required",
+		`body.violations[0].in: "header" not one of [query path body]`,
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestValidateSchemaStrict(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	schema := map[string]any{"$ref": "#/components/schemas/Problem"}
+	var body any
+	_ = json.Unmarshal([]byte(`{"type":"about:blank","title":"Bad Request","status":400,"code":"bad_request","renamed":true}`), &body)
+	if got := validateSchema(spec, schema, body, "body", false); len(got) != 0 {
+		t.Errorf("expected undocumented fields to be allowed, got %q", got)
+	}
+	got := validateSchema(spec, schema, body, "body", true)
+	if want := []string{"body.renamed: not documented"}; !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func resolveRef(spec map[string]any, ref string) any {
+	var v any = spec
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	return v
+}
+
+func resolve(spec map[string]any, v any) any {
+	for {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return v
+		}
+		v = resolveRef(spec, ref)
+	}
+}
+
+// validateSchema checks v against the subset of OpenAPI schemas that
+// openapi.json uses. Strict also reports properties the schema leaves out,
+// which is how a renamed field shows up.
+func validateSchema(spec map[string]any, schema any, v any, at string, strict bool) []string {
+	s, _ := resolve(spec, schema).(map[string]any)
+	if s == nil {
+		return nil
+	}
+	if v == nil && s["nullable"] == true {
+		return nil
+	}
+
+	var problems []string
+	fail := func(format string, args ...any) {
+		problems = append(problems, at+": "+fmt.Sprintf(format, args...))
+	}
+	if allOf, ok := s["allOf"].([]any); ok {
+		seen := make(map[string]bool)
+		for _, sub := range allOf {
+			for _, problem := range validateSchema(spec, sub, v, at, strict) {
+				// Each part only knows about its own properties
+				name, undocumented := strings.CutSuffix(strings.TrimPrefix(problem, at+"."), ": not documented")
+				if undocumented && (inAllOf(spec, s, name) || seen[name]) {
+					continue
+				}
+				seen[name] = undocumented
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	switch s["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("expected an object, got %v", v)
+			return problems
+		}
+		required, _ := s["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				problems = append(problems, at+"."+name.(string)+": required")
+			}
+		}
+		props, _ := s["properties"].(map[string]any)
+		for name, value := range obj {
+			if prop, ok := props[name]; ok {
+				problems = append(problems, validateSchema(spec, prop, value, at+"."+name, strict)...)
+			} else if additional, ok := s["additionalProperties"].(map[string]any); ok {
+				problems = append(problems, validateSchema(spec, additional, value, at+"."+name, strict)...)
+			} else if strict && s["additionalProperties"] != true && !inAllOf(spec, s, name) {
+				problems = append(problems, at+"."+name+": not documented")
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			fail("expected an array, got %v", v)
+			return problems
+		}
+		for i, item := range arr {
+			problems = append(problems, validateSchema(spec, s["items"], item, fmt.Sprintf("%s[%d]", at, i), strict)...)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			fail("expected a string, got %v", v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("expected a boolean, got %v", v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			fail("expected a number, got %v", v)
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			fail("expected an integer, got %v", v)
+		}
+	}
+
+	if enum, ok := s["enum"].([]any); ok && !slices.Contains(enum, v) {
+		fail("%q not one of %v", v, enum)
+	}
+	return problems
+}
+
+// inAllOf is whether one of the schemas s is composed of has the property
+func inAllOf(spec map[string]any, s map[string]any, name string) bool {
+	allOf, _ := s["allOf"].([]any)
+	for _, sub := range allOf {
+		sub, _ := resolve(spec, sub).(map[string]any)
+		if props, ok := sub["properties"].(map[string]any); ok && props[name] != nil {
+			return true
+		}
+		if sub != nil && inAllOf(spec, sub, name) {
+			return true
+		}
+	}
+	return false
+}
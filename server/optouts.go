@@ -0,0 +1,122 @@
+package server
+
+import (
+	"contourguessr-api/repos"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+var validOptOutStates = map[string]bool{
+	string(repos.OptOutPending):  true,
+	string(repos.OptOutApproved): true,
+	string(repos.OptOutRejected): true,
+}
+
+// handlePostOptOut lets a photographer ask for their photos to be removed.
+// Nothing changes until an admin has checked the request is genuine.
+func handlePostOptOut(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PhotographerLink string `json:"photographer_link"`
+		Contact          string `json:"contact"`
+		Reason           string `json:"reason"`
+	}
+	v := newValidator(r)
+	if v.decodeBody(&body) {
+		if v.bodyString("photographer_link", body.PhotographerLink, true, 512) != "" {
+			v.check(repos.PhotographerAccount(body.PhotographerLink) != "", "photographer_link", "invalid_value",
+				"photographer_link must be a Flickr profile link")
+		}
+		v.bodyString("contact", body.Contact, true, 256)
+		v.bodyString("reason", body.Reason, false, 2048)
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	o, err := repo.RequestOptOut(r.Context(), body.PhotographerLink, body.Contact, body.Reason)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error requesting opt-out", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      o.ID,
+		"account": o.Account,
+		"state":   o.State,
+	})
+}
+
+func handleGetOptOuts(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	state := repos.OptOutPending
+	if states := v.queryEnums("state", validOptOutStates); len(states) == 1 {
+		state = repos.OptOutState(states[0])
+	} else if len(states) > 1 {
+		v.add("query", "state", "invalid_value", "state must be a single state")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	optOuts, err := repo.OptOuts(r.Context(), state)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing opt-outs", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if optOuts == nil {
+		optOuts = []repos.OptOut{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(optOuts)
+}
+
+// handlePutOptOut approves or rejects a request. Approving it blocks the
+// photographer and retires their challenges straight away.
+func handlePutOptOut(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		State repos.OptOutState `json:"state"`
+	}
+	v := newValidator(r)
+	id := v.pathInt("id")
+	if v.decodeBody(&body) {
+		v.check(body.State == repos.OptOutApproved || body.State == repos.OptOutRejected, "state", "invalid_value",
+			"state must be approved or rejected")
+	}
+	if !v.valid(w) {
+		return
+	}
+
+	o, archived, err := repo.ReviewOptOut(r.Context(), id, body.State)
+	if errors.Is(err, repos.OptOutNotFoundError) {
+		httpError(w, r, "opt-out not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, repos.OptOutAlreadyReviewedError) {
+		httpError(w, r, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error reviewing opt-out", "opt_out", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if o.State == repos.OptOutApproved {
+		slog.InfoContext(r.Context(), "photographer opted out", "account", o.Account, "archived", len(archived))
+	}
+	if archived == nil {
+		archived = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"opt_out":  o,
+		"archived": archived,
+	})
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"contourguessr-api/repos"
+	"errors"
+	"github.com/gorilla/mux"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Photos are served through the API in v2 because Flickr's URLs contain the
+// photo ID, and the photo's page gives away where it was taken
+var photoClient = &http.Client{}
+
+// Headers passed on from the photo host
+var photoHeaders = []string{"Content-Type", "Content-Length", "ETag", "Last-Modified"}
+
+// photoURL is where the challenge's photo is proxied from in size, which is
+// regular, large or the width of one of its sizes
+func photoURL(challengeID string, size string) string {
+	return strings.TrimSuffix(publicURL, "/") + "/api/v1/photo/" + challengeID + "/" + size
+}
+
+// proxiedSrc replaces the challenge's photo URLs with photoURL
+func proxiedSrc(challenge repos.Challenge) any {
+	src := challenge.Src
+	src.Regular.Src = photoURL(challenge.ID, "regular")
+	src.Large.Src = photoURL(challenge.ID, "large")
+	sizes := challenge.PictureSizes()
+	src.Sizes = make([]repos.PictureSrc, len(sizes))
+	for i, size := range sizes {
+		size.Src = photoURL(challenge.ID, strconv.Itoa(size.Width))
+		src.Sizes[i] = size
+	}
+	return src
+}
+
+func handleGetPhoto(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	challenge, err := repo.Challenge(vars["id"])
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	var src string
+	switch vars["size"] {
+	case "regular":
+		src = challenge.Src.Regular.Src
+	case "large":
+		src = challenge.Src.Large.Src
+	default:
+		width, err := strconv.Atoi(vars["size"])
+		size, ok := challenge.SizeByWidth(width)
+		if err != nil || !ok {
+			httpError(w, r, "unknown size", http.StatusNotFound)
+			return
+		}
+		src = size.Src
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), "GET", src, nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "invalid photo url", "challenge", challenge.ID, "err", err)
+		httpError(w, r, "photo unavailable", http.StatusBadGateway)
+		return
+	}
+	for _, h := range []string{"If-None-Match", "If-Modified-Since"} {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	resp, err := photoClient.Do(req)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.WarnContext(r.Context(), "error fetching photo", "challenge", challenge.ID, "err", err)
+		httpError(w, r, "photo unavailable", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotModified {
+		slog.WarnContext(r.Context(), "error fetching photo", "challenge", challenge.ID, "status", resp.StatusCode)
+		httpError(w, r, "photo unavailable", http.StatusBadGateway)
+		return
+	}
+
+	for _, h := range photoHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	// Photos don't change, but a challenge's can if it's edited upstream
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// linkURL is where the challenge's link of kind, photo or photographer, is
+// redirected from in v2. Like the photos, the links would give away the photo's
+// ID, and so where it was taken. Following the photo link needs the reveal
+// token from a guess, as the Flickr page shows the geotag.
+func linkURL(challengeID string, kind string) string {
+	return strings.TrimSuffix(publicURL, "/") + "/api/v1/link/" + challengeID + "/" + kind
+}
+
+func handleGetChallengeLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	challenge, err := repo.Challenge(vars["id"])
+	if errors.Is(err, repos.ChallengeNotFoundError) {
+		httpError(w, r, "challenge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	var target string
+	switch vars["kind"] {
+	case "photo":
+		if _, err := verifyRevealToken(r.URL.Query().Get("reveal_token"), challenge.ID); err != nil {
+			httpError(w, r, "valid reveal_token required", http.StatusForbidden)
+			return
+		}
+		target = challenge.Link
+	case "photographer":
+		target = challenge.Photographer.Link
+	default:
+		httpError(w, r, "unknown link", http.StatusNotFound)
+		return
+	}
+	u, err := url.Parse(target)
+	if target == "" || err != nil {
+		httpError(w, r, "link unavailable", http.StatusNotFound)
+		return
+	}
+
+	recordOutboundClick(r, target, u.Hostname(), challenge.ID)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// servedLink is linkURL, or empty where the challenge has no link of kind
+func servedLink(challengeID string, kind string, target string) string {
+	if target == "" {
+		return ""
+	}
+	return linkURL(challengeID, kind)
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"contourguessr-api/repos"
+	"contourguessr-api/shared"
+	"contourguessr-api/tokens"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleGetPhoto(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/regular.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Set-Cookie", "upstream=1")
+		_, _ = w.Write([]byte("jpeg"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	f := repos.DefaultFixtures()
+	f.Challenges[0].Challenge.Src.Regular.Src = upstream.URL + "/regular.jpg"
+	f.Challenges[0].Challenge.Src.Large.Src = upstream.URL + "/large.jpg"
+	prev := repo
+	repo = repos.NewMemory(f)
+	t.Cleanup(func() { repo = prev })
+	c := fixtureChallenge(t, f.Challenges[0].Challenge.Title)
+
+	rec := serveFixtureRequest(t, "GET", "/api/v1/photo/"+c.ID+"/regular", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "jpeg" || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("expected the photo, got %d %q", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("ETag") != `"v1"` || rec.Header().Get("Set-Cookie") != "" {
+		t.Errorf("expected only the photo's headers to be passed on, got %v", rec.Header())
+	}
+
+	router := mux.NewRouter()
+	registerRoutes(router, func(h http.HandlerFunc) http.Handler { return h })
+	req := httptest.NewRequest("GET", "/api/v1/photo/"+c.ID+"/regular", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected the photo host's 304 to be passed on, got %d", rec.Code)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/photo/" + c.ID + "/large":  http.StatusBadGateway,
+		"/api/v1/photo/" + c.ID + "/square": http.StatusNotFound,
+		"/api/v1/photo/" + c.ID + "/1024":   http.StatusBadGateway,
+		"/api/v1/photo/" + c.ID + "/640":    http.StatusNotFound,
+		"/api/v1/photo/zzzzzz/regular":      http.StatusNotFound,
+	} {
+		if rec := serveFixtureRequest(t, "GET", path, ""); rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestChallengeV2ProxiesPhotos(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+	c := fixtureChallenge(t, "Snowdon")
+	resp, err := http.Get(ts.URL + "/api/v2/challenge/" + c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got struct {
+		Src struct {
+			Regular repos.PictureSrc   `json:"regular"`
+			Large   repos.PictureSrc   `json:"large"`
+			Sizes   []repos.PictureSrc `json:"sizes"`
+		} `json:"src"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Src.Regular.Src != photoURL(c.ID, "regular") || got.Src.Large.Width != c.Src.Large.Width {
+		t.Errorf("expected the photos to be proxied, got %+v", got.Src)
+	}
+	if len(got.Src.Sizes) != len(c.PictureSizes()) || got.Src.Sizes[0].Src != photoURL(c.ID, "320") {
+		t.Errorf("expected every size to be proxied, got %+v", got.Src.Sizes)
+	}
+}
+
+func TestChallengeV2HidesFlickrURLs(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+	c := fixtureChallenge(t, "Snowdon")
+	if !strings.Contains(c.Link, "flickr.com") || !strings.Contains(c.Photographer.Link, "flickr.com") {
+		t.Fatalf("expected the fixture to link to Flickr, got %q and %q", c.Link, c.Photographer.Link)
+	}
+
+	for _, accept := range []string{"application/json", protobufContentType} {
+		req, err := http.NewRequest("GET", ts.URL+"/api/v2/challenge/"+c.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", accept, resp.StatusCode)
+		}
+		if bytes.Contains(body, []byte("flickr")) {
+			t.Errorf("%s: expected no Flickr URLs, got %q", accept, body)
+		}
+		if !bytes.Contains(body, []byte(linkURL(c.ID, "photo"))) || !bytes.Contains(body, []byte(linkURL(c.ID, "photographer"))) {
+			t.Errorf("%s: expected the links to be redirected, got %q", accept, body)
+		}
+	}
+
+	pb, err := grpcChallenge(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(pb.String(), "flickr") {
+		t.Errorf("expected no Flickr URLs over gRPC, got %v", pb)
+	}
+}
+
+func TestHandleGetChallengeLink(t *testing.T) {
+	keepServerState(t)
+	setupFixtureRepo(t)
+	sharedStore = shared.NewMemory()
+	signer = tokens.NewSigner([]byte("test secret"))
+	c := fixtureChallenge(t, "Snowdon")
+	reveal, err := signer.Sign(revealClaims{Kind: "practice", ChallengeID: c.ID, Expires: time.Now().Add(revealTokenTTL).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	play, err := signer.Sign(playClaims{Kind: "play", ChallengeID: c.ID, Nonce: "n", Expires: time.Now().Add(playTokenTTL).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Flickr page shows where the photo was taken
+	for _, token := range []string{"", play} {
+		rec := serveFixtureRequest(t, "GET", "/api/v1/link/"+c.ID+"/photo?reveal_token="+token, "")
+		if rec.Code != http.StatusForbidden || rec.Header().Get("Location") != "" {
+			t.Errorf("expected the photo link to be forbidden before guessing, got %d %s", rec.Code, rec.Header().Get("Location"))
+		}
+	}
+
+	for path, want := range map[string]string{
+		"/api/v1/link/" + c.ID + "/photo?reveal_token=" + reveal: c.Link,
+		"/api/v1/link/" + c.ID + "/photographer":                 c.Photographer.Link,
+	} {
+		rec := serveFixtureRequest(t, "GET", path, "")
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != want {
+			t.Errorf("%s: expected a redirect to %s, got %d %s", path, want, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+	for _, path := range []string{"/api/v1/link/" + c.ID + "/icon", "/api/v1/link/zzzzzz/photo"} {
+		if rec := serveFixtureRequest(t, "GET", path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+}
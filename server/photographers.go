@@ -0,0 +1,32 @@
+package server
+
+import (
+	"contourguessr-api/repos"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+)
+
+// handleGetPhotographer credits a photographer with how their live photos
+// are used. It deliberately leaves out which challenges they are, as that
+// would give away where they were taken.
+func handleGetPhotographer(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	stats, err := repo.PhotographerStats(r.Context(), id)
+	if errors.Is(err, repos.PhotographerNotFoundError) {
+		httpError(w, r, "photographer not found", http.StatusNotFound)
+		return
+	} else if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting photographer stats", "photographer", id, "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=900")
+	_ = json.NewEncoder(w).Encode(stats)
+}
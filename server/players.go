@@ -0,0 +1,50 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// playerClaims prove the bearer was issued the player ID, which is needed to
+// read back the player's history. Only IDs the API generated are ever signed,
+// so a client can't claim someone else's.
+type playerClaims struct {
+	Kind   string `json:"k"`
+	Player string `json:"p"`
+}
+
+// requestPlayer is the player the request's bearer token was issued for
+func requestPlayer(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	var claims playerClaims
+	if err := signer.Verify(token, &claims); err != nil || claims.Kind != "player" || claims.Player == "" {
+		return "", false
+	}
+	return claims.Player, true
+}
+
+// handleCreatePlayer issues a new player ID along with the token that reads
+// back its history
+func handleCreatePlayer(w http.ResponseWriter, r *http.Request) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	player := base64.RawURLEncoding.EncodeToString(b)
+	token, err := signer.Sign(playerClaims{Kind: "player", Player: player})
+	if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"player":       player,
+		"player_token": token,
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// playTokenTTL is how long a served challenge can be played for. Tokens are
+// only remembered as used until they'd have expired anyway.
+const playTokenTTL = 24 * time.Hour
+
+// playClaims lets one guess be made on a challenge that was served by the
+// API, so that answers can't be harvested by scripting guesses on every ID
+type playClaims struct {
+	Kind        string `json:"k"`
+	ChallengeID string `json:"c"`
+	Nonce       string `json:"n"`
+	// The highest level of hint asked for with the token, whose guess is
+	// penalized for it
+	HintLevel int   `json:"h,omitempty"`
+	Expires   int64 `json:"exp"`
+}
+
+var InvalidPlayTokenError = errors.New("invalid play token")
+var PlayTokenUsedError = errors.New("play token already used")
+
+func newPlayToken(challengeID string) (string, error) {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return signer.Sign(playClaims{
+		Kind:        "play",
+		ChallengeID: challengeID,
+		Nonce:       base64.RawURLEncoding.EncodeToString(b),
+		Expires:     time.Now().Add(playTokenTTL).Unix(),
+	})
+}
+
+// verifyPlayToken checks the token was issued for the challenge and hasn't
+// expired, without using it up
+func verifyPlayToken(token string, challengeID string) (playClaims, error) {
+	var claims playClaims
+	if err := signer.Verify(token, &claims); err != nil || claims.Kind != "play" || claims.ChallengeID != challengeID {
+		return playClaims{}, InvalidPlayTokenError
+	}
+	if time.Now().After(time.Unix(claims.Expires, 0)) {
+		return playClaims{}, InvalidPlayTokenError
+	}
+	return claims, nil
+}
+
+// redeemPlayToken checks the token was issued for the challenge and marks it
+// as used, failing if it already had been
+func redeemPlayToken(ctx context.Context, token string, challengeID string) (playClaims, error) {
+	claims, err := verifyPlayToken(token, challengeID)
+	if err != nil {
+		return playClaims{}, err
+	}
+	// A little past expiry so that a token can't be replayed right at the edge
+	ok, err := sharedStore.SetNX(ctx, "playtoken:"+claims.Nonce, "1", time.Until(time.Unix(claims.Expires, 0))+time.Minute)
+	if err != nil {
+		return playClaims{}, err
+	} else if !ok {
+		return playClaims{}, PlayTokenUsedError
+	}
+	return claims, nil
+}
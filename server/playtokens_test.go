@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// servePlayToken gets the play token the v2 API serves the challenge with
+func servePlayToken(t *testing.T, ts *httptest.Server, id string) string {
+	t.Helper()
+	resp, err := http.Get(ts.URL + "/api/v2/challenge/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out challengeV2
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.PlayToken
+}
+
+func TestPlayTokensAreSingleUse(t *testing.T) {
+	cfg := testConfig()
+	cfg.RateLimit.Burst = 100
+	ts := newTestServer(t, cfg)
+	sample := repo.SampleChallenges(2)
+	c, other := sample[0], sample[1]
+	serve := func(id string) string {
+		return servePlayToken(t, ts, id)
+	}
+	guess := func(version string, id string, body map[string]any) int {
+		t.Helper()
+		body["lng"], body["lat"] = c.Geo.Lng, c.Geo.Lat
+		b, _ := json.Marshal(body)
+		resp, err := http.Post(ts.URL+"/api/"+version+"/challenge/"+id+"/guess", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, practice := range []bool{true, false} {
+		if status := guess("v2", c.ID, map[string]any{"practice": practice}); status != http.StatusForbidden {
+			t.Errorf("expected a v2 guess without a token to be forbidden, got %d", status)
+		}
+	}
+	if status := guess("v1", c.ID, map[string]any{}); status != http.StatusOK {
+		t.Errorf("expected v1 to accept a guess without a token, got %d", status)
+	}
+	features["play_tokens"] = true
+	if status := guess("v1", c.ID, map[string]any{}); status != http.StatusForbidden {
+		t.Errorf("expected v1 to require a token once enforced, got %d", status)
+	}
+
+	token := serve(c.ID)
+	if token == "" {
+		t.Fatal("expected a play token")
+	}
+	if status := guess("v2", c.ID, map[string]any{"play_token": token}); status != http.StatusOK {
+		t.Errorf("expected the first guess to be accepted, got %d", status)
+	}
+	if status := guess("v2", c.ID, map[string]any{"play_token": token}); status != http.StatusConflict {
+		t.Errorf("expected the token to be rejected once used, got %d", status)
+	}
+	if status := guess("v1", c.ID, map[string]any{"play_token": serve(c.ID)}); status != http.StatusOK {
+		t.Errorf("expected a fresh token to be accepted, got %d", status)
+	}
+
+	if status := guess("v2", other.ID, map[string]any{"play_token": serve(c.ID)}); status != http.StatusForbidden {
+		t.Errorf("expected a token for another challenge to be forbidden, got %d", status)
+	}
+}
+
+func TestPlayTokensDontReveal(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+	c, err := repo.RandomChallenge(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := servePlayToken(t, ts, c.ID)
+	if _, err := verifyRevealToken(token, c.ID); !errors.Is(err, InvalidRevealTokenError) {
+		t.Errorf("expected a play token to be an invalid reveal token, got %v", err)
+	}
+	resp, err := http.Get(ts.URL + "/api/v2/challenge/" + c.ID + "/full-metadata?reveal_token=" + url.QueryEscape(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected full metadata to be forbidden with a play token, got %d", resp.StatusCode)
+	}
+}
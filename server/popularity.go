@@ -0,0 +1,60 @@
+package server
+
+import (
+	"contourguessr-api/repos"
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log/slog"
+	"net/http"
+)
+
+var challengesServedCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "challenges_served_total",
+		Help:      "Challenges served to players partitioned by region",
+	},
+	[]string{"region"},
+)
+
+// recordServed counts challenges sent to be played, as opposed to listed for
+// browsing
+func recordServed(challenges ...repos.Challenge) {
+	for _, challenge := range challenges {
+		challengesServedCounter.WithLabelValues(challenge.RegionID).Inc()
+		repo.RecordServe(challenge)
+	}
+}
+
+func handleGetPopularity(w http.ResponseWriter, r *http.Request) {
+	v := newValidator(r)
+	regionID := v.queryOptionalInt("region")
+	limit := v.queryInt("limit", 50, 1, 1000)
+	if !v.valid(w) {
+		return
+	}
+
+	regions, err := repo.RegionPopularity(r.Context())
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting region popularity", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	challenges, err := repo.ChallengePopularity(r.Context(), regionID, limit)
+	if writeContextError(w, r, err) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "error getting challenge popularity", "err", err)
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"regions":    regions,
+		"challenges": challenges,
+	})
+}
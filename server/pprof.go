@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"math"
+	"net/http"
+	"net/http/pprof"
+	"runtime/metrics"
+)
+
+// Importing net/http/pprof also registers its handlers on
+// http.DefaultServeMux, which we never serve, so they're only reachable here
+// behind admin auth.
+func mountPprof(admin *mux.Router) {
+	admin.HandleFunc("/debug/pprof/", pprof.Index).Methods("GET")
+	admin.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline).Methods("GET")
+	admin.Handle("/debug/pprof/profile", withoutTimeout(http.HandlerFunc(pprof.Profile))).Methods("GET")
+	admin.HandleFunc("/debug/pprof/symbol", pprof.Symbol).Methods("GET", "POST")
+	admin.Handle("/debug/pprof/trace", withoutTimeout(http.HandlerFunc(pprof.Trace))).Methods("GET")
+	admin.Handle("/debug/pprof/{profile}", withoutTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pprof.Handler(mux.Vars(r)["profile"]).ServeHTTP(w, r)
+	}))).Methods("GET")
+	admin.HandleFunc("/debug/runtime-metrics", handleGetRuntimeMetrics).Methods("GET")
+}
+
+// withoutTimeout lets profiles run for longer than the request timeout, which
+// would otherwise cut them short. They're still bounded by their seconds
+// parameter.
+func withoutTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithoutCancel(r.Context())))
+	})
+}
+
+// handleGetRuntimeMetrics reports every runtime/metrics sample, which covers
+// far more of the scheduler and GC than the Go collector on /metrics
+func handleGetRuntimeMetrics(w http.ResponseWriter, r *http.Request) {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs))
+	for i, desc := range descs {
+		samples[i].Name = desc.Name
+	}
+	metrics.Read(samples)
+
+	out := make(map[string]any, len(samples))
+	for _, sample := range samples {
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			out[sample.Name] = sample.Value.Uint64()
+		case metrics.KindFloat64:
+			out[sample.Name] = jsonFloat(sample.Value.Float64())
+		case metrics.KindFloat64Histogram:
+			h := sample.Value.Float64Histogram()
+			buckets := make([]float64, len(h.Buckets))
+			for i, b := range h.Buckets {
+				buckets[i] = jsonFloat(b)
+			}
+			out[sample.Name] = map[string]any{"counts": h.Counts, "buckets": buckets}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// jsonFloat clamps infinities, which JSON can't represent and histograms use
+// for their outermost bounds
+func jsonFloat(f float64) float64 {
+	switch {
+	case math.IsInf(f, 1):
+		return math.MaxFloat64
+	case math.IsInf(f, -1):
+		return -math.MaxFloat64
+	case math.IsNaN(f):
+		return 0
+	}
+	return f
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPprofRequiresAdmin(t *testing.T) {
+	adminToken = "secret"
+	t.Cleanup(func() { adminToken = "" })
+	router := mux.NewRouter()
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(adminAuthMiddleware)
+	mountPprof(admin)
+
+	get := func(path string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, path := range []string{"/admin/debug/pprof/", "/admin/debug/pprof/heap", "/admin/debug/runtime-metrics"} {
+		if rec := get(path, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 without a token, got %d", path, rec.Code)
+		}
+	}
+
+	rec := get("/admin/debug/pprof/", "secret")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+		t.Errorf("expected the profile index, got %d", rec.Code)
+	}
+	rec = get("/admin/debug/pprof/goroutine?debug=1", "secret")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine profile") {
+		t.Errorf("expected a goroutine profile, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = get("/admin/debug/runtime-metrics", "secret")
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["/sched/goroutines:goroutines"]; !ok {
+		t.Error("expected the goroutine count")
+	}
+}
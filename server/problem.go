@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// problem is an RFC 7807 problem details document. Code is stable for
+// clients to branch on, while the detail is for people and may change.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+	// Users reporting an error can give us this to search the logs for
+	RequestID  string      `json:"request_id,omitempty"`
+	Violations []violation `json:"violations,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, p problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.RequestID = requestID(r.Context())
+
+	// As http.Error does
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// httpError responds with a problem whose code is derived from msg, which
+// must be a fixed string like "challenge not found". Use httpErrorCode for
+// messages that vary.
+func httpError(w http.ResponseWriter, r *http.Request, msg string, status int) {
+	httpErrorCode(w, r, problemCode(msg), msg, status)
+}
+
+// problemCode turns a message like "invalid region_id" into a code like
+// "invalid_region_id"
+func problemCode(msg string) string {
+	var sb strings.Builder
+	underscore := false
+	for _, c := range strings.ToLower(msg) {
+		if ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+			if underscore && sb.Len() > 0 {
+				sb.WriteByte('_')
+			}
+			underscore = false
+			sb.WriteRune(c)
+		} else {
+			underscore = true
+		}
+	}
+	return sb.String()
+}
+
+func httpErrorCode(w http.ResponseWriter, r *http.Request, code string, msg string, status int) {
+	writeProblem(w, r, problem{Status: status, Code: code, Detail: msg})
+}
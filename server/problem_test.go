@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPError(t *testing.T) {
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		httpError(w, r, "invalid region_id", http.StatusBadRequest)
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected a problem+json 400, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("expected Content-Length to be cleared")
+	}
+	var got problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := problem{
+		Type:      "about:blank",
+		Title:     "Bad Request",
+		Status:    400,
+		Detail:    "invalid region_id",
+		Code:      "invalid_region_id",
+		RequestID: "req-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestProblemCode(t *testing.T) {
+	tests := map[string]string{
+		"challenge not found":         "challenge_not_found",
+		"invalid region_id":           "invalid_region_id",
+		"valid reveal_token required": "valid_reveal_token_required",
+		"Unknown -- thing!":           "unknown_thing",
+	}
+	for msg, want := range tests {
+		if got := problemCode(msg); got != want {
+			t.Errorf("%q: expected %s, got %s", msg, want, got)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type rateLimit struct {
+	// Tokens added per second, and the most a bucket can hold
+	rate  float64
+	burst float64
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// When the bucket will have refilled, after which it behaves the same as
+	// a new one
+	full time.Time
+}
+
+// maxRateLimitBuckets bounds the memory used by clients that arrive faster
+// than idle buckets are swept, for example from a spread of IPv6 addresses
+const maxRateLimitBuckets = 100_000
+
+// rateLimiter keeps a token bucket per client. Limits are per replica.
+type rateLimiter struct {
+	ip  rateLimit
+	key rateLimit
+
+	mu         sync.Mutex
+	buckets    map[string]*bucket
+	maxBuckets int
+	swept      time.Time
+	now        func() time.Time
+}
+
+func newRateLimiter(ip rateLimit, key rateLimit) *rateLimiter {
+	return &rateLimiter{
+		ip:         ip,
+		key:        key,
+		buckets:    make(map[string]*bucket),
+		maxBuckets: maxRateLimitBuckets,
+		now:        time.Now,
+	}
+}
+
+// take removes cost tokens from the bucket for id and returns how long to wait
+// before retrying if there weren't enough
+func (l *rateLimiter) take(id string, limit rateLimit, cost float64) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[id]
+	if !ok {
+		if len(l.buckets) >= l.maxBuckets {
+			l.evict(now)
+		}
+		b = &bucket{tokens: limit.burst, last: now}
+		l.buckets[id] = b
+	}
+	b.tokens = math.Min(limit.burst, b.tokens+now.Sub(b.last).Seconds()*limit.rate)
+	b.last = now
+
+	if b.tokens >= cost {
+		b.tokens -= cost
+		b.full = now.Add(time.Duration((limit.burst - b.tokens) / limit.rate * float64(time.Second)))
+		return true, 0
+	}
+	wait := time.Duration((cost - b.tokens) / limit.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep forgets buckets that have had time to refill, which behave the same as
+// a new bucket
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	for id, b := range l.buckets {
+		if now.Sub(b.last) > 10*time.Minute {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+// evict makes room once there are maxBuckets. Buckets that have refilled go
+// first, and if that isn't enough a tenth of the rest are dropped at random,
+// which lets those clients start over with a full bucket.
+func (l *rateLimiter) evict(now time.Time) {
+	for id, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, id)
+		}
+	}
+	target := l.maxBuckets - l.maxBuckets/10
+	for id := range l.buckets {
+		if len(l.buckets) < target {
+			break
+		}
+		delete(l.buckets, id)
+	}
+}
+
+// requestCost makes requests that hit the database on every call use up more
+// of the bucket
+func requestCost(r *http.Request) float64 {
+	if r.Method == http.MethodPost || strings.HasPrefix(r.URL.Path, "/debug/") {
+		return 5
+	}
+	return 1
+}
+
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/debug/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Clients with a key apiKeyMiddleware has validated get their own,
+		// larger, allowance. The raw header counts for nothing, so made-up
+		// keys can't mint buckets.
+		id, limit := "ip:"+clientIP(r), l.ip
+		if key, ok := requestAPIKey(r); ok {
+			id, limit = "key:"+strconv.Itoa(key.ID), l.key
+		}
+		if !l.allow(w, r, id, limit, requestCost(r)) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes cost from the bucket for id, responding with 429 if there
+// wasn't enough
+func (l *rateLimiter) allow(w http.ResponseWriter, r *http.Request, id string, limit rateLimit, cost float64) bool {
+	if limit.rate <= 0 {
+		return true
+	}
+	ok, wait := l.take(id, limit, cost)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		httpError(w, r, "too many requests", http.StatusTooManyRequests)
+	}
+	return ok
+}
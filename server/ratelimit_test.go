@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/repos"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(rateLimit{rate: 1, burst: 3}, rateLimit{rate: 10, burst: 30})
+	l.now = func() time.Time { return now }
+	handler := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// key stands in for a key apiKeyMiddleware has checked
+	get := func(path string, remoteAddr string, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		if key != "" {
+			req = req.WithContext(context.WithValue(req.Context(), apiKeyContextKey{}, repos.APIKey{ID: 1, Name: key}))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := get("/api/v1/region", "203.0.113.7:1234", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	rec := get("/api/v1/region", "203.0.113.7:1234", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the burst is used, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+
+	if rec := get("/api/v1/region", "198.51.100.1:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected other clients to be unaffected, got %d", rec.Code)
+	}
+	if rec := get("/api/v1/region", "203.0.113.7:1234", "partner-key"); rec.Code != http.StatusOK {
+		t.Errorf("expected API keys to have their own bucket, got %d", rec.Code)
+	}
+	req := httptest.NewRequest("GET", "/api/v1/region", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	req.Header.Set("X-API-Key", "made-up")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected an unvalidated key to share the IP's bucket, got %d", rec.Code)
+	}
+	if rec := get("/metrics", "203.0.113.7:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected non-API paths to be unlimited, got %d", rec.Code)
+	}
+
+	now = now.Add(1 * time.Second)
+	if rec := get("/api/v1/region", "203.0.113.7:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected a token after refilling, got %d", rec.Code)
+	}
+}
+
+func TestRateLimiterEvicts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(rateLimit{rate: 1, burst: 3}, rateLimit{rate: 1, burst: 3})
+	l.now = func() time.Time { return now }
+	l.maxBuckets = 100
+
+	l.take("ip:refilled", l.ip, 1)
+	now = now.Add(2 * time.Second)
+	for i := range 99 {
+		l.take(fmt.Sprintf("ip:%d", i), l.ip, 1)
+	}
+	l.take("ip:new", l.ip, 1)
+	if _, ok := l.buckets["ip:refilled"]; ok {
+		t.Error("expected the refilled bucket to be evicted first")
+	}
+
+	for i := range 1000 {
+		l.take(fmt.Sprintf("ip:more-%d", i), l.ip, 1)
+		if len(l.buckets) > l.maxBuckets {
+			t.Fatalf("expected at most %d buckets, got %d", l.maxBuckets, len(l.buckets))
+		}
+	}
+}
+
+func TestRequestCost(t *testing.T) {
+	if got := requestCost(httptest.NewRequest("POST", "/api/v1/challenge/abc/guess", nil)); got != 5 {
+		t.Errorf("expected guesses to cost 5, got %v", got)
+	}
+	if got := requestCost(httptest.NewRequest("GET", "/debug/challenge", nil)); got != 5 {
+		t.Errorf("expected debug info to cost 5, got %v", got)
+	}
+	if got := requestCost(httptest.NewRequest("GET", "/api/v1/challenge/random", nil)); got != 1 {
+		t.Errorf("expected reads to cost 1, got %v", got)
+	}
+}
+
+func TestRevealsAreThrottled(t *testing.T) {
+	cfg := testConfig()
+	cfg.RateLimit.RevealsPerMinute = 1
+	cfg.RateLimit.RevealBurst = 2
+	ts := newTestServer(t, cfg)
+	c, err := repo.RandomChallenge(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(path string, body string) *http.Response {
+		t.Helper()
+		resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	guess := `{"lng": -3.5, "lat": 54.5, "player": "p1"}`
+	for i := range 2 {
+		if resp := post("/api/v1/challenge/"+c.ID+"/guess", guess); resp.StatusCode != http.StatusOK {
+			t.Fatalf("guess %d: expected 200, got %d", i, resp.StatusCode)
+		}
+	}
+	resp := post("/api/v1/challenge/"+c.ID+"/guess", guess)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After once the burst is used, got %d", resp.StatusCode)
+	}
+
+	for _, client := range []string{"ip:127.0.0.1", "player:p1"} {
+		if flagged, err := revealClientFlagged(context.Background(), client); err != nil || !flagged {
+			t.Errorf("expected %s to be flagged, got %v %v", client, flagged, err)
+		}
+	}
+	if resp := post("/api/v1/leaderboard/weekly", `{"player": "p1", "name": "Pat", "results": ["x"]}`); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a flagged player's score to be refused, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+var handlerPanicsCounter = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "handler_panics_total",
+		Help:      "Number of requests whose handler panicked",
+	},
+)
+
+// recoverMiddleware turns a panicking handler into a 500 for that request
+// alone. It goes inside requestIDMiddleware so the log line can be matched to
+// the ID the user was shown.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				// Deliberately aborted, net/http handles this quietly
+				panic(err)
+			}
+			handlerPanicsCounter.Inc()
+			slog.ErrorContext(r.Context(), "handler panicked",
+				"method", r.Method, "path", r.URL.Path, "err", err, "stack", string(debug.Stack()))
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clients that reveal answers faster than this are likely harvesting the
+// coordinates rather than playing. Flags are kept for a day in the shared
+// store so every replica knows about them.
+const revealFlagTTL = 24 * time.Hour
+
+var revealLimiter *rateLimiter
+var revealLimit rateLimit
+
+var revealsThrottledCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "reveals_throttled_total",
+		Help:      "Guesses refused for revealing answers too quickly partitioned by what the client was identified by",
+	},
+	[]string{"by"},
+)
+
+var revealClientsFlaggedCounter = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "contourguessr",
+		Name:      "reveal_clients_flagged_total",
+		Help:      "Clients flagged for revealing answers too quickly",
+	},
+)
+
+// throttleReveals counts a reveal against both the client's IP and the
+// player, if there is one, and responds with 429 if either is going too fast
+func throttleReveals(w http.ResponseWriter, r *http.Request, player string) bool {
+	if revealLimiter == nil || revealLimit.rate <= 0 {
+		return true
+	}
+	clients := []string{"ip:" + clientIP(r)}
+	if player != "" {
+		clients = append(clients, "player:"+player)
+	}
+	var longest time.Duration
+	for _, client := range clients {
+		ok, wait := revealLimiter.take(client, revealLimit, 1)
+		if ok {
+			continue
+		}
+		by, _, _ := strings.Cut(client, ":")
+		revealsThrottledCounter.WithLabelValues(by).Inc()
+		flagRevealClient(r.Context(), client)
+		longest = max(longest, wait)
+	}
+	if longest > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(longest.Seconds()))))
+		httpError(w, r, "too many reveals", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// revealClientFlagged reports whether the client was throttled in the last day
+func revealClientFlagged(ctx context.Context, client string) (bool, error) {
+	_, ok, err := sharedStore.Get(ctx, "revealflag:"+client)
+	return ok, err
+}
+
+func flagRevealClient(ctx context.Context, client string) {
+	first, err := sharedStore.SetNX(ctx, "revealflag:"+client, time.Now().UTC().Format(time.RFC3339), revealFlagTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "error flagging client", "client", client, "err", err)
+		return
+	}
+	if first {
+		revealClientsFlaggedCounter.Inc()
+		slog.WarnContext(ctx, "flagged client for revealing answers too quickly", "client", client)
+	}
+}
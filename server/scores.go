@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+const resultTokenTTL = 24 * time.Hour
+
+const maxRoundScore = 5000
+
+// Rounds score half of maxRoundScore at about 7km off
+const scoreDistanceScale = 10_000.0
+
+// resultClaims record the outcome of one recorded guess. Each round's claims
+// include a hash of the previous round's token, so a game's results form a
+// chain that can't be reordered, spliced, or have rounds left out.
+type resultClaims struct {
+	Kind        string  `json:"k"`
+	Game        string  `json:"gm"`
+	Round       int     `json:"r"`
+	ChallengeID string  `json:"c"`
+	GuessID     int64   `json:"g"`
+	DistanceM   float64 `json:"d"`
+	Score       int     `json:"s"`
+	Prev        string  `json:"p,omitempty"`
+	Expires     int64   `json:"exp"`
+	// Set for games started from a tour token, which must play Tour in order
+	Mode string   `json:"m,omitempty"`
+	Tour []string `json:"t,omitempty"`
+	// The highest level of hint asked for, which the score was penalized for
+	HintLevel int `json:"h,omitempty"`
+}
+
+var InvalidResultChainError = errors.New("invalid result chain")
+var ResultAlreadyExtendedError = errors.New("result already extended")
+
+func scoreForDistance(meters float64) int {
+	return int(math.Round(maxRoundScore * math.Exp(-meters/scoreDistanceScale)))
+}
+
+func resultTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+func newGameID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// nextResult starts a new game if prev is empty and otherwise continues the
+// game prev belongs to
+func nextResult(prev string) (resultClaims, error) {
+	if prev == "" {
+		return resultClaims{Kind: "result", Game: newGameID(), Round: 1}, nil
+	}
+	var claims resultClaims
+	if err := signer.Verify(prev, &claims); err != nil || claims.Kind != "result" || time.Now().Unix() > claims.Expires {
+		return resultClaims{}, InvalidResultChainError
+	}
+	return resultClaims{
+		Kind:  "result",
+		Game:  claims.Game,
+		Round: claims.Round + 1,
+		Prev:  resultTokenHash(prev),
+		Mode:  claims.Mode,
+		Tour:  claims.Tour,
+	}, nil
+}
+
+// claimNextRound marks prev as having had its next round played, failing if it
+// already had. Otherwise a player could guess again from the same prev once
+// the answer had been revealed.
+func claimNextRound(ctx context.Context, prev string) error {
+	if prev == "" {
+		return nil
+	}
+	// prev can't be used once it has expired, which is at most
+	// resultTokenTTL after it was issued
+	ok, err := sharedStore.SetNX(ctx, "resultnext:"+resultTokenHash(prev), "1", resultTokenTTL+time.Minute)
+	if err != nil {
+		return err
+	} else if !ok {
+		return ResultAlreadyExtendedError
+	}
+	return nil
+}
+
+// boardMode is the mode a leaderboard's games must have been played in. Each
+// tour has its own boards so that its scores aren't ranked against free play.
+func boardMode(board string) string {
+	if board == worldTourBoard {
+		return worldTourMode
+	}
+	if code, ok := strings.CutPrefix(board, customGameBoardPrefix); ok {
+		return customGameMode(code)
+	}
+	return ""
+}
+
+// verifyResultChain checks that tokens are every round of one game in order
+// and returns the game and its total score
+func verifyResultChain(tokens []string) (string, []resultClaims, int, error) {
+	if len(tokens) == 0 {
+		return "", nil, 0, InvalidResultChainError
+	}
+
+	rounds := make([]resultClaims, 0, len(tokens))
+	seen := make(map[string]bool)
+	total := 0
+	for i, token := range tokens {
+		var claims resultClaims
+		if err := signer.Verify(token, &claims); err != nil || claims.Kind != "result" {
+			return "", nil, 0, InvalidResultChainError
+		}
+		if time.Now().Unix() > claims.Expires || claims.Round != i+1 || seen[claims.ChallengeID] {
+			return "", nil, 0, InvalidResultChainError
+		}
+		if i == 0 {
+			if claims.Prev != "" {
+				return "", nil, 0, InvalidResultChainError
+			}
+		} else if claims.Game != rounds[0].Game || claims.Prev != resultTokenHash(tokens[i-1]) {
+			return "", nil, 0, InvalidResultChainError
+		}
+		seen[claims.ChallengeID] = true
+		rounds = append(rounds, claims)
+		total += claims.Score
+	}
+	if len(rounds[0].Tour) > 0 && len(rounds) != len(rounds[0].Tour) {
+		return "", nil, 0, InvalidResultChainError
+	}
+	return rounds[0].Game, rounds, total, nil
+}
+
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"contourguessr-api/repos"
+	"contourguessr-api/tokens"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func playGame(t *testing.T, scores []int) []string {
+	t.Helper()
+	var out []string
+	prev := ""
+	for i, score := range scores {
+		result, err := nextResult(prev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result.ChallengeID = string(rune('a' + i))
+		result.Score = score
+		result.Expires = time.Now().Add(time.Hour).Unix()
+		token, err := signer.Sign(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, token)
+		prev = token
+	}
+	return out
+}
+
+func TestVerifyResultChain(t *testing.T) {
+	signer = tokens.NewSigner([]byte("test secret"))
+
+	game := playGame(t, []int{100, 2000, 300})
+	id, rounds, total, err := verifyResultChain(game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == "" || len(rounds) != 3 || total != 2400 {
+		t.Errorf("unexpected result %q %d %d", id, len(rounds), total)
+	}
+
+	other := playGame(t, []int{5000, 5000})
+	invalid := map[string][]string{
+		"empty":     nil,
+		"missing":   {game[0], game[2]},
+		"reordered": {game[1], game[0], game[2]},
+		"spliced":   {game[0], other[1]},
+		"truncated": {game[1], game[2]},
+		"tampered":  {game[0], game[1] + "x"},
+	}
+	for name, chain := range invalid {
+		if _, _, _, err := verifyResultChain(chain); !errors.Is(err, InvalidResultChainError) {
+			t.Errorf("%s: expected invalid chain, got %v", name, err)
+		}
+	}
+}
+
+func TestScoreForDistance(t *testing.T) {
+	if got := scoreForDistance(0); got != maxRoundScore {
+		t.Errorf("expected a perfect guess to score %d, got %d", maxRoundScore, got)
+	}
+	if scoreForDistance(1_000) <= scoreForDistance(10_000) {
+		t.Error("expected closer guesses to score higher")
+	}
+	if got := scoreForDistance(1_000_000); got != 0 {
+		t.Errorf("expected a distant guess to score 0, got %d", got)
+	}
+}
+
+func TestVerifyWorldTourChain(t *testing.T) {
+	signer = tokens.NewSigner([]byte("test secret"))
+
+	tour, err := signer.Sign(tourClaims{
+		Kind:       "tour",
+		Game:       "tour-game",
+		Mode:       worldTourMode,
+		Challenges: []string{"a", "b"},
+		Expires:    time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var chain []string
+	for i, c := range []string{"a", "b"} {
+		var result resultClaims
+		if i == 0 {
+			result, err = startTour(tour)
+		} else {
+			result, err = nextResult(chain[i-1])
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		result.ChallengeID = c
+		result.Expires = time.Now().Add(time.Hour).Unix()
+		token, err := signer.Sign(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, token)
+	}
+
+	_, rounds, _, err := verifyResultChain(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rounds[1].Mode != worldTourMode {
+		t.Errorf("expected mode to carry through the chain, got %q", rounds[1].Mode)
+	}
+	if _, _, _, err := verifyResultChain(chain[:1]); !errors.Is(err, InvalidResultChainError) {
+		t.Errorf("expected an unfinished tour to be rejected, got %v", err)
+	}
+}
+
+func TestBoardMode(t *testing.T) {
+	tests := map[string]string{
+		"daily":                   "",
+		worldTourBoard:            worldTourMode,
+		"custom-bcdfghjk":         "custom:bcdfghjk",
+		"customised-but-not-tour": "",
+	}
+	for board, want := range tests {
+		if got := boardMode(board); got != want {
+			t.Errorf("%s: expected mode %q, got %q", board, want, got)
+		}
+	}
+}
+
+func TestResultCanOnlyBeContinuedOnce(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+	first, err := repo.RandomChallenge(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := fixtureChallenge(t, "Tryfan")
+	if second.ID == first.ID {
+		second = fixtureChallenge(t, "Scafell")
+	}
+
+	guess := func(c repos.Challenge, prev string) (int, string) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"lng": c.Geo.Lng, "lat": c.Geo.Lat, "prev_result": prev})
+		resp, err := http.Post(ts.URL+"/api/v1/challenge/"+c.ID+"/guess", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Result string `json:"result_token"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.Result
+	}
+
+	status, prev := guess(first, "")
+	if status != http.StatusOK || prev == "" {
+		t.Fatalf("expected a result for the first round, got %d", status)
+	}
+	if status, _ := guess(second, prev); status != http.StatusOK {
+		t.Fatalf("expected the second round to be recorded, got %d", status)
+	}
+	if status, _ := guess(second, prev); status != http.StatusConflict {
+		t.Errorf("expected guessing again from the same result to be refused, got %d", status)
+	}
+}
@@ -0,0 +1,289 @@
+// Package server is the HTTP API. Handlers share package-level state that New
+// sets up, so a process should only create one Server.
+package server
+
+import (
+	"context"
+	"contourguessr-api/config"
+	"contourguessr-api/elevation"
+	"contourguessr-api/geocode"
+	"contourguessr-api/pacing"
+	"contourguessr-api/repos"
+	"contourguessr-api/shared"
+	"contourguessr-api/tokens"
+	"contourguessr-api/weather"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Server struct {
+	cfg       config.Config
+	handler   http.Handler
+	clientIPs *clientIPResolver
+}
+
+// RepoOptions are what the repo passed to New should be created with, so that
+// its refreshes show up in metrics and live events
+func RepoOptions(cfg config.Config) []repos.Option {
+	opts := []repos.Option{
+		repos.WithOutboundLinks(strings.TrimSuffix(cfg.PublicURL, "/") + "/out"),
+		repos.WithRefreshIntervals(cfg.Refresh.Regions, cfg.Refresh.Challenges),
+		repos.WithRefreshObserver(func(res repos.RefreshResult) {
+			observeRefresh(res)
+			select {
+			case liveDataRefreshed <- struct{}{}:
+			default:
+			}
+		}),
+		repos.WithCapabilitiesObserver(observeCapabilitiesFetch),
+	}
+	if cfg.ArchiveRemovedAfter > 0 {
+		opts = append(opts, repos.WithArchiveRemovedAfter(cfg.ArchiveRemovedAfter))
+	}
+	return opts
+}
+
+// New sets up the API to serve from r, which should be ready
+func New(cfg config.Config, r repos.Store) (*Server, error) {
+	repo = r
+	features = cfg.Features
+
+	tokenSecret := []byte(cfg.TokenSecret)
+	if len(tokenSecret) == 0 {
+		slog.Warn("TOKEN_SECRET not set, using a random secret")
+		tokenSecret = make([]byte, 32)
+		if _, err := rand.Read(tokenSecret); err != nil {
+			return nil, err
+		}
+	}
+	signer = tokens.NewSigner(tokenSecret)
+	var err error
+	if hintKey, err = deriveHintKey(tokenSecret); err != nil {
+		return nil, err
+	}
+
+	adminToken = cfg.AdminToken
+	if adminToken == "" {
+		slog.Warn("ADMIN_TOKEN not set, admin endpoints are disabled")
+	}
+
+	weatherURL := cfg.WeatherAPIURL
+	if weatherURL == "" {
+		weatherURL = weather.DefaultBaseURL
+	}
+	weatherClient = weather.NewClient(weatherURL)
+
+	geocodeURL := cfg.GeocodeAPIURL
+	if geocodeURL == "" {
+		geocodeURL = geocode.DefaultBaseURL
+	}
+	geocodeClient = geocode.NewClient(geocodeURL)
+
+	elevationURL := cfg.ElevationAPIURL
+	if elevationURL == "" {
+		elevationURL = elevation.DefaultBaseURL
+	}
+	elevationClient = elevation.NewClient(elevationURL)
+
+	// Shared state only matters with more than one replica, so Redis is
+	// optional
+	if cfg.RedisURL != "" {
+		redisCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		store, err := shared.DialRedis(redisCtx, cfg.RedisURL)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to redis: %w", err)
+		}
+		sharedStore = store
+	} else {
+		sharedStore = shared.NewMemory()
+	}
+	geocodeClient.SetLimiter(shared.NewIntervalLimiter(sharedStore, "ratelimit:geocode", geocode.MinInterval))
+
+	outboundAllowedHosts = cfg.OutboundAllowedHosts
+	siteURL = cfg.SiteURL
+	publicURL = cfg.PublicURL
+	oembedHosts = nil
+	for _, u := range []string{cfg.SiteURL, cfg.PublicURL} {
+		if parsed, err := url.Parse(u); err == nil && parsed.Hostname() != "" {
+			oembedHosts = append(oembedHosts, parsed.Hostname())
+		}
+	}
+	readyMaxRegionsAge = cfg.Ready.MaxRegionsAge
+	readyMaxChallengesAge = cfg.Ready.MaxChallengesAge
+	pacingConfig.Store(&pacing.DefaultConfig)
+
+	clientIPs, err := newClientIPResolver(cfg.Proxy.Trusted, cfg.Proxy.Header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+
+	router := mux.NewRouter()
+
+	router.Use(routeLabelMiddleware)
+	router.Use(timeoutMiddleware(cfg.RequestTimeout))
+	router.Use(compressMiddleware)
+	router.Use(newFeatureOverrides(cfg.QA.Features, cfg.QA.StaffTokens).middleware)
+
+	// Once v2 is announced, v1 endpoints it replaces are marked deprecated so
+	// that the remaining clients can be found before they're removed
+	v1Replaced := func(h http.HandlerFunc) http.Handler { return h }
+	if cfg.Deprecation.V1Since != "" {
+		d := deprecation{Link: cfg.Deprecation.Link}
+		d.Since, _ = time.Parse(time.DateOnly, cfg.Deprecation.V1Since)
+		d.Sunset, _ = time.Parse(time.DateOnly, cfg.Deprecation.V1Sunset)
+		v1Replaced = func(h http.HandlerFunc) http.Handler { return deprecated(d)(h) }
+	}
+
+	registerRoutes(router, v1Replaced)
+
+	limiter := newRateLimiter(
+		rateLimit{rate: cfg.RateLimit.RPS, burst: cfg.RateLimit.Burst},
+		rateLimit{rate: cfg.RateLimit.KeyRPS, burst: cfg.RateLimit.KeyBurst},
+	)
+	revealLimit = rateLimit{rate: cfg.RateLimit.RevealsPerMinute / 60, burst: cfg.RateLimit.RevealBurst}
+	revealLimiter = newRateLimiter(revealLimit, revealLimit)
+	handler := corsMiddleware(corsConfig{
+		allowedOrigins: cfg.CORS.AllowedOrigins,
+		allowedHeaders: cfg.CORS.AllowedHeaders,
+		maxAge:         cfg.CORS.MaxAge,
+	})(apiKeyMiddleware(limiter)(limiter.middleware(router)))
+	handler = recoverMiddleware(handler)
+	handler = metricsMiddleware(handler)
+	if cfg.Log.Access {
+		handler = accessLogMiddleware(slog.Default())(handler)
+	}
+	if errorReporter != nil {
+		handler = errorReportMiddleware(handler)
+	}
+	handler = clientIPMiddleware(clientIPs)(handler)
+	handler = requestIDMiddleware(handler)
+
+	return &Server{cfg: cfg, handler: handler, clientIPs: clientIPs}, nil
+}
+
+// Handler serves the API with every middleware applied
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Start runs the background work that keeps metrics, live events and pacing
+// up to date. Run calls it, but servers embedding Handler need to themselves.
+func (s *Server) Start() {
+	go updateChallengesPerRegionCounter()
+	go watchLiveData(liveEvents, liveDataRefreshed)
+	if s.cfg.ImageHostCheckInterval > 0 {
+		go monitorImageHosts(s.cfg.ImageHostCheckInterval)
+	}
+	if s.cfg.TerrainBackfillInterval > 0 {
+		go backfillTerrain(s.cfg.TerrainBackfillInterval)
+	}
+	if s.cfg.BlurHashBackfillInterval > 0 {
+		go backfillBlurHashes(s.cfg.BlurHashBackfillInterval)
+	}
+	if s.cfg.TimezoneBackfillInterval > 0 {
+		go backfillTimezones(s.cfg.TimezoneBackfillInterval)
+	}
+
+	refreshPacingConfig()
+	go func() {
+		for range time.Tick(1 * time.Minute) {
+			refreshPacingConfig()
+		}
+	}()
+}
+
+// Run starts the server and listens on the configured ports until ctx is
+// done, and then drains requests for up to the shutdown timeout
+func (s *Server) Run(ctx context.Context) error {
+	cfg := s.cfg
+	s.Start()
+
+	errs := make(chan error, 3)
+	serve := func(serve func() error) {
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+	}
+
+	addr := cfg.Host + ":" + cfg.Port
+	server := &http.Server{Addr: addr, Handler: s.handler}
+	server.RegisterOnShutdown(liveEvents.close)
+	var redirectServer *http.Server
+	if cfg.TLS.CertFile != "" {
+		certs, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return fmt.Errorf("error loading TLS certificate: %w", err)
+		}
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+		if cfg.TLS.HTTPPort != "" {
+			redirectAddr := cfg.Host + ":" + cfg.TLS.HTTPPort
+			redirectServer = &http.Server{Addr: redirectAddr, Handler: httpsRedirectHandler(cfg.TLS.ACMEWebroot, cfg.Port)}
+			go func() {
+				slog.Info("listening for HTTPS redirects", "addr", redirectAddr)
+				serve(redirectServer.ListenAndServe)
+			}()
+		}
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if cfg.Proxy.Protocol {
+		listener = &proxyProtocolListener{Listener: listener, resolver: s.clientIPs}
+	}
+	go func() {
+		slog.Info("listening", "addr", addr, "tls", server.TLSConfig != nil, "proxy_protocol", cfg.Proxy.Protocol)
+		if server.TLSConfig != nil {
+			serve(func() error { return server.ServeTLS(listener, "", "") })
+		} else {
+			serve(func() error { return server.Serve(listener) })
+		}
+	}()
+	var grpcServer *http.Server
+	if cfg.GRPCPort != "" {
+		grpcServer = newGRPCServer(cfg.Host + ":" + cfg.GRPCPort)
+		go func() {
+			slog.Info("listening for gRPC", "addr", grpcServer.Addr)
+			serve(grpcServer.ListenAndServe)
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	case err = <-errs:
+	}
+
+	// Kubernetes sends SIGKILL 30s after SIGTERM by default, so stop waiting
+	// for stragglers a little before that
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error draining requests", "err", err)
+	}
+	if redirectServer != nil {
+		_ = redirectServer.Shutdown(shutdownCtx)
+	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("error draining gRPC calls", "err", err)
+		}
+	}
+	if err := sharedStore.Close(); err != nil {
+		slog.Error("error closing shared store", "err", err)
+	}
+	return err
+}
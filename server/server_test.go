@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"contourguessr-api/config"
+	"contourguessr-api/repos"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// keepServerState puts back the package state that New replaces once the
+// test is done
+func keepServerState(t *testing.T) {
+	t.Helper()
+	prevRepo, prevSigner, prevAdminToken, prevFeatures := repo, signer, adminToken, features
+	prevSharedStore, prevWeather, prevGeocode, prevElevation := sharedStore, weatherClient, geocodeClient, elevationClient
+	prevOutbound, prevSiteURL, prevPublicURL, prevOEmbedHosts := outboundAllowedHosts, siteURL, publicURL, oembedHosts
+	prevRegionsAge, prevChallengesAge, prevHintKey := readyMaxRegionsAge, readyMaxChallengesAge, hintKey
+	prevRevealLimiter, prevRevealLimit := revealLimiter, revealLimit
+	t.Cleanup(func() {
+		repo, signer, adminToken, features = prevRepo, prevSigner, prevAdminToken, prevFeatures
+		sharedStore, weatherClient, geocodeClient, elevationClient = prevSharedStore, prevWeather, prevGeocode, prevElevation
+		outboundAllowedHosts, siteURL, publicURL, oembedHosts = prevOutbound, prevSiteURL, prevPublicURL, prevOEmbedHosts
+		readyMaxRegionsAge, readyMaxChallengesAge, hintKey = prevRegionsAge, prevChallengesAge, prevHintKey
+		revealLimiter, revealLimit = prevRevealLimiter, prevRevealLimit
+		apiKeys.reset()
+	})
+}
+
+// newTestServer serves repos.DefaultFixtures through New with cfg
+func newTestServer(t *testing.T, cfg config.Config) *httptest.Server {
+	t.Helper()
+	keepServerState(t)
+	srv, err := New(cfg, repos.NewMemory(repos.DefaultFixtures()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func testConfig() config.Config {
+	cfg := config.Default()
+	cfg.TokenSecret = "test secret"
+	cfg.AdminToken = "admin"
+	return cfg
+}
+
+func TestServerPlaysARound(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+
+	resp, err := http.Get(ts.URL + "/api/v1/challenge/random")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Error("expected the middleware to assign a request ID")
+	}
+	var c repos.Challenge
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(map[string]any{"lng": c.Geo.Lng, "lat": c.Geo.Lat, "player": "p1"})
+	resp, err = http.Post(ts.URL+"/api/v1/challenge/"+c.ID+"/guess", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for the guess, got %d", resp.StatusCode)
+	}
+	var result struct {
+		DistanceM float64 `json:"distance_m"`
+		Result    string  `json:"result_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.DistanceM > 1 || result.Result == "" {
+		t.Errorf("expected a signed result for a perfect guess, got %+v", result)
+	}
+}
+
+func TestServerAdminAuth(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+
+	for token, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "admin": http.StatusOK} {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/export/challenges.csv", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("token %q: expected %d, got %d", token, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestNewRejectsInvalidProxyConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.Proxy.Trusted = []string{"not an address"}
+	keepServerState(t)
+
+	if _, err := New(cfg, repos.NewMemory(repos.DefaultFixtures())); err == nil {
+		t.Error("expected an invalid trusted proxy to be rejected")
+	}
+}
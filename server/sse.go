@@ -0,0 +1,258 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxEventSubscribers = 1000
+	// Enough for a household or office behind one address, while stopping a
+	// single client from taking every place
+	maxEventSubscribersPerIP = 10
+	// Each subscriber can fall this many events behind before it's dropped
+	eventBufferSize = 16
+	// Proxies close connections that are idle for too long
+	eventKeepaliveInterval = 30 * time.Second
+	// How long browsers wait before reconnecting after the stream drops
+	eventRetryInterval = 5 * time.Second
+	// Catches regions changed by capabilities refreshes, which aren't observed
+	liveDataCheckInterval = 1 * time.Minute
+)
+
+const eventsRoute = "/api/v1/events"
+
+// liveEvents is streamed to long-lived sessions by GET /api/v1/events
+var liveEvents = newEventHub(maxEventSubscribers, maxEventSubscribersPerIP)
+
+var EventHubFullError = errors.New("event hub full")
+var TooManyEventSubscriptionsError = errors.New("too many event subscriptions from this address")
+
+// liveDataRefreshed is signalled by the repo after each reload
+var liveDataRefreshed = make(chan struct{}, 1)
+
+type sseEvent struct {
+	ID   uint64
+	Name string
+	Data []byte
+}
+
+// eventHub fans events out to subscribers. It remembers the latest event of
+// each name so that new subscribers start with the current state, which means
+// a client that drops events can simply reconnect.
+type eventHub struct {
+	mu             sync.Mutex
+	maxSubscribers int
+	maxPerIP       int
+	nextID         uint64
+	latest         map[string]sseEvent
+	// The IP each subscriber connected from
+	subscribers map[chan sseEvent]string
+	perIP       map[string]int
+	closed      bool
+}
+
+func newEventHub(maxSubscribers int, maxPerIP int) *eventHub {
+	return &eventHub{
+		maxSubscribers: maxSubscribers,
+		maxPerIP:       maxPerIP,
+		latest:         make(map[string]sseEvent),
+		subscribers:    make(map[chan sseEvent]string),
+		perIP:          make(map[string]int),
+	}
+}
+
+func (h *eventHub) publish(name string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextID++
+	ev := sseEvent{ID: h.nextID, Name: name, Data: b}
+	h.latest[name] = ev
+	for ch := range h.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Too slow, so cut it off rather than block everyone else
+			h.remove(ch)
+		}
+	}
+	return nil
+}
+
+// subscribe returns a channel that receives the latest events and then every
+// new one. It fails if the hub is full or closed, or ip already has its share
+// of subscriptions. The channel is closed if the subscriber falls behind or
+// the hub closes.
+func (h *eventHub) subscribe(ip string) (chan sseEvent, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed || len(h.subscribers) >= h.maxSubscribers {
+		return nil, EventHubFullError
+	}
+	if h.perIP[ip] >= h.maxPerIP {
+		return nil, TooManyEventSubscriptionsError
+	}
+	ch := make(chan sseEvent, eventBufferSize)
+	latest := slices.SortedFunc(maps.Values(h.latest), func(a, b sseEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for _, ev := range latest {
+		ch <- ev
+	}
+	h.subscribers[ch] = ip
+	h.perIP[ip]++
+	return ch, nil
+}
+
+func (h *eventHub) unsubscribe(ch chan sseEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[ch]; ok {
+		h.remove(ch)
+	}
+}
+
+// remove needs h.mu held
+func (h *eventHub) remove(ch chan sseEvent) {
+	ip := h.subscribers[ch]
+	if h.perIP[ip]--; h.perIP[ip] <= 0 {
+		delete(h.perIP, ip)
+	}
+	delete(h.subscribers, ch)
+	close(ch)
+}
+
+// close ends every stream so that shutdown isn't held up by them
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subscribers {
+		h.remove(ch)
+	}
+}
+
+type regionsEvent struct {
+	// Matches the ETag of GET /api/v2/region
+	ETag      string    `json:"etag"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type challengeCountsEvent struct {
+	Total     int            `json:"total"`
+	PerRegion map[string]int `json:"per_region"`
+}
+
+// watchLiveData publishes an event whenever the regions or the number of
+// challenges per region differ from what was last published. refreshed is
+// signalled after each reload.
+func watchLiveData(hub *eventHub, refreshed <-chan struct{}) {
+	var etag string
+	var counts map[int]int
+	check := func() {
+		encoded, err := repo.RegionsV2JSON(nil)
+		if err != nil {
+			slog.Error("error encoding regions for live events", "err", err)
+		} else if encoded.ETag != etag {
+			etag = encoded.ETag
+			if err := hub.publish("regions", regionsEvent{ETag: formatETag(etag, jsonContentType), UpdatedAt: repo.RegionsUpdatedAt()}); err != nil {
+				slog.Error("error publishing regions event", "err", err)
+			}
+		}
+
+		if current := repo.ChallengesPerRegion(); !maps.Equal(current, counts) {
+			counts = current
+			ev := challengeCountsEvent{PerRegion: make(map[string]int, len(counts))}
+			for region, n := range counts {
+				ev.Total += n
+				ev.PerRegion[strconv.Itoa(region)] = n
+			}
+			if err := hub.publish("challenge_counts", ev); err != nil {
+				slog.Error("error publishing challenge counts event", "err", err)
+			}
+		}
+	}
+
+	check()
+	t := time.NewTicker(liveDataCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-refreshed:
+		case <-t.C:
+		}
+		check()
+	}
+}
+
+func handleGetEvents(w http.ResponseWriter, r *http.Request) {
+	serveEvents(liveEvents, w, r)
+}
+
+// serveEvents streams hub as server-sent events until the client goes away
+// or the hub drops it. Every connection starts with the current state, so
+// Last-Event-ID is ignored.
+func serveEvents(hub *eventHub, w http.ResponseWriter, r *http.Request) {
+	events, err := hub.subscribe(clientIP(r))
+	if errors.Is(err, TooManyEventSubscriptionsError) {
+		httpError(w, r, "too many event streams from this address", http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		httpError(w, r, "too many event subscribers", http.StatusServiceUnavailable)
+		return
+	}
+	defer hub.unsubscribe(events)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	// Stops nginx buffering the stream
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventRetryInterval.Milliseconds()); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		slog.ErrorContext(r.Context(), "event stream can't be flushed", "err", err)
+		return
+	}
+
+	keepalive := time.NewTicker(eventKeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		var err error
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Name, ev.Data)
+		case <-keepalive.C:
+			_, err = fmt.Fprint(w, ": keepalive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bufio"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventHub(t *testing.T) {
+	hub := newEventHub(2, 2)
+	_ = hub.publish("regions", map[string]int{"v": 1})
+	_ = hub.publish("challenge_counts", map[string]int{"v": 1})
+	_ = hub.publish("regions", map[string]int{"v": 2})
+
+	events, err := hub.subscribe("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := <-events, <-events
+	if first.Name != "challenge_counts" || second.Name != "regions" || string(second.Data) != `{"v":2}` {
+		t.Errorf("expected the latest of each event in order, got %+v %+v", first, second)
+	}
+
+	slow, _ := hub.subscribe("192.0.2.2")
+	if _, err := hub.subscribe("192.0.2.3"); err != EventHubFullError {
+		t.Errorf("expected a full hub to refuse subscribers, got %v", err)
+	}
+
+	for i := 0; i < eventBufferSize+1; i++ {
+		_ = hub.publish("regions", i)
+		<-events
+	}
+	for range slow {
+	}
+	if _, err := hub.subscribe("192.0.2.3"); err != nil {
+		t.Errorf("expected the slow subscriber's place to be freed, got %v", err)
+	}
+
+	hub.close()
+	if _, ok := <-events; ok {
+		t.Error("expected close to end subscriptions")
+	}
+	if _, err := hub.subscribe("192.0.2.4"); err != EventHubFullError {
+		t.Errorf("expected a closed hub to refuse subscribers, got %v", err)
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	hub := newEventHub(10, 10)
+	_ = hub.publish("regions", regionsEvent{ETag: `"abc"`, UpdatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)})
+	srv := httptest.NewServer(compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveEvents(hub, w, r)
+	})))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	// Compression would hold events back until the buffer filled
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" || resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("unexpected headers %v", resp.Header)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	readEvent := func() string {
+		t.Helper()
+		var ev []string
+		for lines.Scan() {
+			if lines.Text() == "" {
+				return strings.Join(ev, "\n")
+			}
+			ev = append(ev, lines.Text())
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return ""
+	}
+
+	if got := readEvent(); got != "retry: 5000" {
+		t.Errorf("expected a retry interval, got %q", got)
+	}
+	if got, want := readEvent(), "id: 1\nevent: regions\ndata: {\"etag\":\"\\\"abc\\\"\",\"updated_at\":\"2024-05-01T00:00:00Z\"}"; got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+	_ = hub.publish("challenge_counts", challengeCountsEvent{Total: 3, PerRegion: map[string]int{"1": 3}})
+	if got, want := readEvent(), "id: 2\nevent: challenge_counts\ndata: {\"total\":3,\"per_region\":{\"1\":3}}"; got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+
+	hub.close()
+	if lines.Scan() {
+		t.Errorf("expected the stream to end, got %q", lines.Text())
+	}
+}
+
+func TestServeEventsFull(t *testing.T) {
+	hub := newEventHub(0, 10)
+	rec := httptest.NewRecorder()
+	serveEvents(hub, rec, httptest.NewRequest("GET", eventsRoute, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+}
+
+func TestEventHubLimitsPerIP(t *testing.T) {
+	hub := newEventHub(10, 2)
+	first, _ := hub.subscribe("192.0.2.1")
+	_, _ = hub.subscribe("192.0.2.1")
+	if _, err := hub.subscribe("192.0.2.1"); err != TooManyEventSubscriptionsError {
+		t.Errorf("expected a third subscription from one IP to be refused, got %v", err)
+	}
+	if _, err := hub.subscribe("192.0.2.2"); err != nil {
+		t.Errorf("expected other IPs to still subscribe, got %v", err)
+	}
+
+	hub.unsubscribe(first)
+	if _, err := hub.subscribe("192.0.2.1"); err != nil {
+		t.Errorf("expected unsubscribing to free the IP's place, got %v", err)
+	}
+
+	req := httptest.NewRequest("GET", eventsRoute, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	serveEvents(hub, rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", rec.Code)
+	}
+}
+
+func TestTimeoutMiddlewareSkipsEvents(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(timeoutMiddleware(time.Millisecond))
+	deadline := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}
+	router.HandleFunc(eventsRoute, deadline)
+	router.HandleFunc("/api/v1/pacing", deadline)
+
+	for path, want := range map[string]int{eventsRoute: http.StatusOK, "/api/v1/pacing": http.StatusGatewayTimeout} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
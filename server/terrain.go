@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/elevation"
+	"contourguessr-api/geo"
+	"log/slog"
+	"time"
+)
+
+var validLandforms = map[string]bool{
+	elevation.LandformFlat:   true,
+	elevation.LandformSlope:  true,
+	elevation.LandformRidge:  true,
+	elevation.LandformValley: true,
+}
+
+// backfillTerrain computes the terrain of challenges that don't have it yet,
+// one request's worth per interval to stay well inside the elevation API's
+// limits
+func backfillTerrain(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		n, err := backfillTerrainBatch(ctx)
+		cancel()
+		if err != nil {
+			slog.Error("error backfilling terrain", "err", err)
+		} else if n > 0 {
+			slog.Info("computed terrain", "challenges", n)
+		}
+	}
+}
+
+func backfillTerrainBatch(ctx context.Context) (int, error) {
+	pending, err := repo.ChallengesWithoutTerrain(ctx, elevation.MaxTerrainPoints)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	points := make([]geo.Point, len(pending))
+	for i, p := range pending {
+		points[i] = p.Geo
+	}
+	terrain, err := elevationClient.Terrain(ctx, points)
+	if err != nil {
+		return 0, err
+	}
+	for i, p := range pending {
+		if err := repo.SetChallengeTerrain(ctx, p.ID, terrain[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
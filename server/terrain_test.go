@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/repos"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBackfillTerrain(t *testing.T) {
+	dem := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := len(strings.Split(r.URL.Query().Get("latitude"), ","))
+		_, _ = w.Write([]byte(`{"elevation": [` + strings.TrimSuffix(strings.Repeat("300,", n), ",") + `]}`))
+	}))
+	defer dem.Close()
+
+	cfg := testConfig()
+	cfg.ElevationAPIURL = dem.URL
+	newTestServer(t, cfg)
+	ctx := context.Background()
+
+	// Only the first fixture comes with terrain
+	n, err := backfillTerrainBatch(ctx)
+	if err != nil || n != 5 {
+		t.Fatalf("expected to compute five challenges, got %d %v", n, err)
+	}
+	if n, err := backfillTerrainBatch(ctx); err != nil || n != 0 {
+		t.Errorf("expected nothing left to compute, got %d %v", n, err)
+	}
+
+	if got := repo.SearchChallenges(repos.ChallengeFilter{Landforms: []string{"flat"}}, 0); len(got) != 5 {
+		t.Errorf("expected the backfilled challenges to be flat, got %d", len(got))
+	}
+	ridges := repo.SearchChallenges(repos.ChallengeFilter{Landforms: []string{"ridge"}}, 0)
+	if len(ridges) != 1 || ridges[0].Title != "Snowdon from Llyn Llydaw" {
+		t.Errorf("expected the fixture ridge, got %+v", ridges)
+	}
+}
@@ -0,0 +1,40 @@
+-- Two regions with three challenges each, matching repos.DefaultFixtures.
+-- {{capabilities_url}} is replaced with where the map layer's capabilities
+-- are served from.
+INSERT INTO map_layers (id, name, capabilities_url, layer, matrix_set, resolutions, default_resolution, os_branding)
+VALUES (1, 'Outdoor', '{{capabilities_url}}', 'Outdoor_3857', 'EPSG:3857', '{4.777,2.389,1.194}', 2.389, true);
+
+INSERT INTO regions (id, name, country_iso2, geo, min_lng, max_lng, min_lat, max_lat)
+VALUES (1, 'Snowdonia', 'GB', ST_GeogFromText('MULTIPOLYGON(((-4.2 52.8,-3.6 52.8,-3.6 53.3,-4.2 53.3,-4.2 52.8)))'),
+        -4.2, -3.6, 52.8, 53.3),
+       (2, 'Lake District', 'GB', ST_GeogFromText('MULTIPOLYGON(((-3.4 54.2,-2.7 54.2,-2.7 54.7,-3.4 54.7,-3.4 54.2)))'),
+        -3.4, -2.7, 54.2, 54.7);
+
+INSERT INTO region_map_layers (region_id, map_layer_id)
+VALUES (1, 1),
+       (2, 1);
+
+INSERT INTO challenges (id, region_id, geo, title, description_html, date_taken, link, regular_src, regular_width,
+                        regular_height, large_src, large_width, large_height, photographer_text, photographer_link, rx,
+                        ry)
+SELECT id, region_id, ST_MakePoint(lng, lat)::geography, title, '<p>' || title || '</p>', taken,
+       'https://www.flickr.com/photos/example/' || id,
+       'https://live.staticflickr.com/' || id || '_n.jpg', 320, 240,
+       'https://live.staticflickr.com/' || id || '_b.jpg', 1024, 768,
+       'Example Photographer', 'https://www.flickr.com/people/example/', 0.5, 0.5
+FROM (VALUES (1, 1, -4.0763, 53.0685, 'Snowdon from Llyn Llydaw', '2019-01-05T10:00:00Z'::timestamptz),
+             (2, 1, -3.9966, 53.1148, 'Tryfan north ridge', '2020-07-14T10:00:00Z'),
+             (3, 1, -4.0186, 53.0900, 'Glyder Fach cantilever', '2021-10-03T10:00:00Z'),
+             (4, 2, -3.2115, 54.4541, 'Scafell Pike summit', '2018-04-22T10:00:00Z'),
+             (5, 2, -3.0170, 54.5275, 'Helvellyn from Striding Edge', '2022-12-30T10:00:00Z'),
+             (6, 2, -3.0957, 54.4461, 'Langdale Pikes', '2017-08-09T10:00:00Z')) AS f(id, region_id, lng, lat, title, taken);
+
+INSERT INTO flickr_photos (flickr_id, info, inserted_at)
+SELECT id::text, jsonb_build_object('license', '4'), '2024-03-01T00:00:00Z'::timestamptz + make_interval(days => id)
+FROM challenges;
+
+INSERT INTO flickr_challenge_sources (challenge_id, flickr_id)
+SELECT id, id::text
+FROM challenges;
+
+SELECT setval('regions_id_seq', 2), setval('map_layers_id_seq', 1), setval('challenges_id_seq', 6);
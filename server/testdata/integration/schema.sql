@@ -0,0 +1,74 @@
+-- The tables owned by the ingestion pipeline, reduced to the columns the API
+-- reads. The API's own tables come from repos.Migrate.
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+CREATE TABLE regions
+(
+    id           serial PRIMARY KEY,
+    name         text                          NOT NULL,
+    country_iso2 text                          NOT NULL,
+    logo_url     text                          NOT NULL DEFAULT '',
+    geo          geography(MultiPolygon, 4326) NOT NULL,
+    min_lng      double precision              NOT NULL,
+    max_lng      double precision              NOT NULL,
+    min_lat      double precision              NOT NULL,
+    max_lat      double precision              NOT NULL,
+    active       boolean                       NOT NULL DEFAULT true
+);
+
+CREATE TABLE map_layers
+(
+    id                 serial PRIMARY KEY,
+    name               text               NOT NULL,
+    capabilities_url   text               NOT NULL,
+    layer              text               NOT NULL,
+    matrix_set         text               NOT NULL,
+    resolutions        double precision[] NOT NULL,
+    default_resolution double precision   NOT NULL,
+    os_branding        boolean,
+    extra_attributions text[]             NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE region_map_layers
+(
+    region_id    integer PRIMARY KEY REFERENCES regions (id) ON DELETE CASCADE,
+    map_layer_id integer NOT NULL REFERENCES map_layers (id)
+);
+
+CREATE TABLE challenges
+(
+    id                serial PRIMARY KEY,
+    region_id         integer                NOT NULL REFERENCES regions (id),
+    geo               geography(Point, 4326) NOT NULL,
+    title             text                   NOT NULL,
+    description_html  text                   NOT NULL DEFAULT '',
+    date_taken        timestamp with time zone,
+    link              text                   NOT NULL,
+    regular_src       text                   NOT NULL,
+    regular_width     integer                NOT NULL,
+    regular_height    integer                NOT NULL,
+    large_src         text                   NOT NULL,
+    large_width       integer                NOT NULL,
+    large_height      integer                NOT NULL,
+    photographer_icon text                   NOT NULL DEFAULT '',
+    photographer_text text                   NOT NULL DEFAULT '',
+    photographer_link text                   NOT NULL DEFAULT '',
+    rx                double precision       NOT NULL,
+    ry                double precision       NOT NULL
+);
+
+CREATE TABLE flickr_photos
+(
+    flickr_id   text PRIMARY KEY,
+    summary     jsonb,
+    info        jsonb,
+    sizes       jsonb,
+    exif        jsonb,
+    inserted_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE TABLE flickr_challenge_sources
+(
+    challenge_id integer PRIMARY KEY REFERENCES challenges (id) ON DELETE CASCADE,
+    flickr_id    text NOT NULL REFERENCES flickr_photos (flickr_id)
+);
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// timeoutMiddleware bounds how long a request's database queries and upstream
+// calls can run for. Handlers see the deadline through r.Context(), which is
+// also cancelled when the client disconnects. The event stream is left to run
+// until the client goes away.
+func timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if routeTemplate(r) == eventsRoute {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// writeContextError responds to errors caused by the request's context ending
+// and reports whether err was one. There's no point writing a response to a
+// client that has gone away.
+func writeContextError(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.WarnContext(r.Context(), "request timed out", "method", r.Method, "path", r.URL.Path)
+		httpError(w, r, "request timed out", http.StatusGatewayTimeout)
+		return true
+	case errors.Is(err, context.Canceled) && r.Context().Err() != nil:
+		return true
+	default:
+		return false
+	}
+}
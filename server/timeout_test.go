@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handler := timeoutMiddleware(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		if !writeContextError(w, r, fmt.Errorf("query: %w", r.Context().Err())) {
+			t.Error("expected deadline to be handled")
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/challenge/random", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", rec.Code)
+	}
+}
+
+func TestWriteContextErrorDisconnected(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("GET", "/debug/challenge", nil).WithContext(ctx)
+
+	rec := httptest.NewRecorder()
+	if !writeContextError(rec, r, context.Canceled) {
+		t.Fatal("expected cancellation to be handled")
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("expected nothing written for a disconnected client, got %q", rec.Body.String())
+	}
+
+	if writeContextError(rec, httptest.NewRequest("GET", "/", nil), fmt.Errorf("connection refused")) {
+		t.Error("expected other errors to be left to the caller")
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Locations looked up per timezone backfill interval, kept well within the
+// weather archive's daily request limit
+const timezoneBatchSize = 5
+
+// backfillTimezones looks up the timezone of dated challenges that don't have
+// one yet so that their date taken can be placed in time. Locations outside
+// any timezone are recorded as empty so that they aren't looked up again, but
+// failed lookups are retried.
+func backfillTimezones(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		n, err := backfillTimezoneBatch(ctx)
+		cancel()
+		if err != nil {
+			slog.Error("error backfilling timezones", "err", err)
+		} else if n > 0 {
+			slog.Info("looked up timezones", "challenges", n)
+		}
+	}
+}
+
+func backfillTimezoneBatch(ctx context.Context) (int, error) {
+	pending, err := repo.ChallengesWithoutTimezone(ctx, timezoneBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, l := range pending {
+		timezone, err := weatherClient.Timezone(ctx, l.Lng, l.Lat)
+		if err != nil {
+			slog.WarnContext(ctx, "error looking up timezone", "challenge", l.ID, "err", err)
+			continue
+		}
+		if err := repo.SetChallengeTimezone(ctx, l.ID, timezone); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
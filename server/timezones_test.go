@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"contourguessr-api/repos"
+	"contourguessr-api/weather"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBackfillTimezones(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"timezone": "Europe/London"}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	f := repos.DefaultFixtures()
+	prevRepo, prevWeather := repo, weatherClient
+	repo = repos.NewMemory(f)
+	weatherClient = weather.NewClient(upstream.URL)
+	t.Cleanup(func() { repo, weatherClient = prevRepo, prevWeather })
+
+	n, err := backfillTimezoneBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != min(timezoneBatchSize, len(f.Challenges)) {
+		t.Errorf("expected a full batch to be looked up, got %d", n)
+	}
+
+	c := fixtureChallenge(t, f.Challenges[0].Challenge.Title)
+	if c.Timezone != "Europe/London" || c.DateTakenPrecision != repos.DateTakenExact {
+		t.Fatalf("expected the timezone to be set, got %q %q", c.Timezone, c.DateTakenPrecision)
+	}
+	if _, offset := c.DateTaken.Zone(); offset == 0 && c.DateTaken.Month() > time.March && c.DateTaken.Month() < time.October {
+		t.Errorf("expected a summer date taken to be in BST, got %s", c.DateTaken)
+	}
+	wall := f.Challenges[0].Challenge.DateTaken
+	if c.DateTaken.Hour() != wall.Hour() || c.DateTaken.Day() != wall.Day() {
+		t.Errorf("expected the wall-clock time to be kept, got %s for %s", c.DateTaken, wall)
+	}
+}
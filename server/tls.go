@@ -0,0 +1,95 @@
+package server
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certReloader serves the certificate in certFile, picking up renewals
+// written by something like certbot without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+const certCheckInterval = 1 * time.Minute
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) load() error {
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert = &cert
+	c.modTime = info.ModTime()
+	return nil
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.checkedAt) > certCheckInterval {
+		c.checkedAt = time.Now()
+		if info, err := os.Stat(c.certFile); err == nil && !info.ModTime().Equal(c.modTime) {
+			// Keep serving the old certificate if the new one is half written
+			if err := c.load(); err != nil {
+				slog.Error("error reloading TLS certificate", "err", err)
+			} else {
+				slog.Info("reloaded TLS certificate")
+			}
+		}
+	}
+	return c.cert, nil
+}
+
+// httpsRedirectHandler serves ACME http-01 challenges out of webroot, so
+// certbot --webroot can issue certificates, and redirects everything else to
+// HTTPS
+func httpsRedirectHandler(webroot string, httpsPort string) http.Handler {
+	var challenges http.Handler
+	if webroot != "" {
+		challenges = http.StripPrefix("/.well-known/acme-challenge/",
+			http.FileServer(http.Dir(filepath.Join(webroot, ".well-known", "acme-challenge"))))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if challenges != nil && strings.HasPrefix(r.URL.Path, "/.well-known/acme-challenge/") {
+			challenges.ServeHTTP(w, r)
+			return
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCert(t *testing.T, dir string, name string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCert(t, dir, "first")
+
+	certs, err := newCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commonName := func() string {
+		cert, err := certs.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.Subject.CommonName
+	}
+	if got := commonName(); got != "first" {
+		t.Fatalf("expected first certificate, got %s", got)
+	}
+
+	writeTestCert(t, dir, "renewed")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "cert.pem"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	certs.checkedAt = time.Time{}
+	if got := commonName(); got != "renewed" {
+		t.Errorf("expected renewed certificate, got %s", got)
+	}
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	webroot := t.TempDir()
+	challengeDir := filepath.Join(webroot, ".well-known", "acme-challenge")
+	if err := os.MkdirAll(challengeDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(challengeDir, "token"), []byte("token.thumbprint"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	handler := httpsRedirectHandler(webroot, "8443")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://api.example.com/api/v1/region?x=1", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://api.example.com:8443/api/v1/region?x=1" {
+		t.Errorf("unexpected redirect %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://api.example.com/.well-known/acme-challenge/token", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "token.thumbprint" {
+		t.Errorf("expected challenge response, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"contourguessr-api/geo"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// violation is one problem with a request parameter
+type violation struct {
+	// query, path or body
+	In     string `json:"in"`
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// validator parses request parameters, collecting every violation rather than
+// stopping at the first so that clients can fix them all at once. Parsing
+// methods return the zero value or default for invalid input; handlers check
+// valid before using any of them.
+//
+//	v := newValidator(r)
+//	limit := v.queryInt("limit", 20, 1, 100)
+//	player := v.queryString("player", true, 128)
+//	if !v.valid(w) {
+//		return
+//	}
+type validator struct {
+	r          *http.Request
+	query      url.Values
+	violations []violation
+}
+
+func newValidator(r *http.Request) *validator {
+	return &validator{r: r, query: r.URL.Query()}
+}
+
+func (v *validator) add(in string, field string, code string, detail string) {
+	v.violations = append(v.violations, violation{In: in, Field: field, Code: code, Detail: detail})
+}
+
+// check records a violation of the body field unless ok
+func (v *validator) check(ok bool, field string, code string, detail string) {
+	if !ok {
+		v.add("body", field, code, detail)
+	}
+}
+
+// valid responds with every violation and returns false if there were any
+func (v *validator) valid(w http.ResponseWriter) bool {
+	if len(v.violations) == 0 {
+		return true
+	}
+	fields := make([]string, 0, len(v.violations))
+	for _, violation := range v.violations {
+		fields = append(fields, violation.Field)
+	}
+	writeProblem(w, v.r, problem{
+		Status:     http.StatusBadRequest,
+		Code:       "invalid_request",
+		Detail:     "invalid " + strings.Join(fields, ", "),
+		Violations: v.violations,
+	})
+	return false
+}
+
+// decodeBody decodes the JSON body into dst, returning false if it couldn't
+func (v *validator) decodeBody(dst any) bool {
+	if err := json.NewDecoder(v.r.Body).Decode(dst); err != nil {
+		v.add("body", "", "invalid_json", "expected a JSON body")
+		return false
+	}
+	return true
+}
+
+func (v *validator) queryString(name string, required bool, maxLen int) string {
+	s := v.query.Get(name)
+	return v.str("query", name, s, required, maxLen)
+}
+
+// bodyString checks a string already decoded from the body
+func (v *validator) bodyString(name string, s string, required bool, maxLen int) string {
+	return v.str("body", name, s, required, maxLen)
+}
+
+func (v *validator) str(in string, name string, s string, required bool, maxLen int) string {
+	if s == "" {
+		if required {
+			v.add(in, name, "required", name+" is required")
+		}
+		return ""
+	}
+	if len(s) > maxLen {
+		v.add(in, name, "too_long", fmt.Sprintf("%s must be at most %d bytes", name, maxLen))
+		return ""
+	}
+	return s
+}
+
+// queryInt returns def if the parameter is missing
+func (v *validator) queryInt(name string, def int, min int, max int) int {
+	s := v.query.Get(name)
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		v.add("query", name, "not_an_integer", name+" must be an integer")
+		return def
+	}
+	if n < min || n > max {
+		v.add("query", name, "out_of_range", fmt.Sprintf("%s must be between %d and %d", name, min, max))
+		return def
+	}
+	return n
+}
+
+// queryOptionalInt returns nil if the parameter is missing
+func (v *validator) queryOptionalInt(name string) *int {
+	s := v.query.Get(name)
+	if s == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		v.add("query", name, "not_an_integer", name+" must be an integer")
+		return nil
+	}
+	return &n
+}
+
+func (v *validator) queryOptionalFloat(name string) *float64 {
+	s := v.query.Get(name)
+	if s == "" {
+		return nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		v.add("query", name, "not_a_number", name+" must be a number")
+		return nil
+	}
+	return &n
+}
+
+// queryInts parses a comma separated list of integers
+func (v *validator) queryInts(name string) []int {
+	s := v.query.Get(name)
+	if s == "" {
+		return nil
+	}
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			v.add("query", name, "not_an_integer", name+" must be a comma separated list of integers")
+			return nil
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// queryEnums parses a comma separated list of values from allowed
+func (v *validator) queryEnums(name string, allowed map[string]bool) []string {
+	s := v.query.Get(name)
+	if s == "" {
+		return nil
+	}
+	out := strings.Split(s, ",")
+	for _, value := range out {
+		if !allowed[value] {
+			v.add("query", name, "invalid_value", value+" is not a valid "+name)
+			return nil
+		}
+	}
+	return out
+}
+
+func (v *validator) queryUint64(name string) uint64 {
+	s := v.query.Get(name)
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		v.add("query", name, "not_an_integer", name+" must be a non-negative integer")
+		return 0
+	}
+	return n
+}
+
+// queryDate parses a YYYY-MM-DD date, returning def if the parameter is missing
+func (v *validator) queryDate(name string, def time.Time) time.Time {
+	s := v.query.Get(name)
+	if s == "" {
+		return def
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		v.add("query", name, "invalid_date", name+" must be a date like 2024-01-31")
+		return def
+	}
+	return t
+}
+
+// queryPoint parses a required "lng,lat" pair
+func (v *validator) queryPoint(name string) geo.Point {
+	s := v.query.Get(name)
+	if s == "" {
+		v.add("query", name, "required", name+" is required")
+		return geo.Point{}
+	}
+	lngStr, latStr, ok := strings.Cut(s, ",")
+	lng, lngErr := strconv.ParseFloat(strings.TrimSpace(lngStr), 64)
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if !ok || lngErr != nil || latErr != nil {
+		v.add("query", name, "invalid_point", name+" must be a longitude and latitude like -3.2117,54.4541")
+		return geo.Point{}
+	}
+	if lng < -180 || lng > 180 || lat < -90 || lat > 90 {
+		v.add("query", name, "out_of_range", name+" must have a longitude between -180 and 180 and a latitude between -90 and 90")
+		return geo.Point{}
+	}
+	return geo.Point{Lng: lng, Lat: lat}
+}
+
+func (v *validator) pathInt(name string) int {
+	n, err := strconv.Atoi(mux.Vars(v.r)[name])
+	if err != nil {
+		v.add("path", name, "not_an_integer", name+" must be an integer")
+		return 0
+	}
+	return n
+}
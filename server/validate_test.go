@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidatorAggregatesViolations(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?limit=500&region=1,x&season=summer&player=", nil)
+	v := newValidator(req)
+	limit := v.queryInt("limit", 20, 1, 100)
+	v.queryInts("region")
+	v.queryEnums("season", validSeasons)
+	v.queryString("player", true, 128)
+	if limit != 20 {
+		t.Errorf("expected the default limit for an invalid value, got %d", limit)
+	}
+
+	rec := httptest.NewRecorder()
+	if v.valid(rec) {
+		t.Fatal("expected invalid")
+	}
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected a 400 problem, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var body problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != "invalid_request" || body.Detail != "invalid limit, region, player" {
+		t.Errorf("unexpected problem %+v", body)
+	}
+	var got []string
+	for _, violation := range body.Violations {
+		got = append(got, violation.In+" "+violation.Field+" "+violation.Code)
+	}
+	want := []string{"query limit out_of_range", "query region not_an_integer", "query player required"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestValidatorBody(t *testing.T) {
+	v := newValidator(httptest.NewRequest("POST", "/", strings.NewReader("{")))
+	var body struct{}
+	if v.decodeBody(&body) {
+		t.Fatal("expected invalid JSON to fail")
+	}
+	if len(v.violations) != 1 || v.violations[0].Code != "invalid_json" {
+		t.Errorf("unexpected violations %+v", v.violations)
+	}
+
+	v = newValidator(httptest.NewRequest("POST", "/", nil))
+	v.bodyString("name", strings.Repeat("a", 65), true, 64)
+	v.check(false, "rounds", "out_of_range", "rounds must be between 1 and 10")
+	if len(v.violations) != 2 || v.violations[0].Code != "too_long" || v.violations[1].In != "body" {
+		t.Errorf("unexpected violations %+v", v.violations)
+	}
+
+	v = newValidator(httptest.NewRequest("GET", "/", nil))
+	if !v.valid(httptest.NewRecorder()) {
+		t.Error("expected no violations to be valid")
+	}
+}
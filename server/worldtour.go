@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"contourguessr-api/geo"
+	"contourguessr-api/repos"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const worldTourMode = "world-tour"
+
+const worldTourBoard = "world-tour"
+
+const maxWorldTourRounds = 10
+
+// tourClaims fix the challenges of a game played in a mode, like a world tour
+// or a custom game, so that its results can only go on that mode's leaderboard
+type tourClaims struct {
+	Kind       string   `json:"k"`
+	Game       string   `json:"gm"`
+	Mode       string   `json:"m"`
+	Challenges []string `json:"c"`
+	Expires    int64    `json:"exp"`
+}
+
+// regionScoreScale scales scoring to the size of the challenge's region, so
+// that a round in a small national park is worth as much as one in a large
+// range
+func regionScoreScale(challenge repos.Challenge) float64 {
+	id, err := strconv.Atoi(challenge.RegionID)
+	if err != nil {
+		return scoreDistanceScale
+	}
+	region, ok := repo.Regions()[id]
+	if !ok {
+		return scoreDistanceScale
+	}
+	diagonal := geo.Distance(geo.Point{Lng: region.BBox.MinLng, Lat: region.BBox.MinLat}, geo.Point{Lng: region.BBox.MaxLng, Lat: region.BBox.MaxLat})
+	if diagonal == 0 {
+		return scoreDistanceScale
+	}
+	return diagonal / 10
+}
+
+func scoreForDistanceIn(meters float64, scale float64) int {
+	return int(math.Round(maxRoundScore * math.Exp(-meters/scale)))
+}
+
+// startTour begins a game from the tour token handed out with its challenges
+func startTour(token string) (resultClaims, error) {
+	var claims tourClaims
+	if err := signer.Verify(token, &claims); err != nil || claims.Kind != "tour" || time.Now().Unix() > claims.Expires {
+		return resultClaims{}, InvalidResultChainError
+	}
+	return resultClaims{
+		Kind:  "result",
+		Game:  claims.Game,
+		Round: 1,
+		Mode:  claims.Mode,
+		Tour:  claims.Challenges,
+	}, nil
+}
+
+func signTour(mode string, challenges []repos.Challenge) (string, error) {
+	claims := tourClaims{
+		Kind:    "tour",
+		Game:    newGameID(),
+		Mode:    mode,
+		Expires: time.Now().Add(resultTokenTTL).Unix(),
+	}
+	for _, c := range challenges {
+		claims.Challenges = append(claims.Challenges, c.ID)
+	}
+	return signer.Sign(claims)
+}
+
+// writeTour responds with the tour token and challenges, along with any
+// fields in head, which must be a JSON object
+func writeTour(w http.ResponseWriter, r *http.Request, head []byte, token string, challenges []repos.Challenge) {
+	var buf bytes.Buffer
+	if len(head) > 2 {
+		buf.Write(head[:len(head)-1])
+		buf.WriteByte(',')
+	} else {
+		buf.WriteByte('{')
+	}
+	buf.WriteString(`"tour_token":"` + token + `","challenges":[`)
+	for i, challenge := range challenges {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, err := repo.ChallengeJSON(r.Context(), challenge)
+		if err != nil {
+			httpError(w, r, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		buf.Write(b)
+	}
+	buf.WriteString(`]}`)
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(buf.Bytes())
+}
+
+func handleGetWorldTour(w http.ResponseWriter, r *http.Request) {
+	if !feature(r, "world_tour") {
+		httpError(w, r, "world tour is disabled", http.StatusNotFound)
+		return
+	}
+
+	v := newValidator(r)
+	player := v.queryString("player", true, 128)
+	seq := v.queryUint64("seq")
+	rounds := v.queryInt("rounds", 5, 1, maxWorldTourRounds)
+	if !v.valid(w) {
+		return
+	}
+
+	challenges, err := repo.WorldTour(player, seq, rounds)
+	if errors.Is(err, repos.NotEnoughRegionsError) {
+		httpError(w, r, "not enough regions for that many rounds", http.StatusNotFound)
+		return
+	} else if errors.Is(err, repos.NoChallengesAvailableError) {
+		httpError(w, r, "no challenges available", http.StatusNotFound)
+		return
+	} else if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := signTour(worldTourMode, challenges)
+	if err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	recordServed(challenges...)
+	writeTour(w, r, nil, token, challenges)
+}
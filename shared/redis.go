@@ -0,0 +1,64 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Redis is a Store shared by every replica pointed at the same server
+type Redis struct {
+	client *redis.Client
+}
+
+// DialRedis connects to a redis:// URL, for example
+// redis://:password@localhost:6379/0
+func DialRedis(ctx context.Context, rawURL string) (*Redis, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+
+	// Fail fast on a bad address or credentials
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &Redis{client: client}, nil
+}
+
+// incrScript gives a new counter its TTL in the same step as creating it, so
+// a counter can't be left behind without one. Later increments keep the
+// original expiry.
+var incrScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+func (r *Redis) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return incrScript.Run(ctx, r.client, []string{key}, max(ttl.Milliseconds(), 1)).Int64()
+}
+
+func (r *Redis) SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (r *Redis) Get(ctx context.Context, key string) (string, bool, error) {
+	v, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return v, true, nil
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,123 @@
+// Package shared holds state that has to agree across API replicas, such as
+// rate limits for third-party services. Without Redis it falls back to an
+// in-process store, which is fine for a single replica.
+package shared
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	// Incr increments the counter at key, which expires ttl after it was
+	// first created, and returns the new value.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// SetNX sets key if it doesn't already exist and reports whether it did.
+	SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error)
+	Get(ctx context.Context, key string) (string, bool, error)
+	Close() error
+}
+
+// memoryEntry holds either a value from SetNX or a counter from Incr, which
+// Get reads back as a decimal string as Redis does
+type memoryEntry struct {
+	value   string
+	counter int64
+	expires time.Time
+}
+
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]memoryEntry)}
+}
+
+func (m *Memory) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	e, ok := m.entries[key]
+	if !ok || now.After(e.expires) {
+		e = memoryEntry{expires: now.Add(ttl)}
+	}
+	e.counter++
+	m.entries[key] = e
+	m.sweep(now)
+	return e.counter, nil
+}
+
+func (m *Memory) SetNX(_ context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if e, ok := m.entries[key]; ok && now.Before(e.expires) {
+		return false, nil
+	}
+	m.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+	m.sweep(now)
+	return true, nil
+}
+
+func (m *Memory) Get(_ context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return "", false, nil
+	}
+	if e.counter > 0 {
+		return strconv.FormatInt(e.counter, 10), true, nil
+	}
+	return e.value, true, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// sweep drops expired entries once the map has grown, so that keys which are
+// never touched again don't accumulate
+func (m *Memory) sweep(now time.Time) {
+	if len(m.entries) < 10_000 {
+		return
+	}
+	for k, e := range m.entries {
+		if now.After(e.expires) {
+			delete(m.entries, k)
+		}
+	}
+}
+
+// IntervalLimiter allows at most one caller through per interval across every
+// replica sharing the store.
+type IntervalLimiter struct {
+	store    Store
+	key      string
+	interval time.Duration
+}
+
+func NewIntervalLimiter(store Store, key string, interval time.Duration) *IntervalLimiter {
+	return &IntervalLimiter{store: store, key: key, interval: interval}
+}
+
+func (l *IntervalLimiter) Wait(ctx context.Context) error {
+	for {
+		ok, err := l.store.SetNX(ctx, l.key, "1", l.interval)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-time.After(l.interval / 4):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package shared
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := m.Incr(ctx, "counter", time.Minute)
+		if err != nil || got != want {
+			t.Fatalf("expected %d, got %d (%v)", want, got, err)
+		}
+	}
+	if v, ok, _ := m.Get(ctx, "counter"); !ok || v != "3" {
+		t.Errorf("expected the counter to read as 3, got %q (%v)", v, ok)
+	}
+	if got, _ := m.Incr(ctx, "expired", -time.Second); got != 1 {
+		t.Errorf("expected expired counter to restart, got %d", got)
+	}
+	if got, _ := m.Incr(ctx, "expired", time.Minute); got != 1 {
+		t.Errorf("expected expired counter to restart, got %d", got)
+	}
+
+	if ok, _ := m.SetNX(ctx, "key", "a", time.Minute); !ok {
+		t.Error("expected first SetNX to succeed")
+	}
+	if ok, _ := m.SetNX(ctx, "key", "b", time.Minute); ok {
+		t.Error("expected second SetNX to fail")
+	}
+	if v, ok, _ := m.Get(ctx, "key"); !ok || v != "a" {
+		t.Errorf("expected a, got %q (%v)", v, ok)
+	}
+}
+
+func TestRedis(t *testing.T) {
+	srv := miniredis.RunT(t)
+	ctx := context.Background()
+	r, err := DialRedis(ctx, "redis://"+srv.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if ok, err := r.SetNX(ctx, "key", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("expected first SetNX to succeed, got %v (%v)", ok, err)
+	}
+	if ok, err := r.SetNX(ctx, "key", "b", time.Minute); err != nil || ok {
+		t.Fatalf("expected second SetNX to fail, got %v (%v)", ok, err)
+	}
+	if v, ok, err := r.Get(ctx, "key"); err != nil || !ok || v != "a" {
+		t.Errorf("expected a, got %q (%v)", v, err)
+	}
+	if _, ok, err := r.Get(ctx, "missing"); err != nil || ok {
+		t.Errorf("expected missing key, got %v (%v)", ok, err)
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := r.Incr(ctx, "counter", time.Minute)
+		if err != nil || got != want {
+			t.Fatalf("expected %d, got %d (%v)", want, got, err)
+		}
+		if ttl := srv.TTL("counter"); ttl <= 0 || ttl > time.Minute {
+			t.Fatalf("expected the counter to keep its TTL, got %v", ttl)
+		}
+	}
+	srv.FastForward(time.Minute)
+	if got, err := r.Incr(ctx, "counter", time.Minute); err != nil || got != 1 {
+		t.Errorf("expected expired counter to restart, got %d (%v)", got, err)
+	}
+
+	// Error replies surface as errors
+	srv.Set("text", "not a number")
+	if _, err := r.Incr(ctx, "text", time.Minute); err == nil {
+		t.Error("expected error reply to surface")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"contourguessr-api/smoketest"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// runSmoketest is the smoketest subcommand, which checks a deployed server
+// and exits nonzero if anything is broken:
+//
+//	contourguessr-api smoketest -url https://api.contourguessr.org
+func runSmoketest(args []string) int {
+	fs := flag.NewFlagSet("smoketest", flag.ExitOnError)
+	baseURL := fs.String("url", os.Getenv("SMOKETEST_URL"), "server to check")
+	images := fs.Int("images", 3, "how many challenge images to fetch")
+	tiles := fs.Bool("tiles", true, "fetch a sample map tile")
+	timeout := fs.Duration("timeout", 2*time.Minute, "give up after")
+	_ = fs.Parse(args)
+	if *baseURL == "" {
+		fmt.Fprintln(os.Stderr, "smoketest: -url or SMOKETEST_URL required")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := &http.Client{Timeout: 30 * time.Second}
+	results := smoketest.Run(ctx, c, smoketest.Options{
+		BaseURL: strings.TrimSuffix(*baseURL, "/"),
+		Images:  *images,
+		Tiles:   *tiles,
+	})
+	for _, res := range results {
+		fmt.Println(res)
+	}
+	if smoketest.Failed(results) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,275 @@
+// Package smoketest checks a deployed server end to end, the way a client
+// would use it, for use as a post-deploy gate or a synthetic monitor.
+package smoketest
+
+import (
+	"context"
+	"contourguessr-api/wmts"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type Options struct {
+	BaseURL string
+	// How many challenge images to check, so a run doesn't hammer the image
+	// host
+	Images int
+	// Request a tile from the first region's map layer
+	Tiles bool
+}
+
+// Result is the outcome of one check. Skipped checks aren't failures.
+type Result struct {
+	Name    string
+	Err     error
+	Skipped string
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Err != nil:
+		return "FAIL " + r.Name + ": " + r.Err.Error()
+	case r.Skipped != "":
+		return "SKIP " + r.Name + ": " + r.Skipped
+	default:
+		return "ok   " + r.Name
+	}
+}
+
+// Failed reports whether any of results failed
+func Failed(results []Result) bool {
+	for _, res := range results {
+		if res.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+type region struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MapLayer struct {
+		ID              string `json:"id"`
+		CapabilitiesXML string `json:"capabilities_xml"`
+		Layer           string `json:"layer"`
+		MatrixSet       string `json:"matrix_set"`
+	} `json:"map_layer"`
+}
+
+type challenge struct {
+	ID       string `json:"id"`
+	RegionID string `json:"region_id"`
+	Geo      *struct {
+		Lng float64 `json:"lng"`
+		Lat float64 `json:"lat"`
+	} `json:"geo"`
+	Title string `json:"title"`
+	Src   struct {
+		Regular struct {
+			Src    string `json:"src"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+		} `json:"regular"`
+	} `json:"src"`
+}
+
+var errNoChallenges = errors.New("no challenges available")
+
+// Run checks readiness, then fetches the regions and a random challenge in
+// each, validating their payloads, then checks a sample of image URLs and,
+// optionally, a map tile. It carries on past failures so a run reports
+// everything that's wrong.
+func Run(ctx context.Context, c *http.Client, opts Options) []Result {
+	var results []Result
+	check := func(name string, err error) bool {
+		results = append(results, Result{Name: name, Err: err})
+		return err == nil
+	}
+
+	check("readyz", getStatus(ctx, c, opts.BaseURL+"/readyz"))
+
+	var regions []region
+	if !check("regions", getJSON(ctx, c, opts.BaseURL+"/api/v1/region", &regions, func() error {
+		return validateRegions(regions)
+	})) {
+		return results
+	}
+
+	var images []string
+	served := 0
+	for _, r := range regions {
+		name := "challenge in region " + r.ID
+		var ch challenge
+		err := getJSON(ctx, c, opts.BaseURL+"/api/v1/challenge/random?region="+url.QueryEscape(r.ID), &ch, func() error {
+			return validateChallenge(ch, r.ID)
+		})
+		if errors.Is(err, errNoChallenges) {
+			results = append(results, Result{Name: name, Skipped: "no challenges"})
+			continue
+		}
+		if check(name, err) {
+			served++
+			images = append(images, ch.Src.Regular.Src)
+		}
+	}
+	if served == 0 {
+		check("challenges", errors.New("no region served a challenge"))
+	}
+
+	for i, src := range images {
+		if i >= opts.Images {
+			break
+		}
+		check("image "+src, checkImage(ctx, c, src))
+	}
+
+	if opts.Tiles && len(regions) > 0 {
+		tile, err := sampleTileURL(regions[0])
+		if err == nil {
+			err = checkImage(ctx, c, tile)
+		}
+		check("tile for region "+regions[0].ID, err)
+	}
+	return results
+}
+
+func validateRegions(regions []region) error {
+	if len(regions) == 0 {
+		return errors.New("no regions")
+	}
+	for _, r := range regions {
+		if r.ID == "" || r.Name == "" {
+			return fmt.Errorf("region %q missing id or name", r.ID)
+		}
+		if r.MapLayer.CapabilitiesXML == "" || r.MapLayer.Layer == "" || r.MapLayer.MatrixSet == "" {
+			return fmt.Errorf("region %s has an incomplete map layer", r.ID)
+		}
+	}
+	return nil
+}
+
+func validateChallenge(ch challenge, regionID string) error {
+	switch {
+	case ch.ID == "":
+		return errors.New("missing id")
+	case ch.RegionID != regionID:
+		return fmt.Errorf("challenge %s is in region %s", ch.ID, ch.RegionID)
+	case ch.Geo == nil || ch.Geo.Lng < -180 || ch.Geo.Lng > 180 || ch.Geo.Lat < -90 || ch.Geo.Lat > 90:
+		return fmt.Errorf("challenge %s has an invalid location", ch.ID)
+	case !strings.HasPrefix(ch.Src.Regular.Src, "https://"):
+		return fmt.Errorf("challenge %s has an invalid image URL %q", ch.ID, ch.Src.Regular.Src)
+	case ch.Src.Regular.Width <= 0 || ch.Src.Regular.Height <= 0:
+		return fmt.Errorf("challenge %s has invalid image dimensions", ch.ID)
+	}
+	return nil
+}
+
+// sampleTileURL fills in the template of the region's layer for the top left
+// tile of its least detailed tile matrix
+func sampleTileURL(r region) (string, error) {
+	caps, err := wmts.Parse(r.MapLayer.CapabilitiesXML)
+	if err != nil {
+		return "", err
+	}
+	layer, ok := caps.Layer(r.MapLayer.Layer)
+	if !ok {
+		return "", fmt.Errorf("layer %s not in capabilities", r.MapLayer.Layer)
+	}
+	set, ok := caps.TileMatrixSet(r.MapLayer.MatrixSet)
+	if !ok || len(set.TileMatrices) == 0 {
+		return "", fmt.Errorf("tile matrix set %s not in capabilities", r.MapLayer.MatrixSet)
+	}
+	for _, res := range layer.ResourceURLs {
+		if res.ResourceType != "tile" {
+			continue
+		}
+		return strings.NewReplacer(
+			"{TileMatrixSet}", set.Identifier,
+			"{TileMatrix}", set.TileMatrices[0].Identifier,
+			"{TileRow}", "0",
+			"{TileCol}", "0",
+			"{Style}", "default",
+		).Replace(res.Template), nil
+	}
+	return "", fmt.Errorf("layer %s has no tile resource URL", r.MapLayer.Layer)
+}
+
+func get(ctx context.Context, c *http.Client, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "github.com/dzfranklin/contourguessr smoketest")
+	return c.Do(req)
+}
+
+func getStatus(ctx context.Context, c *http.Client, u string) error {
+	resp, err := get(ctx, c, u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func getJSON(ctx context.Context, c *http.Client, u string, v any, validate func() error) error {
+	resp, err := get(ctx, c, u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if strings.Contains(string(body), "no challenges available") {
+			return errNoChallenges
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		return fmt.Errorf("unexpected content type %q", ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid body: %w", err)
+	}
+	return validate()
+}
+
+// checkImage fetches only the first bytes, which is enough to know it's
+// being served
+func checkImage(ctx context.Context, c *http.Client, u string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "github.com/dzfranklin/contourguessr smoketest")
+	req.Header.Set("Range", "bytes=0-1023")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/") {
+		return fmt.Errorf("unexpected content type %q", ct)
+	}
+	if n := resp.Header.Get("Content-Length"); n != "" {
+		if size, err := strconv.Atoi(n); err == nil && size == 0 {
+			return errors.New("empty image")
+		}
+	}
+	return nil
+}
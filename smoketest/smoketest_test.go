@@ -0,0 +1,115 @@
+package smoketest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const capabilities = `<Capabilities xmlns="http://www.opengis.net/wmts/1.0" xmlns:ows="http://www.opengis.net/ows/1.1">
+  <Contents>
+    <Layer>
+      <ows:Identifier>Outdoor</ows:Identifier>
+      <ResourceURL format="image/png" resourceType="tile" template="BASE/tiles/{TileMatrixSet}/{TileMatrix}/{TileCol}/{TileRow}.png"/>
+    </Layer>
+    <TileMatrixSet>
+      <ows:Identifier>EPSG:27700</ows:Identifier>
+      <TileMatrix><ows:Identifier>0</ows:Identifier></TileMatrix>
+    </TileMatrixSet>
+  </Contents>
+</Capabilities>`
+
+func newServer(t *testing.T, badChallenge bool) *httptest.Server {
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/api/v1/region", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		layer := map[string]string{
+			"capabilities_xml": strings.ReplaceAll(capabilities, "BASE", srv.URL),
+			"layer":            "Outdoor",
+			"matrix_set":       "EPSG:27700",
+		}
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{"id": "1", "name": "Lake District", "map_layer": layer},
+			{"id": "2", "name": "Cairngorms", "map_layer": layer},
+		})
+	})
+	mux.HandleFunc("/api/v1/challenge/random", func(w http.ResponseWriter, r *http.Request) {
+		region := r.URL.Query().Get("region")
+		if region == "2" {
+			http.Error(w, "no challenges available", http.StatusNotFound)
+			return
+		}
+		lat := 54.5
+		if badChallenge {
+			lat = 254.5
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":        "abc",
+			"region_id": region,
+			"geo":       map[string]float64{"lng": -3.1, "lat": lat},
+			"src": map[string]any{"regular": map[string]any{
+				// Image hosts are always HTTPS in production
+				"src":    "https" + strings.TrimPrefix(srv.URL, "http") + "/image.jpg",
+				"width":  800,
+				"height": 600,
+			}},
+		})
+	})
+	mux.HandleFunc("/image.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte{0xff, 0xd8})
+	})
+	mux.HandleFunc("/tiles/EPSG:27700/0/0/0.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// rewriteHTTPS sends the test's https image URLs to the plain HTTP server
+type rewriteHTTPS struct{}
+
+func (rewriteHTTPS) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme = "http"
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestRun(t *testing.T) {
+	srv := newServer(t, false)
+	results := Run(context.Background(), &http.Client{Transport: rewriteHTTPS{}}, Options{BaseURL: srv.URL, Images: 3, Tiles: true})
+	if Failed(results) {
+		t.Errorf("expected success, got %v", results)
+	}
+
+	var names []string
+	for _, res := range results {
+		names = append(names, res.String())
+	}
+	got := strings.Join(names, "\n")
+	for _, want := range []string{"ok   regions", "ok   challenge in region 1", "SKIP challenge in region 2", "ok   image https://", "ok   tile for region 1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in\n%s", want, got)
+		}
+	}
+}
+
+func TestRunFailsInvalidChallenge(t *testing.T) {
+	srv := newServer(t, true)
+	results := Run(context.Background(), &http.Client{Transport: rewriteHTTPS{}}, Options{BaseURL: srv.URL})
+	if !Failed(results) {
+		t.Fatal("expected an invalid location to fail")
+	}
+	for _, res := range results {
+		if res.Err != nil && !strings.Contains(res.Err.Error(), "invalid location") && res.Name != "challenges" {
+			t.Errorf("unexpected failure %s", res)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var InvalidTokenError = errors.New("invalid token")
+
+var encoding = base64.RawURLEncoding
+
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+func (s *Signer) Sign(claims any) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := encoding.EncodeToString(payload)
+	return encoded + "." + encoding.EncodeToString(s.mac(encoded)), nil
+}
+
+func (s *Signer) Verify(token string, claims any) error {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return InvalidTokenError
+	}
+
+	gotMAC, err := encoding.DecodeString(sig)
+	if err != nil {
+		return InvalidTokenError
+	}
+	if !hmac.Equal(gotMAC, s.mac(encoded)) {
+		return InvalidTokenError
+	}
+
+	payload, err := encoding.DecodeString(encoded)
+	if err != nil {
+		return InvalidTokenError
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return InvalidTokenError
+	}
+	return nil
+}
+
+func (s *Signer) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
@@ -0,0 +1,49 @@
+package tokens
+
+import (
+	"errors"
+	"testing"
+)
+
+type testClaims struct {
+	Kind string `json:"k"`
+	ID   string `json:"id"`
+}
+
+func TestSigner(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+
+	t.Run("round trip", func(t *testing.T) {
+		token, err := s.Sign(testClaims{Kind: "reveal", ID: "ae"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got testClaims
+		if err := s.Verify(token, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Kind != "reveal" || got.ID != "ae" {
+			t.Fatalf("unexpected claims %+v", got)
+		}
+	})
+
+	t.Run("rejects other key", func(t *testing.T) {
+		token, err := NewSigner([]byte("other")).Sign(testClaims{ID: "ae"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got testClaims
+		if err := s.Verify(token, &got); !errors.Is(err, InvalidTokenError) {
+			t.Fatalf("expected InvalidTokenError, got %v", err)
+		}
+	})
+
+	t.Run("rejects malformed", func(t *testing.T) {
+		for _, token := range []string{"", "abc", "abc.def", "e30.!!"} {
+			var got testClaims
+			if err := s.Verify(token, &got); !errors.Is(err, InvalidTokenError) {
+				t.Errorf("%q: expected InvalidTokenError, got %v", token, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const DefaultBaseURL = "https://archive-api.open-meteo.com/v1/archive"
+
+type Client struct {
+	baseURL string
+	c       *http.Client
+}
+
+type Conditions struct {
+	Time            string   `json:"time"`
+	TemperatureC    *float64 `json:"temperature_c"`
+	PrecipitationMM *float64 `json:"precipitation_mm"`
+	CloudCoverPct   *float64 `json:"cloud_cover_pct"`
+	WindSpeedKmh    *float64 `json:"wind_speed_kmh"`
+	WeatherCode     *int     `json:"weather_code"`
+	Description     string   `json:"description"`
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: baseURL,
+		c:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Historic returns the reanalysis conditions for the hour containing taken, or
+// nil if the archive has no data for it. Flickr dates are local wall-clock
+// times, so taken is matched against the archive's local times rather than
+// converted from UTC.
+func (c *Client) Historic(ctx context.Context, lng float64, lat float64, taken time.Time) (*Conditions, error) {
+	day := taken.Format(time.DateOnly)
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("longitude", strconv.FormatFloat(lng, 'f', 4, 64))
+	q.Set("start_date", day)
+	q.Set("end_date", day)
+	q.Set("hourly", "temperature_2m,precipitation,cloud_cover,wind_speed_10m,weather_code")
+	q.Set("timezone", "auto")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "github.com/dzfranklin/contourguessr")
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Hourly struct {
+			Time          []string   `json:"time"`
+			Temperature   []*float64 `json:"temperature_2m"`
+			Precipitation []*float64 `json:"precipitation"`
+			CloudCover    []*float64 `json:"cloud_cover"`
+			WindSpeed     []*float64 `json:"wind_speed_10m"`
+			WeatherCode   []*int     `json:"weather_code"`
+		} `json:"hourly"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	h := body.Hourly
+
+	hour := taken.Format("2006-01-02T15") + ":00"
+	for i, t := range h.Time {
+		if t != hour {
+			continue
+		}
+		out := &Conditions{
+			Time:            t,
+			TemperatureC:    at(h.Temperature, i),
+			PrecipitationMM: at(h.Precipitation, i),
+			CloudCoverPct:   at(h.CloudCover, i),
+			WindSpeedKmh:    at(h.WindSpeed, i),
+			WeatherCode:     at(h.WeatherCode, i),
+		}
+		if out.TemperatureC == nil && out.WeatherCode == nil {
+			// The archive lags real time by a few days and pads with nulls
+			return nil, nil
+		}
+		if out.WeatherCode != nil {
+			out.Description = describe(*out.WeatherCode)
+		}
+		return out, nil
+	}
+	return nil, nil
+}
+
+// Timezone looks up the IANA name of the timezone at the location, which is
+// empty if there isn't one
+func (c *Client) Timezone(ctx context.Context, lng float64, lat float64) (string, error) {
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("longitude", strconv.FormatFloat(lng, 'f', 4, 64))
+	// The archive needs a range, but only the metadata is used
+	q.Set("start_date", "2020-01-01")
+	q.Set("end_date", "2020-01-01")
+	q.Set("timezone", "auto")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "github.com/dzfranklin/contourguessr")
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	// Given for locations outside any timezone, such as at sea
+	if body.Timezone == "GMT" {
+		return "", nil
+	}
+	return body.Timezone, nil
+}
+
+func at[T any](values []*T, i int) *T {
+	if i < len(values) {
+		return values[i]
+	}
+	return nil
+}
+
+// describe summarizes a WMO weather interpretation code
+func describe(code int) string {
+	switch {
+	case code == 0:
+		return "Clear sky"
+	case code <= 2:
+		return "Partly cloudy"
+	case code == 3:
+		return "Overcast"
+	case code == 45 || code == 48:
+		return "Fog"
+	case code >= 51 && code <= 57:
+		return "Drizzle"
+	case code >= 61 && code <= 67:
+		return "Rain"
+	case code >= 71 && code <= 77:
+		return "Snow"
+	case code >= 80 && code <= 82:
+		return "Rain showers"
+	case code == 85 || code == 86:
+		return "Snow showers"
+	case code >= 95:
+		return "Thunderstorm"
+	default:
+		return ""
+	}
+}
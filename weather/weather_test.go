@@ -0,0 +1,76 @@
+package weather
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHistoric(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("start_date"); got != "2023-06-01" {
+			t.Errorf("expected start_date 2023-06-01, got %s", got)
+		}
+		_, _ = w.Write([]byte(`{"hourly": {
+			"time": ["2023-06-01T13:00", "2023-06-01T14:00"],
+			"temperature_2m": [11.5, 12.1],
+			"precipitation": [0, 0.4],
+			"cloud_cover": [80, 100],
+			"wind_speed_10m": [20.3, 22.0],
+			"weather_code": [3, 61]
+		}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+
+	got, err := c.Historic(context.Background(), -5.0, 56.8, time.Date(2023, 6, 1, 14, 37, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("expected conditions, got nil")
+	}
+	if got.Time != "2023-06-01T14:00" || *got.TemperatureC != 12.1 || got.Description != "Rain" {
+		t.Errorf("unexpected conditions %+v", got)
+	}
+
+	got, err = c.Historic(context.Background(), -5.0, 56.8, time.Date(2023, 6, 1, 3, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("expected nil for missing hour, got %+v", got)
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("latitude") == "0.0000" {
+			_, _ = w.Write([]byte(`{"timezone": "GMT", "utc_offset_seconds": 0}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"timezone": "Europe/London", "utc_offset_seconds": 0}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+
+	got, err := c.Timezone(context.Background(), -4.08, 53.07)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Europe/London" {
+		t.Errorf("expected Europe/London, got %q", got)
+	}
+
+	got, err = c.Timezone(context.Background(), -30, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Errorf("expected no timezone at sea, got %q", got)
+	}
+}
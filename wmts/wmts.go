@@ -0,0 +1,235 @@
+package wmts
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Capabilities is the structure of a WMTS capabilities document that clients
+// depend on. Titles, abstracts and contact details are left out so that
+// cosmetic edits by a provider don't show up as changes.
+type Capabilities struct {
+	Layers         []Layer         `xml:"Contents>Layer"`
+	TileMatrixSets []TileMatrixSet `xml:"Contents>TileMatrixSet"`
+}
+
+type Layer struct {
+	Identifier     string        `xml:"Identifier"`
+	Formats        []string      `xml:"Format"`
+	TileMatrixSets []string      `xml:"TileMatrixSetLink>TileMatrixSet"`
+	ResourceURLs   []ResourceURL `xml:"ResourceURL"`
+}
+
+type ResourceURL struct {
+	Format       string `xml:"format,attr"`
+	ResourceType string `xml:"resourceType,attr"`
+	Template     string `xml:"template,attr"`
+}
+
+type TileMatrixSet struct {
+	Identifier   string       `xml:"Identifier"`
+	SupportedCRS string       `xml:"SupportedCRS"`
+	TileMatrices []TileMatrix `xml:"TileMatrix"`
+}
+
+type TileMatrix struct {
+	Identifier       string `xml:"Identifier"`
+	ScaleDenominator string `xml:"ScaleDenominator"`
+	TopLeftCorner    string `xml:"TopLeftCorner"`
+	TileWidth        string `xml:"TileWidth"`
+	TileHeight       string `xml:"TileHeight"`
+	MatrixWidth      string `xml:"MatrixWidth"`
+	MatrixHeight     string `xml:"MatrixHeight"`
+}
+
+func Parse(doc string) (Capabilities, error) {
+	var c Capabilities
+	if err := xml.Unmarshal([]byte(doc), &c); err != nil {
+		return Capabilities{}, err
+	}
+	return c, nil
+}
+
+func (c Capabilities) Layer(id string) (Layer, bool) {
+	for _, l := range c.Layers {
+		if l.Identifier == id {
+			return l, true
+		}
+	}
+	return Layer{}, false
+}
+
+func (c Capabilities) TileMatrixSet(id string) (TileMatrixSet, bool) {
+	for _, s := range c.TileMatrixSets {
+		if s.Identifier == id {
+			return s, true
+		}
+	}
+	return TileMatrixSet{}, false
+}
+
+// Change is a structural difference between two documents. Path is an XPath
+// to the node that changed, with namespaces left out.
+type Change struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+	// The layer or tile matrix set the change is within, if any
+	Layer         string `json:"layer,omitempty"`
+	TileMatrixSet string `json:"tile_matrix_set,omitempty"`
+}
+
+func (c Change) String() string {
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("added %s = %q", c.Path, c.New)
+	case c.New == "":
+		return fmt.Sprintf("removed %s (was %q)", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("changed %s from %q to %q", c.Path, c.Old, c.New)
+	}
+}
+
+// Diff lists the structural changes from old to new, in a stable order
+func Diff(old Capabilities, new Capabilities) []Change {
+	var out []Change
+
+	oldLayers := make(map[string]Layer)
+	for _, l := range old.Layers {
+		oldLayers[l.Identifier] = l
+	}
+	newLayers := make(map[string]Layer)
+	for _, l := range new.Layers {
+		newLayers[l.Identifier] = l
+	}
+	for _, id := range unionKeys(oldLayers, newLayers) {
+		path := fmt.Sprintf("/Capabilities/Contents/Layer[Identifier='%s']", id)
+		tag := func(changes []Change) []Change {
+			for i := range changes {
+				changes[i].Layer = id
+			}
+			return changes
+		}
+
+		o, inOld := oldLayers[id]
+		n, inNew := newLayers[id]
+		if !inNew {
+			out = append(out, Change{Path: path, Old: id, Layer: id})
+			continue
+		}
+		if !inOld {
+			out = append(out, Change{Path: path, New: id, Layer: id})
+			continue
+		}
+		out = append(out, tag(diffSet(path+"/Format", o.Formats, n.Formats))...)
+		out = append(out, tag(diffSet(path+"/TileMatrixSetLink/TileMatrixSet", o.TileMatrixSets, n.TileMatrixSets))...)
+
+		oldURLs := make(map[string]string)
+		for _, u := range o.ResourceURLs {
+			oldURLs[u.ResourceType+"|"+u.Format] = u.Template
+		}
+		newURLs := make(map[string]string)
+		for _, u := range n.ResourceURLs {
+			newURLs[u.ResourceType+"|"+u.Format] = u.Template
+		}
+		for _, key := range unionKeys(oldURLs, newURLs) {
+			resourceType, format, _ := strings.Cut(key, "|")
+			urlPath := fmt.Sprintf("%s/ResourceURL[@resourceType='%s'][@format='%s']/@template", path, resourceType, format)
+			if oldURLs[key] != newURLs[key] {
+				out = append(out, Change{Path: urlPath, Old: oldURLs[key], New: newURLs[key], Layer: id})
+			}
+		}
+	}
+
+	oldSets := make(map[string]TileMatrixSet)
+	for _, s := range old.TileMatrixSets {
+		oldSets[s.Identifier] = s
+	}
+	newSets := make(map[string]TileMatrixSet)
+	for _, s := range new.TileMatrixSets {
+		newSets[s.Identifier] = s
+	}
+	for _, id := range unionKeys(oldSets, newSets) {
+		path := fmt.Sprintf("/Capabilities/Contents/TileMatrixSet[Identifier='%s']", id)
+		o, inOld := oldSets[id]
+		n, inNew := newSets[id]
+		if !inNew {
+			out = append(out, Change{Path: path, Old: id, TileMatrixSet: id})
+			continue
+		}
+		if !inOld {
+			out = append(out, Change{Path: path, New: id, TileMatrixSet: id})
+			continue
+		}
+		if o.SupportedCRS != n.SupportedCRS {
+			out = append(out, Change{Path: path + "/SupportedCRS", Old: o.SupportedCRS, New: n.SupportedCRS, TileMatrixSet: id})
+		}
+
+		oldMatrices := make(map[string]string)
+		for _, m := range o.TileMatrices {
+			oldMatrices[m.Identifier] = m.summary()
+		}
+		newMatrices := make(map[string]string)
+		for _, m := range n.TileMatrices {
+			newMatrices[m.Identifier] = m.summary()
+		}
+		for _, key := range unionKeys(oldMatrices, newMatrices) {
+			if oldMatrices[key] != newMatrices[key] {
+				out = append(out, Change{
+					Path:          fmt.Sprintf("%s/TileMatrix[Identifier='%s']", path, key),
+					Old:           oldMatrices[key],
+					New:           newMatrices[key],
+					TileMatrixSet: id,
+				})
+			}
+		}
+	}
+
+	return out
+}
+
+func (m TileMatrix) summary() string {
+	return fmt.Sprintf("scale=%s origin=%s tile=%sx%s matrix=%sx%s",
+		strings.TrimSpace(m.ScaleDenominator), strings.TrimSpace(m.TopLeftCorner),
+		strings.TrimSpace(m.TileWidth), strings.TrimSpace(m.TileHeight),
+		strings.TrimSpace(m.MatrixWidth), strings.TrimSpace(m.MatrixHeight))
+}
+
+func diffSet(path string, old []string, new []string) []Change {
+	oldSet := make(map[string]bool)
+	for _, v := range old {
+		oldSet[v] = true
+	}
+	newSet := make(map[string]bool)
+	for _, v := range new {
+		newSet[v] = true
+	}
+	var out []Change
+	for _, v := range unionKeys(oldSet, newSet) {
+		itemPath := fmt.Sprintf("%s[.='%s']", path, v)
+		if !newSet[v] {
+			out = append(out, Change{Path: itemPath, Old: v})
+		} else if !oldSet[v] {
+			out = append(out, Change{Path: itemPath, New: v})
+		}
+	}
+	return out
+}
+
+func unionKeys[V any](a map[string]V, b map[string]V) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for k := range a {
+		seen[k] = true
+		out = append(out, k)
+	}
+	for k := range b {
+		if !seen[k] {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
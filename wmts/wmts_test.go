@@ -0,0 +1,89 @@
+package wmts
+
+import (
+	"strings"
+	"testing"
+)
+
+const before = `<?xml version="1.0"?>
+<Capabilities xmlns="http://www.opengis.net/wmts/1.0" xmlns:ows="http://www.opengis.net/ows/1.1">
+  <ows:ServiceIdentification><ows:Title>Maps</ows:Title></ows:ServiceIdentification>
+  <Contents>
+    <Layer>
+      <ows:Title>Outdoor</ows:Title>
+      <ows:Identifier>Outdoor_27700</ows:Identifier>
+      <Format>image/png</Format>
+      <TileMatrixSetLink><TileMatrixSet>EPSG:27700</TileMatrixSet></TileMatrixSetLink>
+      <ResourceURL format="image/png" resourceType="tile" template="https://tiles.example/v1/{TileMatrix}/{TileCol}/{TileRow}.png"/>
+    </Layer>
+    <Layer>
+      <ows:Identifier>Road_27700</ows:Identifier>
+      <Format>image/png</Format>
+      <TileMatrixSetLink><TileMatrixSet>EPSG:27700</TileMatrixSet></TileMatrixSetLink>
+    </Layer>
+    <TileMatrixSet>
+      <ows:Identifier>EPSG:27700</ows:Identifier>
+      <ows:SupportedCRS>urn:ogc:def:crs:EPSG::27700</ows:SupportedCRS>
+      <TileMatrix>
+        <ows:Identifier>0</ows:Identifier>
+        <ScaleDenominator>3199999.9999999995</ScaleDenominator>
+        <TopLeftCorner>-238375.0 1376256.0</TopLeftCorner>
+        <TileWidth>256</TileWidth><TileHeight>256</TileHeight>
+        <MatrixWidth>5</MatrixWidth><MatrixHeight>7</MatrixHeight>
+      </TileMatrix>
+    </TileMatrixSet>
+  </Contents>
+</Capabilities>`
+
+func TestDiff(t *testing.T) {
+	old, err := Parse(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := old.Layer("Outdoor_27700"); !ok || len(l.ResourceURLs) != 1 {
+		t.Fatalf("unexpected parse %+v", old)
+	}
+
+	cosmetic, err := Parse(strings.ReplaceAll(before, "<ows:Title>Outdoor</ows:Title>", "<ows:Title>Outdoor Map</ows:Title>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := Diff(old, cosmetic); len(changes) != 0 {
+		t.Errorf("expected title change to be ignored, got %v", changes)
+	}
+
+	after := strings.NewReplacer(
+		"/v1/", "/v2/",
+		"<MatrixWidth>5</MatrixWidth>", "<MatrixWidth>6</MatrixWidth>",
+		`<Layer>
+      <ows:Identifier>Road_27700</ows:Identifier>
+      <Format>image/png</Format>
+      <TileMatrixSetLink><TileMatrixSet>EPSG:27700</TileMatrixSet></TileMatrixSetLink>
+    </Layer>`, "",
+	).Replace(before)
+	updated, err := Parse(after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := Diff(old, updated)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %v", changes)
+	}
+	want := []string{
+		"/Capabilities/Contents/Layer[Identifier='Outdoor_27700']/ResourceURL[@resourceType='tile'][@format='image/png']/@template",
+		"/Capabilities/Contents/Layer[Identifier='Road_27700']",
+		"/Capabilities/Contents/TileMatrixSet[Identifier='EPSG:27700']/TileMatrix[Identifier='0']",
+	}
+	for i, c := range changes {
+		if c.Path != want[i] {
+			t.Errorf("change %d: expected %s, got %s", i, want[i], c.Path)
+		}
+	}
+	if changes[0].Layer != "Outdoor_27700" || changes[2].TileMatrixSet != "EPSG:27700" {
+		t.Errorf("expected changes to be tagged, got %+v", changes)
+	}
+	if !strings.HasPrefix(changes[1].String(), "removed") {
+		t.Errorf("unexpected summary %s", changes[1])
+	}
+}
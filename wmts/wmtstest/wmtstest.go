@@ -0,0 +1,96 @@
+// Package wmtstest serves canned WMTS capabilities, so that tests and local
+// development don't depend on national mapping agencies' endpoints.
+package wmtstest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+// Tiles are OpenStreetMap's, which are in web mercator and need no key
+const (
+	TileTemplate = "https://tile.openstreetmap.org/{TileMatrix}/{TileCol}/{TileRow}.png"
+	MaxZoom      = 18
+)
+
+// Metres per pixel at zoom level 0 in web mercator
+const webMercatorResolution = 156543.03392804097
+
+// Resolution is the metres per pixel of the tile matrix for zoom level z
+func Resolution(z int) float64 {
+	return webMercatorResolution / math.Exp2(float64(z))
+}
+
+// Capabilities describes OpenStreetMap's tiles as a WMTS layer with the given
+// identifiers. Tile matrices are identified by their zoom level.
+func Capabilities(layer string, matrixSet string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<Capabilities xmlns="http://www.opengis.net/wmts/1.0" xmlns:ows="http://www.opengis.net/ows/1.1" version="1.0.0">
+  <Contents>
+    <Layer>
+      <ows:Identifier>` + escape(layer) + `</ows:Identifier>
+      <Format>image/png</Format>
+      <TileMatrixSetLink><TileMatrixSet>` + escape(matrixSet) + `</TileMatrixSet></TileMatrixSetLink>
+      <ResourceURL format="image/png" resourceType="tile" template="` + TileTemplate + `"/>
+    </Layer>
+    <TileMatrixSet>
+      <ows:Identifier>` + escape(matrixSet) + `</ows:Identifier>
+      <ows:SupportedCRS>urn:ogc:def:crs:EPSG::3857</ows:SupportedCRS>
+`)
+	for z := 0; z <= MaxZoom; z++ {
+		// Scale denominators assume 0.28mm pixels
+		fmt.Fprintf(&b, `      <TileMatrix>
+        <ows:Identifier>%d</ows:Identifier>
+        <ScaleDenominator>%f</ScaleDenominator>
+        <TopLeftCorner>-20037508.3427892 20037508.3427892</TopLeftCorner>
+        <TileWidth>256</TileWidth>
+        <TileHeight>256</TileHeight>
+        <MatrixWidth>%d</MatrixWidth>
+        <MatrixHeight>%d</MatrixHeight>
+      </TileMatrix>
+`, z, Resolution(z)/0.00028, 1<<z, 1<<z)
+	}
+	b.WriteString(`    </TileMatrixSet>
+  </Contents>
+</Capabilities>
+`)
+	return b.String()
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Handler serves Capabilities for the layer and matrix_set query parameters
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		layer := r.URL.Query().Get("layer")
+		matrixSet := r.URL.Query().Get("matrix_set")
+		if layer == "" || matrixSet == "" {
+			http.Error(w, "layer and matrix_set are required", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(Capabilities(layer, matrixSet)))
+	})
+}
+
+// NewServer starts serving Handler on a loopback port. The caller should
+// call Close when finished.
+func NewServer() *httptest.Server {
+	return httptest.NewServer(Handler())
+}
+
+// URL is where a server at base serves capabilities for layer and matrixSet
+func URL(base string, layer string, matrixSet string) string {
+	q := url.Values{"layer": {layer}, "matrix_set": {matrixSet}}
+	return strings.TrimSuffix(base, "/") + "/?" + q.Encode()
+}
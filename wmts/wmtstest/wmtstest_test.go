@@ -0,0 +1,72 @@
+package wmtstest
+
+import (
+	"contourguessr-api/wmts"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	resp, err := http.Get(URL(srv.URL, "Outdoor_3857", "EPSG:3857"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := wmts.Parse(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, ok := c.Layer("Outdoor_3857")
+	if !ok {
+		t.Fatal("expected the requested layer")
+	}
+	if len(layer.TileMatrixSets) != 1 || layer.TileMatrixSets[0] != "EPSG:3857" {
+		t.Errorf("expected the layer to link to the requested matrix set, got %v", layer.TileMatrixSets)
+	}
+	set, ok := c.TileMatrixSet("EPSG:3857")
+	if !ok {
+		t.Fatal("expected the requested matrix set")
+	}
+	if len(set.TileMatrices) != MaxZoom+1 || set.TileMatrices[12].Identifier != "12" || set.TileMatrices[12].MatrixWidth != "4096" {
+		t.Errorf("expected a tile matrix per zoom level, got %+v", set.TileMatrices)
+	}
+}
+
+func TestServerRequiresIdentifiers(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/?layer=Outdoor_3857")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestCapabilitiesEscapesIdentifiers(t *testing.T) {
+	c, err := wmts.Parse(Capabilities("a<b", "c&d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Layer("a<b"); !ok {
+		t.Error("expected escaped layer identifier to round trip")
+	}
+	if _, ok := c.TileMatrixSet("c&d"); !ok {
+		t.Error("expected escaped matrix set identifier to round trip")
+	}
+}